@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// concurrentManager sets the maximum number of concurrent reconciles of the controllers added to it.
+// The openshift/cluster-api machine controller does not expose its controller options, so the option
+// is set on the controller when it is added to the manager, before the manager starts its workers.
+type concurrentManager struct {
+	manager.Manager
+	maxConcurrentReconciles int
+}
+
+// Add implements manager.Manager.
+func (m *concurrentManager) Add(r manager.Runnable) error {
+	if c, ok := r.(controller.Controller); ok && m.maxConcurrentReconciles > 0 {
+		setMaxConcurrentReconciles(c, m.maxConcurrentReconciles)
+	}
+	return m.Manager.Add(r)
+}
+
+func setMaxConcurrentReconciles(c controller.Controller, maxConcurrentReconciles int) {
+	value := reflect.ValueOf(c)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct {
+		field := value.FieldByName("MaxConcurrentReconciles")
+		if field.IsValid() && field.CanSet() && field.Kind() == reflect.Int {
+			field.SetInt(int64(maxConcurrentReconciles))
+			return
+		}
+	}
+	klog.Warningf("Unable to set the maximum number of concurrent reconciles of controller %T", c)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fakeManager provides controller.New with what it needs to create a controller, without API server.
+type fakeManager struct {
+	manager.Manager
+	added []manager.Runnable
+}
+
+func (m *fakeManager) Add(r manager.Runnable) error {
+	m.added = append(m.added, r)
+	return nil
+}
+
+func (m *fakeManager) SetFields(interface{}) error                  { return nil }
+func (m *fakeManager) GetCache() cache.Cache                        { return nil }
+func (m *fakeManager) GetConfig() *rest.Config                      { return &rest.Config{} }
+func (m *fakeManager) GetScheme() *runtime.Scheme                   { return runtime.NewScheme() }
+func (m *fakeManager) GetClient() client.Client                     { return nil }
+func (m *fakeManager) GetRecorder(name string) record.EventRecorder { return record.NewFakeRecorder(1) }
+
+// TestConcurrentManager checks the maximum number of concurrent reconciles is set on the controllers of the
+// vendored controller-runtime, since it is set through reflection.
+func TestConcurrentManager(t *testing.T) {
+	mgr := &fakeManager{}
+	c, err := controller.New("machine-controller", &concurrentManager{Manager: mgr, maxConcurrentReconciles: 5}, controller.Options{
+		Reconciler: reconcile.Func(func(reconcile.Request) (reconcile.Result, error) { return reconcile.Result{}, nil }),
+	})
+	if err != nil {
+		t.Fatalf("failed to create controller: %v", err)
+	}
+	if len(mgr.added) != 1 {
+		t.Fatalf("expected the controller to be added to the manager, got %d runnables", len(mgr.added))
+	}
+	field := reflect.ValueOf(c).Elem().FieldByName("MaxConcurrentReconciles")
+	if !field.IsValid() {
+		t.Fatalf("controller %T has no MaxConcurrentReconciles field", c)
+	}
+	if field.Int() != 5 {
+		t.Errorf("expected 5 concurrent reconciles, got %d", field.Int())
+	}
+}
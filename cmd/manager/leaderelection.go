@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+)
+
+const inClusterNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// leaderElectionConfig configures the leader election of the controller replicas.
+// The manager of the vendored controller-runtime hardcodes the lease durations, so leader election
+// is run here and the manager is only started once the lease is acquired.
+type leaderElectionConfig struct {
+	// namespace of the configmap holding the lock. Defaults to the namespace the controller runs in.
+	namespace string
+	// id is the name of the configmap holding the lock.
+	id            string
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+// runWithLeaderElection calls run once the leader lease is acquired and returns when run returns,
+// the stop channel is closed or the lease is lost.
+func runWithLeaderElection(cfg *rest.Config, recorder record.EventRecorder, config leaderElectionConfig, stop <-chan struct{}, run func(stop <-chan struct{}) error) error {
+	namespace := config.namespace
+	if namespace == "" {
+		data, err := ioutil.ReadFile(inClusterNamespacePath)
+		if err != nil {
+			return fmt.Errorf("unable to find the leader election namespace, set --leader-elect-namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	identity := hostname + "_" + string(uuid.NewUUID())
+
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	lock, err := resourcelock.New(resourcelock.ConfigMapsResourceLock, namespace, config.id, client.CoreV1(), resourcelock.ResourceLockConfig{
+		Identity:      identity,
+		EventRecorder: recorder,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	errCh := make(chan error, 2)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: config.leaseDuration,
+		RenewDeadline: config.renewDeadline,
+		RetryPeriod:   config.retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("Acquired leader lease %s/%s as %s", namespace, config.id, identity)
+				errCh <- run(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-stop:
+					// Losing the lease is expected when stopping.
+					return
+				default:
+				}
+				// Stop driving GCP operations as soon as another replica may have taken over.
+				errCh <- fmt.Errorf("leader election lost")
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("Waiting for leader lease %s/%s", namespace, config.id)
+	go elector.Run(ctx)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-stop:
+		return nil
+	}
+}
@@ -2,23 +2,96 @@ package main
 
 import (
 	"flag"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/openshift/cluster-api-provider-gcp/pkg/apis"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/cluster"
 	"github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/machine"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/controller/machineset"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/features"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/health"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/tracing"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/version"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/webhooks"
 	clusterapis "github.com/openshift/cluster-api/pkg/apis"
 	"github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset"
+	capicluster "github.com/openshift/cluster-api/pkg/controller/cluster"
 	capimachine "github.com/openshift/cluster-api/pkg/controller/machine"
+	"go.opencensus.io/trace"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+// shutdownGracePeriod is how long in-flight machine operations are given to record their state on shutdown.
+const shutdownGracePeriod = 10 * time.Second
+
 func main() {
+	webhookPort := flag.Int("webhook-port", 0, "Port the admission webhook server listens on. The webhook server is disabled when set to 0.")
+	webhookCertDir := flag.String("webhook-cert-dir", "/etc/machine-api-provider-gcp/webhook-certs", "Directory containing the webhook serving certificate (tls.crt) and key (tls.key).")
+	leaderElect := flag.Bool("leader-elect", false, "Run leader election so that only one of multiple replicas reconciles machines at a time.")
+	leaderElectNamespace := flag.String("leader-elect-namespace", "", "Namespace of the leader election configmap. Defaults to the namespace the controller runs in.")
+	leaderElectID := flag.String("leader-elect-id", "cluster-api-provider-gcp-leader", "Name of the leader election configmap.")
+	leaderElectLeaseDuration := flag.Duration("leader-elect-lease-duration", 15*time.Second, "Duration non-leader replicas wait before taking over the lease of a leader that stopped renewing it.")
+	leaderElectRenewDeadline := flag.Duration("leader-elect-renew-deadline", 10*time.Second, "Duration the leader retries renewing its lease before giving it up. Must be less than --leader-elect-lease-duration.")
+	leaderElectRetryPeriod := flag.Duration("leader-elect-retry-period", 2*time.Second, "Duration replicas wait between attempts to acquire or renew the lease.")
+	healthProbePort := flag.Int("health-probe-port", 9440, "Port serving the /healthz and /readyz probes. /readyz checks the GCP API is reachable with the credentials used by machines. The probe server is disabled when set to 0.")
+	apiQPS := flag.Float64("gcp-api-qps", 10, "Sustained rate of GCP API requests per second allowed across all machines. Rate limiting is disabled when set to 0.")
+	apiBurst := flag.Int("gcp-api-burst", 20, "Number of GCP API requests allowed in a burst above --gcp-api-qps.")
+	maxConcurrentReconciles := flag.Int("max-concurrent-reconciles", 1, "Maximum number of machines reconciled concurrently. Raise it along with --gcp-api-qps to scale up machine creation throughput.")
+	computeEndpoint := flag.String("gcp-compute-endpoint", os.Getenv("GCP_COMPUTE_ENDPOINT"), "Override of the compute API base path, e.g. https://restricted.googleapis.com/compute/v1/projects/. Defaults to the GCP_COMPUTE_ENDPOINT environment variable, or the public endpoint.")
+	httpProxy := flag.String("http-proxy", getEnv("HTTP_PROXY", "http_proxy"), "Proxy URL for plain HTTP requests to GCP. Defaults to the HTTP_PROXY environment variable.")
+	httpsProxy := flag.String("https-proxy", getEnv("HTTPS_PROXY", "https_proxy"), "Proxy URL for HTTPS requests to GCP. Defaults to the HTTPS_PROXY environment variable.")
+	noProxy := flag.String("no-proxy", getEnv("NO_PROXY", "no_proxy"), "Comma-separated list of hosts, domains and CIDRs reached without proxy. Defaults to the NO_PROXY environment variable.")
+	userAgent := flag.String("gcp-user-agent", version.UserAgent(), "User-Agent identifying the provider in GCP API requests. The cluster ID of the machine is appended to it.")
+	capacityAnnotations := flag.Bool("machineset-capacity-annotations", true, "Annotate machine sets with the vCPU, memory and GPU capacity of their machine type, so the cluster-autoscaler can scale them from zero.")
+	quotaChecks := flag.Bool("machineset-quota-checks", true, "Annotate machine sets whose scale-up exceeds the regional GCP quotas with gcpprovider.openshift.io/quota-exceeded and record a QuotaExceeded event on them.")
+	preemptibleNodeLabels := flag.String("preemptible-node-labels", "machine.openshift.io/interruptible-instance=", "Comma-separated list of key=value labels added to the nodes of preemptible machines.")
+	preemptibleNodeTaints := flag.String("preemptible-node-taints", "", "Comma-separated list of key=value:Effect taints added to the nodes of preemptible machines.")
+	auditLog := flag.Bool("audit-log", false, "Log the mutating GCP API requests of machines, with the machine, the service account they are sent as and their outcome, to the gcp-audit logger.")
+	clusterOwnedLabel := flag.Bool("cluster-owned-label", true, "Label the instances and disks of machines with the kubernetes-io-cluster-<cluster ID>: owned label, and refuse to delete instances and disks labelled as owned by another cluster.")
+	orphanDetectionInterval := flag.Duration("orphan-detection-interval", 0, "Interval at which instances labelled as owned by the cluster without a machine are logged and counted in the gcp_machine_orphaned_instances metric. Requires --cluster-owned-label. Orphan detection is disabled when set to 0.")
+	permissionsCheckInterval := flag.Duration("permissions-check-interval", time.Hour, "Interval at which the IAM permissions of the credentials of machines are checked, on start and then periodically. Missing permissions are reported with the MissingPermissions condition of the machines and an event. The check is disabled when set to 0.")
+	instanceCacheInterval := flag.Duration("instance-cache-interval", 0, "Cache the instances of each project, listed at most once per interval, instead of getting the instance of every machine on each resync. Stale instances are refreshed after the controller changes them. The cache is disabled when set to 0.")
+	clusterInfrastructure := flag.Bool("cluster-infrastructure", false, "Run the cluster controller, which creates and maintains the network, subnetworks, Cloud NAT router and base firewall rules of clusters with a GCP cluster provider spec. Requires the Cluster CRD to be installed.")
+	preflightChecks := flag.Bool("preflight-checks", false, "Verify the GCP resources referenced by a machine exist before creating its instance.")
+
+	featureGates := features.NewFeatureGate()
+	flag.Var(featureGates, "feature-gates", "Comma-separated list of key=value pairs enabling experimental features. Options are:\n"+strings.Join(features.KnownFeatures(), "\n"))
+
+	otlpEndpoint := flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP endpoint of an OpenTelemetry collector receiving traces of the machine operations and GCP API calls, e.g. http://otel-collector:4318. Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable. Tracing is disabled when empty.")
+	traceSampleRatio := flag.Float64("trace-sample-ratio", 1, "Fraction of machine operations traced when --otlp-endpoint is set.")
+	logDevelopment := flag.Bool("log-development", false, "Log the structured messages of the controller in a human readable format instead of JSON.")
+
 	klog.InitFlags(nil)
 	flag.Set("logtostderr", "true")
 	flag.Parse()
 
+	logf.SetLogger(logf.ZapLogger(*logDevelopment))
+
+	klog.Infof("Version: %s", version.Raw)
+	if gates := featureGates.String(); gates != "" {
+		klog.Infof("Feature gates: %s", gates)
+	}
+
+	var traceExporter *tracing.Exporter
+	if *otlpEndpoint != "" {
+		traceExporter = tracing.NewExporter(*otlpEndpoint, "cluster-api-provider-gcp")
+		tracing.Register(traceExporter, *traceSampleRatio)
+	} else {
+		trace.ApplyConfig(trace.Config{DefaultSampler: trace.NeverSample()})
+	}
+
+	preemptibleNode, err := preemptibleNodeConfig(*preemptibleNodeLabels, *preemptibleNodeTaints)
+	if err != nil {
+		klog.Fatalf("Invalid preemptible node labels or taints: %v", err)
+	}
+
 	cfg := config.GetConfigOrDie()
 
 	// Setup a Manager
@@ -32,10 +105,30 @@ func main() {
 		klog.Fatalf("Failed to create client from configuration: %v", err)
 	}
 
+	stop := signals.SetupSignalHandler()
+
 	// Initialize machine actuator.
 	machineActuator := machine.NewActuator(machine.ActuatorParams{
 		MachineClient: cs.MachineV1beta1(),
 		CoreClient:    mgr.GetClient(),
+
+		PreflightChecksEnabled: *preflightChecks,
+		APIRateLimitQPS:        *apiQPS,
+		APIRateLimitBurst:      *apiBurst,
+		ComputeEndpoint:        *computeEndpoint,
+		UserAgent:              *userAgent,
+		EventRecorder:          mgr.GetRecorder("gcpcontroller"),
+		FeatureGates:           featureGates,
+		StopCh:                 stop,
+		PreemptibleNode:        preemptibleNode,
+		AuditLog:               *auditLog,
+		InstanceCacheInterval:  *instanceCacheInterval,
+		ClusterOwnedLabel:      *clusterOwnedLabel,
+		Proxy: machine.ProxyConfig{
+			HTTPProxy:  *httpProxy,
+			HTTPSProxy: *httpsProxy,
+			NoProxy:    *noProxy,
+		},
 	})
 
 	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
@@ -46,9 +139,102 @@ func main() {
 		klog.Fatal(err)
 	}
 
-	capimachine.AddWithActuator(mgr, machineActuator)
+	capimachine.AddWithActuator(&concurrentManager{Manager: mgr, maxConcurrentReconciles: *maxConcurrentReconciles}, machineActuator)
 
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	if *clusterOwnedLabel && *orphanDetectionInterval > 0 {
+		if err := mgr.Add(&machine.OrphanDetector{Actuator: machineActuator, Interval: *orphanDetectionInterval}); err != nil {
+			klog.Fatalf("Failed to add the orphan detector: %v", err)
+		}
+	}
+
+	if *permissionsCheckInterval > 0 {
+		if err := mgr.Add(&machine.PermissionsChecker{Actuator: machineActuator, Interval: *permissionsCheckInterval}); err != nil {
+			klog.Fatalf("Failed to add the permissions checker: %v", err)
+		}
+	}
+
+	if *clusterInfrastructure {
+		clusterActuator := cluster.NewActuator(cluster.ActuatorParams{
+			CoreClient:            mgr.GetClient(),
+			EventRecorder:         mgr.GetRecorder("gcpcontroller-cluster"),
+			ComputeServiceBuilder: machineActuator.NewComputeService,
+		})
+		if err := capicluster.AddWithActuator(mgr, clusterActuator); err != nil {
+			klog.Fatalf("Failed to add the cluster controller: %v", err)
+		}
+	}
+
+	if *capacityAnnotations {
+		if err := machineset.Add(mgr, machineActuator); err != nil {
+			klog.Fatalf("Failed to add the machine set capacity controller: %v", err)
+		}
+	}
+
+	if *quotaChecks {
+		if err := machineset.AddQuotaController(mgr, machineActuator); err != nil {
+			klog.Fatalf("Failed to add the machine set quota controller: %v", err)
+		}
+	}
+
+	// The probe and webhook servers and the trace exporter run on every replica, only the controllers wait for the leader lease.
+	var servers []manager.Runnable
+	if *webhookPort != 0 {
+		servers = append(servers, &webhooks.Server{
+			Port:    *webhookPort,
+			CertDir: *webhookCertDir,
+			Webhooks: []*admission.Webhook{
+				webhooks.NewDefaultingWebhook(),
+				webhooks.NewValidatingWebhook(),
+			},
+		})
+	}
+	if *healthProbePort != 0 {
+		servers = append(servers, &health.Server{
+			Port: *healthProbePort,
+			ReadinessChecks: map[string]health.Check{
+				"gcp": machineActuator.CheckGCPConnectivity,
+			},
+		})
+	}
+
+	if traceExporter != nil {
+		servers = append(servers, traceExporter)
+	}
+
+	for _, server := range servers {
+		go func(server manager.Runnable) {
+			if err := server.Start(stop); err != nil {
+				klog.Fatal(err)
+			}
+		}(server)
+	}
+
+	if !*leaderElect {
+		err = mgr.Start(stop)
+	} else {
+		err = runWithLeaderElection(cfg, mgr.GetRecorder("gcpcontroller-leader-election"), leaderElectionConfig{
+			namespace:     *leaderElectNamespace,
+			id:            *leaderElectID,
+			leaseDuration: *leaderElectLeaseDuration,
+			renewDeadline: *leaderElectRenewDeadline,
+			retryPeriod:   *leaderElectRetryPeriod,
+		}, stop, mgr.Start)
+	}
+	if err != nil {
 		klog.Fatalf("Failed to run manager: %v", err)
 	}
+
+	// The manager does not wait for the reconciles in progress, let them record their pending
+	// GCP operation in the machine provider status so the next leader resumes waiting for it.
+	machineActuator.WaitForOperations(shutdownGracePeriod)
+}
+
+// getEnv returns the value of the first set environment variable among keys.
+func getEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/machine"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// preemptibleNodeConfig parses the comma-separated key=value labels and key=value:Effect taints
+// added to the nodes of preemptible machines.
+func preemptibleNodeConfig(labels, taints string) (machine.PreemptibleNodeConfig, error) {
+	config := machine.PreemptibleNodeConfig{}
+	for _, label := range splitList(labels) {
+		parts := strings.SplitN(label, "=", 2)
+		if parts[0] == "" {
+			return config, fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+		if config.Labels == nil {
+			config.Labels = map[string]string{}
+		}
+		if len(parts) == 2 {
+			config.Labels[parts[0]] = parts[1]
+		} else {
+			config.Labels[parts[0]] = ""
+		}
+	}
+	for _, taint := range splitList(taints) {
+		i := strings.LastIndex(taint, ":")
+		if i < 0 {
+			return config, fmt.Errorf("invalid taint %q, expected key=value:Effect", taint)
+		}
+		effect := corev1.TaintEffect(taint[i+1:])
+		switch effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return config, fmt.Errorf("invalid taint %q, effect must be one of %s, %s or %s", taint,
+				corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute)
+		}
+		parts := strings.SplitN(taint[:i], "=", 2)
+		if parts[0] == "" {
+			return config, fmt.Errorf("invalid taint %q, expected key=value:Effect", taint)
+		}
+		t := corev1.Taint{Key: parts[0], Effect: effect}
+		if len(parts) == 2 {
+			t.Value = parts[1]
+		}
+		config.Taints = append(config.Taints, t)
+	}
+	return config, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/termination"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/version"
+	"github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
+)
+
+// The termination handler runs as a DaemonSet on preemptible nodes and deletes the machine of its
+// node when GCP announces the preemption of the instance.
+func main() {
+	nodeName := flag.String("node-name", os.Getenv("NODE_NAME"), "Name of the node the handler runs on. Defaults to the NODE_NAME environment variable.")
+
+	klog.InitFlags(nil)
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	klog.Infof("Version: %s", version.Raw)
+	if *nodeName == "" {
+		klog.Fatal("--node-name or the NODE_NAME environment variable is required")
+	}
+
+	cfg := config.GetConfigOrDie()
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Failed to create client from configuration: %v", err)
+	}
+	machineClient, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Failed to create client from configuration: %v", err)
+	}
+
+	handler := &termination.Handler{
+		NodeName:      *nodeName,
+		Metadata:      metadata.NewClient(nil),
+		NodeClient:    kubeClient.CoreV1(),
+		MachineClient: machineClient.MachineV1beta1(),
+	}
+	if err := handler.Run(signals.SetupSignalHandler()); err != nil {
+		klog.Fatalf("Failed to run termination handler: %v", err)
+	}
+}
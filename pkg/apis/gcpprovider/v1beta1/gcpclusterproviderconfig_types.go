@@ -0,0 +1,64 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GCPClusterProviderSpec is the type that will be embedded in a Cluster.Spec.ProviderSpec field.
+// It describes the cluster-scoped GCP infrastructure the GCP cluster actuator creates and maintains:
+// the network of the cluster, its subnetworks, a router with Cloud NAT and the base firewall rules.
+// +k8s:openapi-gen=true
+type GCPClusterProviderSpec struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// ProjectID is the project of the cluster infrastructure. Defaults to the project of the credentials.
+	ProjectID string `json:"projectID,omitempty"`
+
+	// Region is the region of the subnetworks and the router.
+	Region string `json:"region"`
+
+	// CredentialsSecret is a reference to the secret with GCP credentials, in the namespace of the cluster.
+	// Defaults to the Application Default Credentials of the controller.
+	CredentialsSecret *corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
+
+	// Network is the name of the VPC network of the cluster. It is created in custom subnet mode
+	// when it does not exist.
+	Network string `json:"network"`
+
+	// Subnetworks are the subnetworks of the cluster in the network, created when they do not exist.
+	Subnetworks []GCPSubnetworkSpec `json:"subnetworks,omitempty"`
+
+	// CloudNAT creates a router with a Cloud NAT gateway for all the subnetworks of the network, so
+	// instances without external IP addresses can reach the internet.
+	CloudNAT bool `json:"cloudNAT,omitempty"`
+
+	// FirewallRules are firewall rules applying to every instance of the network, in addition to the base
+	// rule allowing the traffic between the subnetworks of the cluster.
+	FirewallRules []*GCPFirewallRule `json:"firewallRules,omitempty"`
+
+	// ProjectMetadata is project-wide metadata managed for the cluster, which applies to every instance of the
+	// project, e.g. disable-legacy-endpoints, enable-oslogin or block-project-ssh-keys set to TRUE. Keys removed
+	// from the provider spec are removed from the project, other project metadata is left untouched.
+	ProjectMetadata map[string]string `json:"projectMetadata,omitempty"`
+}
+
+// GCPSubnetworkSpec describes a subnetwork of the cluster network.
+type GCPSubnetworkSpec struct {
+	// Name of the subnetwork.
+	Name string `json:"name"`
+	// CIDRBlock is the primary IPv4 range of the subnetwork, e.g. 10.0.0.0/17.
+	CIDRBlock string `json:"cidrBlock"`
+	// PrivateGoogleAccess lets instances without external IP addresses reach Google APIs.
+	PrivateGoogleAccess bool `json:"privateGoogleAccess,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+func init() {
+	SchemeBuilder.Register(&GCPClusterProviderSpec{})
+}
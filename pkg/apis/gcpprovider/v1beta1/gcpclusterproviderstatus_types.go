@@ -0,0 +1,38 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GCPClusterProviderStatus is the type that will be embedded in a Cluster.Status.ProviderStatus field.
+// It contains the GCP infrastructure of the cluster.
+// +k8s:openapi-gen=true
+type GCPClusterProviderStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Network is the self link of the network of the cluster.
+	Network string `json:"network,omitempty"`
+
+	// Subnetworks are the self links of the subnetworks of the cluster.
+	Subnetworks []string `json:"subnetworks,omitempty"`
+
+	// Router is the self link of the router of the Cloud NAT gateway, if any.
+	Router string `json:"router,omitempty"`
+
+	// ProjectMetadataKeys are the keys of the project metadata set by the controller. They are removed from the
+	// project with the cluster.
+	ProjectMetadataKeys []string `json:"projectMetadataKeys,omitempty"`
+
+	// Ready is true once all the infrastructure of the provider spec exists.
+	Ready bool `json:"ready"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+func init() {
+	SchemeBuilder.Register(&GCPClusterProviderStatus{})
+}
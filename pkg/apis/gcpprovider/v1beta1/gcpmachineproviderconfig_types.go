@@ -1,6 +1,10 @@
 package v1beta1
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -19,9 +23,52 @@ type GCPMachineProviderSpec struct {
 	// UserData to apply to the instance
 	UserDataSecret *corev1.LocalObjectReference `json:"userDataSecret,omitempty"`
 
+	// UserDataSecretKey is the key of the user data in the user data secret. Defaults to userData.
+	UserDataSecretKey string `json:"userDataSecretKey,omitempty"`
+
+	// UserDataFormat is the format of the user data, which selects the instance metadata key it is written to.
+	// Defaults to Ignition, or WindowsStartupScript for Windows machines.
+	UserDataFormat GCPUserDataFormat `json:"userDataFormat,omitempty"`
+
+	// OSFamily is the operating system family of the boot image, which selects the user data formats
+	// the instance supports. Defaults to Linux.
+	OSFamily GCPOSFamily `json:"osFamily,omitempty"`
+
+	// RawUserData writes the user data to the instance metadata verbatim rather than base64 encoded,
+	// for boot images expecting plain cloud-init configs. Startup scripts are always written verbatim.
+	RawUserData bool `json:"rawUserData,omitempty"`
+
+	// IgnitionConfigSource is the URL the full Ignition config is fetched from, e.g. the machine config server
+	// or a signed GCS URL, when the user data exceeds the size limit of instance metadata values.
+	// The instance is then given a small Ignition config pointing to it instead.
+	IgnitionConfigSource string `json:"ignitionConfigSource,omitempty"`
+
 	// CredentialsSecret is a reference to the secret with GCP credentials.
+	// When not set, the Application Default Credentials of the controller are used, e.g. the
+	// service account attached to the instance it runs on or workload identity.
 	CredentialsSecret *corev1.LocalObjectReference `json:"credentialsSecret,omitempty"`
 
+	// ImpersonateServiceAccount is the email of a service account impersonated to make the GCP API calls,
+	// e.g. to manage instances in another project under delegation. The credentials must be granted
+	// roles/iam.serviceAccountTokenCreator on it. The instance is created in the project of the impersonated
+	// service account.
+	ImpersonateServiceAccount string `json:"impersonateServiceAccount,omitempty"`
+
+	// ProjectID is the GCP project the instance is created in. It defaults to the project of
+	// the credentials, so a single management cluster can manage machines across projects.
+	ProjectID string `json:"projectID,omitempty"`
+
+	// NetworkProjectID is the GCP project of the networks and subnetworks of the network interfaces, e.g. the
+	// host project of a Shared VPC when the instance is created in a service project. The firewall rules of the
+	// machine are managed in it too. It defaults to the project of the instance.
+	NetworkProjectID string `json:"networkProjectID,omitempty"`
+
+	// InstanceNamePrefix names the instance after the prefix followed by a hash of the machine namespace and
+	// name, e.g. to follow the naming conventions of the project, instead of after the machine. It is at most
+	// 54 characters so the instance name fits the 63 characters GCE allows. The name of the created instance
+	// is recorded in the provider status.
+	InstanceNamePrefix string `json:"instanceNamePrefix,omitempty"`
+
 	CanIPForward       bool                   `json:"canIPForward"`
 	DeletionProtection bool                   `json:"deletionProtection"`
 	Disks              []*GCPDisk             `json:"disks,omitempty"`
@@ -32,7 +79,136 @@ type GCPMachineProviderSpec struct {
 	Tags               []string               `json:"tags,omitempty"`
 	MachineType        string                 `json:"machineType"`
 	Region             string                 `json:"region"`
-	Zone               string                 `json:"zone"`
+
+	// Zone is the zone of the instance. When empty, the controller selects a zone of the region for each
+	// machine, preferring zones without recent stockouts, and records it in the provider status.
+	Zone string `json:"zone"`
+
+	// ResourceManagerTags are the Resource Manager tags bound to the instance when it is created, by tag key,
+	// e.g. tagKeys/123456: tagValues/654321. Unlike the network Tags, they can be used in IAM conditions and
+	// for cost allocation. The credentials need permission to use the tag values.
+	ResourceManagerTags map[string]string `json:"resourceManagerTags,omitempty"`
+
+	// SSHKeys are written to the ssh-keys metadata of the instance, e.g. for break-glass access.
+	// Each entry is in the USERNAME:KEY format of the ssh-keys metadata, e.g. "core:ssh-ed25519 AAAA... admin".
+	SSHKeys []string `json:"sshKeys,omitempty"`
+
+	// SSHKeySecret is a reference to a secret in the namespace of the machine whose sshKeys key holds
+	// additional ssh-keys entries, one per line, so keys can be rotated without editing machine sets.
+	SSHKeySecret *corev1.LocalObjectReference `json:"sshKeySecret,omitempty"`
+
+	// BlockProjectSSHKeys sets the block-project-ssh-keys metadata of the instance, so only the SSH keys
+	// of the machine grant access to it rather than the project-wide ones as well.
+	BlockProjectSSHKeys bool `json:"blockProjectSSHKeys,omitempty"`
+
+	// OSConfig enables the OS Config agent of the instance, for VM Manager patch compliance reporting and OS
+	// inventory: the enable-osconfig and enable-guest-attributes metadata are set and the cloud-platform scope
+	// the agent needs is added to the service account of the instance. The scopes of existing instances are not
+	// updated, enable it before creating the machines. Requires a service account.
+	OSConfig bool `json:"osConfig,omitempty"`
+
+	// TargetPools are the names of the network load balancer target pools of the machine region the
+	// instance is registered in, e.g. for the API server load balancer of control plane machines.
+	// The instance is removed from them when the machine is deleted.
+	TargetPools []string `json:"targetPools,omitempty"`
+
+	// TargetInstanceGroups are the names of the unmanaged instance groups of the machine zone the
+	// instance is added to, e.g. backends of the internal API server load balancer of control plane
+	// machines. The instance is removed from them when the machine is deleted.
+	// Names may contain the InstanceGroupZonePlaceholder, which is replaced by the machine zone, e.g.
+	// "mycluster-master-{zone}". Instance groups missing in the machine zone are created.
+	TargetInstanceGroups []string `json:"targetInstanceGroups,omitempty"`
+
+	// FirewallRules are ensured to exist in the network of the primary network interface, allowing
+	// ingress traffic to the instances with the Tags of the machine, e.g. to the API server, etcd or
+	// node ports. Rules are updated when the tags change, so machines sharing a rule should share
+	// their tags, e.g. as in a machine set. Requires the FirewallRules feature gate.
+	FirewallRules []*GCPFirewallRule `json:"firewallRules,omitempty"`
+
+	// HealthCheck gates the Ready condition of the machine provider status on the health of the
+	// instance as seen by GCP, e.g. through a TCP health check of the kubelet port.
+	HealthCheck *GCPHealthCheck `json:"healthCheck,omitempty"`
+
+	// GPUs are the accelerators attached to the instance. Instances with GPUs cannot live migrate,
+	// they are terminated on host maintenance.
+	GPUs []GCPGPUConfig `json:"gpus,omitempty"`
+
+	// Preemptible runs the instance on preemptible capacity, which GCP may stop at any time. The nodes
+	// of preemptible machines get the preemptible labels and taints configured in the controller.
+	// Requires the SpotVMs feature gate.
+	Preemptible bool `json:"preemptible,omitempty"`
+
+	// AutoRepair deletes and recreates the instance when GCP terminated it, e.g. after a host error,
+	// keeping the machine rather than waiting for a machine health check to replace it.
+	// Instances stopped outside of the controller are recreated as well.
+	AutoRepair bool `json:"autoRepair,omitempty"`
+
+	// InstanceSchedulePolicy is the name of an instance schedule resource policy in the region of the machine,
+	// attached to the instance so GCP starts and stops it on schedule, e.g. to power down development machines
+	// outside business hours. Changing it attaches the new policy in place of the previous one. It cannot be
+	// combined with preemptible or auto-repaired machines, whose stopped instances are replaced.
+	InstanceSchedulePolicy string `json:"instanceSchedulePolicy,omitempty"`
+
+	// AdvancedMachineFeatures are the advanced machine features of the instance, e.g. its performance
+	// monitoring unit. They are set when the instance is created.
+	AdvancedMachineFeatures *GCPAdvancedMachineFeatures `json:"advancedMachineFeatures,omitempty"`
+
+	// ConfidentialInstanceType runs the instance as a Confidential VM encrypting its memory with the given
+	// technology: SEV or SEV_SNP on AMD machine types, TDX on Intel ones. Confidential VMs are terminated on
+	// host maintenance and need a boot image supporting the technology. It is set when the instance is created.
+	ConfidentialInstanceType GCPConfidentialInstanceType `json:"confidentialInstanceType,omitempty"`
+}
+
+// InstanceGroupZonePlaceholder is replaced by the machine zone in the names of TargetInstanceGroups.
+const InstanceGroupZonePlaceholder = "{zone}"
+
+const (
+	// ClusterOwnedLabelPrefix prefixes the cluster ID in the GCP label marking resources as owned by a cluster.
+	// GCP labels only allow lowercase letters, digits, '-' and '_', hence no '/' or '.'.
+	ClusterOwnedLabelPrefix = "kubernetes-io-cluster-"
+	// ClusterOwnedLabelValue is the value of the label marking resources as owned by a cluster.
+	ClusterOwnedLabelValue = "owned"
+
+	// labelKeyMaxLength is the maximum length of GCP label keys.
+	labelKeyMaxLength = 63
+	// labelKeyHashLength is the length of the cluster ID hash suffixing truncated cluster owned label keys.
+	labelKeyHashLength = 8
+)
+
+// ClusterOwnedLabel returns the key of the GCP label marking resources as owned by the cluster. Keys of long
+// cluster IDs are truncated to the 63 characters allowed by GCP and suffixed with a hash of the cluster ID, so
+// they stay distinct.
+func ClusterOwnedLabel(clusterID string) string {
+	key := ClusterOwnedLabelPrefix + clusterID
+	if len(key) <= labelKeyMaxLength {
+		return key
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(clusterID)))[:labelKeyHashLength]
+	return fmt.Sprintf("%s-%s", strings.TrimRight(key[:labelKeyMaxLength-labelKeyHashLength-1], "-"), hash)
+}
+
+// GCPArchitecture is the CPU architecture of a machine type or boot image, as in the architecture of GCP images.
+type GCPArchitecture string
+
+const (
+	// ArchitectureX86_64 is the architecture of the Intel and AMD machine types.
+	ArchitectureX86_64 GCPArchitecture = "X86_64"
+	// ArchitectureARM64 is the architecture of the Arm machine types, e.g. T2A and C4A.
+	ArchitectureARM64 GCPArchitecture = "ARM64"
+)
+
+// arm64MachineFamilies are the machine families with Arm CPUs.
+var arm64MachineFamilies = map[string]bool{
+	"c4a": true,
+	"t2a": true,
+}
+
+// MachineTypeArchitecture returns the CPU architecture of the machine type, e.g. ARM64 for t2a-standard-4.
+func MachineTypeArchitecture(machineType string) GCPArchitecture {
+	if arm64MachineFamilies[strings.SplitN(machineType, "-", 2)[0]] {
+		return ArchitectureARM64
+	}
+	return ArchitectureX86_64
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -49,18 +225,165 @@ type GCPDisk struct {
 	Type       string            `json:"type"`
 	Image      string            `json:"image"`
 	Labels     map[string]string `json:"labels"`
+	// MultiWriter creates the disk in multi-writer mode, so it can be attached read-write to several instances
+	// at once, e.g. for clustered filesystems. Only pd-ssd, hyperdisk-balanced and
+	// hyperdisk-balanced-high-availability data disks support it.
+	MultiWriter bool `json:"multiWriter,omitempty"`
+
+	// Source is the name of an existing disk in the machine zone attached to the instance instead of a disk
+	// initialized from an image, e.g. a boot disk restored from a backup or a pre-provisioned data disk. Its
+	// size and type are the ones of the existing disk. An existing boot disk is deleted with the instance only
+	// when AutoDelete is set; existing data disks are detached, never deleted, when the machine is deleted.
+	// Existing data disks added to or removed from the provider spec of a machine are attached to or detached
+	// from its instance. Requires the zone of the provider spec.
+	Source string `json:"source,omitempty"`
+	// ImageFromStream resolves the image of the boot disk from the CoreOS stream metadata of the cluster, the
+	// coreos-bootimages config map of the openshift-machine-config-operator namespace, when the instance is
+	// created, so machine sets boot the RHCOS image of the current release once the cluster is upgraded.
+	// Exclusive with Image.
+	ImageFromStream bool `json:"imageFromStream,omitempty"`
+	// Mode is the mode existing disks are attached in, READ_WRITE or READ_ONLY. Defaults to READ_WRITE.
+	// Boot disks are attached read-write.
+	Mode GCPDiskMode `json:"mode,omitempty"`
 }
 
+// GCPDiskMode is the mode a disk is attached to an instance in.
+type GCPDiskMode string
+
+const (
+	// DiskModeReadWrite attaches the disk read-write, to a single instance unless it is a multi-writer disk.
+	DiskModeReadWrite GCPDiskMode = "READ_WRITE"
+	// DiskModeReadOnly attaches the disk read-only, possibly to several instances at once.
+	DiskModeReadOnly GCPDiskMode = "READ_ONLY"
+)
+
+// LocalSSDDiskType is the disk type of local SSDs, 375 GB scratch disks physically attached to the host of the
+// instance and deleted with it. They cannot be booted from, and the number of local SSDs of an instance depends
+// on its machine type.
+const LocalSSDDiskType = "local-ssd"
+
+// LocalSSDSizeGb is the size of a local SSD.
+const LocalSSDSizeGb = 375
+
+// GCPUserDataFormat is the format of the user data of an instance.
+type GCPUserDataFormat string
+
+const (
+	// UserDataFormatIgnition is an Ignition config, written to the user-data metadata key.
+	UserDataFormatIgnition GCPUserDataFormat = "Ignition"
+	// UserDataFormatCloudInit is a cloud-init config, written to the user-data metadata key.
+	UserDataFormatCloudInit GCPUserDataFormat = "CloudInit"
+	// UserDataFormatStartupScript is a script run by the guest agent, written to the startup-script metadata key.
+	UserDataFormatStartupScript GCPUserDataFormat = "StartupScript"
+	// UserDataFormatWindowsStartupScript is a PowerShell script run by the Windows guest agent on every boot,
+	// written to the windows-startup-script-ps1 metadata key.
+	UserDataFormatWindowsStartupScript GCPUserDataFormat = "WindowsStartupScript"
+	// UserDataFormatSysprepSpecializeScript is a PowerShell script run once during the sysprep specialize
+	// phase of the first boot of Windows instances, written to the sysprep-specialize-script-ps1 metadata key.
+	UserDataFormatSysprepSpecializeScript GCPUserDataFormat = "SysprepSpecializeScript"
+)
+
+// GCPOSFamily is the operating system family of an instance.
+type GCPOSFamily string
+
+const (
+	// OSFamilyLinux is the family of Linux boot images, e.g. RHCOS.
+	OSFamilyLinux GCPOSFamily = "Linux"
+	// OSFamilyWindows is the family of Windows Server boot images.
+	OSFamilyWindows GCPOSFamily = "Windows"
+)
+
 // GCPMetadata describes metadata for GCP.
 type GCPMetadata struct {
 	Key   string  `json:"key"`
-	Value *string `json:"value"`
+	Value *string `json:"value,omitempty"`
+	// ValueFrom reads the value from a key of a secret or config map in the namespace of the machine,
+	// e.g. for bootstrap tokens which should not be inlined in machine sets. Exclusive with Value.
+	ValueFrom *GCPMetadataValueSource `json:"valueFrom,omitempty"`
+}
+
+// GCPMetadataValueSource is the source of a metadata value. Exactly one of its fields must be set.
+type GCPMetadataValueSource struct {
+	SecretKeyRef    *corev1.SecretKeySelector    `json:"secretKeyRef,omitempty"`
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
 }
 
 // GCPNetworkInterface describes network interfaces for GCP
 type GCPNetworkInterface struct {
 	Network    string `json:"network,omitempty"`
 	Subnetwork string `json:"subnetwork,omitempty"`
+	// PublicIP assigns an ephemeral external IP to the interface. Defaults to true. Instances without
+	// external IPs need a Cloud NAT or Private Google Access in their region to pull images.
+	PublicIP *bool `json:"publicIP,omitempty"`
+	// StaticIP reserves the internal IP, and the external IP of interfaces with a public IP, as static
+	// addresses named after the instance. The addresses are released when the machine is deleted.
+	StaticIP bool `json:"staticIP,omitempty"`
+}
+
+// GCPFirewallRule describes a firewall rule allowing ingress traffic to the tags of the machine.
+type GCPFirewallRule struct {
+	// Name of the firewall rule.
+	Name string `json:"name"`
+	// Protocol of the allowed traffic, e.g. tcp, udp or icmp. Defaults to tcp.
+	Protocol string `json:"protocol,omitempty"`
+	// Ports are the allowed ports or port ranges, e.g. 6443 or 30000-32767. Every port is allowed when empty.
+	Ports []string `json:"ports,omitempty"`
+	// SourceRanges are the CIDR ranges the traffic is allowed from.
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+	// SourceTags are the tags of the instances the traffic is allowed from.
+	SourceTags []string `json:"sourceTags,omitempty"`
+}
+
+// GCPGPUConfig describes accelerators attached to the instance.
+type GCPGPUConfig struct {
+	// Count is the number of accelerators.
+	Count int64 `json:"count"`
+	// Type is the accelerator type offered in the machine zone, e.g. nvidia-tesla-t4.
+	Type string `json:"type"`
+}
+
+// GCPPerformanceMonitoringUnit is the performance monitoring unit (PMU) exposed to the guest of an instance.
+type GCPPerformanceMonitoringUnit string
+
+const (
+	// PerformanceMonitoringUnitArchitectural exposes the architectural PMU events, e.g. CPU cycles and
+	// instructions retired.
+	PerformanceMonitoringUnitArchitectural GCPPerformanceMonitoringUnit = "ARCHITECTURAL"
+	// PerformanceMonitoringUnitStandard exposes the architectural and the core PMU events, e.g. cache misses.
+	PerformanceMonitoringUnitStandard GCPPerformanceMonitoringUnit = "STANDARD"
+	// PerformanceMonitoringUnitEnhanced exposes the standard and the uncore PMU events, e.g. memory bandwidth.
+	PerformanceMonitoringUnitEnhanced GCPPerformanceMonitoringUnit = "ENHANCED"
+)
+
+// GCPAdvancedMachineFeatures describes the advanced machine features of an instance.
+type GCPAdvancedMachineFeatures struct {
+	// PerformanceMonitoringUnit exposes a PMU to the guest, for profilers such as perf. Only supported by
+	// the C3, C3D, C4, C4A, C4D, X4 and Z3 machine families.
+	PerformanceMonitoringUnit GCPPerformanceMonitoringUnit `json:"performanceMonitoringUnit,omitempty"`
+}
+
+// GCPConfidentialInstanceType is the confidential computing technology of a Confidential VM.
+type GCPConfidentialInstanceType string
+
+const (
+	// ConfidentialInstanceTypeSEV is AMD Secure Encrypted Virtualization, supported by the N2D, C2D and C3D
+	// machine families.
+	ConfidentialInstanceTypeSEV GCPConfidentialInstanceType = "SEV"
+	// ConfidentialInstanceTypeSEVSNP is AMD Secure Encrypted Virtualization with Secure Nested Paging, adding
+	// memory integrity protection, supported by the N2D machine family.
+	ConfidentialInstanceTypeSEVSNP GCPConfidentialInstanceType = "SEV_SNP"
+	// ConfidentialInstanceTypeTDX is Intel Trust Domain Extensions, supported by the C3 machine family.
+	ConfidentialInstanceTypeTDX GCPConfidentialInstanceType = "TDX"
+)
+
+// GCPHealthCheck references the regional backend service whose HTTP or TCP health check reports the
+// health of the instance.
+type GCPHealthCheck struct {
+	// BackendService is the name of a backend service of the machine region.
+	BackendService string `json:"backendService"`
+	// InstanceGroup is the backend instance group of the backend service the instance is a member of,
+	// one of TargetInstanceGroups.
+	InstanceGroup string `json:"instanceGroup"`
 }
 
 // GCPServiceAccount describes service accounts for GCP.
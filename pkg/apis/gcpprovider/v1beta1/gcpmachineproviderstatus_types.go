@@ -1,6 +1,7 @@
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -13,7 +14,128 @@ import (
 type GCPMachineProviderStatus struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
-	//TODO(alberto): Populate this and implement status
+
+	// PendingOperation is the GCP operation the controller was waiting for when it stopped, e.g. on shutdown.
+	// The next reconcile of the machine resumes waiting for it instead of starting a new operation.
+	PendingOperation *GCPOperation `json:"pendingOperation,omitempty"`
+
+	// InstanceName is the name of the instance created for the machine. The controller keeps using it even if
+	// the instance name prefix of the provider spec changes.
+	InstanceName string `json:"instanceName,omitempty"`
+
+	// Zone is the zone selected for the instance when the provider spec sets a region but no zone, or when the
+	// machine set of the machine spreads its machines across zones.
+	Zone string `json:"zone,omitempty"`
+
+	// Disks are the names of the disks created with the instance that GCP does not delete with it,
+	// the disks with autoDelete false. They are deleted with the machine.
+	Disks []string `json:"disks,omitempty"`
+
+	// AttachedDisks are the existing data disks the controller attached to the instance. They are detached once
+	// they are removed from the provider spec, while the disks attached by others, e.g. the CSI driver, are not.
+	AttachedDisks []string `json:"attachedDisks,omitempty"`
+
+	// Addresses are the names of the regional static addresses reserved for the instance.
+	// They are released with the machine.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// TargetPools are the target pools the instance was added to. The instance is removed from them
+	// with the machine, even once they are removed from the provider spec.
+	TargetPools []string `json:"targetPools,omitempty"`
+
+	// InstanceGroups are the instance groups the instance was added to. The instance is removed from them
+	// with the machine, even once they are removed from the provider spec.
+	InstanceGroups []string `json:"instanceGroups,omitempty"`
+
+	// InstanceSchedulePolicy is the instance schedule resource policy attached to the instance. It is detached
+	// once the provider spec names another policy or none.
+	InstanceSchedulePolicy string `json:"instanceSchedulePolicy,omitempty"`
+
+	// LastSnapshot is the last snapshot of a disk of the instance taken on request of the snapshot annotation of
+	// the machine.
+	LastSnapshot *GCPSnapshot `json:"lastSnapshot,omitempty"`
+
+	// Conditions is a set of conditions associated with the machine to indicate errors or other status.
+	Conditions []GCPMachineProviderCondition `json:"conditions,omitempty"`
+
+	// LastOperation is the last create, update or delete operation of the controller on the machine,
+	// so tools can observe what the controller attempted without reading its logs.
+	LastOperation *GCPLastOperation `json:"lastOperation,omitempty"`
+}
+
+// GCPMachineProviderConditionType is a valid value for GCPMachineProviderCondition.Type.
+type GCPMachineProviderConditionType string
+
+const (
+	// MachineReady reports whether the instance passes the health check of the provider spec.
+	MachineReady GCPMachineProviderConditionType = "Ready"
+	// MachinePreempted is set when the preemptible instance of the machine was stopped by GCP. The machine is
+	// failed and its instance is not recreated.
+	MachinePreempted GCPMachineProviderConditionType = "Preempted"
+	// MachinePermissionDenied is set when the GCP API denied a request of the last operation on the machine,
+	// e.g. because its service account misses an IAM role. It is cleared by the next successful operation.
+	MachinePermissionDenied GCPMachineProviderConditionType = "PermissionDenied"
+	// MachineMissingPermissions is set when the periodic permissions check finds the machine credentials are not granted
+	// IAM permissions the provider needs in the project. It is cleared once the permissions are granted.
+	MachineMissingPermissions GCPMachineProviderConditionType = "MissingPermissions"
+)
+
+// GCPMachineProviderCondition is a condition in a GCPMachineProviderStatus.
+type GCPMachineProviderCondition struct {
+	// Type is the type of the condition.
+	Type GCPMachineProviderConditionType `json:"type"`
+	// Status is the status of the condition.
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition transitioned from one status to another.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a unique, one-word, CamelCase reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable message indicating details about last transition.
+	Message string `json:"message,omitempty"`
+}
+
+// GCPLastOperationStatus is the outcome of an operation of the controller on a machine.
+type GCPLastOperationStatus string
+
+const (
+	// LastOperationSucceeded is the status of operations which completed without error.
+	LastOperationSucceeded GCPLastOperationStatus = "Succeeded"
+	// LastOperationFailed is the status of operations which returned an error, they are retried.
+	LastOperationFailed GCPLastOperationStatus = "Failed"
+)
+
+// GCPLastOperation describes an operation of the controller on a machine.
+type GCPLastOperation struct {
+	// Type is the type of the operation: create, update or delete.
+	Type string `json:"type"`
+	// Status is the outcome of the operation.
+	Status GCPLastOperationStatus `json:"status"`
+	// StartTime is the time the operation started.
+	StartTime metav1.Time `json:"startTime"`
+	// ErrorMessage is the error the operation failed with.
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	// OperationURL is the URL of the GCP operation started on the instance, if any.
+	OperationURL string `json:"operationURL,omitempty"`
+}
+
+// GCPSnapshot describes a snapshot of a disk of the instance.
+type GCPSnapshot struct {
+	// Request is the value of the snapshot annotation the snapshot was taken for.
+	Request string `json:"request"`
+	// Disk is the name of the disk the snapshot was taken of.
+	Disk string `json:"disk"`
+	// Name is the name of the snapshot.
+	Name string `json:"name"`
+}
+
+// GCPOperation identifies a zonal GCP operation on the machine instance.
+type GCPOperation struct {
+	// Name is the name of the operation.
+	Name string `json:"name"`
+	// Zone is the zone of the operation.
+	Zone string `json:"zone"`
+	// Type is the type of the operation, e.g. insert or delete.
+	Type string `json:"type"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,30 @@
+package v1beta1
+
+// ServiceAccountScopeAliases maps the service account scope aliases understood by gcloud to their scope URLs.
+var ServiceAccountScopeAliases = map[string]string{
+	"bigquery":              "https://www.googleapis.com/auth/bigquery",
+	"cloud-platform":        "https://www.googleapis.com/auth/cloud-platform",
+	"cloud-source-repos":    "https://www.googleapis.com/auth/source.full_control",
+	"cloud-source-repos-ro": "https://www.googleapis.com/auth/source.read_only",
+	"compute-ro":            "https://www.googleapis.com/auth/compute.readonly",
+	"compute-rw":            "https://www.googleapis.com/auth/compute",
+	"datastore":             "https://www.googleapis.com/auth/datastore",
+	"default":               "https://www.googleapis.com/auth/cloud-platform",
+	"logging-write":         "https://www.googleapis.com/auth/logging.write",
+	"monitoring":            "https://www.googleapis.com/auth/monitoring",
+	"monitoring-read":       "https://www.googleapis.com/auth/monitoring.read",
+	"monitoring-write":      "https://www.googleapis.com/auth/monitoring.write",
+	"pubsub":                "https://www.googleapis.com/auth/pubsub",
+	"service-control":       "https://www.googleapis.com/auth/servicecontrol",
+	"service-management":    "https://www.googleapis.com/auth/service.management.readonly",
+	"sql-admin":             "https://www.googleapis.com/auth/sqlservice.admin",
+	"storage-full":          "https://www.googleapis.com/auth/devstorage.full_control",
+	"storage-ro":            "https://www.googleapis.com/auth/devstorage.read_only",
+	"storage-rw":            "https://www.googleapis.com/auth/devstorage.read_write",
+	"taskqueue":             "https://www.googleapis.com/auth/taskqueue",
+	"trace":                 "https://www.googleapis.com/auth/trace.append",
+	"userinfo-email":        "https://www.googleapis.com/auth/userinfo.email",
+}
+
+// ServiceAccountScopePrefix is the prefix of all service account scope URLs.
+const ServiceAccountScopePrefix = "https://www.googleapis.com/auth/"
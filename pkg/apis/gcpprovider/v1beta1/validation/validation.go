@@ -0,0 +1,783 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	// machineTypeRegex matches bare GCE machine type names, e.g. n1-standard-4 or custom-4-16384.
+	machineTypeRegex = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+	// labelKeyRegex and labelValueRegex follow the GCP label requirements:
+	// https://cloud.google.com/compute/docs/labeling-resources#restrictions
+	labelKeyRegex   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	labelValueRegex = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+
+	// regionRegex and zoneRegex match GCP region and zone names, e.g. us-east1 and us-east1-b.
+	regionRegex = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+$`)
+	zoneRegex   = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+-[a-z]$`)
+
+	// projectIDRegex matches GCP project IDs, e.g. my-project-123456.
+	projectIDRegex = regexp.MustCompile(`^[a-z][-a-z0-9]{4,28}[a-z0-9]$`)
+
+	// instanceNamePrefixRegex matches instance name prefixes short enough to be followed by a hyphen and an
+	// 8 character hash in the 63 characters of an instance name.
+	instanceNamePrefixRegex = regexp.MustCompile(`^[a-z][-a-z0-9]{0,53}$`)
+
+	// resourceNameRegex matches GCP resource names, e.g. of target pools and instance groups.
+	resourceNameRegex = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+	// portRangeRegex matches firewall ports and port ranges, e.g. 6443 or 30000-32767.
+	portRangeRegex = regexp.MustCompile(`^[0-9]{1,5}(-[0-9]{1,5})?$`)
+
+	// serviceAccountEmailRegex loosely matches service account emails, e.g. worker@my-project.iam.gserviceaccount.com.
+	serviceAccountEmailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+	// metadataKeyRegex matches instance metadata keys, e.g. enable-oslogin.
+	metadataKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+
+	// sshKeyRegex matches an entry of the ssh-keys metadata, a user name followed by a public key.
+	sshKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+:\S+ \S+( .*)?$`)
+
+	// tagKeyRegex and tagValueRegex match the IDs of Resource Manager tag keys and values.
+	tagKeyRegex   = regexp.MustCompile(`^tagKeys/[0-9]+$`)
+	tagValueRegex = regexp.MustCompile(`^tagValues/[0-9]+$`)
+)
+
+// ValidateGCPMachineProviderSpec validates the fields of a GCPMachineProviderSpec.
+// It only performs static checks that do not require talking to the GCP API.
+func ValidateGCPMachineProviderSpec(spec *v1beta1.GCPMachineProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.Zone == "" {
+		if spec.Region == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("zone"), "zone or region is required"))
+		}
+	} else if !zoneRegex.MatchString(spec.Zone) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("zone"), spec.Zone, "zone must be a GCP zone name, e.g. us-east1-b"))
+	}
+
+	if spec.Region != "" && !regionRegex.MatchString(spec.Region) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("region"), spec.Region, "region must be a GCP region name, e.g. us-east1"))
+	}
+
+	if spec.MachineType == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("machineType"), "machineType is required"))
+	} else if !machineTypeRegex.MatchString(spec.MachineType) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("machineType"), spec.MachineType, "machineType must be a machine type name, e.g. n1-standard-4, not a URL or path"))
+	}
+
+	if spec.Region != "" && spec.Zone != "" && !strings.HasPrefix(spec.Zone, spec.Region+"-") {
+		// Subnetworks are regional, a zone outside of the region fails at insert time with a confusing error.
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("zone"), spec.Zone, fmt.Sprintf("zone must be in region %q", spec.Region)))
+	}
+
+	allErrs = append(allErrs, validateDisks(spec.Disks, fldPath.Child("disks"))...)
+	for _, disk := range spec.Disks {
+		if disk != nil && disk.Source != "" && spec.Zone == "" {
+			// Zonal disks can only be attached to instances of their zone, which is not known until it is selected.
+			allErrs = append(allErrs, field.Required(fldPath.Child("zone"), "zone is required to attach existing disks"))
+			break
+		}
+	}
+	allErrs = append(allErrs, validateLocalSSDs(spec.Disks, spec.MachineType, fldPath.Child("disks"))...)
+	allErrs = append(allErrs, validateLabels(spec.Labels, fldPath.Child("labels"))...)
+	allErrs = append(allErrs, validateResourceManagerTags(spec.ResourceManagerTags, fldPath.Child("resourceManagerTags"))...)
+	allErrs = append(allErrs, validateServiceAccounts(spec.ServiceAccounts, fldPath.Child("serviceAccounts"))...)
+
+	if spec.ProjectID != "" && !projectIDRegex.MatchString(spec.ProjectID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("projectID"), spec.ProjectID, "projectID must be 6 to 30 lowercase letters, digits or hyphens, start with a letter and not end with a hyphen"))
+	}
+	if spec.NetworkProjectID != "" && !projectIDRegex.MatchString(spec.NetworkProjectID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("networkProjectID"), spec.NetworkProjectID, "networkProjectID must be 6 to 30 lowercase letters, digits or hyphens, start with a letter and not end with a hyphen"))
+	}
+
+	if spec.InstanceNamePrefix != "" && !instanceNamePrefixRegex.MatchString(spec.InstanceNamePrefix) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("instanceNamePrefix"), spec.InstanceNamePrefix, "instanceNamePrefix must be at most 54 lowercase letters, digits or hyphens and start with a letter"))
+	}
+
+	if spec.ImpersonateServiceAccount != "" && !serviceAccountEmailRegex.MatchString(spec.ImpersonateServiceAccount) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("impersonateServiceAccount"), spec.ImpersonateServiceAccount, "impersonateServiceAccount must be a service account email"))
+	}
+
+	for i, targetPool := range spec.TargetPools {
+		if !resourceNameRegex.MatchString(targetPool) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("targetPools").Index(i), targetPool, "target pool must be the name of a target pool in the machine region, not a URL"))
+		}
+	}
+
+	for i, instanceGroup := range spec.TargetInstanceGroups {
+		if !resourceNameRegex.MatchString(strings.Replace(instanceGroup, v1beta1.InstanceGroupZonePlaceholder, "zone", -1)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("targetInstanceGroups").Index(i), instanceGroup, "instance group must be the name of an unmanaged instance group in the machine zone, not a URL"))
+		}
+	}
+
+	allErrs = append(allErrs, validateMetadata(spec.Metadata, fldPath.Child("metadata"))...)
+	allErrs = append(allErrs, validateSSHKeys(spec, fldPath)...)
+	allErrs = append(allErrs, validateOSConfig(spec, fldPath)...)
+
+	allErrs = append(allErrs, validateFirewallRules(spec.FirewallRules, fldPath.Child("firewallRules"))...)
+	if len(spec.FirewallRules) > 0 && len(spec.Tags) == 0 {
+		// Rules without target tags would apply to every instance of the network.
+		allErrs = append(allErrs, field.Required(fldPath.Child("tags"), "tags are required to target firewall rules"))
+	}
+
+	for i, gpu := range spec.GPUs {
+		if gpu.Count < 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("gpus").Index(i).Child("count"), gpu.Count, "count must be at least 1"))
+		}
+		if !resourceNameRegex.MatchString(gpu.Type) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("gpus").Index(i).Child("type"), gpu.Type, "type must be an accelerator type name, e.g. nvidia-tesla-t4"))
+		}
+	}
+
+	for i, nic := range spec.NetworkInterfaces {
+		if nic != nil && nic.StaticIP && nic.Subnetwork == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("networkInterfaces").Index(i).Child("subnetwork"), "subnetwork is required to reserve a static IP"))
+		}
+	}
+
+	if spec.AutoRepair && spec.Preemptible {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("autoRepair"), spec.AutoRepair, "autoRepair is not supported for preemptible machines, which are replaced once preempted"))
+	}
+
+	if spec.InstanceSchedulePolicy != "" {
+		if !resourceNameRegex.MatchString(spec.InstanceSchedulePolicy) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("instanceSchedulePolicy"), spec.InstanceSchedulePolicy, "instanceSchedulePolicy must be the name of a resource policy in the machine region, not a URL"))
+		}
+		// The instances stopped by the schedule would be recreated or replaced.
+		if spec.AutoRepair {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("instanceSchedulePolicy"), spec.InstanceSchedulePolicy, "instanceSchedulePolicy is not supported for auto-repaired machines"))
+		}
+		if spec.Preemptible {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("instanceSchedulePolicy"), spec.InstanceSchedulePolicy, "instanceSchedulePolicy is not supported for preemptible machines"))
+		}
+	}
+
+	if spec.AdvancedMachineFeatures != nil {
+		allErrs = append(allErrs, validateAdvancedMachineFeatures(spec.AdvancedMachineFeatures, spec.MachineType, fldPath.Child("advancedMachineFeatures"))...)
+	}
+
+	if spec.ConfidentialInstanceType != "" {
+		allErrs = append(allErrs, validateConfidentialInstanceType(spec.ConfidentialInstanceType, spec.MachineType, fldPath.Child("confidentialInstanceType"))...)
+	}
+
+	if spec.HealthCheck != nil {
+		allErrs = append(allErrs, validateHealthCheck(spec.HealthCheck, spec.TargetInstanceGroups, fldPath.Child("healthCheck"))...)
+	}
+
+	if spec.UserDataSecret != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.UserDataSecret.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("userDataSecret", "name"), spec.UserDataSecret.Name, msg))
+		}
+	}
+	if spec.UserDataSecretKey != "" {
+		for _, msg := range validation.IsConfigMapKey(spec.UserDataSecretKey) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("userDataSecretKey"), spec.UserDataSecretKey, msg))
+		}
+	}
+	if spec.IgnitionConfigSource != "" {
+		if u, err := url.Parse(spec.IgnitionConfigSource); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("ignitionConfigSource"), spec.IgnitionConfigSource, err.Error()))
+		} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "gs" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("ignitionConfigSource"), spec.IgnitionConfigSource, "must be an http, https or gs URL"))
+		}
+		if spec.OSFamily == v1beta1.OSFamilyWindows || spec.UserDataFormat != "" && spec.UserDataFormat != v1beta1.UserDataFormatIgnition {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("ignitionConfigSource"), spec.IgnitionConfigSource, "requires the Ignition user data format"))
+		}
+	}
+	switch spec.OSFamily {
+	case "", v1beta1.OSFamilyLinux:
+		switch spec.UserDataFormat {
+		case "", v1beta1.UserDataFormatIgnition, v1beta1.UserDataFormatCloudInit, v1beta1.UserDataFormatStartupScript:
+		default:
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("userDataFormat"), spec.UserDataFormat,
+				[]string{string(v1beta1.UserDataFormatIgnition), string(v1beta1.UserDataFormatCloudInit), string(v1beta1.UserDataFormatStartupScript)}))
+		}
+	case v1beta1.OSFamilyWindows:
+		switch spec.UserDataFormat {
+		case "", v1beta1.UserDataFormatWindowsStartupScript, v1beta1.UserDataFormatSysprepSpecializeScript:
+		default:
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("userDataFormat"), spec.UserDataFormat,
+				[]string{string(v1beta1.UserDataFormatWindowsStartupScript), string(v1beta1.UserDataFormatSysprepSpecializeScript)}))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("osFamily"), spec.OSFamily,
+			[]string{string(v1beta1.OSFamilyLinux), string(v1beta1.OSFamilyWindows)}))
+	}
+
+	return allErrs
+}
+
+// ValidateGCPClusterProviderSpec validates the fields of a GCPClusterProviderSpec.
+// It only performs static checks that do not require talking to the GCP API.
+func ValidateGCPClusterProviderSpec(spec *v1beta1.GCPClusterProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.Region == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("region"), "region is required"))
+	} else if !regionRegex.MatchString(spec.Region) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("region"), spec.Region, "region must be a GCP region name, e.g. us-east1"))
+	}
+
+	if spec.ProjectID != "" && !projectIDRegex.MatchString(spec.ProjectID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("projectID"), spec.ProjectID, "projectID must be 6 to 30 lowercase letters, digits or hyphens, start with a letter and not end with a hyphen"))
+	}
+
+	if spec.Network == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("network"), "network is required"))
+	} else if !resourceNameRegex.MatchString(spec.Network) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("network"), spec.Network, "network must be a GCP resource name"))
+	}
+
+	names := map[string]bool{}
+	for i, subnetwork := range spec.Subnetworks {
+		if !resourceNameRegex.MatchString(subnetwork.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("subnetworks").Index(i).Child("name"), subnetwork.Name, "name must be a GCP resource name"))
+		} else if names[subnetwork.Name] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child("subnetworks").Index(i).Child("name"), subnetwork.Name))
+		}
+		names[subnetwork.Name] = true
+		if ip, _, err := net.ParseCIDR(subnetwork.CIDRBlock); err != nil || ip.To4() == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("subnetworks").Index(i).Child("cidrBlock"), subnetwork.CIDRBlock, "cidrBlock must be an IPv4 CIDR range"))
+		}
+	}
+
+	allErrs = append(allErrs, validateFirewallRules(spec.FirewallRules, fldPath.Child("firewallRules"))...)
+
+	for key := range spec.ProjectMetadata {
+		if !metadataKeyRegex.MatchString(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("projectMetadata").Key(key), key, "key must be 1 to 128 letters, digits, underscores or hyphens"))
+		}
+	}
+
+	if spec.CredentialsSecret != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.CredentialsSecret.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("credentialsSecret", "name"), spec.CredentialsSecret.Name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+func validateMetadata(metadata []*v1beta1.GCPMetadata, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, item := range metadata {
+		if item == nil {
+			continue
+		}
+		idxPath := fldPath.Index(i)
+		if item.Value != nil && item.ValueFrom != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("valueFrom"), "", "value and valueFrom are mutually exclusive"))
+		}
+		if item.ValueFrom == nil {
+			continue
+		}
+		source := item.ValueFrom
+		if (source.SecretKeyRef == nil) == (source.ConfigMapKeyRef == nil) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("valueFrom"), "", "exactly one of secretKeyRef and configMapKeyRef must be set"))
+			continue
+		}
+		refPath := idxPath.Child("valueFrom", "secretKeyRef")
+		name, key := "", ""
+		if source.SecretKeyRef != nil {
+			name, key = source.SecretKeyRef.Name, source.SecretKeyRef.Key
+		} else {
+			refPath = idxPath.Child("valueFrom", "configMapKeyRef")
+			name, key = source.ConfigMapKeyRef.Name, source.ConfigMapKeyRef.Key
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(refPath.Child("name"), name, msg))
+		}
+		for _, msg := range validation.IsConfigMapKey(key) {
+			allErrs = append(allErrs, field.Invalid(refPath.Child("key"), key, msg))
+		}
+	}
+	return allErrs
+}
+
+func validateSSHKeys(spec *v1beta1.GCPMachineProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, key := range spec.SSHKeys {
+		if !sshKeyRegex.MatchString(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("sshKeys").Index(i), key, "ssh key must be in the USERNAME:KEY format, e.g. core:ssh-ed25519 AAAA..."))
+		}
+	}
+	if spec.SSHKeySecret != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.SSHKeySecret.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("sshKeySecret", "name"), spec.SSHKeySecret.Name, msg))
+		}
+	}
+	if len(spec.SSHKeys) == 0 && spec.SSHKeySecret == nil && !spec.BlockProjectSSHKeys {
+		return allErrs
+	}
+	// The metadata keys set from the SSH key fields would be duplicated, which the insert rejects.
+	for i, item := range spec.Metadata {
+		if item != nil && (item.Key == "ssh-keys" || item.Key == "block-project-ssh-keys") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("metadata").Index(i).Child("key"), item.Key, "conflicts with the sshKeys, sshKeySecret and blockProjectSSHKeys fields"))
+		}
+	}
+	return allErrs
+}
+
+func validateOSConfig(spec *v1beta1.GCPMachineProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if !spec.OSConfig {
+		return allErrs
+	}
+	if len(spec.ServiceAccounts) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("serviceAccounts"), "a service account is required for the OS Config agent"))
+	}
+	// The metadata keys set from the osConfig field would be duplicated, which the insert rejects.
+	for i, item := range spec.Metadata {
+		if item != nil && (item.Key == "enable-osconfig" || item.Key == "enable-guest-attributes") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("metadata").Index(i).Child("key"), item.Key, "conflicts with the osConfig field"))
+		}
+	}
+	return allErrs
+}
+
+// pmuMachineFamilies are the machine families supporting a performance monitoring unit.
+var pmuMachineFamilies = map[string]bool{
+	"c3":  true,
+	"c3d": true,
+	"c4":  true,
+	"c4a": true,
+	"c4d": true,
+	"x4":  true,
+	"z3":  true,
+}
+
+func validateAdvancedMachineFeatures(features *v1beta1.GCPAdvancedMachineFeatures, machineType string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch features.PerformanceMonitoringUnit {
+	case "":
+	case v1beta1.PerformanceMonitoringUnitArchitectural, v1beta1.PerformanceMonitoringUnitStandard, v1beta1.PerformanceMonitoringUnitEnhanced:
+		if family := strings.SplitN(machineType, "-", 2)[0]; machineType != "" && !pmuMachineFamilies[family] {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("performanceMonitoringUnit"), features.PerformanceMonitoringUnit,
+				fmt.Sprintf("the %s machine family does not support a performance monitoring unit, use a C3, C3D, C4, C4A, C4D, X4 or Z3 machine type", family)))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("performanceMonitoringUnit"), features.PerformanceMonitoringUnit,
+			[]string{string(v1beta1.PerformanceMonitoringUnitArchitectural), string(v1beta1.PerformanceMonitoringUnitStandard), string(v1beta1.PerformanceMonitoringUnitEnhanced)}))
+	}
+	return allErrs
+}
+
+// confidentialMachineFamilies are the machine families supporting each confidential computing technology.
+var confidentialMachineFamilies = map[v1beta1.GCPConfidentialInstanceType][]string{
+	v1beta1.ConfidentialInstanceTypeSEV:    {"n2d", "c2d", "c3d"},
+	v1beta1.ConfidentialInstanceTypeSEVSNP: {"n2d"},
+	v1beta1.ConfidentialInstanceTypeTDX:    {"c3"},
+}
+
+func validateConfidentialInstanceType(instanceType v1beta1.GCPConfidentialInstanceType, machineType string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	families, ok := confidentialMachineFamilies[instanceType]
+	if !ok {
+		return append(allErrs, field.NotSupported(fldPath, instanceType,
+			[]string{string(v1beta1.ConfidentialInstanceTypeSEV), string(v1beta1.ConfidentialInstanceTypeSEVSNP), string(v1beta1.ConfidentialInstanceTypeTDX)}))
+	}
+	if machineType == "" {
+		return allErrs
+	}
+	family := strings.SplitN(machineType, "-", 2)[0]
+	for _, supported := range families {
+		if family == supported {
+			return allErrs
+		}
+	}
+	return append(allErrs, field.Invalid(fldPath, instanceType,
+		fmt.Sprintf("%s is not supported by the %s machine family, use a %s machine type", instanceType, family, strings.ToUpper(strings.Join(families, ", ")))))
+}
+
+func validateDisks(disks []*v1beta1.GCPDisk, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	bootDisks := 0
+	for i, disk := range disks {
+		if disk == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i), "disk must not be empty"))
+			continue
+		}
+		if disk.Boot {
+			bootDisks++
+			if disk.Image == "" && disk.Source == "" && !disk.ImageFromStream {
+				allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("image"), "boot disk image, imageFromStream or source is required"))
+			}
+		}
+		if disk.ImageFromStream {
+			if !disk.Boot {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("imageFromStream"), disk.ImageFromStream, "only the image of the boot disk can be resolved from the stream metadata"))
+			}
+			if disk.Image != "" || disk.Source != "" {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("imageFromStream"), disk.ImageFromStream, "imageFromStream is exclusive with image and source"))
+			}
+		}
+		if disk.Source != "" {
+			allErrs = append(allErrs, validateSourceDisk(disk, fldPath.Index(i))...)
+		}
+		if disk.Source == "" && disk.Mode != "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("mode"), disk.Mode, "only the mode of existing disks can be set"))
+		}
+		if disk.MultiWriter {
+			if disk.Boot {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("multiWriter"), disk.MultiWriter, "boot disks cannot be multi-writer disks"))
+			}
+			if !multiWriterDiskTypes[disk.Type] {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("type"), disk.Type, "multi-writer disks must be of type pd-ssd, hyperdisk-balanced or hyperdisk-balanced-high-availability"))
+			}
+		}
+		allErrs = append(allErrs, validateLabels(disk.Labels, fldPath.Index(i).Child("labels"))...)
+	}
+
+	switch {
+	case bootDisks == 0:
+		allErrs = append(allErrs, field.Required(fldPath, "at least one boot disk is required"))
+	case bootDisks > 1:
+		allErrs = append(allErrs, field.Invalid(fldPath, bootDisks, "only one boot disk is allowed"))
+	}
+
+	return allErrs
+}
+
+// validateSourceDisk checks a disk attaching an existing disk sets none of the fields initializing a new disk.
+func validateSourceDisk(disk *v1beta1.GCPDisk, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if !resourceNameRegex.MatchString(disk.Source) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("source"), disk.Source, "source must be the name of a disk in the machine zone, not a URL"))
+	}
+	switch disk.Mode {
+	case "", v1beta1.DiskModeReadWrite:
+	case v1beta1.DiskModeReadOnly:
+		if disk.Boot {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("mode"), disk.Mode, "boot disks are attached read-write"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("mode"), disk.Mode, []string{string(v1beta1.DiskModeReadWrite), string(v1beta1.DiskModeReadOnly)}))
+	}
+	if !disk.Boot && disk.AutoDelete {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("autoDelete"), disk.AutoDelete, "existing data disks are detached, not deleted, when the machine is deleted"))
+	}
+	if disk.Image != "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("image"), disk.Image, "image and source are mutually exclusive"))
+	}
+	if disk.SizeGb != 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("sizeGb"), disk.SizeGb, "the size of existing disks cannot be set"))
+	}
+	if disk.Type != "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("type"), disk.Type, "the type of existing disks cannot be set"))
+	}
+	if disk.MultiWriter {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("multiWriter"), disk.MultiWriter, "existing disks cannot be created in multi-writer mode"))
+	}
+	if len(disk.Labels) > 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("labels"), disk.Labels, "the labels of existing disks cannot be set"))
+	}
+	return allErrs
+}
+
+// imageArchitecture returns the architecture of an image or image family from its name, e.g. ARM64 for
+// rhcos-412-86-202212081411-0-gcp-aarch64 or debian-12-arm64. It returns false when the name does not tell.
+func imageArchitecture(image string) (v1beta1.GCPArchitecture, bool) {
+	name := strings.ToLower(image[strings.LastIndex(image, "/")+1:])
+	switch {
+	case strings.Contains(name, "aarch64") || strings.Contains(name, "arm64"):
+		return v1beta1.ArchitectureARM64, true
+	case strings.Contains(name, "x86-64") || strings.Contains(name, "x86_64") || strings.Contains(name, "amd64"):
+		return v1beta1.ArchitectureX86_64, true
+	}
+	return "", false
+}
+
+// ValidateImageArchitectureHints checks the images whose name tells their architecture match the architecture of
+// the machine type, instances booting an image of another architecture never become ready. The name is only a hint
+// the webhook rejects obvious mismatches with, the pre-flight checks of the actuator check the architecture of the
+// image itself.
+func ValidateImageArchitectureHints(spec *v1beta1.GCPMachineProviderSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	machineType := spec.MachineType
+	if machineType == "" {
+		return allErrs
+	}
+	machineArchitecture := v1beta1.MachineTypeArchitecture(machineType)
+	for i, disk := range spec.Disks {
+		if disk == nil || disk.Image == "" {
+			continue
+		}
+		if architecture, ok := imageArchitecture(disk.Image); ok && architecture != machineArchitecture {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("disks").Index(i).Child("image"), disk.Image,
+				fmt.Sprintf("image architecture %s does not match the %s architecture of machine type %s", architecture, machineArchitecture, machineType)))
+		}
+	}
+	return allErrs
+}
+
+// multiWriterDiskTypes are the disk types supporting multi-writer mode.
+var multiWriterDiskTypes = map[string]bool{
+	"pd-ssd":                               true,
+	"hyperdisk-balanced":                   true,
+	"hyperdisk-balanced-high-availability": true,
+}
+
+// localSSDLimit lists the numbers of local SSDs supported by the machine types of a family with up to maxCPUs vCPUs.
+type localSSDLimit struct {
+	maxCPUs int
+	counts  []int
+}
+
+// localSSDLimits are the local SSD counts supported by the machine families with local SSDs attached as disks,
+// by number of vCPUs: https://cloud.google.com/compute/docs/disks/local-ssd#choose_number_local_ssds
+var localSSDLimits = map[string][]localSSDLimit{
+	"n1": {
+		{maxCPUs: 96, counts: []int{1, 2, 3, 4, 5, 6, 7, 8, 16, 24}},
+	},
+	"n2": {
+		{maxCPUs: 10, counts: []int{1, 2, 4, 8, 16, 24}},
+		{maxCPUs: 20, counts: []int{2, 4, 8, 16, 24}},
+		{maxCPUs: 40, counts: []int{4, 8, 16, 24}},
+		{maxCPUs: 80, counts: []int{8, 16, 24}},
+		{maxCPUs: 128, counts: []int{16, 24}},
+	},
+	"n2d": {
+		{maxCPUs: 16, counts: []int{1, 2, 4, 8, 16, 24}},
+		{maxCPUs: 48, counts: []int{2, 4, 8, 16, 24}},
+		{maxCPUs: 80, counts: []int{4, 8, 16, 24}},
+		{maxCPUs: 224, counts: []int{8, 16, 24}},
+	},
+	"c2": {
+		{maxCPUs: 8, counts: []int{1, 2, 4, 8}},
+		{maxCPUs: 16, counts: []int{2, 4, 8}},
+		{maxCPUs: 30, counts: []int{4, 8}},
+		{maxCPUs: 60, counts: []int{8}},
+	},
+	"c2d": {
+		{maxCPUs: 16, counts: []int{1, 2, 4, 8}},
+		{maxCPUs: 32, counts: []int{2, 4, 8}},
+		{maxCPUs: 56, counts: []int{4, 8}},
+		{maxCPUs: 112, counts: []int{8}},
+	},
+}
+
+// bundledLocalSSDMachineFamilies are the machine families whose local SSDs come with their -lssd machine types,
+// e.g. c3-standard-8-lssd, in a fixed number.
+var bundledLocalSSDMachineFamilies = map[string]bool{
+	"c3":  true,
+	"c3d": true,
+	"c4":  true,
+	"c4a": true,
+	"c4d": true,
+	"z3":  true,
+}
+
+// noLocalSSDMachineFamilies are the machine families without local SSD support.
+var noLocalSSDMachineFamilies = map[string]bool{
+	"e2":  true,
+	"n4":  true,
+	"t2a": true,
+	"t2d": true,
+}
+
+// machineTypeCPUs returns the number of vCPUs of a predefined or custom machine type, e.g. 8 for n2-standard-8,
+// n2-custom-8-32768 or custom-8-32768. It returns false for shared-core machine types.
+func machineTypeCPUs(machineType string) (int, bool) {
+	parts := strings.Split(strings.TrimSuffix(machineType, "-lssd"), "-")
+	var value string
+	switch {
+	case len(parts) == 3 && parts[0] == "custom":
+		value = parts[1]
+	case len(parts) == 4 && parts[1] == "custom":
+		value = parts[2]
+	case len(parts) == 3:
+		value = parts[2]
+	default:
+		return 0, false
+	}
+	cpus, err := strconv.Atoi(value)
+	return cpus, err == nil
+}
+
+// validateLocalSSDs checks the local SSDs of the disks, and that their number is supported by the machine type,
+// which GCP only reports once the instance insert operation fails.
+func validateLocalSSDs(disks []*v1beta1.GCPDisk, machineType string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	count := 0
+	for i, disk := range disks {
+		if disk == nil || disk.Type != v1beta1.LocalSSDDiskType {
+			continue
+		}
+		count++
+		if disk.Boot {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("boot"), disk.Boot, "local SSDs cannot be boot disks"))
+		}
+		if disk.Image != "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("disks").Index(i).Child("image"), disk.Image, "local SSDs cannot be created from an image"))
+		}
+		if !disk.AutoDelete {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("autoDelete"), disk.AutoDelete, "local SSDs are always deleted with the instance"))
+		}
+		if disk.SizeGb != 0 && disk.SizeGb != v1beta1.LocalSSDSizeGb {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("sizeGb"), disk.SizeGb, fmt.Sprintf("local SSDs are %d GB", v1beta1.LocalSSDSizeGb)))
+		}
+	}
+	if count == 0 || machineType == "" {
+		return allErrs
+	}
+
+	family := strings.SplitN(machineType, "-", 2)[0]
+	if strings.HasPrefix(family, "custom") {
+		// Custom machine types without family prefix are N1 machine types.
+		family = "n1"
+	}
+	switch {
+	case bundledLocalSSDMachineFamilies[family]:
+		return append(allErrs, field.Invalid(fldPath, count,
+			fmt.Sprintf("%s machine types do not support local SSD disks, use a machine type with bundled local SSDs instead, e.g. %s-standard-8-lssd", strings.ToUpper(family), family)))
+	case noLocalSSDMachineFamilies[family]:
+		return append(allErrs, field.Invalid(fldPath, count, fmt.Sprintf("%s machine types do not support local SSDs", strings.ToUpper(family))))
+	}
+	limits, ok := localSSDLimits[family]
+	cpus, known := machineTypeCPUs(machineType)
+	if !ok || !known {
+		return allErrs
+	}
+	for _, limit := range limits {
+		if cpus > limit.maxCPUs {
+			continue
+		}
+		for _, supported := range limit.counts {
+			if count == supported {
+				return allErrs
+			}
+		}
+		return append(allErrs, field.Invalid(fldPath, count,
+			fmt.Sprintf("machine type %s supports %s local SSDs, got %d", machineType, joinCounts(limit.counts), count)))
+	}
+	return allErrs
+}
+
+// joinCounts formats counts as a list, e.g. "1, 2, 4 or 8".
+func joinCounts(counts []int) string {
+	values := make([]string, len(counts))
+	for i, count := range counts {
+		values[i] = strconv.Itoa(count)
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+func validateServiceAccounts(serviceAccounts []v1beta1.GCPServiceAccount, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	// GCE instances can only run as a single service account.
+	if len(serviceAccounts) > 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath, len(serviceAccounts), "only one service account is allowed"))
+	}
+
+	for i, sa := range serviceAccounts {
+		if sa.Email != "default" && !serviceAccountEmailRegex.MatchString(sa.Email) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("email"), sa.Email, "email must be a service account email or \"default\""))
+		}
+		if len(sa.Scopes) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("scopes"), "at least one scope is required"))
+		}
+		for j, scope := range sa.Scopes {
+			if _, ok := v1beta1.ServiceAccountScopeAliases[scope]; !ok && !strings.HasPrefix(scope, v1beta1.ServiceAccountScopePrefix) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("scopes").Index(j), scope, fmt.Sprintf("scope must be a known alias or start with %q", v1beta1.ServiceAccountScopePrefix)))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateFirewallRules(rules []*v1beta1.GCPFirewallRule, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, rule := range rules {
+		if rule == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i), "firewall rule must not be empty"))
+			continue
+		}
+		if !resourceNameRegex.MatchString(rule.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("name"), rule.Name, "name must be a GCP resource name"))
+		}
+		for j, port := range rule.Ports {
+			if !portRangeRegex.MatchString(port) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("ports").Index(j), port, "port must be a port or a port range, e.g. 6443 or 30000-32767"))
+			}
+		}
+		// GCP allows traffic from anywhere when neither source ranges nor tags are set.
+		if len(rule.SourceRanges) == 0 && len(rule.SourceTags) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i), "sourceRanges or sourceTags are required"))
+		}
+		for j, sourceRange := range rule.SourceRanges {
+			if _, _, err := net.ParseCIDR(sourceRange); err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("sourceRanges").Index(j), sourceRange, "source range must be a CIDR range"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func validateHealthCheck(healthCheck *v1beta1.GCPHealthCheck, targetInstanceGroups []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !resourceNameRegex.MatchString(healthCheck.BackendService) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("backendService"), healthCheck.BackendService, "backendService must be the name of a backend service in the machine region"))
+	}
+	found := false
+	for _, instanceGroup := range targetInstanceGroups {
+		if instanceGroup == healthCheck.InstanceGroup {
+			found = true
+		}
+	}
+	if !found {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("instanceGroup"), healthCheck.InstanceGroup, "instanceGroup must be one of targetInstanceGroups"))
+	}
+
+	return allErrs
+}
+
+func validateLabels(labels map[string]string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for key, value := range labels {
+		if !labelKeyRegex.MatchString(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath, key, "label keys must start with a lowercase letter and contain at most 63 lowercase letters, digits, '_' or '-'"))
+		}
+		if !labelValueRegex.MatchString(value) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(key), value, "label values must contain at most 63 lowercase letters, digits, '_' or '-'"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateResourceManagerTags(tags map[string]string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for key, value := range tags {
+		if !tagKeyRegex.MatchString(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath, key, "resource manager tag keys must be tag key IDs, e.g. tagKeys/123456"))
+		}
+		if !tagValueRegex.MatchString(value) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(key), value, "resource manager tag values must be tag value IDs, e.g. tagValues/654321"))
+		}
+	}
+
+	return allErrs
+}
@@ -0,0 +1,905 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validSpec() *v1beta1.GCPMachineProviderSpec {
+	return &v1beta1.GCPMachineProviderSpec{
+		Region:      "us-east1",
+		Zone:        "us-east1-b",
+		MachineType: "n1-standard-4",
+		Disks: []*v1beta1.GCPDisk{
+			{
+				Boot:  true,
+				Image: "rhcos",
+			},
+		},
+		Labels: map[string]string{
+			"kubernetes-io-cluster-abc": "owned",
+		},
+		ServiceAccounts: []v1beta1.GCPServiceAccount{
+			{
+				Email:  "worker@my-project.iam.gserviceaccount.com",
+				Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+			},
+		},
+		UserDataSecret: &corev1.LocalObjectReference{Name: "worker-user-data"},
+	}
+}
+
+// withLocalSSDs appends count local SSDs to the disks of the spec and sets its machine type.
+func withLocalSSDs(spec *v1beta1.GCPMachineProviderSpec, machineType string, count int) {
+	spec.MachineType = machineType
+	for i := 0; i < count; i++ {
+		spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{AutoDelete: true, Type: v1beta1.LocalSSDDiskType})
+	}
+}
+
+func TestValidateGCPMachineProviderSpec(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mutate    func(spec *v1beta1.GCPMachineProviderSpec)
+		expectErr bool
+	}{
+		{
+			name:   "valid spec",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {},
+		},
+		{
+			name: "missing zone and region",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Zone = ""
+				spec.Region = ""
+			},
+			expectErr: true,
+		},
+		{
+			name:      "region without zone",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Zone = "" },
+			expectErr: false,
+		},
+		{
+			name:      "missing machine type",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.MachineType = "" },
+			expectErr: true,
+		},
+		{
+			name:      "zone outside of region",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Zone = "us-west1-a" },
+			expectErr: true,
+		},
+		{
+			name:      "zone without suffix",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Zone = "us-east1" },
+			expectErr: true,
+		},
+		{
+			name:      "region is a zone",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Region = "us-east1-b" },
+			expectErr: true,
+		},
+		{
+			name:      "uppercase zone",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Zone = "US-EAST1-B" },
+			expectErr: true,
+		},
+		{
+			name:      "region is optional",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Region = "" },
+			expectErr: false,
+		},
+		{
+			name:      "boot disk without image",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Disks[0].Image = "" },
+			expectErr: true,
+		},
+		{
+			name: "two boot disks",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{Boot: true, Image: "rhcos"})
+			},
+			expectErr: true,
+		},
+		{
+			name: "arm64 machine type",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "t2a-standard-4"
+				spec.Disks[0].Image = "projects/rhcos-cloud/global/images/rhcos-412-86-202212081411-0-gcp-aarch64"
+			},
+			expectErr: false,
+		},
+		{
+			name: "boot image from stream",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks[0].Image = ""
+				spec.Disks[0].ImageFromStream = true
+			},
+			expectErr: false,
+		},
+		{
+			name:      "boot image from stream and image",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Disks[0].ImageFromStream = true },
+			expectErr: true,
+		},
+		{
+			name: "data disk image from stream",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{SizeGb: 100, ImageFromStream: true})
+			},
+			expectErr: true,
+		},
+		{
+			name: "boot from existing disk",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks[0].Image = ""
+				spec.Disks[0].Source = "worker-0-restored"
+			},
+			expectErr: false,
+		},
+		{
+			name:      "boot from existing disk and image",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Disks[0].Source = "worker-0-restored" },
+			expectErr: true,
+		},
+		{
+			name: "boot from existing disk URL",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks[0].Image = ""
+				spec.Disks[0].Source = "projects/my-project/zones/us-east1-b/disks/worker-0-restored"
+			},
+			expectErr: true,
+		},
+		{
+			name: "boot from existing disk with size",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks[0].Image = ""
+				spec.Disks[0].Source = "worker-0-restored"
+				spec.Disks[0].SizeGb = 128
+			},
+			expectErr: true,
+		},
+		{
+			name: "existing data disk",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{Source: "shared-data", Mode: v1beta1.DiskModeReadOnly})
+			},
+			expectErr: false,
+		},
+		{
+			name: "existing data disk without zone",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Zone = ""
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{Source: "shared-data"})
+			},
+			expectErr: true,
+		},
+		{
+			name: "existing data disk deleted with the instance",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{Source: "shared-data", AutoDelete: true})
+			},
+			expectErr: true,
+		},
+		{
+			name: "existing data disk with unknown mode",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{Source: "shared-data", Mode: "READ_MANY"})
+			},
+			expectErr: true,
+		},
+		{
+			name: "read-only boot disk",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks[0].Image = ""
+				spec.Disks[0].Source = "worker-0-restored"
+				spec.Disks[0].Mode = v1beta1.DiskModeReadOnly
+			},
+			expectErr: true,
+		},
+		{
+			name: "mode of new disk",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{SizeGb: 100, Type: "pd-ssd", Mode: v1beta1.DiskModeReadOnly})
+			},
+			expectErr: true,
+		},
+		{
+			name: "multi-writer disk",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{SizeGb: 100, Type: "pd-ssd", MultiWriter: true})
+			},
+			expectErr: false,
+		},
+		{
+			name: "multi-writer boot disk",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks[0].Type = "pd-ssd"
+				spec.Disks[0].MultiWriter = true
+			},
+			expectErr: true,
+		},
+		{
+			name: "multi-writer disk of unsupported type",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Disks = append(spec.Disks, &v1beta1.GCPDisk{SizeGb: 100, Type: "pd-standard", MultiWriter: true})
+			},
+			expectErr: true,
+		},
+		{
+			name:      "local SSDs",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { withLocalSSDs(spec, "n2-standard-8", 4) },
+			expectErr: false,
+		},
+		{
+			name:      "local SSDs of custom N1 machine type",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { withLocalSSDs(spec, "custom-4-16384", 3) },
+			expectErr: false,
+		},
+		{
+			name:      "too few local SSDs for machine type",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { withLocalSSDs(spec, "n2-standard-32", 2) },
+			expectErr: true,
+		},
+		{
+			name:      "unsupported local SSD count",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { withLocalSSDs(spec, "c2-standard-8", 3) },
+			expectErr: true,
+		},
+		{
+			name:      "local SSDs of machine type with bundled local SSDs",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { withLocalSSDs(spec, "c3-standard-8", 1) },
+			expectErr: true,
+		},
+		{
+			name:      "local SSDs of machine type without local SSDs",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { withLocalSSDs(spec, "e2-standard-4", 1) },
+			expectErr: true,
+		},
+		{
+			name: "local SSD with image",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				withLocalSSDs(spec, "n2-standard-8", 1)
+				spec.Disks[1].Image = "rhcos"
+			},
+			expectErr: true,
+		},
+		{
+			name: "local SSD not deleted with the instance",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				withLocalSSDs(spec, "n2-standard-8", 1)
+				spec.Disks[1].AutoDelete = false
+			},
+			expectErr: true,
+		},
+		{
+			name:      "uppercase label key",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Labels["Owner"] = "me" },
+			expectErr: true,
+		},
+		{
+			name:      "label value with dots",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.Labels["version"] = "4.1" },
+			expectErr: true,
+		},
+		{
+			name: "multiple service accounts",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.ServiceAccounts = append(spec.ServiceAccounts, spec.ServiceAccounts[0])
+			},
+			expectErr: true,
+		},
+		{
+			name:      "invalid service account email",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.ServiceAccounts[0].Email = "worker" },
+			expectErr: true,
+		},
+		{
+			name:      "service account without scopes",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.ServiceAccounts[0].Scopes = nil },
+			expectErr: true,
+		},
+		{
+			name: "scope alias",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.ServiceAccounts[0].Scopes = []string{"compute-rw", "storage-ro"}
+			},
+			expectErr: false,
+		},
+		{
+			name:      "unknown scope",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.ServiceAccounts[0].Scopes = []string{"compute-admin"} },
+			expectErr: true,
+		},
+		{
+			name: "impersonated service account",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.ImpersonateServiceAccount = "machines@other-project.iam.gserviceaccount.com"
+			},
+			expectErr: false,
+		},
+		{
+			name:      "invalid impersonated service account",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.ImpersonateServiceAccount = "machines" },
+			expectErr: true,
+		},
+		{
+			name:      "project override",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.ProjectID = "other-project-123" },
+			expectErr: false,
+		},
+		{
+			name:      "invalid project override",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.ProjectID = "Other_Project" },
+			expectErr: true,
+		},
+		{
+			name: "resource manager tags",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.ResourceManagerTags = map[string]string{"tagKeys/123456": "tagValues/654321"}
+			},
+			expectErr: false,
+		},
+		{
+			name: "resource manager tags by name",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.ResourceManagerTags = map[string]string{"123456789/environment": "123456789/environment/production"}
+			},
+			expectErr: true,
+		},
+		{
+			name:      "network project",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.NetworkProjectID = "host-project-123" },
+			expectErr: false,
+		},
+		{
+			name:      "invalid network project",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.NetworkProjectID = "host-project-" },
+			expectErr: true,
+		},
+		{
+			name:      "instance schedule policy",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.InstanceSchedulePolicy = "business-hours" },
+			expectErr: false,
+		},
+		{
+			name: "instance schedule policy URL",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.InstanceSchedulePolicy = "projects/p/regions/us-east1/resourcePolicies/business-hours"
+			},
+			expectErr: true,
+		},
+		{
+			name: "instance schedule policy with auto-repair",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.InstanceSchedulePolicy = "business-hours"
+				spec.AutoRepair = true
+			},
+			expectErr: true,
+		},
+		{
+			name:      "instance name prefix",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.InstanceNamePrefix = "prod-gke-worker" },
+			expectErr: false,
+		},
+		{
+			name:      "instance name prefix too long",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.InstanceNamePrefix = strings.Repeat("a", 55) },
+			expectErr: true,
+		},
+		{
+			name:      "invalid instance name prefix",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.InstanceNamePrefix = "1worker" },
+			expectErr: true,
+		},
+		{
+			name: "target pools",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.TargetPools = []string{"cluster-api", "cluster-api-internal"}
+			},
+			expectErr: false,
+		},
+		{
+			name: "target pool URL",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.TargetPools = []string{"regions/us-east1/targetPools/cluster-api"}
+			},
+			expectErr: true,
+		},
+		{
+			name: "target instance groups",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.TargetInstanceGroups = []string{"cluster-master-us-east1-b"}
+			},
+			expectErr: false,
+		},
+		{
+			name: "target instance group with zone placeholder",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.TargetInstanceGroups = []string{"cluster-master-{zone}"}
+			},
+			expectErr: false,
+		},
+		{
+			name: "target instance group URL",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.TargetInstanceGroups = []string{"zones/us-east1-b/instanceGroups/master"}
+			},
+			expectErr: true,
+		},
+		{
+			name: "gpus",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.GPUs = []v1beta1.GCPGPUConfig{{Count: 2, Type: "nvidia-tesla-t4"}}
+			},
+			expectErr: false,
+		},
+		{
+			name: "gpus without count",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.GPUs = []v1beta1.GCPGPUConfig{{Type: "nvidia-tesla-t4"}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "static IP",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.NetworkInterfaces = []*v1beta1.GCPNetworkInterface{{Network: "default", Subnetwork: "workers", StaticIP: true}}
+			},
+			expectErr: false,
+		},
+		{
+			name: "static IP without subnetwork",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.NetworkInterfaces = []*v1beta1.GCPNetworkInterface{{Network: "default", StaticIP: true}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "auto-repair",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.AutoRepair = true
+			},
+			expectErr: false,
+		},
+		{
+			name: "auto-repair of preemptible machine",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.AutoRepair = true
+				spec.Preemptible = true
+			},
+			expectErr: true,
+		},
+		{
+			name: "health check",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.TargetInstanceGroups = []string{"cluster-master-{zone}"}
+				spec.HealthCheck = &v1beta1.GCPHealthCheck{BackendService: "api-internal", InstanceGroup: "cluster-master-{zone}"}
+			},
+			expectErr: false,
+		},
+		{
+			name: "health check of another instance group",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.TargetInstanceGroups = []string{"cluster-master-{zone}"}
+				spec.HealthCheck = &v1beta1.GCPHealthCheck{BackendService: "api-internal", InstanceGroup: "cluster-worker-{zone}"}
+			},
+			expectErr: true,
+		},
+		{
+			name: "firewall rules",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Tags = []string{"cluster-master"}
+				spec.FirewallRules = []*v1beta1.GCPFirewallRule{
+					{Name: "cluster-api", Ports: []string{"6443"}, SourceRanges: []string{"0.0.0.0/0"}},
+					{Name: "cluster-etcd", Ports: []string{"2379-2380"}, SourceTags: []string{"cluster-master"}},
+				}
+			},
+			expectErr: false,
+		},
+		{
+			name: "firewall rules without tags",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Tags = nil
+				spec.FirewallRules = []*v1beta1.GCPFirewallRule{{Name: "cluster-api", SourceRanges: []string{"0.0.0.0/0"}}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "firewall rule without sources",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Tags = []string{"cluster-master"}
+				spec.FirewallRules = []*v1beta1.GCPFirewallRule{{Name: "cluster-api", Ports: []string{"6443"}}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "firewall rule invalid port range",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Tags = []string{"cluster-master"}
+				spec.FirewallRules = []*v1beta1.GCPFirewallRule{{Name: "cluster-api", Ports: []string{"6443:6444"}, SourceRanges: []string{"10.0.0.0/8"}}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "firewall rule invalid source range",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Tags = []string{"cluster-master"}
+				spec.FirewallRules = []*v1beta1.GCPFirewallRule{{Name: "cluster-api", SourceRanges: []string{"10.0.0.0"}}}
+			},
+			expectErr: true,
+		},
+		{
+			name:      "invalid user data secret name",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.UserDataSecret.Name = "Worker_User_Data" },
+			expectErr: true,
+		},
+		{
+			name:      "user data secret key",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.UserDataSecretKey = "ignition.json" },
+			expectErr: false,
+		},
+		{
+			name:      "invalid user data secret key",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.UserDataSecretKey = "user/data" },
+			expectErr: true,
+		},
+		{
+			name:      "cloud-init user data format",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.UserDataFormat = v1beta1.UserDataFormatCloudInit },
+			expectErr: false,
+		},
+		{
+			name: "ignition config source",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.IgnitionConfigSource = "https://api-int.example.com:22623/config/worker"
+			},
+			expectErr: false,
+		},
+		{
+			name:      "invalid ignition config source scheme",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.IgnitionConfigSource = "ftp://example.com/worker.ign" },
+			expectErr: true,
+		},
+		{
+			name: "ignition config source with cloud-init",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.IgnitionConfigSource = "https://api-int.example.com:22623/config/worker"
+				spec.UserDataFormat = v1beta1.UserDataFormatCloudInit
+			},
+			expectErr: true,
+		},
+		{
+			name: "metadata from secret",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Metadata = []*v1beta1.GCPMetadata{{Key: "token", ValueFrom: &v1beta1.GCPMetadataValueSource{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "bootstrap"}, Key: "token"},
+				}}}
+			},
+			expectErr: false,
+		},
+		{
+			name: "metadata with value and valueFrom",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				value := "abc"
+				spec.Metadata = []*v1beta1.GCPMetadata{{Key: "token", Value: &value, ValueFrom: &v1beta1.GCPMetadataValueSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "bootstrap"}, Key: "token"},
+				}}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "metadata valueFrom without reference",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Metadata = []*v1beta1.GCPMetadata{{Key: "token", ValueFrom: &v1beta1.GCPMetadataValueSource{}}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "metadata valueFrom invalid key",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.Metadata = []*v1beta1.GCPMetadata{{Key: "token", ValueFrom: &v1beta1.GCPMetadataValueSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "bootstrap"}, Key: "a/b"},
+				}}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "ssh keys",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.SSHKeys = []string{"core:ssh-ed25519 AAAAC3Nza admin@example.com"}
+				spec.SSHKeySecret = &corev1.LocalObjectReference{Name: "ssh-keys"}
+				spec.BlockProjectSSHKeys = true
+			},
+			expectErr: false,
+		},
+		{
+			name:      "ssh key without user name",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.SSHKeys = []string{"ssh-ed25519 AAAAC3Nza"} },
+			expectErr: true,
+		},
+		{
+			name: "ssh keys conflicting with metadata",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				value := "core:ssh-ed25519 AAAAC3Nza"
+				spec.SSHKeys = []string{"core:ssh-ed25519 AAAAC3Nza"}
+				spec.Metadata = []*v1beta1.GCPMetadata{{Key: "ssh-keys", Value: &value}}
+			},
+			expectErr: true,
+		},
+		{
+			name:      "os config",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.OSConfig = true },
+			expectErr: false,
+		},
+		{
+			name: "os config without service account",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.OSConfig = true
+				spec.ServiceAccounts = nil
+			},
+			expectErr: true,
+		},
+		{
+			name: "os config conflicting with metadata",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				value := "FALSE"
+				spec.OSConfig = true
+				spec.Metadata = []*v1beta1.GCPMetadata{{Key: "enable-osconfig", Value: &value}}
+			},
+			expectErr: true,
+		},
+		{
+			name: "performance monitoring unit",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "c3-standard-8"
+				spec.AdvancedMachineFeatures = &v1beta1.GCPAdvancedMachineFeatures{PerformanceMonitoringUnit: v1beta1.PerformanceMonitoringUnitStandard}
+			},
+			expectErr: false,
+		},
+		{
+			name: "performance monitoring unit on unsupported machine family",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "n2-standard-8"
+				spec.AdvancedMachineFeatures = &v1beta1.GCPAdvancedMachineFeatures{PerformanceMonitoringUnit: v1beta1.PerformanceMonitoringUnitStandard}
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown performance monitoring unit",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "c3-standard-8"
+				spec.AdvancedMachineFeatures = &v1beta1.GCPAdvancedMachineFeatures{PerformanceMonitoringUnit: "FULL"}
+			},
+			expectErr: true,
+		},
+		{
+			name: "confidential instance type",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "n2d-standard-4"
+				spec.ConfidentialInstanceType = v1beta1.ConfidentialInstanceTypeSEVSNP
+			},
+			expectErr: false,
+		},
+		{
+			name: "TDX on AMD machine type",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "n2d-standard-4"
+				spec.ConfidentialInstanceType = v1beta1.ConfidentialInstanceTypeTDX
+			},
+			expectErr: true,
+		},
+		{
+			name: "SEV on Intel machine type",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "c3-standard-8"
+				spec.ConfidentialInstanceType = v1beta1.ConfidentialInstanceTypeSEV
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown confidential instance type",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.MachineType = "n2d-standard-4"
+				spec.ConfidentialInstanceType = "SGX"
+			},
+			expectErr: true,
+		},
+		{
+			name: "windows startup script",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.OSFamily = v1beta1.OSFamilyWindows
+				spec.UserDataFormat = v1beta1.UserDataFormatSysprepSpecializeScript
+			},
+			expectErr: false,
+		},
+		{
+			name: "windows with ignition",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.OSFamily = v1beta1.OSFamilyWindows
+				spec.UserDataFormat = v1beta1.UserDataFormatIgnition
+			},
+			expectErr: true,
+		},
+		{
+			name: "windows with ignition config source",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.OSFamily = v1beta1.OSFamilyWindows
+				spec.IgnitionConfigSource = "https://api-int.example.com:22623/config/worker"
+			},
+			expectErr: true,
+		},
+		{
+			name: "linux with windows startup script",
+			mutate: func(spec *v1beta1.GCPMachineProviderSpec) {
+				spec.UserDataFormat = v1beta1.UserDataFormatWindowsStartupScript
+			},
+			expectErr: true,
+		},
+		{
+			name:      "unknown os family",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.OSFamily = "Plan9" },
+			expectErr: true,
+		},
+		{
+			name:      "unknown user data format",
+			mutate:    func(spec *v1beta1.GCPMachineProviderSpec) { spec.UserDataFormat = "Kickstart" },
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := validSpec()
+			tc.mutate(spec)
+			errs := ValidateGCPMachineProviderSpec(spec, field.NewPath("spec"))
+			if tc.expectErr && len(errs) == 0 {
+				t.Errorf("expected validation errors, got none")
+			}
+			if !tc.expectErr && len(errs) != 0 {
+				t.Errorf("expected no validation errors, got: %v", errs)
+			}
+		})
+	}
+}
+
+func TestValidateImageArchitectureHints(t *testing.T) {
+	testCases := []struct {
+		name        string
+		machineType string
+		image       string
+		expectErr   bool
+	}{
+		{
+			name:        "arm64 image on arm64 machine type",
+			machineType: "t2a-standard-4",
+			image:       "projects/rhcos-cloud/global/images/rhcos-412-86-202212081411-0-gcp-aarch64",
+		},
+		{
+			name:        "x86_64 image on arm64 machine type",
+			machineType: "c4a-standard-8",
+			image:       "projects/rhcos-cloud/global/images/rhcos-412-86-202212081411-0-gcp-x86-64",
+			expectErr:   true,
+		},
+		{
+			name:        "arm64 image family on x86_64 machine type",
+			machineType: "n1-standard-4",
+			image:       "projects/debian-cloud/global/images/family/debian-12-arm64",
+			expectErr:   true,
+		},
+		{
+			name:        "image name without architecture",
+			machineType: "c4a-standard-8",
+			image:       "rhcos",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := validSpec()
+			spec.MachineType = tc.machineType
+			spec.Disks[0].Image = tc.image
+			errs := ValidateImageArchitectureHints(spec, field.NewPath("spec", "providerSpec", "value"))
+			if tc.expectErr && len(errs) == 0 {
+				t.Errorf("expected an error, got none")
+			}
+			if !tc.expectErr && len(errs) > 0 {
+				t.Errorf("expected no error, got: %v", errs.ToAggregate())
+			}
+		})
+	}
+}
+
+func TestValidateGCPClusterProviderSpec(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mutate    func(spec *v1beta1.GCPClusterProviderSpec)
+		expectErr bool
+	}{
+		{
+			name:   "valid spec",
+			mutate: func(spec *v1beta1.GCPClusterProviderSpec) {},
+		},
+		{
+			name:      "missing region",
+			mutate:    func(spec *v1beta1.GCPClusterProviderSpec) { spec.Region = "" },
+			expectErr: true,
+		},
+		{
+			name:      "missing network",
+			mutate:    func(spec *v1beta1.GCPClusterProviderSpec) { spec.Network = "" },
+			expectErr: true,
+		},
+		{
+			name: "network URL",
+			mutate: func(spec *v1beta1.GCPClusterProviderSpec) {
+				spec.Network = "projects/my-project/global/networks/cluster"
+			},
+			expectErr: true,
+		},
+		{
+			name: "duplicate subnetwork",
+			mutate: func(spec *v1beta1.GCPClusterProviderSpec) {
+				spec.Subnetworks = append(spec.Subnetworks, v1beta1.GCPSubnetworkSpec{Name: "control-plane", CIDRBlock: "10.0.128.0/17"})
+			},
+			expectErr: true,
+		},
+		{
+			name:      "invalid subnetwork range",
+			mutate:    func(spec *v1beta1.GCPClusterProviderSpec) { spec.Subnetworks[0].CIDRBlock = "10.0.0.0" },
+			expectErr: true,
+		},
+		{
+			name:      "IPv6 subnetwork range",
+			mutate:    func(spec *v1beta1.GCPClusterProviderSpec) { spec.Subnetworks[0].CIDRBlock = "fd00::/64" },
+			expectErr: true,
+		},
+		{
+			name: "project metadata",
+			mutate: func(spec *v1beta1.GCPClusterProviderSpec) {
+				spec.ProjectMetadata = map[string]string{"enable-oslogin": "TRUE", "disable-legacy-endpoints": "TRUE"}
+			},
+		},
+		{
+			name: "invalid project metadata key",
+			mutate: func(spec *v1beta1.GCPClusterProviderSpec) {
+				spec.ProjectMetadata = map[string]string{"enable oslogin": "TRUE"}
+			},
+			expectErr: true,
+		},
+		{
+			name: "firewall rule without source",
+			mutate: func(spec *v1beta1.GCPClusterProviderSpec) {
+				spec.FirewallRules = []*v1beta1.GCPFirewallRule{{Name: "ssh", Ports: []string{"22"}}}
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &v1beta1.GCPClusterProviderSpec{
+				Region:  "us-east1",
+				Network: "cluster",
+				Subnetworks: []v1beta1.GCPSubnetworkSpec{
+					{Name: "control-plane", CIDRBlock: "10.0.0.0/17"},
+					{Name: "compute", CIDRBlock: "10.0.128.0/17"},
+				},
+				CloudNAT: true,
+			}
+			tc.mutate(spec)
+			errs := ValidateGCPClusterProviderSpec(spec, field.NewPath("spec"))
+			if tc.expectErr && len(errs) == 0 {
+				t.Errorf("expected validation errors, got none")
+			}
+			if !tc.expectErr && len(errs) != 0 {
+				t.Errorf("expected no validation errors, got: %v", errs)
+			}
+		})
+	}
+}
@@ -25,6 +25,112 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPAdvancedMachineFeatures) DeepCopyInto(out *GCPAdvancedMachineFeatures) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPAdvancedMachineFeatures.
+func (in *GCPAdvancedMachineFeatures) DeepCopy() *GCPAdvancedMachineFeatures {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPAdvancedMachineFeatures)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPClusterProviderSpec) DeepCopyInto(out *GCPClusterProviderSpec) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Subnetworks != nil {
+		in, out := &in.Subnetworks, &out.Subnetworks
+		*out = make([]GCPSubnetworkSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.FirewallRules != nil {
+		in, out := &in.FirewallRules, &out.FirewallRules
+		*out = make([]*GCPFirewallRule, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(GCPFirewallRule)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.ProjectMetadata != nil {
+		in, out := &in.ProjectMetadata, &out.ProjectMetadata
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPClusterProviderSpec.
+func (in *GCPClusterProviderSpec) DeepCopy() *GCPClusterProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPClusterProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GCPClusterProviderSpec) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPClusterProviderStatus) DeepCopyInto(out *GCPClusterProviderStatus) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Subnetworks != nil {
+		in, out := &in.Subnetworks, &out.Subnetworks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProjectMetadataKeys != nil {
+		in, out := &in.ProjectMetadataKeys, &out.ProjectMetadataKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPClusterProviderStatus.
+func (in *GCPClusterProviderStatus) DeepCopy() *GCPClusterProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPClusterProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GCPClusterProviderStatus) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCPDisk) DeepCopyInto(out *GCPDisk) {
 	*out = *in
@@ -48,6 +154,103 @@ func (in *GCPDisk) DeepCopy() *GCPDisk {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPFirewallRule) DeepCopyInto(out *GCPFirewallRule) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SourceRanges != nil {
+		in, out := &in.SourceRanges, &out.SourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SourceTags != nil {
+		in, out := &in.SourceTags, &out.SourceTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPFirewallRule.
+func (in *GCPFirewallRule) DeepCopy() *GCPFirewallRule {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPFirewallRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPGPUConfig) DeepCopyInto(out *GCPGPUConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPGPUConfig.
+func (in *GCPGPUConfig) DeepCopy() *GCPGPUConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPGPUConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPHealthCheck) DeepCopyInto(out *GCPHealthCheck) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPHealthCheck.
+func (in *GCPHealthCheck) DeepCopy() *GCPHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPLastOperation) DeepCopyInto(out *GCPLastOperation) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPLastOperation.
+func (in *GCPLastOperation) DeepCopy() *GCPLastOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPLastOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPMachineProviderCondition) DeepCopyInto(out *GCPMachineProviderCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMachineProviderCondition.
+func (in *GCPMachineProviderCondition) DeepCopy() *GCPMachineProviderCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPMachineProviderCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCPMachineProviderSpec) DeepCopyInto(out *GCPMachineProviderSpec) {
 	*out = *in
@@ -99,7 +302,7 @@ func (in *GCPMachineProviderSpec) DeepCopyInto(out *GCPMachineProviderSpec) {
 			if (*in)[i] != nil {
 				in, out := &(*in)[i], &(*out)[i]
 				*out = new(GCPNetworkInterface)
-				**out = **in
+				(*in).DeepCopyInto(*out)
 			}
 		}
 	}
@@ -115,6 +318,59 @@ func (in *GCPMachineProviderSpec) DeepCopyInto(out *GCPMachineProviderSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ResourceManagerTags != nil {
+		in, out := &in.ResourceManagerTags, &out.ResourceManagerTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SSHKeys != nil {
+		in, out := &in.SSHKeys, &out.SSHKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SSHKeySecret != nil {
+		in, out := &in.SSHKeySecret, &out.SSHKeySecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.TargetPools != nil {
+		in, out := &in.TargetPools, &out.TargetPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetInstanceGroups != nil {
+		in, out := &in.TargetInstanceGroups, &out.TargetInstanceGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FirewallRules != nil {
+		in, out := &in.FirewallRules, &out.FirewallRules
+		*out = make([]*GCPFirewallRule, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(GCPFirewallRule)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(GCPHealthCheck)
+		**out = **in
+	}
+	if in.GPUs != nil {
+		in, out := &in.GPUs, &out.GPUs
+		*out = make([]GCPGPUConfig, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdvancedMachineFeatures != nil {
+		in, out := &in.AdvancedMachineFeatures, &out.AdvancedMachineFeatures
+		*out = new(GCPAdvancedMachineFeatures)
+		**out = **in
+	}
 	return
 }
 
@@ -141,6 +397,53 @@ func (in *GCPMachineProviderStatus) DeepCopyInto(out *GCPMachineProviderStatus)
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.PendingOperation != nil {
+		in, out := &in.PendingOperation, &out.PendingOperation
+		*out = new(GCPOperation)
+		**out = **in
+	}
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AttachedDisks != nil {
+		in, out := &in.AttachedDisks, &out.AttachedDisks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetPools != nil {
+		in, out := &in.TargetPools, &out.TargetPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceGroups != nil {
+		in, out := &in.InstanceGroups, &out.InstanceGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastSnapshot != nil {
+		in, out := &in.LastSnapshot, &out.LastSnapshot
+		*out = new(GCPSnapshot)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]GCPMachineProviderCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastOperation != nil {
+		in, out := &in.LastOperation, &out.LastOperation
+		*out = new(GCPLastOperation)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -170,6 +473,11 @@ func (in *GCPMetadata) DeepCopyInto(out *GCPMetadata) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(GCPMetadataValueSource)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -183,9 +491,40 @@ func (in *GCPMetadata) DeepCopy() *GCPMetadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPMetadataValueSource) DeepCopyInto(out *GCPMetadataValueSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMetadataValueSource.
+func (in *GCPMetadataValueSource) DeepCopy() *GCPMetadataValueSource {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPMetadataValueSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCPNetworkInterface) DeepCopyInto(out *GCPNetworkInterface) {
 	*out = *in
+	if in.PublicIP != nil {
+		in, out := &in.PublicIP, &out.PublicIP
+		*out = new(bool)
+		**out = **in
+	}
 	return
 }
 
@@ -199,6 +538,22 @@ func (in *GCPNetworkInterface) DeepCopy() *GCPNetworkInterface {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPOperation) DeepCopyInto(out *GCPOperation) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPOperation.
+func (in *GCPOperation) DeepCopy() *GCPOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCPServiceAccount) DeepCopyInto(out *GCPServiceAccount) {
 	*out = *in
@@ -219,3 +574,35 @@ func (in *GCPServiceAccount) DeepCopy() *GCPServiceAccount {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPSnapshot) DeepCopyInto(out *GCPSnapshot) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPSnapshot.
+func (in *GCPSnapshot) DeepCopy() *GCPSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPSubnetworkSpec) DeepCopyInto(out *GCPSubnetworkSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPSubnetworkSpec.
+func (in *GCPSubnetworkSpec) DeepCopy() *GCPSubnetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPSubnetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	clusterv1 "github.com/openshift/cluster-api/pkg/apis/cluster/v1alpha1"
+	apicorev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// log is the structured logger of the cluster actuator.
+var log = logf.Log.WithName("gcp-cluster-actuator")
+
+// ComputeServiceBuilder returns a compute service authenticated with the content of a credentials secret,
+// or the Application Default Credentials when empty, along with the project of the credentials.
+type ComputeServiceBuilder func(serviceAccountJSON string) (computeservice.GCPComputeService, string, error)
+
+// Actuator is responsible for reconciling the cluster-scoped GCP infrastructure of clusters.
+type Actuator struct {
+	coreClient            controllerclient.Client
+	eventRecorder         record.EventRecorder
+	computeServiceBuilder ComputeServiceBuilder
+}
+
+// ActuatorParams holds parameter information for Actuator.
+type ActuatorParams struct {
+	CoreClient controllerclient.Client
+	// EventRecorder records events on clusters.
+	EventRecorder record.EventRecorder
+	// ComputeServiceBuilder builds the compute service of the credentials of a cluster.
+	ComputeServiceBuilder ComputeServiceBuilder
+}
+
+// NewActuator returns an actuator.
+func NewActuator(params ActuatorParams) *Actuator {
+	return &Actuator{
+		coreClient:            params.CoreClient,
+		eventRecorder:         params.EventRecorder,
+		computeServiceBuilder: params.ComputeServiceBuilder,
+	}
+}
+
+// Reconcile creates or updates the network, subnetworks, router and firewall rules of the cluster
+// and is invoked by the cluster controller. Clusters without provider spec are ignored.
+func (a *Actuator) Reconcile(cluster *clusterv1.Cluster) error {
+	logger := log.WithValues("operation", "reconcile", "cluster", cluster.Name, "namespace", cluster.Namespace)
+	if cluster.Spec.ProviderSpec.Value == nil {
+		logger.V(2).Info("Skipping cluster without provider spec")
+		return nil
+	}
+	logger.Info("Reconciling cluster")
+	scope, err := newClusterScope(clusterScopeParams{
+		Context:               context.Background(),
+		coreClient:            a.coreClient,
+		cluster:               cluster,
+		logger:                logger,
+		computeServiceBuilder: a.computeServiceBuilder,
+	})
+	if err != nil {
+		logger.Error(err, "Failed to create cluster scope")
+		a.recordFailure(cluster, "FailedReconcile", err)
+		return fmt.Errorf("failed to create scope for cluster %q: %v", cluster.Name, err)
+	}
+	defer scope.Close()
+	err = newReconciler(scope, a.eventRecorder).reconcile()
+	scope.providerStatus.Ready = err == nil
+	if err != nil {
+		scope.logger.Error(err, "Failed to reconcile cluster")
+		a.recordFailure(cluster, "FailedReconcile", err)
+		return err
+	}
+	return nil
+}
+
+// Delete deletes the network, subnetworks, router and firewall rules created for the cluster and is
+// invoked by the cluster controller. Resources the actuator did not create are left in place.
+func (a *Actuator) Delete(cluster *clusterv1.Cluster) error {
+	logger := log.WithValues("operation", "delete", "cluster", cluster.Name, "namespace", cluster.Namespace)
+	if cluster.Spec.ProviderSpec.Value == nil {
+		return nil
+	}
+	logger.Info("Deleting cluster")
+	scope, err := newClusterScope(clusterScopeParams{
+		Context:               context.Background(),
+		coreClient:            a.coreClient,
+		cluster:               cluster,
+		logger:                logger,
+		computeServiceBuilder: a.computeServiceBuilder,
+	})
+	if err != nil {
+		logger.Error(err, "Failed to create cluster scope")
+		a.recordFailure(cluster, "FailedDelete", err)
+		return fmt.Errorf("failed to create scope for cluster %q: %v", cluster.Name, err)
+	}
+	if err := newReconciler(scope, a.eventRecorder).delete(); err != nil {
+		scope.logger.Error(err, "Failed to delete cluster")
+		a.recordFailure(cluster, "FailedDelete", err)
+		return err
+	}
+	return nil
+}
+
+// recordFailure records a warning event on the cluster.
+func (a *Actuator) recordFailure(cluster *clusterv1.Cluster, reason string, err error) {
+	if a.eventRecorder == nil {
+		return
+	}
+	a.eventRecorder.Event(cluster, apicorev1.EventTypeWarning, reason, err.Error())
+}
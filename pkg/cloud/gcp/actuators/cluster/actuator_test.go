@@ -0,0 +1,275 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	clusterapis "github.com/openshift/cluster-api/pkg/apis"
+	clusterv1 "github.com/openshift/cluster-api/pkg/apis/cluster/v1alpha1"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestCluster(t *testing.T, spec *v1beta1.GCPClusterProviderSpec) *clusterv1.Cluster {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: clusterv1.ClusterSpec{
+			ProviderSpec: clusterv1.ProviderSpec{Value: &runtime.RawExtension{Raw: raw}},
+		},
+	}
+}
+
+func newTestActuator(t *testing.T, mockComputeService *computeservice.GCPComputeServiceMock, cluster *clusterv1.Cluster) (*Actuator, controllerclient.Client, *record.FakeRecorder) {
+	clusterScheme := runtime.NewScheme()
+	if err := scheme.AddToScheme(clusterScheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterapis.AddToScheme(clusterScheme); err != nil {
+		t.Fatal(err)
+	}
+	client := controllerfake.NewFakeClientWithScheme(clusterScheme, cluster)
+	recorder := record.NewFakeRecorder(20)
+	actuator := NewActuator(ActuatorParams{
+		CoreClient:    client,
+		EventRecorder: recorder,
+		ComputeServiceBuilder: func(serviceAccountJSON string) (computeservice.GCPComputeService, string, error) {
+			return mockComputeService, "my-project", nil
+		},
+	})
+	return actuator, client, recorder
+}
+
+func testClusterSpec() *v1beta1.GCPClusterProviderSpec {
+	return &v1beta1.GCPClusterProviderSpec{
+		Region:  "us-east1",
+		Network: "cluster",
+		Subnetworks: []v1beta1.GCPSubnetworkSpec{
+			{Name: "control-plane", CIDRBlock: "10.0.0.0/17"},
+			{Name: "compute", CIDRBlock: "10.0.128.0/17", PrivateGoogleAccess: true},
+		},
+		CloudNAT: true,
+		FirewallRules: []*v1beta1.GCPFirewallRule{
+			{Name: "cluster-api", Ports: []string{"6443"}, SourceRanges: []string{"0.0.0.0/0"}},
+		},
+	}
+}
+
+func TestReconcileAndDelete(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	cluster := newTestCluster(t, testClusterSpec())
+	actuator, client, recorder := newTestActuator(t, mockComputeService, cluster)
+	ctx := context.TODO()
+
+	if err := actuator.Reconcile(cluster); err != nil {
+		t.Fatalf("unexpected error reconciling cluster: %v", err)
+	}
+
+	network, err := mockComputeService.NetworksGet(ctx, "my-project", "cluster")
+	if err != nil {
+		t.Fatalf("expected network to be created: %v", err)
+	}
+	if network.AutoCreateSubnetworks {
+		t.Errorf("expected network in custom subnet mode")
+	}
+	subnetwork, err := mockComputeService.SubnetworksGet(ctx, "my-project", "us-east1", "compute")
+	if err != nil {
+		t.Fatalf("expected subnetwork to be created: %v", err)
+	}
+	if subnetwork.Network != network.SelfLink || subnetwork.IpCidrRange != "10.0.128.0/17" || !subnetwork.PrivateIpGoogleAccess {
+		t.Errorf("unexpected subnetwork: %+v", subnetwork)
+	}
+	router, err := mockComputeService.RoutersGet(ctx, "my-project", "us-east1", "cluster-router")
+	if err != nil {
+		t.Fatalf("expected router to be created: %v", err)
+	}
+	if len(router.Nats) != 1 || router.Nats[0].Name != "cluster-nat" {
+		t.Errorf("expected router with Cloud NAT gateway, got: %+v", router.Nats)
+	}
+	internal := mockComputeService.Firewall("my-project", "cluster-allow-internal")
+	if internal == nil {
+		t.Fatalf("expected internal firewall rule to be created")
+	}
+	sourceRanges := append([]string{}, internal.SourceRanges...)
+	sort.Strings(sourceRanges)
+	if !reflect.DeepEqual(sourceRanges, []string{"10.0.0.0/17", "10.0.128.0/17"}) {
+		t.Errorf("unexpected internal firewall rule source ranges: %v", internal.SourceRanges)
+	}
+	if mockComputeService.Firewall("my-project", "cluster-api") == nil {
+		t.Errorf("expected firewall rule of the provider spec to be created")
+	}
+	if len(recorder.Events) != 4 {
+		t.Errorf("expected 4 created events, got %d", len(recorder.Events))
+	}
+
+	updated := &clusterv1.Cluster{}
+	if err := client.Get(ctx, controllerclient.ObjectKey{Namespace: "default", Name: "test"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	status, err := providerStatusFromRawExtension(updated.Status.ProviderStatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Ready || status.Network != network.SelfLink || len(status.Subnetworks) != 2 || status.Router != router.SelfLink {
+		t.Errorf("unexpected provider status: %+v", status)
+	}
+
+	// Reconciling again is a no-op.
+	if err := actuator.Reconcile(updated); err != nil {
+		t.Fatalf("unexpected error reconciling cluster again: %v", err)
+	}
+
+	if err := actuator.Delete(updated); err != nil {
+		t.Fatalf("unexpected error deleting cluster: %v", err)
+	}
+	if _, err := mockComputeService.NetworksGet(ctx, "my-project", "cluster"); !isNotFoundError(err) {
+		t.Errorf("expected network to be deleted, got: %v", err)
+	}
+	if _, err := mockComputeService.SubnetworksGet(ctx, "my-project", "us-east1", "compute"); !isNotFoundError(err) {
+		t.Errorf("expected subnetwork to be deleted, got: %v", err)
+	}
+	if _, err := mockComputeService.RoutersGet(ctx, "my-project", "us-east1", "cluster-router"); !isNotFoundError(err) {
+		t.Errorf("expected router to be deleted, got: %v", err)
+	}
+	if mockComputeService.Firewall("my-project", "cluster-allow-internal") != nil {
+		t.Errorf("expected internal firewall rule to be deleted")
+	}
+}
+
+func TestDeleteKeepsExistingNetwork(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	ctx := context.TODO()
+	if _, err := mockComputeService.NetworksInsert(ctx, "my-project", &compute.Network{Name: "cluster"}); err != nil {
+		t.Fatal(err)
+	}
+	cluster := newTestCluster(t, testClusterSpec())
+	actuator, _, _ := newTestActuator(t, mockComputeService, cluster)
+
+	if err := actuator.Reconcile(cluster); err != nil {
+		t.Fatalf("unexpected error reconciling cluster: %v", err)
+	}
+	if err := actuator.Delete(cluster); err != nil {
+		t.Fatalf("unexpected error deleting cluster: %v", err)
+	}
+	if _, err := mockComputeService.NetworksGet(ctx, "my-project", "cluster"); err != nil {
+		t.Errorf("expected existing network to be kept, got: %v", err)
+	}
+	if _, err := mockComputeService.SubnetworksGet(ctx, "my-project", "us-east1", "compute"); !isNotFoundError(err) {
+		t.Errorf("expected subnetwork to be deleted, got: %v", err)
+	}
+}
+
+func TestReconcileWithoutProviderSpec(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	actuator, _, _ := newTestActuator(t, mockComputeService, cluster)
+	if err := actuator.Reconcile(cluster); err != nil {
+		t.Errorf("unexpected error reconciling cluster without provider spec: %v", err)
+	}
+	if _, err := mockComputeService.NetworksGet(context.TODO(), "my-project", "cluster"); !isNotFoundError(err) {
+		t.Errorf("expected no network to be created, got: %v", err)
+	}
+}
+
+func TestReconcileInvalidProviderSpec(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	spec := testClusterSpec()
+	spec.Network = ""
+	cluster := newTestCluster(t, spec)
+	actuator, _, recorder := newTestActuator(t, mockComputeService, cluster)
+	if err := actuator.Reconcile(cluster); err == nil {
+		t.Errorf("expected error reconciling cluster with invalid provider spec")
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a FailedReconcile event, got %d events", len(recorder.Events))
+	}
+}
+
+func TestReconcileProjectMetadata(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	ctx := context.TODO()
+	otherValue := "other"
+	if _, err := mockComputeService.ProjectsSetCommonInstanceMetadata(ctx, "my-project", &compute.Metadata{
+		Items: []*compute.MetadataItems{{Key: "other", Value: &otherValue}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	spec := testClusterSpec()
+	spec.ProjectMetadata = map[string]string{
+		"enable-oslogin":           "TRUE",
+		"disable-legacy-endpoints": "TRUE",
+	}
+	cluster := newTestCluster(t, spec)
+	actuator, client, _ := newTestActuator(t, mockComputeService, cluster)
+
+	metadata := func() map[string]string {
+		project, err := mockComputeService.ProjectsGet(ctx, "my-project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		items := map[string]string{}
+		for _, item := range project.CommonInstanceMetadata.Items {
+			items[item.Key] = *item.Value
+		}
+		return items
+	}
+	reconcile := func(spec *v1beta1.GCPClusterProviderSpec) {
+		updated := &clusterv1.Cluster{}
+		if err := client.Get(ctx, controllerclient.ObjectKey{Namespace: "default", Name: "test"}, updated); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := json.Marshal(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: raw}
+		if err := actuator.Reconcile(updated); err != nil {
+			t.Fatalf("unexpected error reconciling cluster: %v", err)
+		}
+	}
+
+	reconcile(spec)
+	if got := metadata(); len(got) != 3 || got["enable-oslogin"] != "TRUE" || got["disable-legacy-endpoints"] != "TRUE" || got["other"] != "other" {
+		t.Errorf("unexpected project metadata: %v", got)
+	}
+
+	// Reconciling the same metadata again does not update the project.
+	reconcile(spec)
+	if updates := mockComputeService.ProjectMetadataUpdates("my-project"); len(updates) != 2 {
+		t.Errorf("expected a single update of the project metadata, got %d", len(updates)-1)
+	}
+
+	delete(spec.ProjectMetadata, "enable-oslogin")
+	spec.ProjectMetadata["disable-legacy-endpoints"] = "FALSE"
+	reconcile(spec)
+	if got := metadata(); len(got) != 2 || got["disable-legacy-endpoints"] != "FALSE" || got["other"] != "other" {
+		t.Errorf("unexpected project metadata after removing a key: %v", got)
+	}
+	if fingerprint := mockComputeService.ProjectMetadataUpdates("my-project")[2].Fingerprint; fingerprint != "fingerprint-2" {
+		t.Errorf("expected the update to carry the fingerprint of the previous metadata, got %q", fingerprint)
+	}
+
+	updated := &clusterv1.Cluster{}
+	if err := client.Get(ctx, controllerclient.ObjectKey{Namespace: "default", Name: "test"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := actuator.Delete(updated); err != nil {
+		t.Fatalf("unexpected error deleting cluster: %v", err)
+	}
+	if got := metadata(); len(got) != 1 || got["other"] != "other" {
+		t.Errorf("expected only unmanaged project metadata to be left, got: %v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1/validation"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	clusterv1 "github.com/openshift/cluster-api/pkg/apis/cluster/v1alpha1"
+	apicorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const credentialsSecretKey = "serviceAccountJSON"
+
+// clusterScopeParams defines the input parameters used to create a new clusterScope.
+type clusterScopeParams struct {
+	context.Context
+
+	coreClient controllerclient.Client
+	cluster    *clusterv1.Cluster
+	// logger is the logger of the actuator operation, the scope attaches the project and region to it.
+	logger                logr.Logger
+	computeServiceBuilder ComputeServiceBuilder
+}
+
+// clusterScope defines a scope defined around a cluster.
+type clusterScope struct {
+	context.Context
+
+	coreClient     controllerclient.Client
+	projectID      string
+	computeService computeservice.GCPComputeService
+	cluster        *clusterv1.Cluster
+	providerSpec   *v1beta1.GCPClusterProviderSpec
+	providerStatus *v1beta1.GCPClusterProviderStatus
+	// logger has the cluster, namespace, operation, project and region attached.
+	logger logr.Logger
+}
+
+// newClusterScope creates a new clusterScope from the supplied parameters.
+// This is meant to be called for each cluster actuator operation.
+func newClusterScope(params clusterScopeParams) (*clusterScope, error) {
+	providerSpec, err := providerSpecFromRawExtension(params.cluster.Spec.ProviderSpec.Value)
+	if err != nil {
+		return nil, err
+	}
+	if errs := validation.ValidateGCPClusterProviderSpec(providerSpec, field.NewPath("spec", "providerSpec", "value")); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid cluster provider spec: %v", errs.ToAggregate())
+	}
+	providerStatus, err := providerStatusFromRawExtension(params.cluster.Status.ProviderStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceAccountJSON, err := getCredentialsSecret(params.Context, params.coreClient, params.cluster, providerSpec)
+	if err != nil {
+		return nil, err
+	}
+	computeService, projectID, err := params.computeServiceBuilder(serviceAccountJSON)
+	if err != nil {
+		return nil, err
+	}
+	if providerSpec.ProjectID != "" {
+		projectID = providerSpec.ProjectID
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("unable to determine the GCP project, set projectID in the provider spec")
+	}
+
+	return &clusterScope{
+		Context:        params.Context,
+		coreClient:     params.coreClient,
+		projectID:      projectID,
+		computeService: computeService,
+		cluster:        params.cluster,
+		providerSpec:   providerSpec,
+		providerStatus: providerStatus,
+		logger:         params.logger.WithValues("project", projectID, "region", providerSpec.Region),
+	}, nil
+}
+
+// Close the clusterScope by updating the cluster provider status if it changed.
+func (s *clusterScope) Close() {
+	// Do not write an empty provider status to clusters which never had one.
+	if s.cluster.Status.ProviderStatus == nil && reflect.DeepEqual(*s.providerStatus, v1beta1.GCPClusterProviderStatus{}) {
+		return
+	}
+	s.providerStatus.APIVersion = v1beta1.SchemeGroupVersion.String()
+	s.providerStatus.Kind = "GCPClusterProviderStatus"
+	raw, err := json.Marshal(s.providerStatus)
+	if err != nil {
+		s.logger.Error(err, "Failed to encode cluster provider status")
+		return
+	}
+	if s.cluster.Status.ProviderStatus != nil && bytes.Equal(s.cluster.Status.ProviderStatus.Raw, raw) {
+		return
+	}
+	s.cluster.Status.ProviderStatus = &runtime.RawExtension{Raw: raw}
+	if err := s.coreClient.Status().Update(s.Context, s.cluster); err != nil {
+		s.logger.Error(err, "Failed to update cluster provider status")
+	}
+}
+
+func providerSpecFromRawExtension(rawExtension *runtime.RawExtension) (*v1beta1.GCPClusterProviderSpec, error) {
+	providerSpec := &v1beta1.GCPClusterProviderSpec{}
+	if err := yaml.Unmarshal(rawExtension.Raw, providerSpec); err != nil {
+		return nil, fmt.Errorf("error unmarshalling providerSpec: %v", err)
+	}
+	return providerSpec, nil
+}
+
+// providerStatusFromRawExtension decodes the cluster provider status, which is empty until first set.
+func providerStatusFromRawExtension(rawExtension *runtime.RawExtension) (*v1beta1.GCPClusterProviderStatus, error) {
+	providerStatus := &v1beta1.GCPClusterProviderStatus{}
+	if rawExtension == nil || len(rawExtension.Raw) == 0 {
+		return providerStatus, nil
+	}
+	if err := yaml.Unmarshal(rawExtension.Raw, providerStatus); err != nil {
+		return nil, fmt.Errorf("error unmarshalling providerStatus: %v", err)
+	}
+	return providerStatus, nil
+}
+
+// getCredentialsSecret returns the service account JSON of the credentials secret of the cluster,
+// empty when the cluster has no credentials secret.
+func getCredentialsSecret(ctx context.Context, coreClient controllerclient.Client, cluster *clusterv1.Cluster, spec *v1beta1.GCPClusterProviderSpec) (string, error) {
+	if spec.CredentialsSecret == nil {
+		return "", nil
+	}
+	var credentialsSecret apicorev1.Secret
+	if err := coreClient.Get(ctx, controllerclient.ObjectKey{Namespace: cluster.Namespace, Name: spec.CredentialsSecret.Name}, &credentialsSecret); err != nil {
+		return "", fmt.Errorf("error getting credentials secret %q in namespace %q: %v", spec.CredentialsSecret.Name, cluster.Namespace, err)
+	}
+	data, exists := credentialsSecret.Data[credentialsSecretKey]
+	if !exists {
+		return "", fmt.Errorf("secret %v/%v does not have %q field set", cluster.Namespace, spec.CredentialsSecret.Name, credentialsSecretKey)
+	}
+	return string(data), nil
+}
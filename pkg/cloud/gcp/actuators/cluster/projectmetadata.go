@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// ensureProjectMetadata sets the project metadata of the provider spec on the project and removes the keys
+// the controller set before which were removed from the provider spec since.
+func (r *Reconciler) ensureProjectMetadata() error {
+	var keys []string
+	for key := range r.providerSpec.ProjectMetadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var removed []string
+	for _, key := range r.providerStatus.ProjectMetadataKeys {
+		if _, ok := r.providerSpec.ProjectMetadata[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	if err := r.updateProjectMetadata(r.providerSpec.ProjectMetadata, removed); err != nil {
+		return err
+	}
+	r.providerStatus.ProjectMetadataKeys = keys
+	return nil
+}
+
+// deleteProjectMetadata removes the project metadata set by the controller from the project.
+func (r *Reconciler) deleteProjectMetadata() error {
+	if err := r.updateProjectMetadata(nil, r.providerStatus.ProjectMetadataKeys); err != nil {
+		return err
+	}
+	r.providerStatus.ProjectMetadataKeys = nil
+	return nil
+}
+
+// updateProjectMetadata sets the desired items and removes the removed keys from the common instance
+// metadata of the project, when it differs. The metadata fingerprint makes the update fail rather than
+// overwrite concurrent changes, the next reconcile then retries.
+func (r *Reconciler) updateProjectMetadata(desired map[string]string, removed []string) error {
+	if len(desired) == 0 && len(removed) == 0 {
+		return nil
+	}
+	project, err := r.computeService.ProjectsGet(r.Context, r.projectID)
+	if err != nil {
+		return fmt.Errorf("error getting project %q: %v", r.projectID, err)
+	}
+	updated := &compute.Metadata{}
+	if project.CommonInstanceMetadata != nil {
+		updated.Fingerprint = project.CommonInstanceMetadata.Fingerprint
+	}
+
+	changed := false
+	found := map[string]bool{}
+	if project.CommonInstanceMetadata != nil {
+		for _, item := range project.CommonInstanceMetadata.Items {
+			if hasString(removed, item.Key) {
+				changed = true
+				continue
+			}
+			if value, ok := desired[item.Key]; ok {
+				found[item.Key] = true
+				if item.Value == nil || *item.Value != value {
+					item = &compute.MetadataItems{Key: item.Key, Value: stringPointer(value)}
+					changed = true
+				}
+			}
+			updated.Items = append(updated.Items, item)
+		}
+	}
+	var missing []string
+	for key := range desired {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		updated.Items = append(updated.Items, &compute.MetadataItems{Key: key, Value: stringPointer(desired[key])})
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	operation, err := r.computeService.ProjectsSetCommonInstanceMetadata(r.Context, r.projectID, updated)
+	if err != nil {
+		return fmt.Errorf("error updating metadata of project %q: %v", r.projectID, err)
+	}
+	if err := r.waitUntilGlobalOperationCompleted(operation.Name); err != nil {
+		return fmt.Errorf("error updating metadata of project %q: %v", r.projectID, err)
+	}
+	r.logger.Info("Updated project metadata", "removedKeys", removed)
+	return nil
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
@@ -0,0 +1,458 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	apicorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	operationTimeOut   = 180 * time.Second
+	operationRetryWait = 5 * time.Second
+
+	// managedDescription marks the resources created by the actuator, only those are deleted with the cluster.
+	managedDescription = "Managed by the GCP cluster controller"
+
+	defaultFirewallProtocol = "tcp"
+)
+
+// Reconciler reconciles the GCP infrastructure of a cluster.
+type Reconciler struct {
+	*clusterScope
+	eventRecorder record.EventRecorder
+}
+
+// newReconciler populates all the services based on input scope.
+func newReconciler(scope *clusterScope, eventRecorder record.EventRecorder) *Reconciler {
+	return &Reconciler{
+		clusterScope:  scope,
+		eventRecorder: eventRecorder,
+	}
+}
+
+// reconcile creates the infrastructure of the provider spec which does not exist and updates the parts which drifted.
+func (r *Reconciler) reconcile() error {
+	if err := r.ensureNetwork(); err != nil {
+		return err
+	}
+	if err := r.ensureSubnetworks(); err != nil {
+		return err
+	}
+	if err := r.ensureRouter(); err != nil {
+		return err
+	}
+	if err := r.ensureFirewallRules(); err != nil {
+		return err
+	}
+	return r.ensureProjectMetadata()
+}
+
+// delete deletes the infrastructure created by the actuator, in the reverse order of its creation.
+// Deleting the network fails while instances are still attached to it, the cluster controller then retries.
+func (r *Reconciler) delete() error {
+	if err := r.deleteProjectMetadata(); err != nil {
+		return err
+	}
+	for _, name := range r.firewallRuleNames() {
+		if err := r.deleteFirewall(name); err != nil {
+			return err
+		}
+	}
+	if err := r.deleteRouter(); err != nil {
+		return err
+	}
+	for _, subnetwork := range r.providerSpec.Subnetworks {
+		if err := r.deleteSubnetwork(subnetwork.Name); err != nil {
+			return err
+		}
+	}
+	return r.deleteNetwork()
+}
+
+func (r *Reconciler) networkURL() string {
+	return fmt.Sprintf("projects/%s/global/networks/%s", r.projectID, r.providerSpec.Network)
+}
+
+func (r *Reconciler) routerName() string {
+	return r.providerSpec.Network + "-router"
+}
+
+func (r *Reconciler) natName() string {
+	return r.providerSpec.Network + "-nat"
+}
+
+func (r *Reconciler) internalFirewallName() string {
+	return r.providerSpec.Network + "-allow-internal"
+}
+
+// ensureNetwork creates the network of the cluster in custom subnet mode when it does not exist.
+func (r *Reconciler) ensureNetwork() error {
+	network, err := r.computeService.NetworksGet(r.Context, r.projectID, r.providerSpec.Network)
+	if err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("error getting network %q: %v", r.providerSpec.Network, err)
+	}
+	if err != nil {
+		operation, err := r.computeService.NetworksInsert(r.Context, r.projectID, &compute.Network{
+			Name:                  r.providerSpec.Network,
+			Description:           managedDescription,
+			AutoCreateSubnetworks: false,
+			// Networks without autoCreateSubnetworks are legacy networks, which do not support subnetworks.
+			ForceSendFields: []string{"AutoCreateSubnetworks"},
+		})
+		if err != nil {
+			return fmt.Errorf("error creating network %q: %v", r.providerSpec.Network, err)
+		}
+		r.logger.Info("Creating network", "network", r.providerSpec.Network, "gcpOperation", operation.Name)
+		if err := r.waitUntilGlobalOperationCompleted(operation.Name); err != nil {
+			return fmt.Errorf("error creating network %q: %v", r.providerSpec.Network, err)
+		}
+		r.recordCreated("network", r.providerSpec.Network)
+		if network, err = r.computeService.NetworksGet(r.Context, r.projectID, r.providerSpec.Network); err != nil {
+			return fmt.Errorf("error getting network %q: %v", r.providerSpec.Network, err)
+		}
+	}
+	r.providerStatus.Network = network.SelfLink
+	return nil
+}
+
+// ensureSubnetworks creates the subnetworks of the provider spec which do not exist.
+func (r *Reconciler) ensureSubnetworks() error {
+	var selfLinks []string
+	for _, spec := range r.providerSpec.Subnetworks {
+		subnetwork, err := r.computeService.SubnetworksGet(r.Context, r.projectID, r.providerSpec.Region, spec.Name)
+		if err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("error getting subnetwork %q: %v", spec.Name, err)
+		}
+		if err == nil {
+			// Subnetwork names are unique per region, not per network.
+			if subnetwork.Network != r.providerStatus.Network {
+				return fmt.Errorf("subnetwork %q exists in network %q rather than %q", spec.Name, subnetwork.Network, r.providerStatus.Network)
+			}
+			selfLinks = append(selfLinks, subnetwork.SelfLink)
+			continue
+		}
+		operation, err := r.computeService.SubnetworksInsert(r.Context, r.projectID, r.providerSpec.Region, &compute.Subnetwork{
+			Name:                  spec.Name,
+			Description:           managedDescription,
+			Network:               r.providerStatus.Network,
+			IpCidrRange:           spec.CIDRBlock,
+			PrivateIpGoogleAccess: spec.PrivateGoogleAccess,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating subnetwork %q: %v", spec.Name, err)
+		}
+		r.logger.Info("Creating subnetwork", "subnetwork", spec.Name, "gcpOperation", operation.Name)
+		if err := r.waitUntilRegionOperationCompleted(operation.Name); err != nil {
+			return fmt.Errorf("error creating subnetwork %q: %v", spec.Name, err)
+		}
+		r.recordCreated("subnetwork", spec.Name)
+		if subnetwork, err = r.computeService.SubnetworksGet(r.Context, r.projectID, r.providerSpec.Region, spec.Name); err != nil {
+			return fmt.Errorf("error getting subnetwork %q: %v", spec.Name, err)
+		}
+		selfLinks = append(selfLinks, subnetwork.SelfLink)
+	}
+	r.providerStatus.Subnetworks = selfLinks
+	return nil
+}
+
+// desiredNAT returns the Cloud NAT gateway translating the addresses of all the subnetworks of the network.
+func (r *Reconciler) desiredNAT() *compute.RouterNat {
+	return &compute.RouterNat{
+		Name:                          r.natName(),
+		NatIpAllocateOption:           "AUTO_ONLY",
+		SourceSubnetworkIpRangesToNat: "ALL_SUBNETWORKS_ALL_IP_RANGES",
+	}
+}
+
+// ensureRouter creates the router with the Cloud NAT gateway of the cluster when CloudNAT is set, and adds
+// the gateway to the router when it is missing. The router is deleted once CloudNAT is unset.
+func (r *Reconciler) ensureRouter() error {
+	if !r.providerSpec.CloudNAT {
+		r.providerStatus.Router = ""
+		return r.deleteRouter()
+	}
+	router, err := r.computeService.RoutersGet(r.Context, r.projectID, r.providerSpec.Region, r.routerName())
+	if err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("error getting router %q: %v", r.routerName(), err)
+	}
+
+	var operation *compute.Operation
+	switch {
+	case err != nil:
+		operation, err = r.computeService.RoutersInsert(r.Context, r.projectID, r.providerSpec.Region, &compute.Router{
+			Name:        r.routerName(),
+			Description: managedDescription,
+			Network:     r.providerStatus.Network,
+			Nats:        []*compute.RouterNat{r.desiredNAT()},
+		})
+		if err != nil {
+			return fmt.Errorf("error creating router %q: %v", r.routerName(), err)
+		}
+		r.logger.Info("Creating router", "router", r.routerName(), "gcpOperation", operation.Name)
+	case !hasNAT(router, r.natName()):
+		operation, err = r.computeService.RoutersPatch(r.Context, r.projectID, r.providerSpec.Region, r.routerName(), &compute.Router{
+			Nats: append(router.Nats, r.desiredNAT()),
+		})
+		if err != nil {
+			return fmt.Errorf("error adding Cloud NAT gateway %q to router %q: %v", r.natName(), r.routerName(), err)
+		}
+		r.logger.Info("Adding Cloud NAT gateway to router", "router", r.routerName(), "nat", r.natName(), "gcpOperation", operation.Name)
+	default:
+		r.providerStatus.Router = router.SelfLink
+		return nil
+	}
+	if err := r.waitUntilRegionOperationCompleted(operation.Name); err != nil {
+		return fmt.Errorf("error reconciling router %q: %v", r.routerName(), err)
+	}
+	if router == nil {
+		r.recordCreated("router", r.routerName())
+	}
+	if router, err = r.computeService.RoutersGet(r.Context, r.projectID, r.providerSpec.Region, r.routerName()); err != nil {
+		return fmt.Errorf("error getting router %q: %v", r.routerName(), err)
+	}
+	r.providerStatus.Router = router.SelfLink
+	return nil
+}
+
+func hasNAT(router *compute.Router, name string) bool {
+	for _, nat := range router.Nats {
+		if nat.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// desiredFirewalls returns the base firewall rule allowing the traffic between the subnetworks of the
+// cluster, followed by the firewall rules of the provider spec.
+func (r *Reconciler) desiredFirewalls() []*compute.Firewall {
+	var firewalls []*compute.Firewall
+	var internalRanges []string
+	for _, subnetwork := range r.providerSpec.Subnetworks {
+		internalRanges = append(internalRanges, subnetwork.CIDRBlock)
+	}
+	if len(internalRanges) > 0 {
+		firewalls = append(firewalls, &compute.Firewall{
+			Name:        r.internalFirewallName(),
+			Description: managedDescription,
+			Network:     r.networkURL(),
+			Direction:   "INGRESS",
+			Allowed: []*compute.FirewallAllowed{
+				{IPProtocol: "tcp"},
+				{IPProtocol: "udp"},
+				{IPProtocol: "icmp"},
+			},
+			SourceRanges: internalRanges,
+		})
+	}
+	for _, rule := range r.providerSpec.FirewallRules {
+		protocol := rule.Protocol
+		if protocol == "" {
+			protocol = defaultFirewallProtocol
+		}
+		firewalls = append(firewalls, &compute.Firewall{
+			Name:        rule.Name,
+			Description: managedDescription,
+			Network:     r.networkURL(),
+			Direction:   "INGRESS",
+			Allowed: []*compute.FirewallAllowed{
+				{
+					IPProtocol: protocol,
+					Ports:      rule.Ports,
+				},
+			},
+			SourceRanges: rule.SourceRanges,
+			SourceTags:   rule.SourceTags,
+		})
+	}
+	return firewalls
+}
+
+// firewallRuleNames returns the names of the firewall rules of the cluster.
+func (r *Reconciler) firewallRuleNames() []string {
+	names := []string{r.internalFirewallName()}
+	for _, rule := range r.providerSpec.FirewallRules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+// ensureFirewallRules creates the firewall rules of the cluster which do not exist and updates the ones
+// which drifted, e.g. after a subnetwork was added.
+func (r *Reconciler) ensureFirewallRules() error {
+	for _, desired := range r.desiredFirewalls() {
+		existing, err := r.computeService.FirewallsGet(r.Context, r.projectID, desired.Name)
+		if err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("error getting firewall rule %q: %v", desired.Name, err)
+		}
+
+		var operation *compute.Operation
+		switch {
+		case err != nil:
+			operation, err = r.computeService.FirewallsInsert(r.Context, r.projectID, desired)
+			if err != nil {
+				return fmt.Errorf("error creating firewall rule %q: %v", desired.Name, err)
+			}
+			r.logger.Info("Creating firewall rule", "firewall", desired.Name, "gcpOperation", operation.Name)
+		case !computeservice.FirewallUpToDate(existing, desired):
+			operation, err = r.computeService.FirewallsPatch(r.Context, r.projectID, desired.Name, &compute.Firewall{
+				Allowed:      desired.Allowed,
+				SourceRanges: desired.SourceRanges,
+				SourceTags:   desired.SourceTags,
+			})
+			if err != nil {
+				return fmt.Errorf("error updating firewall rule %q: %v", desired.Name, err)
+			}
+			r.logger.Info("Updating firewall rule", "firewall", desired.Name, "gcpOperation", operation.Name)
+		default:
+			continue
+		}
+		if err := r.waitUntilGlobalOperationCompleted(operation.Name); err != nil {
+			return fmt.Errorf("error reconciling firewall rule %q: %v", desired.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteFirewall deletes the firewall rule if it exists and was created by the actuator.
+func (r *Reconciler) deleteFirewall(name string) error {
+	firewall, err := r.computeService.FirewallsGet(r.Context, r.projectID, name)
+	if isNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting firewall rule %q: %v", name, err)
+	}
+	if firewall.Description != managedDescription {
+		r.logger.Info("Keeping firewall rule not created by the cluster controller", "firewall", name)
+		return nil
+	}
+	operation, err := r.computeService.FirewallsDelete(r.Context, r.projectID, name)
+	if err != nil {
+		return fmt.Errorf("error deleting firewall rule %q: %v", name, err)
+	}
+	r.logger.Info("Deleting firewall rule", "firewall", name, "gcpOperation", operation.Name)
+	return r.waitUntilGlobalOperationCompleted(operation.Name)
+}
+
+// deleteRouter deletes the router of the cluster if it exists and was created by the actuator.
+func (r *Reconciler) deleteRouter() error {
+	router, err := r.computeService.RoutersGet(r.Context, r.projectID, r.providerSpec.Region, r.routerName())
+	if isNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting router %q: %v", r.routerName(), err)
+	}
+	if router.Description != managedDescription {
+		r.logger.Info("Keeping router not created by the cluster controller", "router", r.routerName())
+		return nil
+	}
+	operation, err := r.computeService.RoutersDelete(r.Context, r.projectID, r.providerSpec.Region, r.routerName())
+	if err != nil {
+		return fmt.Errorf("error deleting router %q: %v", r.routerName(), err)
+	}
+	r.logger.Info("Deleting router", "router", r.routerName(), "gcpOperation", operation.Name)
+	return r.waitUntilRegionOperationCompleted(operation.Name)
+}
+
+// deleteSubnetwork deletes the subnetwork if it exists and was created by the actuator.
+func (r *Reconciler) deleteSubnetwork(name string) error {
+	subnetwork, err := r.computeService.SubnetworksGet(r.Context, r.projectID, r.providerSpec.Region, name)
+	if isNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting subnetwork %q: %v", name, err)
+	}
+	if subnetwork.Description != managedDescription {
+		r.logger.Info("Keeping subnetwork not created by the cluster controller", "subnetwork", name)
+		return nil
+	}
+	operation, err := r.computeService.SubnetworksDelete(r.Context, r.projectID, r.providerSpec.Region, name)
+	if err != nil {
+		return fmt.Errorf("error deleting subnetwork %q: %v", name, err)
+	}
+	r.logger.Info("Deleting subnetwork", "subnetwork", name, "gcpOperation", operation.Name)
+	return r.waitUntilRegionOperationCompleted(operation.Name)
+}
+
+// deleteNetwork deletes the network of the cluster if it exists and was created by the actuator.
+func (r *Reconciler) deleteNetwork() error {
+	network, err := r.computeService.NetworksGet(r.Context, r.projectID, r.providerSpec.Network)
+	if isNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting network %q: %v", r.providerSpec.Network, err)
+	}
+	if network.Description != managedDescription {
+		r.logger.Info("Keeping network not created by the cluster controller", "network", r.providerSpec.Network)
+		return nil
+	}
+	operation, err := r.computeService.NetworksDelete(r.Context, r.projectID, r.providerSpec.Network)
+	if err != nil {
+		return fmt.Errorf("error deleting network %q: %v", r.providerSpec.Network, err)
+	}
+	r.logger.Info("Deleting network", "network", r.providerSpec.Network, "gcpOperation", operation.Name)
+	return r.waitUntilGlobalOperationCompleted(operation.Name)
+}
+
+// recordCreated records an event on the cluster for a resource created by the actuator.
+func (r *Reconciler) recordCreated(kind, name string) {
+	if r.eventRecorder == nil {
+		return
+	}
+	r.eventRecorder.Eventf(r.cluster, apicorev1.EventTypeNormal, "Created", "Created %s %q in project %q", kind, name, r.projectID)
+}
+
+func (r *Reconciler) waitUntilGlobalOperationCompleted(operationName string) error {
+	return r.waitUntilOperationCompleted(operationName, func(ctx context.Context) (*compute.Operation, error) {
+		return r.computeService.GlobalOperationsGet(ctx, r.projectID, operationName)
+	})
+}
+
+func (r *Reconciler) waitUntilRegionOperationCompleted(operationName string) error {
+	return r.waitUntilOperationCompleted(operationName, func(ctx context.Context) (*compute.Operation, error) {
+		return r.computeService.RegionOperationsGet(ctx, r.projectID, r.providerSpec.Region, operationName)
+	})
+}
+
+// waitUntilOperationCompleted polls the operation until it is done or times out. Operations are not
+// recorded as pending since the next reconcile of the cluster ensures its infrastructure again.
+func (r *Reconciler) waitUntilOperationCompleted(operationName string, getOperation func(ctx context.Context) (*compute.Operation, error)) error {
+	ctx, cancel := context.WithTimeout(r.Context, operationTimeOut)
+	defer cancel()
+	return wait.PollImmediateUntil(operationRetryWait, func() (bool, error) {
+		op, err := getOperation(ctx)
+		if err != nil {
+			return false, err
+		}
+		r.logger.V(3).Info("Waiting for operation to be completed", "gcpOperation", operationName, "status", op.Status)
+		if op.Status != "DONE" {
+			return false, nil
+		}
+		if op.Error == nil {
+			return true, nil
+		}
+		var errs []error
+		for _, opErr := range op.Error.Errors {
+			errs = append(errs, fmt.Errorf("%s", *opErr))
+		}
+		return false, fmt.Errorf("the following errors occurred: %+v", errs)
+	}, ctx.Done())
+}
+
+func isNotFoundError(err error) bool {
+	if googleErr, ok := err.(*googleapi.Error); ok {
+		return googleErr.Code == 404
+	}
+	return false
+}
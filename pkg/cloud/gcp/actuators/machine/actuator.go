@@ -3,60 +3,372 @@ package machine
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/go-logr/logr"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/features"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/version"
 	clusterv1 "github.com/openshift/cluster-api/pkg/apis/cluster/v1alpha1"
 	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
 	mapiclient "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/typed/machine/v1beta1"
-	"k8s.io/klog"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
+// log is the structured logger of the actuator. Operations attach the machine, namespace,
+// operation, project and zone to it so log pipelines can index machine failures.
+var log = logf.Log.WithName("gcp-machine-actuator")
+
 // Actuator is responsible for performing machine reconciliation.
 type Actuator struct {
-	machineClient mapiclient.MachineV1beta1Interface
-	coreClient    controllerclient.Client
+	machineClient          mapiclient.MachineV1beta1Interface
+	coreClient             controllerclient.Client
+	preflightChecksEnabled bool
+	apiRateLimiter         *rate.Limiter
+	computeEndpoint        string
+	proxy                  ProxyConfig
+	userAgent              string
+	credentialsCache       *credentialsCache
+	eventRecorder          record.EventRecorder
+	connectivityChecker    *connectivityChecker
+	featureGates           *features.FeatureGate
+	stopCh                 <-chan struct{}
+	preemptibleNode        PreemptibleNodeConfig
+	auditLog               bool
+	failureEvents          *failureEvents
+	instanceCache          *instanceCache
+	clusterOwnedLabel      bool
+	zoneSelector           *zoneSelector
+	// operations tracks the in-flight operations so shutdown can wait for them to record their state.
+	operations sync.WaitGroup
 }
 
 // ActuatorParams holds parameter information for Actuator.
 type ActuatorParams struct {
 	MachineClient mapiclient.MachineV1beta1Interface
 	CoreClient    controllerclient.Client
+	// PreflightChecksEnabled makes machine creation verify referenced GCP resources exist first.
+	PreflightChecksEnabled bool
+	// APIRateLimitQPS is the sustained rate of GCP API requests allowed across all machines. 0 disables rate limiting.
+	APIRateLimitQPS float64
+	// APIRateLimitBurst is the number of GCP API requests allowed in a burst above APIRateLimitQPS.
+	APIRateLimitBurst int
+	// ComputeEndpoint overrides the compute API base path, e.g. for restricted VIPs or emulators.
+	ComputeEndpoint string
+	// Proxy configures the proxy used to reach the GCP APIs.
+	Proxy ProxyConfig
+	// UserAgent identifies the provider in GCP API requests. Defaults to the provider name and version.
+	UserAgent string
+	// EventRecorder records events on machines.
+	EventRecorder record.EventRecorder
+	// FeatureGates enables experimental features. When nil, features have their default state.
+	FeatureGates *features.FeatureGate
+	// StopCh is closed when the controller shuts down. In-flight operations then stop waiting for
+	// their GCP operation and record it as pending in the machine provider status.
+	StopCh <-chan struct{}
+	// PreemptibleNode are the labels and taints added to the nodes of preemptible machines.
+	PreemptibleNode PreemptibleNodeConfig
+	// AuditLog logs the mutating GCP API requests of machines with their outcome to the gcp-audit logger.
+	AuditLog bool
+	// InstanceCacheInterval enables a cache of the instances of each project, refreshed with an aggregated list
+	// at most once per interval, which exists and update consult instead of getting the instance of every
+	// machine. 0 disables the cache.
+	InstanceCacheInterval time.Duration
+	// ClusterOwnedLabel labels the instances and disks created for machines with the kubernetes-io-cluster-<cluster
+	// ID> label, and refuses to delete instances and disks labelled as owned by other clusters.
+	ClusterOwnedLabel bool
 }
 
 // NewActuator returns an actuator.
 func NewActuator(params ActuatorParams) *Actuator {
+	var apiRateLimiter *rate.Limiter
+	if params.APIRateLimitQPS > 0 {
+		apiRateLimiter = rate.NewLimiter(rate.Limit(params.APIRateLimitQPS), params.APIRateLimitBurst)
+	}
+	var cache *instanceCache
+	if params.InstanceCacheInterval > 0 {
+		cache = newInstanceCache(params.InstanceCacheInterval)
+	}
 	return &Actuator{
-		machineClient: params.MachineClient,
-		coreClient:    params.CoreClient,
+		machineClient:          params.MachineClient,
+		coreClient:             params.CoreClient,
+		preflightChecksEnabled: params.PreflightChecksEnabled,
+		apiRateLimiter:         apiRateLimiter,
+		computeEndpoint:        params.ComputeEndpoint,
+		proxy:                  params.Proxy,
+		userAgent:              params.UserAgent,
+		credentialsCache:       newCredentialsCache(),
+		eventRecorder:          params.EventRecorder,
+		connectivityChecker:    newConnectivityChecker(),
+		featureGates:           params.FeatureGates,
+		stopCh:                 params.StopCh,
+		preemptibleNode:        params.PreemptibleNode,
+		auditLog:               params.AuditLog,
+		failureEvents:          newFailureEvents(),
+		instanceCache:          cache,
+		clusterOwnedLabel:      params.ClusterOwnedLabel,
+		zoneSelector:           newZoneSelector(),
+	}
+}
+
+// startOperation returns the context of an actuator operation, cancelled when the controller shuts down.
+// The returned function must be called when the operation is done.
+func (a *Actuator) startOperation(ctx context.Context) (context.Context, func()) {
+	a.operations.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-a.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		a.operations.Done()
+	}
+}
+
+// WaitForOperations waits up to timeout for the in-flight operations to return, e.g. to record
+// their pending GCP operation after the controller was asked to shut down.
+func (a *Actuator) WaitForOperations(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		a.operations.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Info("Timed out waiting for in-flight machine operations", "timeout", timeout)
 	}
 }
 
 // Create creates a machine and is invoked by the machine controller.
-func (a *Actuator) Create(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) error {
-	klog.Infof("Creating machine %v", machine.Name)
-	scope, err := newMachineScope(machineScopeParams{
+func (a *Actuator) Create(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) (err error) {
+	defer observeActuatorOperation("create", machine.Namespace, time.Now(), &err)
+	startTime := metav1.Now()
+	ctx, done := a.startOperation(ctx)
+	defer done()
+	ctx, span := startOperationSpan(ctx, "create", machine)
+	defer endSpan(span, &err)
+	logger := operationLogger("create", machine)
+	logger.Info("Creating machine")
+	scope, err := a.newMachineScope(ctx, machine, logger)
+	if err != nil {
+		logger.Error(err, "Failed to create machine scope")
+		return fmt.Errorf("failed to create scope for machine %q: %v", machine.Name, err)
+	}
+	defer scope.Close()
+	if err := addResourcesFinalizer(scope.machineClient, machine); err != nil {
+		scope.logger.Error(err, "Failed to add finalizer")
+		return err
+	}
+	if err := newReconciler(scope).create(); err != nil {
+		scope.logger.Error(err, "Failed to create machine")
+		scope.recordOperationEvent("create", err)
+		scope.setLastOperation("create", startTime, err)
+		return a.handleMachineError(machine, scope.handlePermissionDenied(err))
+	}
+	scope.clearPermissionDenied()
+	scope.setLastOperation("create", startTime, nil)
+	if machine.Annotations[dryRunAnnotation] != "true" {
+		scope.recordOperationEvent("create", nil)
+		if err := setProviderID(scope.machineClient, machine, scope.providerID()); err != nil {
+			scope.logger.Error(err, "Failed to set provider ID")
+			return err
+		}
+	}
+	return nil
+}
+
+// operationLogger returns the logger of an actuator operation on the machine.
+func operationLogger(operation string, machine *machinev1.Machine) logr.Logger {
+	return log.WithValues("operation", operation, "machine", machine.Name, "namespace", machine.Namespace)
+}
+
+// newMachineScope returns the scope of an actuator operation on the machine.
+func (a *Actuator) newMachineScope(ctx context.Context, machine *machinev1.Machine, logger logr.Logger) (*machineScope, error) {
+	return newMachineScope(a.machineScopeParams(ctx, machine, logger))
+}
+
+func (a *Actuator) machineScopeParams(ctx context.Context, machine *machinev1.Machine, logger logr.Logger) machineScopeParams {
+	return machineScopeParams{
+		Context:       ctx,
 		machineClient: a.machineClient,
 		coreClient:    a.coreClient,
 		machine:       machine,
+		logger:        logger,
+
+		preflightChecksEnabled: a.preflightChecksEnabled,
+		apiRateLimiter:         a.apiRateLimiter,
+		computeEndpoint:        a.computeEndpoint,
+		proxy:                  a.proxy,
+		userAgent:              a.userAgent,
+		credentialsCache:       a.credentialsCache,
+		eventRecorder:          a.eventRecorder,
+		connectivityChecker:    a.connectivityChecker,
+		featureGates:           a.featureGates,
+		preemptibleNode:        a.preemptibleNode,
+		auditLog:               a.auditLog,
+		failureEvents:          a.failureEvents,
+		instanceCache:          a.instanceCache,
+		clusterOwnedLabel:      a.clusterOwnedLabel,
+		zoneSelector:           a.zoneSelector,
+	}
+}
+
+// CheckGCPConnectivity verifies the credentials used by machines can reach the GCP API.
+// It is meant to back readiness probes of the controller.
+func (a *Actuator) CheckGCPConnectivity(ctx context.Context) error {
+	return a.connectivityChecker.check(ctx)
+}
+
+// NewComputeService returns a compute service authenticated with the content of a credentials secret, or the
+// Application Default Credentials when empty, along with the project of the credentials. The cluster actuator
+// uses it so cluster infrastructure is managed through the same proxy, endpoint and rate limiter as machines.
+func (a *Actuator) NewComputeService(serviceAccountJSON string) (computeservice.GCPComputeService, string, error) {
+	creds, err := newCredentials(a.proxy, serviceAccountJSON, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("error getting credentials: %v", err)
+	}
+	oauthClient := *creds.client
+	oauthClient.Transport = tracingTransport(oauthClient.Transport)
+	if a.apiRateLimiter != nil {
+		oauthClient.Transport = computeservice.NewRateLimitedTransport(a.apiRateLimiter, oauthClient.Transport)
+	}
+	userAgent := a.userAgent
+	if userAgent == "" {
+		userAgent = version.UserAgent()
+	}
+	computeService, err := computeservice.NewComputeService(&oauthClient, computeservice.ServiceOptions{
+		Endpoint:  a.computeEndpoint,
+		UserAgent: userAgent,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create scope for machine %q: %v", machine.Name, err)
+		return nil, "", fmt.Errorf("error creating compute service: %v", err)
 	}
-	defer scope.Close()
-	return newReconciler(scope).create()
+	return computeService, creds.projectID, nil
+}
+
+// MachineType returns the GCP machine type of the provider spec of the machine, e.g. of the machine
+// template of a machine set, which does not need to exist.
+func (a *Actuator) MachineType(ctx context.Context, machine *machinev1.Machine) (*compute.MachineType, error) {
+	scope, err := a.newMachineScope(ctx, machine, operationLogger("machineType", machine))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scope for machine %q: %v", machine.Name, err)
+	}
+	zone, err := newReconciler(scope).machineTypeZone()
+	if err != nil {
+		return nil, err
+	}
+	return scope.computeService.MachineTypesGet(ctx, scope.projectID, zone, scope.providerSpec.MachineType)
+}
+
+// handleMachineError records terminal machine errors in the machine status
+// so the failure is visible to users.
+func (a *Actuator) handleMachineError(machine *machinev1.Machine, err error) error {
+	machineErr, ok := err.(*machineapierrors.MachineError)
+	if !ok {
+		return err
+	}
+
+	machine.Status.ErrorReason = &machineErr.Reason
+	machine.Status.ErrorMessage = &machineErr.Message
+	updated, updateErr := a.machineClient.Machines(machine.Namespace).UpdateStatus(machine)
+	if updateErr != nil {
+		log.Error(updateErr, "Failed to update machine status", "machine", machine.Name, "namespace", machine.Namespace)
+		return err
+	}
+	machine.ResourceVersion = updated.ResourceVersion
+	return err
 }
 
-func (a *Actuator) Exists(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) (bool, error) {
-	// TODO(alberto): implement this
-	return false, nil
+// Exists determines if the given machine currently exists.
+func (a *Actuator) Exists(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) (exists bool, err error) {
+	defer observeActuatorOperation("exists", machine.Namespace, time.Now(), &err)
+	ctx, done := a.startOperation(ctx)
+	defer done()
+	ctx, span := startOperationSpan(ctx, "exists", machine)
+	defer endSpan(span, &err)
+	logger := operationLogger("exists", machine)
+	logger.Info("Checking if machine exists")
+	scope, err := a.newMachineScope(ctx, machine, logger)
+	if err != nil {
+		logger.Error(err, "Failed to create machine scope")
+		return false, fmt.Errorf("failed to create scope for machine %q: %v", machine.Name, err)
+	}
+	defer scope.Close()
+	return newReconciler(scope).exists()
 }
 
-func (a *Actuator) Update(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) error {
-	// TODO(alberto): implement this
+// Update updates a machine and is invoked by the machine controller.
+func (a *Actuator) Update(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) (err error) {
+	defer observeActuatorOperation("update", machine.Namespace, time.Now(), &err)
+	startTime := metav1.Now()
+	ctx, done := a.startOperation(ctx)
+	defer done()
+	ctx, span := startOperationSpan(ctx, "update", machine)
+	defer endSpan(span, &err)
+	logger := operationLogger("update", machine)
+	logger.Info("Updating machine")
+	scope, err := a.newMachineScope(ctx, machine, logger)
+	if err != nil {
+		logger.Error(err, "Failed to create machine scope")
+		return fmt.Errorf("failed to create scope for machine %q: %v", machine.Name, err)
+	}
+	defer scope.Close()
+	if err := newReconciler(scope).update(); err != nil {
+		scope.logger.Error(err, "Failed to update machine")
+		scope.recordOperationEvent("update", err)
+		scope.setLastOperation("update", startTime, err)
+		return a.handleMachineError(machine, scope.handlePermissionDenied(err))
+	}
+	scope.clearPermissionDenied()
+	scope.setLastOperation("update", startTime, nil)
+	// Machines created before the provider ID was set get it on update.
+	if err := setProviderID(scope.machineClient, machine, scope.providerID()); err != nil {
+		scope.logger.Error(err, "Failed to set provider ID")
+		return err
+	}
 	return nil
 }
 
-func (a *Actuator) Delete(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) error {
-	// TODO(alberto): implement this
+// Delete deletes a machine and is invoked by the machine controller.
+func (a *Actuator) Delete(ctx context.Context, cluster *clusterv1.Cluster, machine *machinev1.Machine) (err error) {
+	defer observeActuatorOperation("delete", machine.Namespace, time.Now(), &err)
+	startTime := metav1.Now()
+	ctx, done := a.startOperation(ctx)
+	defer done()
+	ctx, span := startOperationSpan(ctx, "delete", machine)
+	defer endSpan(span, &err)
+	logger := operationLogger("delete", machine)
+	logger.Info("Deleting machine")
+	scope, err := a.newMachineScope(ctx, machine, logger)
+	if err != nil {
+		logger.Error(err, "Failed to create machine scope")
+		return fmt.Errorf("failed to create scope for machine %q: %v", machine.Name, err)
+	}
+	defer scope.Close()
+	if err := newReconciler(scope).delete(); err != nil {
+		scope.logger.Error(err, "Failed to delete machine")
+		scope.recordOperationEvent("delete", err)
+		scope.setLastOperation("delete", startTime, err)
+		return a.handleMachineError(machine, scope.handlePermissionDenied(err))
+	}
+	scope.clearPermissionDenied()
+	scope.setLastOperation("delete", startTime, nil)
+	scope.recordOperationEvent("delete", nil)
+	if err := removeResourcesFinalizer(scope.machineClient, machine); err != nil {
+		scope.logger.Error(err, "Failed to remove finalizer")
+		return err
+	}
 	return nil
 }
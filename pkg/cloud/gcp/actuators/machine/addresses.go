@@ -0,0 +1,91 @@
+package machine
+
+import (
+	"fmt"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// addressName returns the name of the internal or external static address of the network interface at index i.
+func addressName(instanceName string, i int, external bool) string {
+	if external {
+		return suffixedName(instanceName, fmt.Sprintf("-nic%d-external", i))
+	}
+	return suffixedName(instanceName, fmt.Sprintf("-nic%d", i))
+}
+
+// reserveAddresses reserves the static addresses of the network interfaces with a static IP and assigns
+// them to the interfaces of the instance. Addresses reserved by a previous attempt are reused.
+func (r *Reconciler) reserveAddresses(instance *compute.Instance) error {
+	region := r.region()
+	for i, nic := range r.providerSpec.NetworkInterfaces {
+		if !nic.StaticIP {
+			continue
+		}
+		computeNIC := instance.NetworkInterfaces[i]
+		ip, err := r.reserveAddress(region, &compute.Address{
+			Name:        addressName(instance.Name, i, false),
+			AddressType: "INTERNAL",
+			Subnetwork:  fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", r.networkProjectID(), region, nic.Subnetwork),
+		})
+		if err != nil {
+			return err
+		}
+		computeNIC.NetworkIP = ip
+		if len(computeNIC.AccessConfigs) > 0 {
+			ip, err := r.reserveAddress(region, &compute.Address{
+				Name:        addressName(instance.Name, i, true),
+				AddressType: "EXTERNAL",
+			})
+			if err != nil {
+				return err
+			}
+			computeNIC.AccessConfigs[0].NatIP = ip
+		}
+	}
+	return nil
+}
+
+// reserveAddress reserves the regional address unless it exists and returns its IP. The address is
+// recorded in the provider status first, so that it is released with the machine even if reserving fails.
+func (r *Reconciler) reserveAddress(region string, address *compute.Address) (string, error) {
+	if !hasString(r.providerStatus.Addresses, address.Name) {
+		r.providerStatus.Addresses = append(r.providerStatus.Addresses, address.Name)
+	}
+	operation, err := r.computeService.AddressesInsert(r.Context, r.projectID, region, address)
+	if err != nil && !isAlreadyExistsError(err) {
+		return "", fmt.Errorf("error reserving address %q in region %q: %v", address.Name, region, err)
+	}
+	if err == nil {
+		r.logger.Info("Reserving address", "address", address.Name, "gcpOperation", operation.Name)
+		if err := r.waitUntilRegionOperationCompleted(region, operation.Name); err != nil {
+			return "", fmt.Errorf("error reserving address %q in region %q: %v", address.Name, region, err)
+		}
+	}
+	reserved, err := r.computeService.AddressesGet(r.Context, r.projectID, region, address.Name)
+	if err != nil {
+		return "", fmt.Errorf("error getting address %q in region %q: %v", address.Name, region, err)
+	}
+	return reserved.Address, nil
+}
+
+// releaseAddresses releases the static addresses of the instance once the instance is deleted.
+// Released addresses are removed from the provider status as they go.
+func (r *Reconciler) releaseAddresses() error {
+	region := r.region()
+	for len(r.providerStatus.Addresses) > 0 {
+		name := r.providerStatus.Addresses[0]
+		operation, err := r.computeService.AddressesDelete(r.Context, r.projectID, region, name)
+		if err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("error releasing address %q in region %q: %v", name, region, err)
+		}
+		if err == nil {
+			r.logger.Info("Releasing address", "address", name, "gcpOperation", operation.Name)
+			if err := r.waitUntilRegionOperationCompleted(region, operation.Name); err != nil {
+				return fmt.Errorf("error releasing address %q in region %q: %v", name, region, err)
+			}
+		}
+		r.providerStatus.Addresses = r.providerStatus.Addresses[1:]
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package machine
+
+import (
+	"net/http"
+
+	"github.com/go-logr/logr"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// auditLog is the logger of the audit trail of mutating GCP API requests, so log pipelines can route
+// it to a dedicated stream, e.g. for change management audits.
+var auditLog = logf.Log.WithName("gcp-audit")
+
+// auditTransport logs the mutating GCP API requests sent through base, i.e. the insert, delete and set
+// requests, with the machine they are sent for, the identity they are sent as and their outcome.
+type auditTransport struct {
+	base   http.RoundTripper
+	logger logr.Logger
+}
+
+// newAuditTransport returns a transport auditing the mutating requests sent through base for the machine
+// with the credentials of the service account, which is empty when unknown.
+func newAuditTransport(base http.RoundTripper, logger logr.Logger, machine *machinev1.Machine, serviceAccount string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if serviceAccount == "" {
+		serviceAccount = "application-default-credentials"
+	}
+	return &auditTransport{
+		base:   base,
+		logger: logger.WithValues("machine", machine.Name, "namespace", machine.Namespace, "requester", serviceAccount),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+	resp, err := t.base.RoundTrip(req)
+	logger := t.logger.WithValues("method", req.Method, "resource", req.URL.Path)
+	switch {
+	case err != nil:
+		logger.Info("GCP API request failed", "outcome", "error", "error", err.Error())
+	case resp.StatusCode >= http.StatusBadRequest:
+		logger.Info("GCP API request rejected", "outcome", "rejected", "statusCode", resp.StatusCode)
+	default:
+		logger.Info("GCP API request accepted", "outcome", "accepted", "statusCode", resp.StatusCode)
+	}
+	return resp, err
+}
@@ -0,0 +1,79 @@
+package machine
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordingLogger records the messages and key/value pairs logged through it.
+type recordingLogger struct {
+	values []interface{}
+	lines  *[]string
+}
+
+func (l recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	*l.lines = append(*l.lines, fmt.Sprint(append(append([]interface{}{msg}, l.values...), keysAndValues...)...))
+}
+func (l recordingLogger) Enabled() bool { return true }
+func (l recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Info(msg, append(keysAndValues, "error", err)...)
+}
+func (l recordingLogger) V(level int) logr.InfoLogger      { return l }
+func (l recordingLogger) WithName(name string) logr.Logger { return l }
+func (l recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return recordingLogger{values: append(append([]interface{}{}, l.values...), keysAndValues...), lines: l.lines}
+}
+
+func TestAuditTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/forbidden") {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	var lines []string
+	machine := &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "openshift-machine-api"}}
+	client := &http.Client{Transport: newAuditTransport(nil, recordingLogger{lines: &lines}, machine, "machines@my-project.iam.gserviceaccount.com")}
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/compute/v1/projects/my-project/zones/us-east1-b/instances/worker-0"},
+		{method: http.MethodPost, path: "/compute/v1/projects/my-project/zones/us-east1-b/instances"},
+		{method: http.MethodDelete, path: "/compute/v1/projects/my-project/zones/us-east1-b/instances/forbidden"},
+	}
+	for _, request := range requests {
+		req, err := http.NewRequest(request.method, server.URL+request.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected the 2 mutating requests to be audited, got %q", lines)
+	}
+	for i, expected := range [][]string{
+		{"accepted", "POST", "/zones/us-east1-b/instances", "worker-0", "machines@my-project.iam.gserviceaccount.com"},
+		{"rejected", "DELETE", "/instances/forbidden", "403"},
+	} {
+		for _, value := range expected {
+			if !strings.Contains(lines[i], value) {
+				t.Errorf("expected audit log %q to contain %q", lines[i], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package machine
+
+import (
+	"fmt"
+
+	apicorev1 "k8s.io/api/core/v1"
+)
+
+// repairInstance deletes and recreates the instance of an auto-repaired machine once GCP terminated it.
+// The machine and its node name are kept, the new instance joins the cluster as the same node.
+func (r *Reconciler) repairInstance() error {
+	if !r.providerSpec.AutoRepair {
+		return nil
+	}
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	instance, _, err := r.getInstance()
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil
+		}
+		return fmt.Errorf("error getting instance %q in zone %q: %v", name, zone, err)
+	}
+	if instance.Status != "TERMINATED" {
+		return nil
+	}
+
+	r.logger.Info("Instance was terminated, recreating it", "instance", name)
+	if r.eventRecorder != nil {
+		r.eventRecorder.Eventf(r.machine, apicorev1.EventTypeWarning, "AutoRepair", "Recreating terminated instance %s", name)
+	}
+	operation, err := r.computeService.InstancesDelete(r.Context, r.projectID, zone, name)
+	if err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("error deleting terminated instance %q in zone %q: %v", name, zone, err)
+	}
+	r.invalidateInstance()
+	if err == nil {
+		if err := r.waitUntilOperationCompleted(zone, operation.Name, operationTypeDelete); err != nil {
+			return err
+		}
+	}
+	// The new instance creates its disks again.
+	if err := r.deleteRetainedDisks(); err != nil {
+		return err
+	}
+	// Should creating the instance fail, the machine controller creates it since it no longer exists.
+	if err := r.create(); err != nil {
+		return fmt.Errorf("error recreating terminated instance %q: %v", name, err)
+	}
+	return nil
+}
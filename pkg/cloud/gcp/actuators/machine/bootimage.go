@@ -0,0 +1,95 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	apicorev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// bootImagesNamespace and bootImagesConfigMap locate the CoreOS stream metadata of the release the cluster
+	// runs, which the machine config operator updates on upgrades.
+	bootImagesNamespace = "openshift-machine-config-operator"
+	bootImagesConfigMap = "coreos-bootimages"
+	// bootImagesStreamKey is the key of the config map holding the stream metadata JSON.
+	bootImagesStreamKey = "stream"
+)
+
+// streamArchitecture returns the architecture of the stream metadata to resolve the boot image of the machine
+// type from, e.g. aarch64 for t2a-standard-4.
+func streamArchitecture(machineType string) string {
+	if v1beta1.MachineTypeArchitecture(machineType) == v1beta1.ArchitectureARM64 {
+		return "aarch64"
+	}
+	return "x86_64"
+}
+
+// coreOSStream is the part of the CoreOS stream metadata describing the GCP boot images, see
+// https://github.com/coreos/stream-metadata-go.
+type coreOSStream struct {
+	Stream        string                              `json:"stream"`
+	Architectures map[string]coreOSStreamArchitecture `json:"architectures"`
+}
+
+// coreOSStreamArchitecture is the part of the stream metadata of an architecture describing its boot images.
+type coreOSStreamArchitecture struct {
+	Images struct {
+		GCP *coreOSStreamGCPImage `json:"gcp"`
+	} `json:"images"`
+}
+
+// coreOSStreamGCPImage is the GCP boot image of an architecture of a stream.
+type coreOSStreamGCPImage struct {
+	Release string `json:"release"`
+	Project string `json:"project"`
+	Name    string `json:"name"`
+}
+
+// resolveStreamImages sets the image of the disks resolving their image from the stream metadata to the GCP
+// boot image of the stream metadata of the cluster.
+func (r *Reconciler) resolveStreamImages() error {
+	var image string
+	for _, disk := range r.providerSpec.Disks {
+		if !disk.ImageFromStream {
+			continue
+		}
+		if image == "" {
+			resolved, err := r.streamImage()
+			if err != nil {
+				return err
+			}
+			image = resolved
+		}
+		disk.Image = image
+	}
+	return nil
+}
+
+// streamImage returns the GCP boot image of the stream metadata of the cluster for the architecture of the
+// machine type, e.g.
+// projects/rhcos-cloud/global/images/rhcos-412-86-202212081411-0-gcp-x86-64.
+func (r *Reconciler) streamImage() (string, error) {
+	var configMap apicorev1.ConfigMap
+	if err := r.coreClient.Get(r.Context, client.ObjectKey{Namespace: bootImagesNamespace, Name: bootImagesConfigMap}, &configMap); err != nil {
+		return "", fmt.Errorf("error getting config map %q in namespace %q for the boot image: %v", bootImagesConfigMap, bootImagesNamespace, err)
+	}
+	data, ok := configMap.Data[bootImagesStreamKey]
+	if !ok {
+		return "", fmt.Errorf("config map %v/%v does not have %q field set for the boot image", bootImagesNamespace, bootImagesConfigMap, bootImagesStreamKey)
+	}
+	var stream coreOSStream
+	if err := json.Unmarshal([]byte(data), &stream); err != nil {
+		return "", fmt.Errorf("error decoding the stream metadata of config map %v/%v: %v", bootImagesNamespace, bootImagesConfigMap, err)
+	}
+	architecture := streamArchitecture(r.providerSpec.MachineType)
+	gcpImage := stream.Architectures[architecture].Images.GCP
+	if gcpImage == nil || gcpImage.Project == "" || gcpImage.Name == "" {
+		return "", machineapierrors.InvalidMachineConfiguration("stream %q has no GCP boot image for architecture %s", stream.Stream, architecture)
+	}
+	r.logger.Info("Resolved boot image from stream metadata", "stream", stream.Stream, "release", gcpImage.Release, "image", gcpImage.Name)
+	return fmt.Sprintf("projects/%s/global/images/%s", gcpImage.Project, gcpImage.Name), nil
+}
@@ -0,0 +1,96 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	apicorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testStream = `{
+  "stream": "rhcos-4.12",
+  "architectures": {
+    "aarch64": {
+      "images": {
+        "gcp": {
+          "release": "412.86.202212081411-0",
+          "project": "rhcos-cloud",
+          "name": "rhcos-412-86-202212081411-0-gcp-aarch64"
+        }
+      }
+    },
+    "x86_64": {
+      "images": {
+        "gcp": {
+          "release": "412.86.202212081411-0",
+          "project": "rhcos-cloud",
+          "name": "rhcos-412-86-202212081411-0-gcp-x86-64"
+        }
+      }
+    }
+  }
+}`
+
+func TestResolveStreamImages(t *testing.T) {
+	bootImages := &apicorev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootImagesConfigMap,
+			Namespace: bootImagesNamespace,
+		},
+		Data: map[string]string{bootImagesStreamKey: testStream},
+	}
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(bootImages),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:            true,
+					ImageFromStream: true,
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if expected := "projects/rhcos-cloud/global/images/rhcos-412-86-202212081411-0-gcp-x86-64"; receivedInstance.Disks[0].InitializeParams.SourceImage != expected {
+		t.Errorf("expected the boot image %q of the stream, got %q", expected, receivedInstance.Disks[0].InitializeParams.SourceImage)
+	}
+
+	// Arm machine types boot the image of the aarch64 architecture.
+	reconciler.providerSpec.MachineType = "t2a-standard-4"
+	reconciler.providerSpec.Disks[0].Image = ""
+	if err := reconciler.resolveStreamImages(); err != nil {
+		t.Fatalf("resolveStreamImages was not expected to return error: %v", err)
+	}
+	if expected := "projects/rhcos-cloud/global/images/rhcos-412-86-202212081411-0-gcp-aarch64"; reconciler.providerSpec.Disks[0].Image != expected {
+		t.Errorf("expected the aarch64 boot image %q of the stream, got %q", expected, reconciler.providerSpec.Disks[0].Image)
+	}
+
+	// Streams without GCP image for the architecture cannot be booted.
+	bootImages.Data[bootImagesStreamKey] = `{"stream": "rhcos-4.12", "architectures": {"x86_64": {"images": {}}}}`
+	reconciler.coreClient = controllerfake.NewFakeClient(bootImages)
+	reconciler.providerSpec.Disks[0].Image = ""
+	err := reconciler.resolveStreamImages()
+	if _, ok := err.(*machineapierrors.MachineError); !ok {
+		t.Errorf("expected a terminal machine error for a stream without GCP image, got: %v", err)
+	}
+}
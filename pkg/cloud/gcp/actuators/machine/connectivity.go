@@ -0,0 +1,69 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+)
+
+// connectivityCheckInterval is how long the result of a connectivity check is reused, so frequent
+// probes do not consume API quota.
+const connectivityCheckInterval = time.Minute
+
+// connectivityChecker verifies the credentials used by machines can still reach the GCP API, so bad
+// or revoked credentials are detected by readiness probes rather than by failing machines.
+type connectivityChecker struct {
+	lock sync.Mutex
+	// targets are the compute services of the credentials and project pairs used by machines.
+	targets   map[string]connectivityTarget
+	lastCheck time.Time
+	lastErr   error
+}
+
+type connectivityTarget struct {
+	projectID      string
+	computeService computeservice.GCPComputeService
+}
+
+func newConnectivityChecker() *connectivityChecker {
+	return &connectivityChecker{
+		targets: map[string]connectivityTarget{},
+	}
+}
+
+// register adds the credentials and project pair used by a machine to the pairs checked.
+// A nil checker does not register anything.
+func (c *connectivityChecker) register(credentialsKey string, projectID string, computeService computeservice.GCPComputeService) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.targets[credentialsKey+"@"+projectID] = connectivityTarget{projectID: projectID, computeService: computeService}
+}
+
+// check lists the zones of every registered project, a cheap call that requires valid credentials.
+// The result is reused for connectivityCheckInterval. Nothing is checked until a machine was reconciled.
+func (c *connectivityChecker) check(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.lastCheck.IsZero() && time.Since(c.lastCheck) < connectivityCheckInterval {
+		return c.lastErr
+	}
+
+	c.lastErr = nil
+	for key, target := range c.targets {
+		if _, err := target.computeService.ZonesList(ctx, target.projectID, ""); err != nil {
+			c.lastErr = fmt.Errorf("error listing zones of project %q with credentials %q: %v", target.projectID, key, err)
+			break
+		}
+	}
+	c.lastCheck = time.Now()
+	return c.lastErr
+}
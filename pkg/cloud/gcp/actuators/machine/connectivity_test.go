@@ -0,0 +1,37 @@
+package machine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+)
+
+func TestConnectivityChecker(t *testing.T) {
+	var nilChecker *connectivityChecker
+	if err := nilChecker.check(context.TODO()); err != nil {
+		t.Errorf("expected nil checker to succeed, got %v", err)
+	}
+
+	checker := newConnectivityChecker()
+	if err := checker.check(context.TODO()); err != nil {
+		t.Errorf("expected no error before any machine was reconciled, got %v", err)
+	}
+
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	checker.register("namespace/secret", "project", mockComputeService)
+	checker.lastCheck = time.Time{}
+	if err := checker.check(context.TODO()); err != nil {
+		t.Errorf("expected no error with valid credentials, got %v", err)
+	}
+
+	mockComputeService.FailOn("ZonesList", computeservice.APIError(401, "authError", "Invalid Credentials"))
+	if err := checker.check(context.TODO()); err != nil {
+		t.Errorf("expected the previous result to be reused, got %v", err)
+	}
+	checker.lastCheck = time.Time{}
+	if err := checker.check(context.TODO()); err == nil {
+		t.Errorf("expected an error with invalid credentials")
+	}
+}
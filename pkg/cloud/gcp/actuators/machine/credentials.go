@@ -0,0 +1,164 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	serviceAccountDomain = ".iam.gserviceaccount.com"
+	impersonationURLFmt  = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken"
+)
+
+// credentials are the project and the oauth client built from a credentials secret.
+type credentials struct {
+	// resourceVersion is the resource version of the secret the credentials were built from.
+	resourceVersion string
+	projectID       string
+	// serviceAccount is the email of the service account of the credentials, empty when unknown,
+	// e.g. for the service account of the instance the controller runs on.
+	serviceAccount string
+	client         *http.Client
+}
+
+// newCredentials builds credentials from the content of a credentials secret. When there is no
+// credentials secret, it falls back to the Application Default Credentials, e.g. the service account
+// attached to the instance the controller runs on or workload identity.
+// When impersonateServiceAccount is set, the credentials are used to impersonate that service account
+// and the project is the one of the impersonated service account.
+// Both token requests and API requests go through the configured proxy.
+func newCredentials(proxy ProxyConfig, serviceAccountJSON string, impersonateServiceAccount string) (*credentials, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: proxy.transport()})
+
+	var googleCredentials *google.Credentials
+	var err error
+	if serviceAccountJSON == "" {
+		googleCredentials, err = google.FindDefaultCredentials(ctx, compute.CloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("no credentials secret specified and no application default credentials found: %v", err)
+		}
+	} else if isExternalAccount(serviceAccountJSON) {
+		googleCredentials, err = externalAccountCredentials(ctx, serviceAccountJSON, compute.CloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing external account credentials: %v", err)
+		}
+	} else {
+		googleCredentials, err = google.CredentialsFromJSON(ctx, []byte(serviceAccountJSON), compute.CloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing credentials JSON: %v", err)
+		}
+	}
+	serviceAccount := credentialsServiceAccount(googleCredentials.JSON)
+	if impersonateServiceAccount != "" {
+		googleCredentials = impersonatedCredentials(ctx, googleCredentials, impersonateServiceAccount, compute.CloudPlatformScope)
+		serviceAccount = impersonateServiceAccount
+	}
+	return &credentials{
+		projectID:      googleCredentials.ProjectID,
+		serviceAccount: serviceAccount,
+		client:         oauth2.NewClient(ctx, googleCredentials.TokenSource),
+	}, nil
+}
+
+// credentialsServiceAccount returns the service account email of a service account key, if any.
+func credentialsServiceAccount(credentialsJSON []byte) string {
+	var config struct {
+		ClientEmail string `json:"client_email"`
+	}
+	if json.Unmarshal(credentialsJSON, &config) != nil {
+		return ""
+	}
+	return config.ClientEmail
+}
+
+// isExternalAccount returns true for external account (Workload Identity Federation) credentials,
+// which the vendored oauth2 library does not support.
+func isExternalAccount(credentialsJSON string) bool {
+	var config struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal([]byte(credentialsJSON), &config) == nil && config.Type == externalAccountCredentialsType
+}
+
+// externalAccountCredentials returns the credentials of an external account credentials JSON.
+func externalAccountCredentials(ctx context.Context, credentialsJSON string, scopes ...string) (*google.Credentials, error) {
+	var config externalAccountConfig
+	if err := json.Unmarshal([]byte(credentialsJSON), &config); err != nil {
+		return nil, err
+	}
+	ts, err := externalAccountTokenSource(ctx, &config, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	projectID := config.ProjectID
+	if projectID == "" {
+		projectID = config.QuotaProjectID
+	}
+	return &google.Credentials{
+		ProjectID:   projectID,
+		TokenSource: ts,
+		JSON:        []byte(credentialsJSON),
+	}, nil
+}
+
+// impersonatedCredentials returns credentials of the service account, impersonated with the base credentials.
+func impersonatedCredentials(ctx context.Context, base *google.Credentials, serviceAccount string, scopes ...string) *google.Credentials {
+	projectID := base.ProjectID
+	// Service account emails are <name>@<project>.iam.gserviceaccount.com.
+	if parts := strings.SplitN(serviceAccount, "@", 2); len(parts) == 2 && strings.HasSuffix(parts[1], serviceAccountDomain) {
+		projectID = strings.TrimSuffix(parts[1], serviceAccountDomain)
+	}
+	return &google.Credentials{
+		ProjectID: projectID,
+		TokenSource: oauth2.ReuseTokenSource(nil, &impersonatedTokenSource{
+			ctx:    ctx,
+			base:   base.TokenSource,
+			url:    fmt.Sprintf(impersonationURLFmt, serviceAccount),
+			scopes: scopes,
+		}),
+	}
+}
+
+// credentialsCache caches the credentials built from credentials secrets, so oauth tokens are reused
+// across actuator operations. Credentials are rebuilt as soon as their secret changes, e.g. after a
+// service account key rotation, without having to restart the controller.
+type credentialsCache struct {
+	lock  sync.Mutex
+	items map[string]*credentials
+}
+
+func newCredentialsCache() *credentialsCache {
+	return &credentialsCache{
+		items: map[string]*credentials{},
+	}
+}
+
+// get returns the cached credentials of the secret identified by key if they were built from
+// the given resource version, otherwise it builds and caches new ones.
+// A nil cache always builds new credentials.
+func (c *credentialsCache) get(key string, resourceVersion string, build func() (*credentials, error)) (*credentials, error) {
+	if c == nil {
+		return build()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cached, ok := c.items[key]; ok && cached.resourceVersion == resourceVersion {
+		return cached, nil
+	}
+	creds, err := build()
+	if err != nil {
+		return nil, err
+	}
+	creds.resourceVersion = resourceVersion
+	c.items[key] = creds
+	return creds, nil
+}
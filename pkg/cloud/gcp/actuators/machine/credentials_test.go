@@ -0,0 +1,93 @@
+package machine
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestCredentialsCache(t *testing.T) {
+	cache := newCredentialsCache()
+	builds := 0
+	build := func() (*credentials, error) {
+		builds++
+		return &credentials{projectID: "my-project", client: &http.Client{}}, nil
+	}
+
+	first, err := cache.get("openshift-machine-api/gcp-sa", "1", build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := cache.get("openshift-machine-api/gcp-sa", "1", build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if builds != 1 || first != second {
+		t.Errorf("expected credentials to be reused while the secret is unchanged, got %d builds", builds)
+	}
+
+	rotated, err := cache.get("openshift-machine-api/gcp-sa", "2", build)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if builds != 2 || rotated == first {
+		t.Errorf("expected credentials to be rebuilt after the secret changed, got %d builds", builds)
+	}
+}
+
+func TestNewCredentials(t *testing.T) {
+	serviceAccountJSON := `{"type": "service_account", "project_id": "my-project", "client_email": "worker@my-project.iam.gserviceaccount.com"}`
+
+	creds, err := newCredentials(ProxyConfig{}, serviceAccountJSON, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if creds.projectID != "my-project" {
+		t.Errorf("expected project %q, got %q", "my-project", creds.projectID)
+	}
+	if creds.serviceAccount != "worker@my-project.iam.gserviceaccount.com" {
+		t.Errorf("expected service account %q, got %q", "worker@my-project.iam.gserviceaccount.com", creds.serviceAccount)
+	}
+
+	// The project of credentials without project is set in the provider spec.
+	creds, err = newCredentials(ProxyConfig{}, `{"type": "service_account"}`, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if creds.projectID != "" {
+		t.Errorf("expected no project, got %q", creds.projectID)
+	}
+
+	creds, err = newCredentials(ProxyConfig{}, serviceAccountJSON, "machines@other-project.iam.gserviceaccount.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if creds.projectID != "other-project" {
+		t.Errorf("expected project %q of the impersonated service account, got %q", "other-project", creds.projectID)
+	}
+	if creds.serviceAccount != "machines@other-project.iam.gserviceaccount.com" {
+		t.Errorf("expected the impersonated service account, got %q", creds.serviceAccount)
+	}
+
+	// Without credentials secret, the application default credentials are used.
+	file, err := ioutil.TempFile("", "application_default_credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(serviceAccountJSON); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", file.Name())
+
+	creds, err = newCredentials(ProxyConfig{}, "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if creds.projectID != "my-project" {
+		t.Errorf("expected project %q from application default credentials, got %q", "my-project", creds.projectID)
+	}
+}
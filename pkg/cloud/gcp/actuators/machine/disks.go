@@ -0,0 +1,200 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"google.golang.org/api/compute/v1"
+)
+
+// diskName returns the name of the disk at index i of the provider spec. The boot disk is named after the
+// instance, as GCP does by default.
+func diskName(instanceName string, i int, boot bool) string {
+	if boot {
+		return instanceName
+	}
+	return suffixedName(instanceName, fmt.Sprintf("-disk-%d", i))
+}
+
+// deleteRetainedDisks deletes the disks of the instance that GCP does not delete with it, once the
+// instance is deleted. Deleted disks are removed from the provider status as they go, so a failure
+// only retries the remaining ones.
+func (r *Reconciler) deleteRetainedDisks() error {
+	zone := r.providerSpec.Zone
+	for len(r.providerStatus.Disks) > 0 {
+		name := r.providerStatus.Disks[0]
+		exists, err := r.checkDiskOwnership(zone, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			r.providerStatus.Disks = r.providerStatus.Disks[1:]
+			continue
+		}
+		operation, err := r.computeService.DisksDelete(r.Context, r.projectID, zone, name)
+		if err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("error deleting disk %q in zone %q: %v", name, zone, err)
+		}
+		if err == nil {
+			r.logger.Info("Deleting disk", "disk", name, "gcpOperation", operation.Name)
+			if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+				return fmt.Errorf("error deleting disk %q in zone %q: %v", name, zone, err)
+			}
+		}
+		r.providerStatus.Disks = r.providerStatus.Disks[1:]
+	}
+	return nil
+}
+
+// insertMultiWriterDisks inserts the multi-writer disks of the instance, skipping the disks inserted by a
+// previous attempt.
+func (r *Reconciler) insertMultiWriterDisks(zone string, disks []*compute.Disk) error {
+	for _, disk := range disks {
+		operation, err := r.computeService.DisksInsertMultiWriter(r.Context, r.projectID, zone, disk)
+		if err != nil {
+			if isAlreadyExistsError(err) {
+				if _, err := r.checkDiskOwnership(zone, disk.Name); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("error inserting disk %q in zone %q: %v", disk.Name, zone, err)
+		}
+		r.logger.Info("Inserting multi-writer disk", "disk", disk.Name, "gcpOperation", operation.Name)
+		if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+			return fmt.Errorf("error inserting disk %q in zone %q: %v", disk.Name, zone, err)
+		}
+	}
+	return nil
+}
+
+// existingDiskURL returns the partial URL of an existing disk of the provider spec.
+func (r *Reconciler) existingDiskURL(zone, name string) string {
+	return fmt.Sprintf("projects/%s/zones/%s/disks/%s", r.projectID, zone, name)
+}
+
+// existingAttachedDisk returns the attachment of an existing disk of the provider spec. The device name is the
+// name of the disk, so the disk can be found on the instance.
+func (r *Reconciler) existingAttachedDisk(zone string, disk *v1beta1.GCPDisk) *compute.AttachedDisk {
+	mode := disk.Mode
+	if mode == "" {
+		mode = v1beta1.DiskModeReadWrite
+	}
+	return &compute.AttachedDisk{
+		AutoDelete: disk.AutoDelete,
+		Boot:       disk.Boot,
+		DeviceName: disk.Source,
+		Mode:       string(mode),
+		Source:     r.existingDiskURL(zone, disk.Source),
+	}
+}
+
+// findAttachedDisk returns the disk of the instance with the URL, nil when the disk is not attached.
+func findAttachedDisk(instance *compute.Instance, diskURL string) *compute.AttachedDisk {
+	for _, disk := range instance.Disks {
+		if disk.Source == diskURL || strings.HasSuffix(disk.Source, "/"+diskURL) {
+			return disk
+		}
+	}
+	return nil
+}
+
+// existingDataDisks returns the names of the existing data disks of the provider spec.
+func existingDataDisks(disks []*v1beta1.GCPDisk) []string {
+	var names []string
+	for _, disk := range disks {
+		if disk.Source != "" && !disk.Boot {
+			names = append(names, disk.Source)
+		}
+	}
+	return names
+}
+
+// ensureExistingDisks attaches the existing data disks of the provider spec the instance lacks, and detaches the
+// disks the controller attached which were removed from the provider spec. The boot disk and the disks attached
+// outside of the controller, e.g. by the CSI driver, are left alone.
+func (r *Reconciler) ensureExistingDisks() error {
+	instance, _, err := r.getInstance()
+	if err != nil {
+		return fmt.Errorf("error getting instance %q in zone %q: %v", r.instanceName(), r.providerSpec.Zone, err)
+	}
+	zone := r.providerSpec.Zone
+	for _, disk := range r.providerSpec.Disks {
+		if disk.Source == "" || disk.Boot {
+			continue
+		}
+		if !hasString(r.providerStatus.AttachedDisks, disk.Source) {
+			r.providerStatus.AttachedDisks = append(r.providerStatus.AttachedDisks, disk.Source)
+		}
+		if findAttachedDisk(instance, r.existingDiskURL(zone, disk.Source)) != nil {
+			continue
+		}
+		operation, err := r.computeService.InstancesAttachDisk(r.Context, r.projectID, zone, instance.Name, r.existingAttachedDisk(zone, disk))
+		if err != nil {
+			return fmt.Errorf("error attaching disk %q to instance %q: %v", disk.Source, instance.Name, err)
+		}
+		r.invalidateInstance()
+		r.logger.Info("Attaching disk", "disk", disk.Source, "gcpOperation", operation.Name)
+		if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+			return fmt.Errorf("error attaching disk %q to instance %q: %v", disk.Source, instance.Name, err)
+		}
+	}
+
+	desired := existingDataDisks(r.providerSpec.Disks)
+	for _, name := range r.providerStatus.AttachedDisks {
+		if hasString(desired, name) {
+			continue
+		}
+		if err := r.detachDisk(instance, name); err != nil {
+			return err
+		}
+		r.providerStatus.AttachedDisks = removeString(r.providerStatus.AttachedDisks, name)
+	}
+	return nil
+}
+
+// detachExistingDisks detaches the existing data disks of the provider spec and provider status from the
+// instance before it is deleted, so they outlive it even if they were set to be deleted with it outside of
+// the controller. The instance is only got when there are disks to detach and it was not got already.
+func (r *Reconciler) detachExistingDisks(instance *compute.Instance) error {
+	names := mergeStrings(existingDataDisks(r.providerSpec.Disks), r.providerStatus.AttachedDisks)
+	if len(names) == 0 {
+		return nil
+	}
+	if instance == nil {
+		var err error
+		if instance, _, err = r.getInstance(); err != nil {
+			if isNotFoundError(err) {
+				return nil
+			}
+			return fmt.Errorf("error getting instance %q in zone %q: %v", r.instanceName(), r.providerSpec.Zone, err)
+		}
+	}
+	for _, name := range names {
+		if err := r.detachDisk(instance, name); err != nil {
+			return err
+		}
+		r.providerStatus.AttachedDisks = removeString(r.providerStatus.AttachedDisks, name)
+	}
+	return nil
+}
+
+// detachDisk detaches the existing disk from the instance, when attached.
+func (r *Reconciler) detachDisk(instance *compute.Instance, name string) error {
+	zone := r.providerSpec.Zone
+	attached := findAttachedDisk(instance, r.existingDiskURL(zone, name))
+	if attached == nil || attached.Boot {
+		return nil
+	}
+	operation, err := r.computeService.InstancesDetachDisk(r.Context, r.projectID, zone, instance.Name, attached.DeviceName)
+	if err != nil {
+		return fmt.Errorf("error detaching disk %q from instance %q: %v", name, instance.Name, err)
+	}
+	r.invalidateInstance()
+	r.logger.Info("Detaching disk", "disk", name, "gcpOperation", operation.Name)
+	if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+		return fmt.Errorf("error detaching disk %q from instance %q: %v", name, instance.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package machine
+
+import (
+	"fmt"
+	"path"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"google.golang.org/api/compute/v1"
+	apicorev1 "k8s.io/api/core/v1"
+)
+
+// hasPublicIP returns true if the network interface gets an ephemeral external IP, the default.
+func hasPublicIP(nic *gcpproviderv1.GCPNetworkInterface) bool {
+	return nic.PublicIP == nil || *nic.PublicIP
+}
+
+// checkPrivateEgress warns with an event when a network interface without external IP is in a subnetwork
+// with neither Private Google Access nor a Cloud NAT, since the instance then silently fails to pull images.
+// The check is best effort: failing to get the subnetwork or routers is only logged.
+func (r *Reconciler) checkPrivateEgress() {
+	region := r.region()
+	var routers []*compute.Router
+	for i, nic := range r.providerSpec.NetworkInterfaces {
+		if hasPublicIP(nic) {
+			continue
+		}
+		network := nic.Network
+		if nic.Subnetwork != "" {
+			subnetwork, err := r.computeService.SubnetworksGet(r.Context, r.networkProjectID(), region, nic.Subnetwork)
+			if err != nil {
+				r.logger.Error(err, "Failed to check private egress of the network interface", "subnetwork", nic.Subnetwork)
+				continue
+			}
+			if subnetwork.PrivateIpGoogleAccess {
+				continue
+			}
+			network = path.Base(subnetwork.Network)
+		}
+		if network == "" {
+			network = "default"
+		}
+
+		if routers == nil {
+			var err error
+			if routers, err = r.computeService.RoutersList(r.Context, r.networkProjectID(), region); err != nil {
+				r.logger.Error(err, "Failed to check private egress of the network interface", "region", region)
+				return
+			}
+		}
+		if natCoversSubnetwork(routers, network, nic.Subnetwork) {
+			continue
+		}
+
+		message := fmt.Sprintf("Network interface %d has no external IP and neither a Cloud NAT nor Private Google Access is configured for network %q in region %q, the instance will likely fail to pull images", i, network, region)
+		r.logger.Info("Network interface has no private egress", "networkInterface", i, "network", network, "subnetwork", nic.Subnetwork)
+		if r.eventRecorder != nil {
+			r.eventRecorder.Event(r.machine, apicorev1.EventTypeWarning, "NoPrivateEgress", message)
+		}
+	}
+}
+
+// natCoversSubnetwork returns true if a Cloud NAT of the routers translates the primary range of the
+// subnetwork of the network. An empty subnetwork is the auto mode subnetwork, only covered by NATs of
+// every subnetwork.
+func natCoversSubnetwork(routers []*compute.Router, network, subnetwork string) bool {
+	for _, router := range routers {
+		if path.Base(router.Network) != network {
+			continue
+		}
+		for _, nat := range router.Nats {
+			switch nat.SourceSubnetworkIpRangesToNat {
+			case "ALL_SUBNETWORKS_ALL_IP_RANGES", "ALL_SUBNETWORKS_ALL_PRIMARY_IP_RANGES":
+				return true
+			case "LIST_OF_SUBNETWORKS":
+				for _, natSubnetwork := range nat.Subnetworks {
+					if subnetwork != "" && path.Base(natSubnetwork.Name) == subnetwork {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
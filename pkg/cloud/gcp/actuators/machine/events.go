@@ -0,0 +1,139 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	apicorev1 "k8s.io/api/core/v1"
+)
+
+// operationEventReasons are the reasons of the events recorded on success and failure of the actuator operations.
+// Successful updates are not recorded, they happen on every resync.
+var operationEventReasons = map[string][2]string{
+	"create": {"Created", "FailedCreate"},
+	"update": {"", "FailedUpdate"},
+	"delete": {"Deleted", "FailedDelete"},
+}
+
+// failureEventInterval is the minimum interval between two failure events with the same reason on a machine.
+// Failing machines are reconciled over and over, recording every failure floods the event stream and etcd.
+const failureEventInterval = 10 * time.Minute
+
+// failureEvents throttles the failure events of the actuator operations per machine and reason.
+type failureEvents struct {
+	lock  sync.Mutex
+	now   func() time.Time
+	items map[string]*failureEventState
+}
+
+type failureEventState struct {
+	lastRecorded time.Time
+	suppressed   int
+	since        time.Time
+}
+
+func newFailureEvents() *failureEvents {
+	return &failureEvents{
+		now:   time.Now,
+		items: map[string]*failureEventState{},
+	}
+}
+
+// record returns whether a failure event with the reason is to be recorded on the machine and, if so, the number
+// of failures suppressed since the last recorded one along with the time of the first of them.
+// A nil failureEvents records every event.
+func (f *failureEvents) record(machine, reason string) (bool, int, time.Time) {
+	if f == nil {
+		return true, 0, time.Time{}
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	now := f.now()
+	key := machine + "/" + reason
+	state, ok := f.items[key]
+	if !ok {
+		f.items[key] = &failureEventState{lastRecorded: now}
+		return true, 0, time.Time{}
+	}
+	if now.Sub(state.lastRecorded) < failureEventInterval {
+		if state.suppressed == 0 {
+			state.since = now
+		}
+		state.suppressed++
+		return false, 0, time.Time{}
+	}
+	suppressed, since := state.suppressed, state.since
+	f.items[key] = &failureEventState{lastRecorded: now}
+	return true, suppressed, since
+}
+
+// reset forgets the failures of the machine once an operation on it succeeded.
+func (f *failureEvents) reset(machine string) {
+	if f == nil {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for key := range f.items {
+		if strings.HasPrefix(key, machine+"/") {
+			delete(f.items, key)
+		}
+	}
+}
+
+// operationConsoleURL returns the Cloud Console page of a zone operation.
+func operationConsoleURL(project, zone, operation string) string {
+	return fmt.Sprintf("https://console.cloud.google.com/compute/operationsDetail/projects/%s/zones/%s/operations/%s?project=%s", project, zone, operation, project)
+}
+
+// recordOperationEvent records the outcome of an actuator operation on the machine. The event names the zone
+// and machine type of the instance and links the GCP operation started for it, if any, in the Cloud Console.
+// Repeated failures with the same reason are recorded at most every failureEventInterval with their count.
+func (s *machineScope) recordOperationEvent(operation string, err error) {
+	if s.eventRecorder == nil {
+		return
+	}
+	reasons := operationEventReasons[operation]
+	name := s.instanceName()
+	details := s.eventDetails()
+	machineKey := s.machine.Namespace + "/" + s.machine.Name
+	if err != nil {
+		ok, suppressed, since := s.failureEvents.record(machineKey, reasons[1])
+		if !ok {
+			return
+		}
+		message := fmt.Sprintf("Failed to %s instance %s (%s): %v", operation, name, details, err)
+		if suppressed > 0 {
+			message += fmt.Sprintf(" (%d more failures since %s)", suppressed, since.UTC().Format(time.RFC3339))
+		}
+		s.eventRecorder.Event(s.machine, apicorev1.EventTypeWarning, reasons[1], message)
+		return
+	}
+	s.failureEvents.reset(machineKey)
+	if reasons[0] == "" {
+		return
+	}
+	s.eventRecorder.Eventf(s.machine, apicorev1.EventTypeNormal, reasons[0], "%s instance %s (%s)", reasons[0], name, details)
+}
+
+// eventDetails describes the location and machine type of the instance and the GCP operation started for it.
+func (s *machineScope) eventDetails() string {
+	details := []string{
+		"zone " + s.providerSpec.Zone,
+		"machine type " + s.providerSpec.MachineType,
+	}
+	if op := s.operation; op != nil {
+		details = append(details, fmt.Sprintf("operation %s %s", op.Name, operationConsoleURL(s.projectID, s.providerSpec.Zone, op.Name)))
+	}
+	return strings.Join(details, ", ")
+}
+
+// setOperation records the GCP operation started on the instance, referenced by the events of the actuator operation.
+func (s *machineScope) setOperation(operation *compute.Operation) {
+	s.operation = operation
+}
@@ -0,0 +1,116 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecordOperationEvent(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	recorder := record.NewFakeRecorder(10)
+	scope := &machineScope{
+		Context:    context.TODO(),
+		machine:    &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+		},
+		computeService: mockComputeService,
+		eventRecorder:  recorder,
+	}
+	if err := newReconciler(scope).create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	scope.recordOperationEvent("create", nil)
+	scope.recordOperationEvent("update", nil)
+	scope.recordOperationEvent("update", errors.New("quota exceeded"))
+
+	expected := []string{
+		"Normal Created Created instance worker-0 (zone us-east1-b, machine type n1-standard-4, operation operation-insert-worker-0 " +
+			"https://console.cloud.google.com/compute/operationsDetail/projects/my-project/zones/us-east1-b/operations/operation-insert-worker-0?project=my-project)",
+		"Warning FailedUpdate Failed to update instance worker-0 (zone us-east1-b, machine type n1-standard-4",
+	}
+	for _, prefix := range expected {
+		select {
+		case event := <-recorder.Events:
+			if !strings.HasPrefix(event, prefix) {
+				t.Errorf("expected event %q, got %q", prefix, event)
+			}
+		default:
+			t.Errorf("expected event %q", prefix)
+		}
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no event for successful updates, got %q", <-recorder.Events)
+	}
+}
+
+func TestFailureEventsThrottling(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	events := newFailureEvents()
+	events.now = func() time.Time { return now }
+	recorder := record.NewFakeRecorder(10)
+	scope := &machineScope{
+		machine: &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "openshift-machine-api"}},
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+		},
+		eventRecorder: recorder,
+		failureEvents: events,
+	}
+	expectEvent := func(expected string) {
+		t.Helper()
+		select {
+		case event := <-recorder.Events:
+			if !strings.Contains(event, expected) {
+				t.Errorf("expected event containing %q, got %q", expected, event)
+			}
+		default:
+			t.Errorf("expected event containing %q", expected)
+		}
+	}
+	expectNoEvent := func() {
+		t.Helper()
+		select {
+		case event := <-recorder.Events:
+			t.Errorf("expected no event, got %q", event)
+		default:
+		}
+	}
+
+	scope.recordOperationEvent("create", errors.New("quota exceeded"))
+	expectEvent("FailedCreate")
+
+	// Failures within the interval are counted but not recorded, unless their reason differs.
+	now = now.Add(time.Minute)
+	scope.recordOperationEvent("create", errors.New("quota exceeded"))
+	now = now.Add(time.Minute)
+	scope.recordOperationEvent("create", errors.New("quota exceeded"))
+	expectNoEvent()
+	scope.recordOperationEvent("update", errors.New("quota exceeded"))
+	expectEvent("FailedUpdate")
+
+	now = now.Add(failureEventInterval)
+	scope.recordOperationEvent("create", errors.New("quota exceeded"))
+	expectEvent("(2 more failures since 2019-06-01T12:01:00Z)")
+
+	// A successful operation resets the throttling.
+	scope.recordOperationEvent("create", nil)
+	expectEvent("Created")
+	scope.recordOperationEvent("create", errors.New("quota exceeded"))
+	expectEvent("FailedCreate")
+}
@@ -0,0 +1,227 @@
+package machine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	externalAccountCredentialsType = "external_account"
+
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenTokenType   = "urn:ietf:params:oauth:token-type:access_token"
+	defaultTokenURL        = "https://sts.googleapis.com/v1/token"
+	impersonationTokenLife = "3600s"
+)
+
+// externalAccountConfig is the content of an external account (Workload Identity Federation) credentials JSON, see
+// https://cloud.google.com/iam/docs/workload-identity-federation-with-other-providers#create-cred-config
+type externalAccountConfig struct {
+	Type                           string                   `json:"type"`
+	Audience                       string                   `json:"audience"`
+	SubjectTokenType               string                   `json:"subject_token_type"`
+	TokenURL                       string                   `json:"token_url"`
+	ServiceAccountImpersonationURL string                   `json:"service_account_impersonation_url"`
+	CredentialSource               externalCredentialSource `json:"credential_source"`
+	QuotaProjectID                 string                   `json:"quota_project_id"`
+	// ProjectID is not part of the external account format, it is honored for parity with service account keys.
+	ProjectID string `json:"project_id"`
+}
+
+// externalCredentialSource locates the subject token exchanged for a GCP access token,
+// e.g. a projected Kubernetes service account token file.
+type externalCredentialSource struct {
+	File    string            `json:"file"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Format  struct {
+		Type                  string `json:"type"`
+		SubjectTokenFieldName string `json:"subject_token_field_name"`
+	} `json:"format"`
+}
+
+// externalAccountTokenSource exchanges the external subject token for a GCP access token with the
+// security token service, and impersonates the configured service account with it if any.
+func externalAccountTokenSource(ctx context.Context, config *externalAccountConfig, scopes ...string) (oauth2.TokenSource, error) {
+	if config.Audience == "" || config.SubjectTokenType == "" {
+		return nil, fmt.Errorf("external account credentials require audience and subject_token_type")
+	}
+	if config.CredentialSource.File == "" && config.CredentialSource.URL == "" {
+		return nil, fmt.Errorf("external account credentials require a file or url credential source")
+	}
+	if config.TokenURL == "" {
+		config.TokenURL = defaultTokenURL
+	}
+
+	var ts oauth2.TokenSource = &stsTokenSource{ctx: ctx, config: config, scopes: scopes}
+	if config.ServiceAccountImpersonationURL != "" {
+		ts = &impersonatedTokenSource{
+			ctx:    ctx,
+			base:   oauth2.ReuseTokenSource(nil, ts),
+			url:    config.ServiceAccountImpersonationURL,
+			scopes: scopes,
+		}
+	}
+	return oauth2.ReuseTokenSource(nil, ts), nil
+}
+
+type stsTokenSource struct {
+	ctx    context.Context
+	config *externalAccountConfig
+	scopes []string
+}
+
+// Token implements oauth2.TokenSource.
+func (s *stsTokenSource) Token() (*oauth2.Token, error) {
+	subjectToken, err := s.subjectToken()
+	if err != nil {
+		return nil, fmt.Errorf("error reading subject token: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":           {tokenExchangeGrantType},
+		"audience":             {s.config.Audience},
+		"scope":                {strings.Join(s.scopes, " ")},
+		"requested_token_type": {accessTokenTokenType},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {s.config.SubjectTokenType},
+	}
+	req, err := http.NewRequest("POST", s.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := doTokenRequest(s.ctx, req, &response); err != nil {
+		return nil, fmt.Errorf("error exchanging subject token: %v", err)
+	}
+	return &oauth2.Token{
+		AccessToken: response.AccessToken,
+		TokenType:   response.TokenType,
+		Expiry:      time.Now().Add(time.Duration(response.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// subjectToken reads the external token from the credential source. It is read on every exchange
+// since projected service account tokens are rotated.
+func (s *stsTokenSource) subjectToken() (string, error) {
+	source := s.config.CredentialSource
+	var content []byte
+	if source.File != "" {
+		data, err := ioutil.ReadFile(source.File)
+		if err != nil {
+			return "", err
+		}
+		content = data
+	} else {
+		req, err := http.NewRequest("GET", source.URL, nil)
+		if err != nil {
+			return "", err
+		}
+		for key, value := range source.Headers {
+			req.Header.Set(key, value)
+		}
+		res, err := oauth2.NewClient(s.ctx, nil).Do(req.WithContext(s.ctx))
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return "", err
+		}
+		if res.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("unexpected status %d: %s", res.StatusCode, data)
+		}
+		content = data
+	}
+
+	if source.Format.Type != "json" {
+		return strings.TrimSpace(string(content)), nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return "", err
+	}
+	token, ok := fields[source.Format.SubjectTokenFieldName].(string)
+	if !ok || token == "" {
+		return "", fmt.Errorf("missing subject token field %q", source.Format.SubjectTokenFieldName)
+	}
+	return token, nil
+}
+
+// impersonatedTokenSource returns access tokens of a service account, generated with the
+// iamcredentials generateAccessToken method authenticated with the base token source.
+type impersonatedTokenSource struct {
+	ctx  context.Context
+	base oauth2.TokenSource
+	// url is the generateAccessToken URL of the impersonated service account, see
+	// https://cloud.google.com/iam/docs/reference/credentials/rest/v1/projects.serviceAccounts/generateAccessToken
+	url    string
+	scopes []string
+}
+
+// Token implements oauth2.TokenSource.
+func (s *impersonatedTokenSource) Token() (*oauth2.Token, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"scope":    s.scopes,
+		"lifetime": impersonationTokenLife,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	baseToken, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+	baseToken.SetAuthHeader(req)
+
+	var response struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := doTokenRequest(s.ctx, req, &response); err != nil {
+		return nil, fmt.Errorf("error impersonating service account: %v", err)
+	}
+	return &oauth2.Token{
+		AccessToken: response.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      response.ExpireTime,
+	}, nil
+}
+
+// doTokenRequest sends a token request with the HTTP client of the context and decodes the JSON response.
+func doTokenRequest(ctx context.Context, req *http.Request, response interface{}) error {
+	res, err := oauth2.NewClient(ctx, nil).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, data)
+	}
+	return json.Unmarshal(data, response)
+}
@@ -0,0 +1,98 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestExternalAccountTokenSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/token":
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			if r.Form.Get("subject_token") != "projected-token" || r.Form.Get("grant_type") != tokenExchangeGrantType {
+				t.Errorf("unexpected token exchange request: %v", r.Form)
+			}
+			fmt.Fprint(w, `{"access_token": "federated-token", "token_type": "Bearer", "expires_in": 3600}`)
+		case "/v1/projects/-/serviceAccounts/worker@my-project.iam.gserviceaccount.com:generateAccessToken":
+			if r.Header.Get("Authorization") != "Bearer federated-token" {
+				t.Errorf("expected impersonation to be authenticated with the federated token, got %q", r.Header.Get("Authorization"))
+			}
+			fmt.Fprint(w, `{"accessToken": "impersonated-token", "expireTime": "2030-01-01T00:00:00Z"}`)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	if _, err := tokenFile.WriteString("projected-token\n"); err != nil {
+		t.Fatal(err)
+	}
+	tokenFile.Close()
+
+	config := externalAccountConfig{
+		Type:             externalAccountCredentialsType,
+		Audience:         "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		SubjectTokenType: "urn:ietf:params:oauth:token-type:jwt",
+		TokenURL:         server.URL + "/v1/token",
+		ProjectID:        "my-project",
+	}
+	config.CredentialSource.File = tokenFile.Name()
+
+	testCases := []struct {
+		name             string
+		impersonationURL string
+		expectedToken    string
+	}{
+		{
+			name:          "federated token",
+			expectedToken: "federated-token",
+		},
+		{
+			name:             "impersonated service account",
+			impersonationURL: server.URL + "/v1/projects/-/serviceAccounts/worker@my-project.iam.gserviceaccount.com:generateAccessToken",
+			expectedToken:    "impersonated-token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := config
+			config.ServiceAccountImpersonationURL = tc.impersonationURL
+			credentialsJSON, err := json.Marshal(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isExternalAccount(string(credentialsJSON)) {
+				t.Fatalf("expected credentials to be detected as external account")
+			}
+			creds, err := externalAccountCredentials(context.Background(), string(credentialsJSON), "https://www.googleapis.com/auth/cloud-platform")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if creds.ProjectID != "my-project" {
+				t.Errorf("expected project %q, got %q", "my-project", creds.ProjectID)
+			}
+			token, err := creds.TokenSource.Token()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if token.AccessToken != tc.expectedToken {
+				t.Errorf("expected token %q, got %q", tc.expectedToken, token.AccessToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package machine
+
+import (
+	"fmt"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machineclient "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/typed/machine/v1beta1"
+)
+
+// resourcesFinalizer keeps a machine until the GCP resources created for it, recorded in its provider status,
+// are deleted. The machine controller removes its own finalizer once the actuator deleted the machine,
+// this one guarantees that a machine is never removed while its disks, addresses or memberships remain.
+const resourcesFinalizer = "gcpprovider.openshift.io/resources"
+
+// addResourcesFinalizer adds the resources finalizer to the machine, before any resource is created for it.
+func addResourcesFinalizer(machineClient machineclient.MachineInterface, machine *machinev1.Machine) error {
+	if hasString(machine.Finalizers, resourcesFinalizer) {
+		return nil
+	}
+	machine.Finalizers = append(machine.Finalizers, resourcesFinalizer)
+	return updateFinalizers(machineClient, machine)
+}
+
+// removeResourcesFinalizer removes the resources finalizer from the machine once its resources are deleted.
+func removeResourcesFinalizer(machineClient machineclient.MachineInterface, machine *machinev1.Machine) error {
+	if !hasString(machine.Finalizers, resourcesFinalizer) {
+		return nil
+	}
+	machine.Finalizers = removeString(machine.Finalizers, resourcesFinalizer)
+	return updateFinalizers(machineClient, machine)
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeStrings returns the values of a followed by the values of b missing from a.
+func mergeStrings(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, value := range b {
+		if !hasString(merged, value) {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+// removeString returns values without value.
+func removeString(values []string, value string) []string {
+	var result []string
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func updateFinalizers(machineClient machineclient.MachineInterface, machine *machinev1.Machine) error {
+	updated, err := machineClient.Update(machine)
+	if err != nil {
+		return fmt.Errorf("failed to update finalizers of machine: %v", err)
+	}
+	// Later updates of the machine by the machine controller need the new resource version.
+	machine.ResourceVersion = updated.ResourceVersion
+	return nil
+}
@@ -0,0 +1,45 @@
+package machine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machinefake "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourcesFinalizer(t *testing.T) {
+	machine := &v1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "worker-0",
+			Namespace:  "openshift-machine-api",
+			Finalizers: []string{v1beta1.MachineFinalizer},
+		},
+	}
+	machineClient := machinefake.NewSimpleClientset(machine).MachineV1beta1().Machines(machine.Namespace)
+
+	for i := 0; i < 2; i++ {
+		if err := addResourcesFinalizer(machineClient, machine); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	updated, err := machineClient.Get(machine.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{v1beta1.MachineFinalizer, resourcesFinalizer}; !reflect.DeepEqual(updated.Finalizers, expected) {
+		t.Errorf("expected finalizers %v, got %v", expected, updated.Finalizers)
+	}
+
+	if err := removeResourcesFinalizer(machineClient, machine); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	updated, err = machineClient.Get(machine.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{v1beta1.MachineFinalizer}; !reflect.DeepEqual(updated.Finalizers, expected) {
+		t.Errorf("expected finalizers %v, got %v", expected, updated.Finalizers)
+	}
+}
@@ -0,0 +1,92 @@
+package machine
+
+import (
+	"fmt"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/features"
+	"google.golang.org/api/compute/v1"
+)
+
+const defaultFirewallProtocol = "tcp"
+
+// firewallNetwork returns the partial URL of the network of the primary network interface, the
+// default network when unset.
+func (r *Reconciler) firewallNetwork() string {
+	network := "default"
+	if len(r.providerSpec.NetworkInterfaces) > 0 && r.providerSpec.NetworkInterfaces[0].Network != "" {
+		network = r.providerSpec.NetworkInterfaces[0].Network
+	}
+	return fmt.Sprintf("projects/%s/global/networks/%s", r.networkProjectID(), network)
+}
+
+// desiredFirewall returns the firewall rule allowing the traffic of the rule to the tags of the machine.
+func (r *Reconciler) desiredFirewall(rule *gcpproviderv1.GCPFirewallRule) *compute.Firewall {
+	protocol := rule.Protocol
+	if protocol == "" {
+		protocol = defaultFirewallProtocol
+	}
+	return &compute.Firewall{
+		Name:        rule.Name,
+		Description: "Managed by the GCP machine controller",
+		Network:     r.firewallNetwork(),
+		Direction:   "INGRESS",
+		Allowed: []*compute.FirewallAllowed{
+			{
+				IPProtocol: protocol,
+				Ports:      rule.Ports,
+			},
+		},
+		SourceRanges: rule.SourceRanges,
+		SourceTags:   rule.SourceTags,
+		TargetTags:   r.providerSpec.Tags,
+	}
+}
+
+// ensureFirewallRules creates the firewall rules of the provider spec which do not exist and updates the
+// ones which drifted, e.g. after the tags of the machine changed. It is a no-op unless the FirewallRules
+// feature gate is enabled.
+func (r *Reconciler) ensureFirewallRules() error {
+	if len(r.providerSpec.FirewallRules) == 0 {
+		return nil
+	}
+	if !r.featureGates.Enabled(features.FirewallRules) {
+		r.logger.V(2).Info("Skipping firewall rules, the feature gate is disabled", "featureGate", features.FirewallRules)
+		return nil
+	}
+	for _, rule := range r.providerSpec.FirewallRules {
+		desired := r.desiredFirewall(rule)
+		existing, err := r.computeService.FirewallsGet(r.Context, r.networkProjectID(), rule.Name)
+		if err != nil && !isNotFoundError(err) {
+			return fmt.Errorf("error getting firewall rule %q: %v", rule.Name, err)
+		}
+
+		var operation *compute.Operation
+		switch {
+		case err != nil:
+			operation, err = r.computeService.FirewallsInsert(r.Context, r.networkProjectID(), desired)
+			if err != nil {
+				return fmt.Errorf("error creating firewall rule %q: %v", rule.Name, err)
+			}
+			r.logger.Info("Creating firewall rule", "firewall", rule.Name, "gcpOperation", operation.Name)
+		case !computeservice.FirewallUpToDate(existing, desired):
+			operation, err = r.computeService.FirewallsPatch(r.Context, r.networkProjectID(), rule.Name, &compute.Firewall{
+				Allowed:      desired.Allowed,
+				SourceRanges: desired.SourceRanges,
+				SourceTags:   desired.SourceTags,
+				TargetTags:   desired.TargetTags,
+			})
+			if err != nil {
+				return fmt.Errorf("error updating firewall rule %q: %v", rule.Name, err)
+			}
+			r.logger.Info("Updating firewall rule", "firewall", rule.Name, "gcpOperation", operation.Name)
+		default:
+			continue
+		}
+		if err := r.waitUntilGlobalOperationCompleted(r.networkProjectID(), operation.Name); err != nil {
+			return fmt.Errorf("error reconciling firewall rule %q: %v", rule.Name, err)
+		}
+	}
+	return nil
+}
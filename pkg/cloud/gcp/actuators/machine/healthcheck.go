@@ -0,0 +1,90 @@
+package machine
+
+import (
+	"fmt"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// updateReadyCondition sets the Ready condition of the provider status from the health state the backend
+// service of the health check reports for the instance. Failing to get the health state is not fatal, the
+// condition becomes Unknown.
+func (r *Reconciler) updateReadyCondition() {
+	healthCheck := r.providerSpec.HealthCheck
+	if healthCheck == nil {
+		return
+	}
+	region := r.region()
+	group := fmt.Sprintf("projects/%s/zones/%s/instanceGroups/%s", r.projectID, r.providerSpec.Zone, r.instanceGroupName(healthCheck.InstanceGroup))
+	health, err := r.computeService.RegionBackendServicesGetHealth(r.Context, r.projectID, region, healthCheck.BackendService, &compute.ResourceGroupReference{
+		Group: group,
+	})
+	if err != nil {
+		r.logger.Error(err, "Failed to get instance health", "backendService", healthCheck.BackendService)
+		setCondition(r.providerStatus, gcpproviderv1.MachineReady, corev1.ConditionUnknown, "HealthCheckUnavailable",
+			fmt.Sprintf("error getting health of backend service %q in region %q: %v", healthCheck.BackendService, region, err))
+		return
+	}
+
+	instanceURL := r.instanceURL()
+	state := ""
+	for _, status := range health.HealthStatus {
+		if isInstanceURL(status.Instance, instanceURL) {
+			state = status.HealthState
+		}
+	}
+	switch state {
+	case "HEALTHY":
+		setCondition(r.providerStatus, gcpproviderv1.MachineReady, corev1.ConditionTrue, "HealthCheckPassed",
+			fmt.Sprintf("backend service %q reports the instance healthy", healthCheck.BackendService))
+	case "":
+		setCondition(r.providerStatus, gcpproviderv1.MachineReady, corev1.ConditionUnknown, "InstanceNotInBackend",
+			fmt.Sprintf("backend service %q does not report the health of the instance yet", healthCheck.BackendService))
+	default:
+		setCondition(r.providerStatus, gcpproviderv1.MachineReady, corev1.ConditionFalse, "HealthCheckFailed",
+			fmt.Sprintf("backend service %q reports the instance %s", healthCheck.BackendService, state))
+	}
+}
+
+// hasCondition returns true if the condition of the provider status is true.
+func hasCondition(providerStatus *gcpproviderv1.GCPMachineProviderStatus, conditionType gcpproviderv1.GCPMachineProviderConditionType) bool {
+	condition := getCondition(providerStatus, conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// getCondition returns the condition of the provider status, or nil when it is not set.
+func getCondition(providerStatus *gcpproviderv1.GCPMachineProviderStatus, conditionType gcpproviderv1.GCPMachineProviderConditionType) *gcpproviderv1.GCPMachineProviderCondition {
+	for i := range providerStatus.Conditions {
+		if providerStatus.Conditions[i].Type == conditionType {
+			return &providerStatus.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setCondition sets the condition of the provider status. The transition time only changes with the status.
+func setCondition(providerStatus *gcpproviderv1.GCPMachineProviderStatus, conditionType gcpproviderv1.GCPMachineProviderConditionType, status corev1.ConditionStatus, reason, message string) {
+	for i := range providerStatus.Conditions {
+		condition := &providerStatus.Conditions[i]
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status != status {
+			condition.Status = status
+			condition.LastTransitionTime = metav1.Now()
+		}
+		condition.Reason = reason
+		condition.Message = message
+		return
+	}
+	providerStatus.Conditions = append(providerStatus.Conditions, gcpproviderv1.GCPMachineProviderCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
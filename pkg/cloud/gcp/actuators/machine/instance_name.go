@@ -0,0 +1,73 @@
+package machine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const instanceNameHashLength = 8
+
+// instanceName returns the GCE instance name for a machine. GCE requires RFC1035 names
+// of at most 63 characters; longer machine names, e.g. generated from long MachineSet names,
+// are deterministically truncated and suffixed with a hash of the full name to keep them unique.
+func instanceName(machineName string) string {
+	if len(machineName) <= validation.DNS1035LabelMaxLength {
+		return machineName
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(machineName)))[:instanceNameHashLength]
+	prefix := strings.TrimRight(machineName[:validation.DNS1035LabelMaxLength-instanceNameHashLength-1], "-")
+	return fmt.Sprintf("%s-%s", prefix, hash)
+}
+
+// suffixedName returns the name of a resource of the instance: the instance name followed by the suffix, e.g.
+// worker-0-disk-1. Names longer than the 63 characters allowed by GCE keep the suffix, the instance name is
+// truncated and suffixed with a hash of the full name to keep them unique.
+func suffixedName(instanceName, suffix string) string {
+	name := instanceName + suffix
+	if len(name) <= validation.DNS1035LabelMaxLength {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))[:instanceNameHashLength]
+	prefix := strings.TrimRight(instanceName[:validation.DNS1035LabelMaxLength-len(suffix)-instanceNameHashLength-1], "-")
+	return fmt.Sprintf("%s-%s%s", prefix, hash, suffix)
+}
+
+// validateInstanceName checks the instance name derived from the machine name is accepted by GCE.
+func validateInstanceName(machineName string) error {
+	name := instanceName(machineName)
+	if errs := validation.IsDNS1035Label(name); len(errs) > 0 {
+		return fmt.Errorf("instance name %q derived from machine name %q is invalid: %s", name, machineName, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// prefixedInstanceName returns the instance name of a machine whose provider spec sets an instance name prefix:
+// the prefix followed by a hash of the machine namespace and name, so instances follow the naming conventions of
+// the project whatever the machine names, and machines of the same name in different namespaces do not collide.
+func prefixedInstanceName(machine *machinev1.Machine, prefix string) string {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(machine.Namespace+"/"+machine.Name)))[:instanceNameHashLength]
+	return fmt.Sprintf("%s-%s", strings.TrimRight(prefix, "-"), hash)
+}
+
+// instanceName returns the name of the instance of the machine. Once the instance was created, it is the name
+// recorded in the provider status, or in the provider ID for instances created before names were recorded, so
+// changing the instance name prefix of the provider spec does not orphan the instance. Otherwise the name is
+// derived from the machine and its provider spec.
+func (s *machineScope) instanceName() string {
+	if s.providerStatus != nil && s.providerStatus.InstanceName != "" {
+		return s.providerStatus.InstanceName
+	}
+	if s.machine.Spec.ProviderID != nil && *s.machine.Spec.ProviderID != "" {
+		if providerID, err := ParseProviderID(*s.machine.Spec.ProviderID); err == nil {
+			return providerID.Instance
+		}
+	}
+	if s.providerSpec.InstanceNamePrefix != "" {
+		return prefixedInstanceName(s.machine, s.providerSpec.InstanceNamePrefix)
+	}
+	return instanceName(s.machine.Name)
+}
@@ -0,0 +1,110 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"google.golang.org/api/compute/v1"
+)
+
+// instanceCache caches the instances of each project, listed with one aggregated list request per refresh
+// interval, so the steady-state resyncs of hundreds of machines do not each get their instance. Instances
+// missing from the cache, e.g. created since the last refresh, are got from the API.
+type instanceCache struct {
+	interval time.Duration
+
+	lock     sync.Mutex
+	projects map[string]*projectInstances
+}
+
+// projectInstances are the cached instances of a project, by zone and name.
+type projectInstances struct {
+	// lock serializes the refreshes of the project, so concurrent reconciles list its instances once.
+	lock      sync.Mutex
+	refreshed time.Time
+	instances map[string]*compute.Instance
+}
+
+func newInstanceCache(interval time.Duration) *instanceCache {
+	return &instanceCache{
+		interval: interval,
+		projects: map[string]*projectInstances{},
+	}
+}
+
+func (c *instanceCache) project(project string) *projectInstances {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached, ok := c.projects[project]
+	if !ok {
+		cached = &projectInstances{}
+		c.projects[project] = cached
+	}
+	return cached
+}
+
+// get returns the cached instance, listing the instances of the project first when they are older than the
+// refresh interval. It returns nil when the cache does not have the instance.
+func (c *instanceCache) get(ctx context.Context, computeService computeservice.GCPComputeService, project, zone, name string) (*compute.Instance, error) {
+	cached := c.project(project)
+	cached.lock.Lock()
+	defer cached.lock.Unlock()
+
+	if time.Since(cached.refreshed) >= c.interval {
+		instances, err := computeService.InstancesAggregatedList(ctx, project, "")
+		if err != nil {
+			return nil, fmt.Errorf("error listing instances of project %q: %v", project, err)
+		}
+		cached.instances = map[string]*compute.Instance{}
+		for _, instance := range instances {
+			cached.instances[path.Join(path.Base(instance.Zone), instance.Name)] = instance
+		}
+		cached.refreshed = time.Now()
+	}
+	instance, ok := cached.instances[path.Join(zone, name)]
+	if !ok {
+		return nil, nil
+	}
+	// Callers may modify the instance.
+	copied := *instance
+	return &copied, nil
+}
+
+// invalidate removes the instance from the cache, e.g. once the controller changed it, so it is got from the
+// API until the next refresh.
+func (c *instanceCache) invalidate(project, zone, name string) {
+	cached := c.project(project)
+	cached.lock.Lock()
+	defer cached.lock.Unlock()
+	delete(cached.instances, path.Join(zone, name))
+}
+
+// getInstance returns the instance of the machine from the instance cache when it is enabled and has it,
+// otherwise from the API. cached is true when the instance comes from the cache, it is then up to the
+// refresh interval old. Failing to refresh the cache falls back to the API.
+func (r *Reconciler) getInstance() (instance *compute.Instance, cached bool, err error) {
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	if r.instanceCache != nil {
+		instance, err := r.instanceCache.get(r.Context, r.computeService, r.projectID, zone, name)
+		if err != nil {
+			r.logger.Error(err, "Failed to refresh the instance cache")
+		} else if instance != nil {
+			return instance, true, nil
+		}
+	}
+	instance, err = r.computeService.InstancesGet(r.Context, r.projectID, zone, name)
+	return instance, false, err
+}
+
+// invalidateInstance removes the instance of the machine from the instance cache, if enabled, once the
+// controller changed it.
+func (r *Reconciler) invalidateInstance() {
+	if r.instanceCache != nil {
+		r.instanceCache.invalidate(r.projectID, r.providerSpec.Zone, r.instanceName())
+	}
+}
@@ -0,0 +1,75 @@
+package machine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInstanceCache(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	cache := newInstanceCache(time.Hour)
+	newMachineReconciler := func(name string) *Reconciler {
+		return newReconciler(&machineScope{
+			Context: context.TODO(),
+			machine: &v1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+			},
+			coreClient: controllerfake.NewFakeClient(),
+			projectID:  "my-project",
+			providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+				Zone:        "us-east1-b",
+				MachineType: "n1-standard-4",
+				Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+				SSHKeys:     []string{"core:ssh-rsa AAAAA core"},
+			},
+			computeService: mockComputeService,
+			instanceCache:  cache,
+		})
+	}
+	reconciler := newMachineReconciler("worker-0")
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+
+	// Instances are not got once cached.
+	mockComputeService.FailOn("InstancesGet", computeservice.APIError(http.StatusInternalServerError, "backendError", "backend error"))
+	if exists, err := reconciler.exists(); err != nil || !exists {
+		t.Errorf("expected the cached instance to exist, got %v, %v", exists, err)
+	}
+	if err := reconciler.update(); err != nil {
+		t.Errorf("reconciler was not expected to return error: %v", err)
+	}
+
+	// Updating the metadata gets the current instance for its fingerprint.
+	reconciler.providerSpec.SSHKeys = []string{"core:ssh-rsa BBBBB core"}
+	if err := reconciler.ensureMetadata(); err == nil {
+		t.Errorf("expected the metadata update to get the instance")
+	}
+	mockComputeService.ClearFailures()
+	if err := reconciler.ensureMetadata(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectMetadata(t, mockComputeService, map[string]string{
+		"ssh-keys": "core:ssh-rsa BBBBB core",
+	})
+
+	// The updated instance is no longer cached.
+	mockComputeService.FailOn("InstancesGet", computeservice.APIError(http.StatusInternalServerError, "backendError", "backend error"))
+	if _, err := reconciler.exists(); err == nil {
+		t.Errorf("expected the updated instance to be got")
+	}
+	mockComputeService.ClearFailures()
+
+	// Instances missing from the cache are got.
+	if exists, err := newMachineReconciler("worker-1").exists(); err != nil || exists {
+		t.Errorf("expected the instance of a new machine not to exist, got %v, %v", exists, err)
+	}
+}
@@ -0,0 +1,124 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"google.golang.org/api/compute/v1"
+)
+
+// instanceGroupName returns the name of the target instance group in the machine zone.
+func (r *Reconciler) instanceGroupName(targetInstanceGroup string) string {
+	return strings.Replace(targetInstanceGroup, gcpproviderv1.InstanceGroupZonePlaceholder, r.providerSpec.Zone, -1)
+}
+
+// createInstanceGroup creates the unmanaged instance group in the machine zone, in the network of the
+// primary network interface of the instance if set.
+func (r *Reconciler) createInstanceGroup(name string) error {
+	zone := r.providerSpec.Zone
+	instanceGroup := &compute.InstanceGroup{
+		Name:        name,
+		Description: "Created by the GCP machine controller",
+	}
+	if len(r.providerSpec.NetworkInterfaces) > 0 && r.providerSpec.NetworkInterfaces[0].Network != "" {
+		instanceGroup.Network = fmt.Sprintf("projects/%s/global/networks/%s", r.networkProjectID(), r.providerSpec.NetworkInterfaces[0].Network)
+	}
+	operation, err := r.computeService.InstanceGroupsInsert(r.Context, r.projectID, zone, instanceGroup)
+	if err != nil {
+		if isAlreadyExistsError(err) {
+			return nil
+		}
+		return fmt.Errorf("error creating instance group %q in zone %q: %v", name, zone, err)
+	}
+	r.logger.Info("Creating instance group", "instanceGroup", name, "gcpOperation", operation.Name)
+	if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+		return fmt.Errorf("error creating instance group %q in zone %q: %v", name, zone, err)
+	}
+	return nil
+}
+
+// isInstanceGroupMember returns true if the instance group members include the instance partial URL.
+func (r *Reconciler) isInstanceGroupMember(name string, instanceURL string) (bool, error) {
+	members, err := r.computeService.InstanceGroupsListInstances(r.Context, r.projectID, r.providerSpec.Zone, name, &compute.InstanceGroupsListInstancesRequest{})
+	if err != nil {
+		return false, err
+	}
+	for _, member := range members {
+		if isInstanceURL(member.Instance, instanceURL) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ensureInstanceGroupsMembership adds the instance to the instance groups of the provider spec it is not a member of yet.
+// Instance groups missing in the machine zone are created first.
+func (r *Reconciler) ensureInstanceGroupsMembership() error {
+	zone := r.providerSpec.Zone
+	instanceURL := r.instanceURL()
+	for _, targetInstanceGroup := range r.providerSpec.TargetInstanceGroups {
+		name := r.instanceGroupName(targetInstanceGroup)
+		member, err := r.isInstanceGroupMember(name, instanceURL)
+		if isNotFoundError(err) {
+			member, err = false, r.createInstanceGroup(name)
+		}
+		if err != nil {
+			return fmt.Errorf("error listing instances of instance group %q in zone %q: %v", name, zone, err)
+		}
+		if !hasString(r.providerStatus.InstanceGroups, name) {
+			r.providerStatus.InstanceGroups = append(r.providerStatus.InstanceGroups, name)
+		}
+		if member {
+			continue
+		}
+		operation, err := r.computeService.InstanceGroupsAddInstances(r.Context, r.projectID, zone, name, &compute.InstanceGroupsAddInstancesRequest{
+			Instances: []*compute.InstanceReference{{Instance: instanceURL}},
+		})
+		if err != nil {
+			return fmt.Errorf("error adding instance to instance group %q in zone %q: %v", name, zone, err)
+		}
+		r.logger.Info("Adding instance to instance group", "instanceGroup", name, "gcpOperation", operation.Name)
+		if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+			return fmt.Errorf("error adding instance to instance group %q in zone %q: %v", name, zone, err)
+		}
+	}
+	return nil
+}
+
+// removeFromInstanceGroups removes the instance from the instance groups of the provider spec and provider status
+// it is a member of. Instance groups which do not exist anymore are skipped.
+func (r *Reconciler) removeFromInstanceGroups() error {
+	zone := r.providerSpec.Zone
+	instanceURL := r.instanceURL()
+	var names []string
+	for _, targetInstanceGroup := range r.providerSpec.TargetInstanceGroups {
+		names = append(names, r.instanceGroupName(targetInstanceGroup))
+	}
+	for _, name := range mergeStrings(names, r.providerStatus.InstanceGroups) {
+		member, err := r.isInstanceGroupMember(name, instanceURL)
+		if err != nil {
+			if isNotFoundError(err) {
+				r.providerStatus.InstanceGroups = removeString(r.providerStatus.InstanceGroups, name)
+				continue
+			}
+			return fmt.Errorf("error listing instances of instance group %q in zone %q: %v", name, zone, err)
+		}
+		if !member {
+			r.providerStatus.InstanceGroups = removeString(r.providerStatus.InstanceGroups, name)
+			continue
+		}
+		operation, err := r.computeService.InstanceGroupsRemoveInstances(r.Context, r.projectID, zone, name, &compute.InstanceGroupsRemoveInstancesRequest{
+			Instances: []*compute.InstanceReference{{Instance: instanceURL}},
+		})
+		if err != nil {
+			return fmt.Errorf("error removing instance from instance group %q in zone %q: %v", name, zone, err)
+		}
+		r.logger.Info("Removing instance from instance group", "instanceGroup", name, "gcpOperation", operation.Name)
+		if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+			return fmt.Errorf("error removing instance from instance group %q in zone %q: %v", name, zone, err)
+		}
+		r.providerStatus.InstanceGroups = removeString(r.providerStatus.InstanceGroups, name)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package machine
+
+import (
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setLastOperation records the outcome of an actuator operation started at startTime in the provider status.
+// A successful update following a successful update is not recorded, so resyncs do not rewrite the machine status.
+func (s *machineScope) setLastOperation(operationType string, startTime metav1.Time, err error) {
+	last := s.providerStatus.LastOperation
+	if err == nil && operationType == "update" && last != nil && last.Type == operationType && last.Status == v1beta1.LastOperationSucceeded {
+		return
+	}
+	operation := &v1beta1.GCPLastOperation{
+		Type:      operationType,
+		Status:    v1beta1.LastOperationSucceeded,
+		StartTime: startTime,
+	}
+	if err != nil {
+		operation.Status = v1beta1.LastOperationFailed
+		operation.ErrorMessage = err.Error()
+	}
+	if s.operation != nil {
+		operation.OperationURL = s.operation.SelfLink
+	}
+	s.providerStatus.LastOperation = operation
+}
@@ -0,0 +1,61 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSetLastOperation(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	scope := &machineScope{
+		Context:    context.TODO(),
+		machine:    &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+		},
+		providerStatus: &gcpv1beta1.GCPMachineProviderStatus{},
+		computeService: mockComputeService,
+	}
+	startTime := metav1.Now()
+	err := newReconciler(scope).create()
+	if err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	scope.setLastOperation("create", startTime, err)
+	last := scope.providerStatus.LastOperation
+	if last == nil || last.Type != "create" || last.Status != gcpv1beta1.LastOperationSucceeded || !last.StartTime.Equal(&startTime) {
+		t.Fatalf("expected a successful create operation, got %+v", last)
+	}
+	expectedURL := "https://www.googleapis.com/compute/v1/projects/my-project/zones/us-east1-b/operations/operation-insert-worker-0"
+	if last.OperationURL != expectedURL {
+		t.Errorf("expected operation URL %q, got %q", expectedURL, last.OperationURL)
+	}
+
+	scope.operation = nil
+	scope.setLastOperation("update", metav1.Now(), errors.New("quota exceeded"))
+	last = scope.providerStatus.LastOperation
+	if last.Type != "update" || last.Status != gcpv1beta1.LastOperationFailed || last.ErrorMessage != "quota exceeded" || last.OperationURL != "" {
+		t.Fatalf("expected a failed update operation, got %+v", last)
+	}
+
+	// Successful resyncs only record the first successful update.
+	first := metav1.Now()
+	scope.setLastOperation("update", first, nil)
+	scope.setLastOperation("update", metav1.NewTime(first.Add(time.Second)), nil)
+	last = scope.providerStatus.LastOperation
+	if last.Status != gcpv1beta1.LastOperationSucceeded || !last.StartTime.Equal(&first) {
+		t.Errorf("expected the first successful update to be kept, got %+v", last)
+	}
+}
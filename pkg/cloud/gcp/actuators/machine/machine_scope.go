@@ -1,22 +1,28 @@
 package machine
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+	"reflect"
+	"strings"
 
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/compute/v1"
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
 
 	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
 	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/features"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/version"
+	"google.golang.org/api/compute/v1"
 	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
 	machineclient "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/typed/machine/v1beta1"
 	apicorev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/klog"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
@@ -28,13 +34,51 @@ const (
 
 // machineScopeParams defines the input parameters used to create a new MachineScope.
 type machineScopeParams struct {
+	context.Context
+
 	machineClient machineclient.MachineV1beta1Interface
 	coreClient    controllerclient.Client
 	machine       *machinev1.Machine
+	// logger is the logger of the actuator operation, the scope attaches the project and zone to it.
+	logger logr.Logger
+	// preflightChecksEnabled makes create() verify referenced GCP resources exist before inserting the instance.
+	preflightChecksEnabled bool
+	// apiRateLimiter throttles the GCP API requests of all machines, nil disables rate limiting.
+	apiRateLimiter *rate.Limiter
+	// computeEndpoint overrides the compute API base path when set.
+	computeEndpoint string
+	// proxy configures the proxy used to reach the GCP APIs.
+	proxy ProxyConfig
+	// credentialsCache caches the credentials built from credentials secrets, nil disables caching.
+	credentialsCache *credentialsCache
+	// userAgent identifies the provider in GCP API requests, the machine cluster ID is appended to it.
+	userAgent string
+	// eventRecorder records events on the machine, it may be nil.
+	eventRecorder record.EventRecorder
+	// connectivityChecker is told about the credentials used by the machine so readiness probes check them.
+	connectivityChecker *connectivityChecker
+	// featureGates enables experimental features, it may be nil.
+	featureGates *features.FeatureGate
+	// preemptibleNode are the labels and taints added to the nodes of preemptible machines.
+	preemptibleNode PreemptibleNodeConfig
+	// auditLog logs the mutating GCP API requests of the machine to the audit log.
+	auditLog bool
+	// failureEvents throttles the failure events of the machine, nil records every event.
+	failureEvents *failureEvents
+	// instanceCache caches the instances of the project, nil disables caching.
+	instanceCache *instanceCache
+	// clusterOwnedLabel labels the instance and disks with the cluster owned label and checks it before deleting them.
+	clusterOwnedLabel bool
+	// zoneSelector selects the zones of machines without a zone, nil always selects the first zone of the region.
+	zoneSelector *zoneSelector
+	// machineSetClient gets the machine sets of the namespace of the machine.
+	machineSetClient machineclient.MachineSetInterface
 }
 
 // machineScope defines a scope defined around a machine and its cluster.
 type machineScope struct {
+	context.Context
+
 	machineClient  machineclient.MachineInterface
 	coreClient     controllerclient.Client
 	projectID      string
@@ -42,48 +86,204 @@ type machineScope struct {
 	machine        *machinev1.Machine
 	providerSpec   *v1beta1.GCPMachineProviderSpec
 	providerStatus *v1beta1.GCPMachineProviderStatus
+	// logger has the machine, namespace, operation, project and zone attached.
+	logger logr.Logger
+	// preflightChecksEnabled makes create() verify referenced GCP resources exist before inserting the instance.
+	preflightChecksEnabled bool
+	// featureGates enables experimental features, it may be nil.
+	featureGates *features.FeatureGate
+	// eventRecorder records events on the machine, it may be nil.
+	eventRecorder record.EventRecorder
+	// preemptibleNode are the labels and taints added to the nodes of preemptible machines.
+	preemptibleNode PreemptibleNodeConfig
+	// operation is the insert or delete operation of the instance started by the actuator operation, if any.
+	operation *compute.Operation
+	// serviceAccount is the email of the service account of the credentials, empty when unknown.
+	serviceAccount string
+	// failureEvents throttles the failure events of the machine, nil records every event.
+	failureEvents *failureEvents
+	// instanceCache caches the instances of the project, nil disables caching.
+	instanceCache *instanceCache
+	// clusterOwnedLabel labels the instance and disks with the cluster owned label and checks it before deleting them.
+	clusterOwnedLabel bool
+	// zoneSelector selects the zones of machines without a zone, nil always selects the first zone of the region.
+	zoneSelector *zoneSelector
+	// machineSetClient gets the machine sets of the namespace of the machine.
+	machineSetClient machineclient.MachineSetInterface
+	// httpClient is the client authenticated with the credentials of the machine.
+	httpClient *http.Client
+	// credentialsKey identifies the credentials of the machine, empty for the Application Default Credentials.
+	credentialsKey string
 }
 
 // newMachineScope creates a new MachineScope from the supplied parameters.
 // This is meant to be called for each machine actuator operation.
 func newMachineScope(params machineScopeParams) (*machineScope, error) {
+	if params.logger == nil {
+		params.logger = log.WithValues("machine", params.machine.Name, "namespace", params.machine.Namespace)
+	}
 	providerSpec, err := machineConfigFromProviderSpec(params.machine.Spec.ProviderSpec)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get machine config: %v", err)
 	}
+	providerStatus, err := providerStatusFromRawExtension(params.machine.Status.ProviderStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machine provider status: %v", err)
+	}
+	if providerStatus.Zone != "" {
+		// The zone selected for the instance, when the provider spec only sets a region or the machine set of the
+		// machine spreads its machines across zones.
+		providerSpec.Zone = providerStatus.Zone
+	}
 
-	serviceAccountJSON, err := getCredentialsSecret(params.coreClient, *params.machine, *providerSpec)
+	serviceAccountJSON, resourceVersion, err := getCredentialsSecret(params.Context, params.coreClient, *params.machine, *providerSpec)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get serviceAccountJSON: %v", err)
 	}
 
-	projectID, err := getProjectIDFromJSONKey([]byte(serviceAccountJSON))
+	credentialsKey := ""
+	if providerSpec.CredentialsSecret != nil {
+		credentialsKey = path.Join(params.machine.Namespace, providerSpec.CredentialsSecret.Name)
+	}
+	if providerSpec.ImpersonateServiceAccount != "" {
+		credentialsKey += "/" + providerSpec.ImpersonateServiceAccount
+	}
+	creds, err := params.credentialsCache.get(credentialsKey, resourceVersion, func() (*credentials, error) {
+		return newCredentials(params.proxy, serviceAccountJSON, providerSpec.ImpersonateServiceAccount)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error getting project from JSON key: %v", err)
+		return nil, fmt.Errorf("error getting credentials: %v", err)
+	}
+	projectID := creds.projectID
+	if providerSpec.ProjectID != "" {
+		projectID = providerSpec.ProjectID
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("unable to determine the GCP project, set projectID in the provider spec")
 	}
 
-	oauthClient, err := createOauth2Client(serviceAccountJSON, compute.CloudPlatformScope)
-	if err != nil {
-		return nil, fmt.Errorf("error creating oauth client: %v", err)
+	// Copy the cached client so wrapping its transport does not affect other machines.
+	oauthClient := *creds.client
+	oauthClient.Transport = tracingTransport(oauthClient.Transport)
+	if params.auditLog {
+		oauthClient.Transport = newAuditTransport(oauthClient.Transport, auditLog, params.machine, creds.serviceAccount)
+	}
+	if params.apiRateLimiter != nil {
+		oauthClient.Transport = computeservice.NewRateLimitedTransport(params.apiRateLimiter, oauthClient.Transport)
 	}
 
-	computeService, err := computeservice.NewComputeService(oauthClient)
+	computeService, err := computeservice.NewComputeService(&oauthClient, computeservice.ServiceOptions{
+		Endpoint:  params.computeEndpoint,
+		UserAgent: machineUserAgent(params.userAgent, params.machine),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating compute service: %v", err)
 	}
+	params.connectivityChecker.register(credentialsKey, projectID, computeService)
+
 	return &machineScope{
+		Context:        params.Context,
 		machineClient:  params.machineClient.Machines(params.machine.Namespace),
 		coreClient:     params.coreClient,
 		projectID:      projectID,
+		serviceAccount: creds.serviceAccount,
 		computeService: computeService,
 		machine:        params.machine,
 		providerSpec:   providerSpec,
+		providerStatus: providerStatus,
+		logger:         params.logger.WithValues("project", projectID, "zone", providerSpec.Zone),
+
+		preflightChecksEnabled: params.preflightChecksEnabled,
+		featureGates:           params.featureGates,
+		eventRecorder:          params.eventRecorder,
+		preemptibleNode:        params.preemptibleNode,
+		failureEvents:          params.failureEvents,
+		instanceCache:          params.instanceCache,
+		clusterOwnedLabel:      params.clusterOwnedLabel,
+		zoneSelector:           params.zoneSelector,
+		machineSetClient:       params.machineClient.MachineSets(params.machine.Namespace),
+		httpClient:             &oauthClient,
+		credentialsKey:         credentialsKey,
 	}, nil
 }
 
-// Close the MachineScope by updating the machine spec, machine status.
+// checkPermissions reports the required IAM permissions the machine credentials are missing in the project with
+// the MissingPermissions condition, and with an event when the missing permissions change.
+// Failing to check permissions is not fatal since the credentials may still be able to manage instances.
+func (m *machineScope) checkPermissions(checker *permissionsChecker) {
+	missing, err := checker.missingPermissions(m.Context, m.httpClient, m.credentialsKey, m.projectID)
+	if err != nil {
+		m.logger.Error(err, "Failed to check GCP permissions of the machine credentials")
+		return
+	}
+	if len(missing) == 0 {
+		if hasCondition(m.providerStatus, v1beta1.MachineMissingPermissions) {
+			setCondition(m.providerStatus, v1beta1.MachineMissingPermissions, apicorev1.ConditionFalse, "PermissionsGranted", "")
+		}
+		return
+	}
+	message := fmt.Sprintf("Credentials are missing permissions in project %q: %s", m.projectID, strings.Join(missing, ", "))
+	if condition := getCondition(m.providerStatus, v1beta1.MachineMissingPermissions); condition != nil && condition.Status == apicorev1.ConditionTrue && condition.Message == message {
+		return
+	}
+	setCondition(m.providerStatus, v1beta1.MachineMissingPermissions, apicorev1.ConditionTrue, "MissingPermissions", message)
+	m.logger.Info("Credentials are missing permissions", "missingPermissions", missing)
+	if m.eventRecorder != nil {
+		m.eventRecorder.Event(m.machine, apicorev1.EventTypeWarning, "MissingPermissions", message)
+	}
+}
+
+// machineUserAgent returns the User-Agent of the GCP API requests issued for the machine,
+// so support and audit logs can attribute the traffic to the provider and cluster.
+func machineUserAgent(userAgent string, machine *machinev1.Machine) string {
+	if userAgent == "" {
+		userAgent = version.UserAgent()
+	}
+	if clusterID := machine.Labels[machinev1.MachineClusterIDLabel]; clusterID != "" {
+		userAgent += " cluster/" + clusterID
+	}
+	return userAgent
+}
+
+// Close the MachineScope by updating the machine provider status if it changed.
 func (m *machineScope) Close() {
-	//TODO (alberto): implement this. Status can be refreshed here
+	if m.providerStatus == nil {
+		return
+	}
+	// Do not write an empty provider status to machines which never had one.
+	if m.machine.Status.ProviderStatus == nil && reflect.DeepEqual(*m.providerStatus, v1beta1.GCPMachineProviderStatus{}) {
+		return
+	}
+	m.providerStatus.APIVersion = v1beta1.SchemeGroupVersion.String()
+	m.providerStatus.Kind = "GCPMachineProviderStatus"
+	raw, err := json.Marshal(m.providerStatus)
+	if err != nil {
+		m.logger.Error(err, "Failed to encode machine provider status")
+		return
+	}
+	if m.machine.Status.ProviderStatus != nil && bytes.Equal(m.machine.Status.ProviderStatus.Raw, raw) {
+		return
+	}
+	m.machine.Status.ProviderStatus = &runtime.RawExtension{Raw: raw}
+	updated, err := m.machineClient.UpdateStatus(m.machine)
+	if err != nil {
+		m.logger.Error(err, "Failed to update machine provider status")
+		return
+	}
+	// Later updates of the machine by the machine controller need the new resource version.
+	m.machine.ResourceVersion = updated.ResourceVersion
+}
+
+// providerStatusFromRawExtension decodes the machine provider status, which is empty until first set.
+func providerStatusFromRawExtension(rawExtension *runtime.RawExtension) (*v1beta1.GCPMachineProviderStatus, error) {
+	providerStatus := &v1beta1.GCPMachineProviderStatus{}
+	if rawExtension == nil || len(rawExtension.Raw) == 0 {
+		return providerStatus, nil
+	}
+	if err := yaml.Unmarshal(rawExtension.Raw, providerStatus); err != nil {
+		return nil, fmt.Errorf("error unmarshalling providerStatus: %v", err)
+	}
+	return providerStatus, nil
 }
 
 // machineConfigFromProviderSpec tries to decode the JSON-encoded spec, falling back on getting a MachineClass if the value is absent.
@@ -101,7 +301,7 @@ func unmarshalProviderSpec(spec *runtime.RawExtension) (*v1beta1.GCPMachineProvi
 			return nil, fmt.Errorf("error unmarshalling providerSpec: %v", err)
 		}
 	}
-	klog.V(5).Infof("Found ProviderSpec: %+v", config)
+	log.V(5).Info("Found ProviderSpec", "providerSpec", config)
 	return &config, nil
 }
 
@@ -116,39 +316,21 @@ func unmarshalProviderSpec(spec *runtime.RawExtension) (*v1beta1.GCPMachineProvi
 //type: Opaque
 //data:
 //  serviceAccountJSON: base64 encoded content of the file
-func getCredentialsSecret(coreClient controllerclient.Client, machine machinev1.Machine, spec v1beta1.GCPMachineProviderSpec) (string, error) {
+//
+// The resource version of the secret is returned along with its content.
+func getCredentialsSecret(ctx context.Context, coreClient controllerclient.Client, machine machinev1.Machine, spec v1beta1.GCPMachineProviderSpec) (string, string, error) {
 	if spec.CredentialsSecret == nil {
-		return "", nil
+		return "", "", nil
 	}
 	var credentialsSecret apicorev1.Secret
 
-	if err := coreClient.Get(context.Background(), client.ObjectKey{Namespace: machine.GetNamespace(), Name: spec.CredentialsSecret.Name}, &credentialsSecret); err != nil {
-		return "", fmt.Errorf("error getting user data secret %q in namespace %q: %v", spec.UserDataSecret.Name, machine.GetNamespace(), err)
+	if err := coreClient.Get(ctx, client.ObjectKey{Namespace: machine.GetNamespace(), Name: spec.CredentialsSecret.Name}, &credentialsSecret); err != nil {
+		return "", "", fmt.Errorf("error getting user data secret %q in namespace %q: %v", spec.UserDataSecret.Name, machine.GetNamespace(), err)
 	}
 	data, exists := credentialsSecret.Data[credentialsSecretKey]
 	if !exists {
-		return "", fmt.Errorf("secret %v/%v does not have %q field set. Thus, no user data applied when creating an instance", machine.GetNamespace(), spec.UserDataSecret.Name, credentialsSecretKey)
-	}
-
-	return string(data), nil
-}
-
-func getProjectIDFromJSONKey(content []byte) (string, error) {
-	var JSONKey struct {
-		ProjectID string `json:"project_id"`
-	}
-	if err := json.Unmarshal(content, &JSONKey); err != nil {
-		return "", fmt.Errorf("error un marshalling JSON key: %v", err)
+		return "", "", fmt.Errorf("secret %v/%v does not have %q field set. Thus, no user data applied when creating an instance", machine.GetNamespace(), spec.UserDataSecret.Name, credentialsSecretKey)
 	}
-	return JSONKey.ProjectID, nil
-}
 
-func createOauth2Client(serviceAccountJSON string, scope ...string) (*http.Client, error) {
-	ctx := context.Background()
-
-	jwt, err := google.JWTConfigFromJSON([]byte(serviceAccountJSON), scope...)
-	if err != nil {
-		return nil, err
-	}
-	return oauth2.NewClient(ctx, jwt.TokenSource(ctx)), nil
+	return string(data), credentialsSecret.ResourceVersion, nil
 }
@@ -0,0 +1,106 @@
+package machine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machinefake "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewMachineScopeProject(t *testing.T) {
+	credentialsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gcp-sa",
+			Namespace: "openshift-machine-api",
+		},
+		Data: map[string][]byte{
+			credentialsSecretKey: []byte(`{"type": "service_account", "project_id": "my-project"}`),
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		providerSpec    string
+		expectedProject string
+	}{
+		{
+			name:            "project of the credentials",
+			providerSpec:    `{"zone": "us-east1-b", "credentialsSecret": {"name": "gcp-sa"}}`,
+			expectedProject: "my-project",
+		},
+		{
+			name:            "project override",
+			providerSpec:    `{"zone": "us-east1-b", "credentialsSecret": {"name": "gcp-sa"}, "projectID": "other-project"}`,
+			expectedProject: "other-project",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine := &machinev1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "worker",
+					Namespace: "openshift-machine-api",
+				},
+				Spec: machinev1.MachineSpec{
+					ProviderSpec: machinev1.ProviderSpec{
+						Value: &runtime.RawExtension{Raw: []byte(tc.providerSpec)},
+					},
+				},
+			}
+			scope, err := newMachineScope(machineScopeParams{
+				Context:       context.TODO(),
+				machineClient: machinefake.NewSimpleClientset().MachineV1beta1(),
+				coreClient:    controllerfake.NewFakeClient(credentialsSecret),
+				machine:       machine,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if scope.projectID != tc.expectedProject {
+				t.Errorf("expected project %q, got %q", tc.expectedProject, scope.projectID)
+			}
+		})
+	}
+}
+
+func TestCloseUpdatesProviderStatus(t *testing.T) {
+	machine := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "worker",
+			Namespace: "openshift-machine-api",
+		},
+	}
+	machineClient := machinefake.NewSimpleClientset(machine).MachineV1beta1().Machines(machine.Namespace)
+
+	scope := &machineScope{
+		machineClient:  machineClient,
+		machine:        machine,
+		providerStatus: &v1beta1.GCPMachineProviderStatus{},
+	}
+	scope.Close()
+	if machine.Status.ProviderStatus != nil {
+		t.Errorf("expected no provider status to be written when empty, got %s", machine.Status.ProviderStatus.Raw)
+	}
+
+	scope.providerStatus.PendingOperation = &v1beta1.GCPOperation{Name: "operation-1", Zone: "us-east1-b", Type: operationTypeInsert}
+	scope.Close()
+	updated, err := machineClient.Get(machine.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	providerStatus, err := providerStatusFromRawExtension(updated.Status.ProviderStatus)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(providerStatus.PendingOperation, scope.providerStatus.PendingOperation) {
+		t.Errorf("expected pending operation %+v, got %+v", scope.providerStatus.PendingOperation, providerStatus.PendingOperation)
+	}
+}
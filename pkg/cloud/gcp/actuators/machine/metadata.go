@@ -0,0 +1,242 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	apicorev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// sshKeysSecretKey is the key of the SSH key secret holding the ssh-keys entries.
+	sshKeysSecretKey = "sshKeys"
+
+	sshKeysMetadataKey             = "ssh-keys"
+	blockProjectSSHKeysMetadataKey = "block-project-ssh-keys"
+
+	// Size limits of instance metadata: https://cloud.google.com/compute/docs/metadata/setting-custom-metadata#limitations
+	maxMetadataKeySize   = 128
+	maxMetadataValueSize = 256 * 1024
+	maxMetadataSize      = 512 * 1024
+)
+
+// validateMetadataSize checks the metadata items against the size limits of GCE, so that oversized metadata
+// fails naming the offending key rather than with the error of the insert request.
+func validateMetadataSize(items []*compute.MetadataItems) error {
+	total := 0
+	for _, item := range items {
+		size := 0
+		if item.Value != nil {
+			size = len(*item.Value)
+		}
+		if len(item.Key) > maxMetadataKeySize {
+			return machineapierrors.InvalidMachineConfiguration("metadata key %q exceeds the limit of %d bytes", item.Key, maxMetadataKeySize)
+		}
+		if size > maxMetadataValueSize {
+			return machineapierrors.InvalidMachineConfiguration("value of metadata %q is %d bytes, which exceeds the limit of %d bytes per value", item.Key, size, maxMetadataValueSize)
+		}
+		total += len(item.Key) + size
+	}
+	if total > maxMetadataSize {
+		return machineapierrors.InvalidMachineConfiguration("metadata is %d bytes, which exceeds the limit of %d bytes in total", total, maxMetadataSize)
+	}
+	return nil
+}
+
+// metadataItems returns the instance metadata items of the provider spec, reading the values of valueFrom entries,
+// the SSH keys and the OS Config items.
+func (r *Reconciler) metadataItems() ([]*compute.MetadataItems, error) {
+	var items []*compute.MetadataItems
+	for _, metadata := range r.providerSpec.Metadata {
+		value, err := r.metadataValue(metadata)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &compute.MetadataItems{
+			Key:   metadata.Key,
+			Value: value,
+		})
+	}
+	sshItems, err := r.sshKeysMetadataItems()
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, sshItems...)
+	return append(items, r.osConfigMetadataItems()...), nil
+}
+
+// metadataValue returns the value of a metadata entry, read from its secret or config map when it has a valueFrom.
+func (r *Reconciler) metadataValue(metadata *v1beta1.GCPMetadata) (*string, error) {
+	if metadata.ValueFrom == nil {
+		return metadata.Value, nil
+	}
+	namespace := r.machine.GetNamespace()
+	if ref := metadata.ValueFrom.SecretKeyRef; ref != nil {
+		var secret apicorev1.Secret
+		if err := r.coreClient.Get(r.Context, client.ObjectKey{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+			return nil, fmt.Errorf("error getting secret %q in namespace %q for metadata %q: %v", ref.Name, namespace, metadata.Key, err)
+		}
+		data, ok := secret.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("secret %v/%v does not have %q field set for metadata %q", namespace, ref.Name, ref.Key, metadata.Key)
+		}
+		value := string(data)
+		return &value, nil
+	}
+	if ref := metadata.ValueFrom.ConfigMapKeyRef; ref != nil {
+		var configMap apicorev1.ConfigMap
+		if err := r.coreClient.Get(r.Context, client.ObjectKey{Namespace: namespace, Name: ref.Name}, &configMap); err != nil {
+			return nil, fmt.Errorf("error getting config map %q in namespace %q for metadata %q: %v", ref.Name, namespace, metadata.Key, err)
+		}
+		value, ok := configMap.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("config map %v/%v does not have %q field set for metadata %q", namespace, ref.Name, ref.Key, metadata.Key)
+		}
+		return &value, nil
+	}
+	return metadata.Value, nil
+}
+
+// secretMetadataKeys returns the metadata keys whose values are read from secrets, which are redacted when rendering instances.
+func (r *Reconciler) secretMetadataKeys() []string {
+	keys := append([]string{}, userDataMetadataKeys...)
+	for _, metadata := range r.providerSpec.Metadata {
+		if metadata.ValueFrom != nil && metadata.ValueFrom.SecretKeyRef != nil {
+			keys = append(keys, metadata.Key)
+		}
+	}
+	return keys
+}
+
+// sshKeysMetadataItems returns the ssh-keys and block-project-ssh-keys metadata items of the SSH key fields of the provider spec.
+func (r *Reconciler) sshKeysMetadataItems() ([]*compute.MetadataItems, error) {
+	var items []*compute.MetadataItems
+	keys := append([]string{}, r.providerSpec.SSHKeys...)
+	if ref := r.providerSpec.SSHKeySecret; ref != nil {
+		namespace := r.machine.GetNamespace()
+		var secret apicorev1.Secret
+		if err := r.coreClient.Get(r.Context, client.ObjectKey{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+			return nil, fmt.Errorf("error getting SSH key secret %q in namespace %q: %v", ref.Name, namespace, err)
+		}
+		data, ok := secret.Data[sshKeysSecretKey]
+		if !ok {
+			return nil, fmt.Errorf("secret %v/%v does not have %q field set", namespace, ref.Name, sshKeysSecretKey)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				keys = append(keys, line)
+			}
+		}
+	}
+	if len(keys) > 0 {
+		value := strings.Join(keys, "\n")
+		items = append(items, &compute.MetadataItems{Key: sshKeysMetadataKey, Value: &value})
+	}
+	if r.providerSpec.BlockProjectSSHKeys {
+		value := "TRUE"
+		items = append(items, &compute.MetadataItems{Key: blockProjectSSHKeysMetadataKey, Value: &value})
+	}
+	return items, nil
+}
+
+// managedMetadataItems returns the metadata items kept up to date on existing instances: the values read
+// from secrets and config maps, the SSH keys and the OS Config items. Other metadata is only set when the
+// instance is created.
+func (r *Reconciler) managedMetadataItems() ([]*compute.MetadataItems, error) {
+	var items []*compute.MetadataItems
+	for _, metadata := range r.providerSpec.Metadata {
+		if metadata.ValueFrom == nil {
+			continue
+		}
+		value, err := r.metadataValue(metadata)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &compute.MetadataItems{Key: metadata.Key, Value: value})
+	}
+	sshItems, err := r.sshKeysMetadataItems()
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, sshItems...)
+	return append(items, r.osConfigMetadataItems()...), nil
+}
+
+// ensureMetadata updates the managed metadata items of the instance when they changed,
+// e.g. when a bootstrap token or an SSH key is rotated.
+func (r *Reconciler) ensureMetadata() error {
+	managed, err := r.managedMetadataItems()
+	if err != nil {
+		return err
+	}
+	if len(managed) == 0 {
+		return nil
+	}
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	instance, cached, err := r.getInstance()
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil
+		}
+		return fmt.Errorf("error getting instance %q in zone %q: %v", name, zone, err)
+	}
+	updated, changed := updatedMetadata(instance, managed)
+	if changed && cached {
+		// The metadata fingerprint of a cached instance may be stale, update the current metadata.
+		instance, err = r.computeService.InstancesGet(r.Context, r.projectID, zone, name)
+		if err != nil {
+			return fmt.Errorf("error getting instance %q in zone %q: %v", name, zone, err)
+		}
+		updated, changed = updatedMetadata(instance, managed)
+	}
+	if !changed {
+		return nil
+	}
+	if err := validateMetadataSize(updated.Items); err != nil {
+		return err
+	}
+
+	operation, err := r.computeService.InstancesSetMetadata(r.Context, r.projectID, zone, name, updated)
+	if err != nil {
+		return fmt.Errorf("error updating metadata of instance %q in zone %q: %v", name, zone, err)
+	}
+	r.invalidateInstance()
+	if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+		return fmt.Errorf("error updating metadata of instance %q in zone %q: %v", name, zone, err)
+	}
+	r.logger.Info("Updated instance metadata", "instance", name)
+	return nil
+}
+
+// updatedMetadata returns the metadata of the instance with the managed items set, and whether they changed it.
+func updatedMetadata(instance *compute.Instance, managed []*compute.MetadataItems) (*compute.Metadata, bool) {
+	updated := &compute.Metadata{}
+	if instance.Metadata != nil {
+		updated.Fingerprint = instance.Metadata.Fingerprint
+		updated.Items = append([]*compute.MetadataItems{}, instance.Metadata.Items...)
+	}
+	changed := false
+	for _, desired := range managed {
+		found := false
+		for i, item := range updated.Items {
+			if item.Key != desired.Key {
+				continue
+			}
+			found = true
+			if item.Value == nil || *item.Value != *desired.Value {
+				updated.Items[i] = desired
+				changed = true
+			}
+		}
+		if !found {
+			updated.Items = append(updated.Items, desired)
+			changed = true
+		}
+	}
+	return updated, changed
+}
@@ -0,0 +1,264 @@
+package machine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMetadataValueFrom(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "openshift-machine-api"},
+		Data:       map[string][]byte{"token": []byte("abc")},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "openshift-machine-api"},
+		Data:       map[string]string{"endpoint": "https://api.example.com"},
+	}
+	coreClient := controllerfake.NewFakeClient(secret, configMap)
+	plain := "value"
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "openshift-machine-api"},
+		},
+		coreClient: coreClient,
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			Metadata: []*gcpv1beta1.GCPMetadata{
+				{Key: "plain", Value: &plain},
+				{Key: "token", ValueFrom: &gcpv1beta1.GCPMetadataValueSource{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "bootstrap"}, Key: "token"},
+				}},
+				{Key: "endpoint", ValueFrom: &gcpv1beta1.GCPMetadataValueSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cluster"}, Key: "endpoint"},
+				}},
+			},
+		},
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectMetadata(t, mockComputeService, map[string]string{"plain": "value", "token": "abc", "endpoint": "https://api.example.com"})
+
+	// Rotating the secret updates the instance metadata.
+	secret.Data["token"] = []byte("def")
+	if err := coreClient.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.ensureMetadata(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectMetadata(t, mockComputeService, map[string]string{"plain": "value", "token": "def", "endpoint": "https://api.example.com"})
+
+	// A missing secret key fails rather than clearing the value.
+	delete(secret.Data, "token")
+	if err := coreClient.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.ensureMetadata(); err == nil {
+		t.Error("reconciler was expected to return error")
+	}
+}
+
+func TestSSHKeysMetadata(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssh-keys", Namespace: "openshift-machine-api"},
+		Data:       map[string][]byte{"sshKeys": []byte("admin:ssh-ed25519 AAAAB admin\n")},
+	}
+	coreClient := controllerfake.NewFakeClient(secret)
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "openshift-machine-api"},
+		},
+		coreClient: coreClient,
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:                "us-east1-b",
+			MachineType:         "n1-standard-4",
+			Disks:               []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			SSHKeys:             []string{"core:ssh-rsa AAAAA core"},
+			SSHKeySecret:        &corev1.LocalObjectReference{Name: "ssh-keys"},
+			BlockProjectSSHKeys: true,
+		},
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectMetadata(t, mockComputeService, map[string]string{
+		"ssh-keys":               "core:ssh-rsa AAAAA core\nadmin:ssh-ed25519 AAAAB admin",
+		"block-project-ssh-keys": "TRUE",
+	})
+
+	// Rotating the keys of the secret updates the instance metadata.
+	secret.Data["sshKeys"] = []byte("admin:ssh-ed25519 AAAAC admin")
+	if err := coreClient.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.ensureMetadata(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectMetadata(t, mockComputeService, map[string]string{
+		"ssh-keys":               "core:ssh-rsa AAAAA core\nadmin:ssh-ed25519 AAAAC admin",
+		"block-project-ssh-keys": "TRUE",
+	})
+}
+
+func TestOSConfig(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "openshift-machine-api"},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:            "us-east1-b",
+			MachineType:     "n1-standard-4",
+			Disks:           []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			ServiceAccounts: []gcpv1beta1.GCPServiceAccount{{Email: "worker@my-project.iam.gserviceaccount.com", Scopes: []string{"logging-write"}}},
+		},
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if scopes := receivedInstance.ServiceAccounts[0].Scopes; len(scopes) != 1 {
+		t.Errorf("expected only the logging scope without OS Config, got %v", scopes)
+	}
+
+	// Enabling OS Config on an existing instance sets its metadata.
+	reconciler.providerSpec.OSConfig = true
+	if err := reconciler.ensureMetadata(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectMetadata(t, mockComputeService, map[string]string{
+		"enable-osconfig":         "TRUE",
+		"enable-guest-attributes": "TRUE",
+	})
+
+	// New instances also get the scope of the agent.
+	scopes := reconciler.serviceAccountScopes(reconciler.providerSpec.ServiceAccounts[0])
+	expected := []string{"https://www.googleapis.com/auth/logging.write", "https://www.googleapis.com/auth/cloud-platform"}
+	if strings.Join(scopes, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected scopes %v, got %v", expected, scopes)
+	}
+	reconciler.providerSpec.ServiceAccounts[0].Scopes = []string{"cloud-platform"}
+	if scopes := reconciler.serviceAccountScopes(reconciler.providerSpec.ServiceAccounts[0]); len(scopes) != 1 {
+		t.Errorf("expected the cloud-platform scope once, got %v", scopes)
+	}
+}
+
+func TestRenderInstanceRedactsSecretMetadata(t *testing.T) {
+	reconciler := newReconciler(&machineScope{
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Metadata: []*gcpv1beta1.GCPMetadata{
+				{Key: "token", ValueFrom: &gcpv1beta1.GCPMetadataValueSource{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "bootstrap"}, Key: "token"},
+				}},
+			},
+		},
+	})
+	value := "abc"
+	rendered, err := renderInstance(&compute.Instance{
+		Metadata: &compute.Metadata{Items: []*compute.MetadataItems{{Key: "token", Value: &value}}},
+	}, reconciler.secretMetadataKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(rendered), "abc") {
+		t.Errorf("expected the token to be redacted, got %s", rendered)
+	}
+}
+
+func TestValidateMetadataSize(t *testing.T) {
+	value := func(size int) *string {
+		v := strings.Repeat("a", size)
+		return &v
+	}
+	testCases := []struct {
+		name          string
+		items         []*compute.MetadataItems
+		expectedError string
+	}{
+		{
+			name:  "within limits",
+			items: []*compute.MetadataItems{{Key: "user-data", Value: value(maxMetadataValueSize)}, {Key: "empty"}},
+		},
+		{
+			name:          "oversized value",
+			items:         []*compute.MetadataItems{{Key: "small", Value: value(1)}, {Key: "user-data", Value: value(maxMetadataValueSize + 1)}},
+			expectedError: `value of metadata "user-data"`,
+		},
+		{
+			name:          "oversized key",
+			items:         []*compute.MetadataItems{{Key: strings.Repeat("k", maxMetadataKeySize+1), Value: value(1)}},
+			expectedError: "exceeds the limit of 128 bytes",
+		},
+		{
+			name: "oversized total",
+			items: []*compute.MetadataItems{
+				{Key: "user-data", Value: value(maxMetadataValueSize)},
+				{Key: "startup-script", Value: value(maxMetadataValueSize)},
+			},
+			expectedError: "in total",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMetadataSize(tc.items)
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("expected error containing %q, got %v", tc.expectedError, err)
+			}
+			if _, ok := err.(*machineapierrors.MachineError); !ok {
+				t.Errorf("expected an invalid configuration machine error, got %T", err)
+			}
+		})
+	}
+}
+
+func expectMetadata(t *testing.T, mockComputeService *computeservice.GCPComputeServiceMock, expected map[string]string) {
+	t.Helper()
+	instance, err := mockComputeService.InstancesGet(context.TODO(), "my-project", "us-east1-b", "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range expected {
+		found := false
+		for _, item := range instance.Metadata.Items {
+			if item.Key == key {
+				found = true
+				if item.Value == nil || *item.Value != value {
+					t.Errorf("expected metadata %s=%q, got %v", key, value, item.Value)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected metadata %s", key)
+		}
+	}
+}
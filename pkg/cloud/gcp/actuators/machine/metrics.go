@@ -0,0 +1,116 @@
+package machine
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	controllererror "github.com/openshift/cluster-api/pkg/controller/error"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/googleapi"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Error classes used as metric labels.
+const (
+	errorClassNone                 = "none"
+	errorClassInvalidConfiguration = "invalid_configuration"
+	errorClassNotFound             = "not_found"
+	errorClassConflict             = "conflict"
+	errorClassPermissionDenied     = "permission_denied"
+	errorClassRateLimited          = "rate_limited"
+	errorClassTimeout              = "timeout"
+	errorClassAPI                  = "api_error"
+	errorClassOther                = "other"
+)
+
+var (
+	actuatorOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gcp_machine_actuator_operation_duration_seconds",
+			Help:    "Duration of machine actuator operations by operation, namespace and error class.",
+			Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 180, 300},
+		},
+		[]string{"operation", "namespace", "error_class"},
+	)
+
+	actuatorOperationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gcp_machine_actuator_operations_total",
+			Help: "Number of machine actuator operations by operation, namespace and error class.",
+		},
+		[]string{"operation", "namespace", "error_class"},
+	)
+
+	operationWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "gcp_machine_operation_wait_duration_seconds",
+			Help:    "Time spent waiting for GCP operations to complete by namespace and error class.",
+			Buckets: []float64{1, 5, 10, 20, 30, 60, 90, 120, 180},
+		},
+		[]string{"namespace", "error_class"},
+	)
+
+	orphanedInstancesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gcp_machine_orphaned_instances",
+			Help: "Number of instances labelled as owned by a cluster without a machine, by project and cluster ID.",
+		},
+		[]string{"project", "cluster_id"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(actuatorOperationDuration, actuatorOperationsTotal, operationWaitDuration, orphanedInstancesGauge)
+}
+
+// observeActuatorOperation records the duration and outcome of an actuator operation started at start.
+// It is meant to be deferred with a pointer to the named error result of the operation.
+func observeActuatorOperation(operation string, namespace string, start time.Time, err *error) {
+	class := errorClass(*err)
+	actuatorOperationDuration.WithLabelValues(operation, namespace, class).Observe(time.Since(start).Seconds())
+	actuatorOperationsTotal.WithLabelValues(operation, namespace, class).Inc()
+}
+
+// observeOperationWait records the time spent waiting for a GCP operation started at start.
+func observeOperationWait(namespace string, start time.Time, err error) {
+	operationWaitDuration.WithLabelValues(namespace, errorClass(err)).Observe(time.Since(start).Seconds())
+}
+
+// errorClass returns a coarse classification of err usable as a metric label.
+func errorClass(err error) string {
+	if err == nil {
+		return errorClassNone
+	}
+	if _, ok := err.(*machineapierrors.MachineError); ok {
+		return errorClassInvalidConfiguration
+	}
+	if _, ok := err.(*controllererror.RequeueAfterError); ok {
+		// The actuator only delays retries of operations denied by the GCP API.
+		return errorClassPermissionDenied
+	}
+	if err == wait.ErrWaitTimeout || err == context.DeadlineExceeded {
+		return errorClassTimeout
+	}
+	if googleErr, ok := err.(*googleapi.Error); ok {
+		switch googleErr.Code {
+		case http.StatusNotFound:
+			return errorClassNotFound
+		case http.StatusConflict:
+			return errorClassConflict
+		case http.StatusTooManyRequests:
+			return errorClassRateLimited
+		case http.StatusForbidden:
+			for _, item := range googleErr.Errors {
+				if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+					return errorClassRateLimited
+				}
+			}
+			return errorClassPermissionDenied
+		}
+		return errorClassAPI
+	}
+	return errorClassOther
+}
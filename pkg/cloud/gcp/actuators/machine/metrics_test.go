@@ -0,0 +1,38 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	controllererror "github.com/openshift/cluster-api/pkg/controller/error"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"google.golang.org/api/googleapi"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestErrorClass(t *testing.T) {
+	testCases := []struct {
+		err           error
+		expectedClass string
+	}{
+		{err: nil, expectedClass: errorClassNone},
+		{err: machineapierrors.InvalidMachineConfiguration("invalid zone"), expectedClass: errorClassInvalidConfiguration},
+		{err: wait.ErrWaitTimeout, expectedClass: errorClassTimeout},
+		{err: context.DeadlineExceeded, expectedClass: errorClassTimeout},
+		{err: &googleapi.Error{Code: http.StatusNotFound}, expectedClass: errorClassNotFound},
+		{err: &googleapi.Error{Code: http.StatusTooManyRequests}, expectedClass: errorClassRateLimited},
+		{err: &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, expectedClass: errorClassRateLimited},
+		{err: &googleapi.Error{Code: http.StatusForbidden}, expectedClass: errorClassPermissionDenied},
+		{err: &googleapi.Error{Code: http.StatusInternalServerError}, expectedClass: errorClassAPI},
+		{err: &controllererror.RequeueAfterError{RequeueAfter: permissionDeniedRequeueAfter}, expectedClass: errorClassPermissionDenied},
+		{err: fmt.Errorf("boom"), expectedClass: errorClassOther},
+	}
+
+	for _, tc := range testCases {
+		if class := errorClass(tc.err); class != tc.expectedClass {
+			t.Errorf("%v: expected error class %q, got %q", tc.err, tc.expectedClass, class)
+		}
+	}
+}
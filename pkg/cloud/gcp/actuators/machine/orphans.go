@@ -0,0 +1,123 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// OrphanDetector periodically reports the instances labelled as owned by a cluster that no machine of the cluster
+// accounts for, e.g. left behind after their machine was removed without its finalizer running. Orphaned instances
+// are logged and counted in the gcp_machine_orphaned_instances metric, they are never deleted.
+type OrphanDetector struct {
+	Actuator *Actuator
+	// Interval is the time between two detections.
+	Interval time.Duration
+}
+
+// Start runs the detection every interval until stop is closed.
+func (d *OrphanDetector) Start(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+	wait.Until(func() {
+		if err := d.Actuator.detectOrphanedInstances(ctx); err != nil {
+			log.Error(err, "Failed to detect orphaned instances")
+		}
+	}, d.Interval, stop)
+	return nil
+}
+
+// orphanScope are the machines of a cluster in a project.
+type orphanScope struct {
+	scope     *machineScope
+	clusterID string
+	// instances are the zone/name of the instances of the machines, or their name alone when their zone is not
+	// known yet, e.g. for machines of a region whose zone is still being selected.
+	instances map[string]bool
+}
+
+// detectOrphanedInstances lists the instances owned by the cluster of each project machines run in, and reports
+// the ones without a machine.
+func (a *Actuator) detectOrphanedInstances(ctx context.Context) error {
+	machines, err := a.machineClient.Machines(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing machines: %v", err)
+	}
+
+	scopes := orphanScopes(machines.Items, func(machine *machinev1.Machine) (*machineScope, error) {
+		return a.newMachineScope(ctx, machine, operationLogger("orphans", machine))
+	})
+	for _, s := range scopes {
+		label := gcpproviderv1.ClusterOwnedLabel(s.clusterID)
+		instances, err := s.scope.computeService.InstancesAggregatedList(ctx, s.scope.projectID, fmt.Sprintf("labels.%s = %s", label, gcpproviderv1.ClusterOwnedLabelValue))
+		if err != nil {
+			log.Error(err, "Failed to list the instances of the cluster", "project", s.scope.projectID, "cluster", s.clusterID)
+			continue
+		}
+		orphans := orphanedInstances(instances, s.instances, s.clusterID)
+		for _, instance := range orphans {
+			log.Info("Instance owned by the cluster has no machine", "project", s.scope.projectID, "zone", path.Base(instance.Zone), "instance", instance.Name, "cluster", s.clusterID)
+		}
+		orphanedInstancesGauge.WithLabelValues(s.scope.projectID, s.clusterID).Set(float64(len(orphans)))
+	}
+	return nil
+}
+
+// orphanScopes groups the machines by project and cluster. A cluster is skipped when the scope of any of its
+// machines cannot be created, since the instance of that machine would be reported as orphaned otherwise.
+func orphanScopes(machines []machinev1.Machine, newScope func(machine *machinev1.Machine) (*machineScope, error)) map[string]*orphanScope {
+	scopes := map[string]*orphanScope{}
+	skippedClusters := map[string]bool{}
+	for i := range machines {
+		machine := &machines[i]
+		clusterID := machine.Labels[machinev1.MachineClusterIDLabel]
+		if clusterID == "" || skippedClusters[clusterID] {
+			continue
+		}
+		scope, err := newScope(machine)
+		if err != nil {
+			log.Error(err, "Failed to create scope for machine, skipping its cluster", "machine", machine.Name, "namespace", machine.Namespace, "cluster", clusterID)
+			skippedClusters[clusterID] = true
+			continue
+		}
+		r := newReconciler(scope)
+		r.useProviderIDLocation()
+		key := path.Join(r.projectID, clusterID)
+		if _, ok := scopes[key]; !ok {
+			scopes[key] = &orphanScope{scope: scope, clusterID: clusterID, instances: map[string]bool{}}
+		}
+		// path.Join drops the zone when it is empty, leaving the name alone.
+		scopes[key].instances[path.Join(r.providerSpec.Zone, r.instanceName())] = true
+	}
+	for key, s := range scopes {
+		if skippedClusters[s.clusterID] {
+			delete(scopes, key)
+		}
+	}
+	return scopes
+}
+
+// orphanedInstances returns the instances labelled as owned by the cluster which are not among the zone/name, or
+// the name alone, of the instances of its machines.
+func orphanedInstances(instances []*compute.Instance, machineInstances map[string]bool, clusterID string) []*compute.Instance {
+	var orphans []*compute.Instance
+	for _, instance := range instances {
+		if instance.Labels[gcpproviderv1.ClusterOwnedLabel(clusterID)] != gcpproviderv1.ClusterOwnedLabelValue {
+			continue
+		}
+		if !machineInstances[path.Join(path.Base(instance.Zone), instance.Name)] && !machineInstances[instance.Name] {
+			orphans = append(orphans, instance)
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,44 @@
+package machine
+
+import (
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	osConfigMetadataKey        = "enable-osconfig"
+	guestAttributesMetadataKey = "enable-guest-attributes"
+)
+
+// osConfigScope is the scope the OS Config agent needs to report to VM Manager.
+var osConfigScope = v1beta1.ServiceAccountScopeAliases["cloud-platform"]
+
+// osConfigMetadataItems returns the metadata items enabling the OS Config agent and the guest attributes it
+// reports the OS inventory with, when the provider spec enables OS Config.
+func (r *Reconciler) osConfigMetadataItems() []*compute.MetadataItems {
+	if !r.providerSpec.OSConfig {
+		return nil
+	}
+	var items []*compute.MetadataItems
+	for _, key := range []string{osConfigMetadataKey, guestAttributesMetadataKey} {
+		value := "TRUE"
+		items = append(items, &compute.MetadataItems{Key: key, Value: &value})
+	}
+	return items
+}
+
+// serviceAccountScopes returns the scope URLs of the service account, with the scope of the OS Config agent
+// added when the provider spec enables OS Config.
+func (r *Reconciler) serviceAccountScopes(sa v1beta1.GCPServiceAccount) []string {
+	var scopes []string
+	for _, scope := range sa.Scopes {
+		if url, ok := v1beta1.ServiceAccountScopeAliases[scope]; ok {
+			scope = url
+		}
+		scopes = append(scopes, scope)
+	}
+	if r.providerSpec.OSConfig && !hasString(scopes, osConfigScope) {
+		scopes = append(scopes, osConfigScope)
+	}
+	return scopes
+}
@@ -0,0 +1,81 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"google.golang.org/api/compute/v1"
+)
+
+// clusterID returns the ID of the cluster of the machine, empty when the machine has no cluster ID label.
+func (r *Reconciler) clusterID() string {
+	return r.machine.Labels[machinev1.MachineClusterIDLabel]
+}
+
+// ownedLabels returns the labels with the cluster owned label of the machine cluster added, when enabled and the
+// cluster is known. The labels are copied rather than modified.
+func (r *Reconciler) ownedLabels(labels map[string]string) map[string]string {
+	clusterID := r.clusterID()
+	if !r.clusterOwnedLabel || clusterID == "" {
+		return labels
+	}
+	owned := map[string]string{}
+	for key, value := range labels {
+		owned[key] = value
+	}
+	owned[gcpproviderv1.ClusterOwnedLabel(clusterID)] = gcpproviderv1.ClusterOwnedLabelValue
+	return owned
+}
+
+// checkOwnership fails when the labels of the resource mark it as owned by other clusters but not by the cluster
+// of the machine, so a machine never deletes the instance or disks of another cluster, e.g. after its provider
+// spec was copied from another cluster. Resources without owned labels, e.g. created before they were labelled,
+// pass.
+func (r *Reconciler) checkOwnership(resource string, labels map[string]string) error {
+	clusterID := r.clusterID()
+	if !r.clusterOwnedLabel || clusterID == "" {
+		return nil
+	}
+	if labels[gcpproviderv1.ClusterOwnedLabel(clusterID)] == gcpproviderv1.ClusterOwnedLabelValue {
+		return nil
+	}
+	for key, value := range labels {
+		if strings.HasPrefix(key, gcpproviderv1.ClusterOwnedLabelPrefix) && value == gcpproviderv1.ClusterOwnedLabelValue {
+			return fmt.Errorf("%s is owned by cluster %q, not %q, refusing to delete it", resource, strings.TrimPrefix(key, gcpproviderv1.ClusterOwnedLabelPrefix), clusterID)
+		}
+	}
+	return nil
+}
+
+// checkInstanceOwnership fails when the instance of the machine is owned by other clusters. It returns the
+// instance when it got it, so the delete path does not get it again.
+func (r *Reconciler) checkInstanceOwnership() (*compute.Instance, error) {
+	if !r.clusterOwnedLabel || r.clusterID() == "" {
+		return nil, nil
+	}
+	instance, _, err := r.getInstance()
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting instance %q in zone %q: %v", r.instanceName(), r.providerSpec.Zone, err)
+	}
+	return instance, r.checkOwnership(fmt.Sprintf("instance %q", instance.Name), instance.Labels)
+}
+
+// checkDiskOwnership fails when the disk is owned by other clusters. It returns false when the disk does not exist.
+func (r *Reconciler) checkDiskOwnership(zone, name string) (bool, error) {
+	if !r.clusterOwnedLabel || r.clusterID() == "" {
+		return true, nil
+	}
+	disk, err := r.computeService.DisksGet(r.Context, r.projectID, zone, name)
+	if err != nil {
+		if isNotFoundError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting disk %q in zone %q: %v", name, zone, err)
+	}
+	return true, r.checkOwnership(fmt.Sprintf("disk %q", name), disk.Labels)
+}
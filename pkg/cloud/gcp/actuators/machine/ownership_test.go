@@ -0,0 +1,188 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClusterOwnedLabel(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	machine := &v1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "worker-0",
+			Labels: map[string]string{v1beta1.MachineClusterIDLabel: "cluster-a"},
+		},
+	}
+	reconciler := newReconciler(&machineScope{
+		Context:    context.TODO(),
+		machine:    machine,
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Labels:      map[string]string{"team": "infra"},
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+		},
+		computeService:    mockComputeService,
+		clusterOwnedLabel: true,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expected := map[string]string{"team": "infra", "kubernetes-io-cluster-cluster-a": "owned"}
+	if !reflect.DeepEqual(receivedInstance.Labels, expected) {
+		t.Errorf("expected instance labels %v, got %v", expected, receivedInstance.Labels)
+	}
+	if labels := receivedInstance.Disks[0].InitializeParams.Labels; !reflect.DeepEqual(labels, map[string]string{"kubernetes-io-cluster-cluster-a": "owned"}) {
+		t.Errorf("expected the disk to be labelled as owned by the cluster, got %v", labels)
+	}
+	if !reflect.DeepEqual(reconciler.providerSpec.Labels, map[string]string{"team": "infra"}) {
+		t.Errorf("expected the provider spec labels to be left untouched, got %v", reconciler.providerSpec.Labels)
+	}
+
+	// A machine of another cluster does not delete the instance.
+	machine.Labels[v1beta1.MachineClusterIDLabel] = "cluster-b"
+	if err := reconciler.delete(); err == nil {
+		t.Errorf("expected deleting the instance of another cluster to fail")
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "worker-0"); status == "" {
+		t.Errorf("expected the instance of another cluster not to be deleted")
+	}
+
+	machine.Labels[v1beta1.MachineClusterIDLabel] = "cluster-a"
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "worker-0"); status != "" {
+		t.Errorf("expected the instance to be deleted, got status %q", status)
+	}
+}
+
+func TestRetainedDiskOwnership(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	machine := &v1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "worker-0",
+			Labels: map[string]string{v1beta1.MachineClusterIDLabel: "cluster-a"},
+		},
+	}
+	reconciler := newReconciler(&machineScope{
+		Context:    context.TODO(),
+		machine:    machine,
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+				{
+					SizeGb:     100,
+					AutoDelete: false,
+				},
+			},
+		},
+		computeService:    mockComputeService,
+		clusterOwnedLabel: true,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if len(reconciler.providerStatus.Disks) == 0 {
+		t.Fatalf("expected retained disks in the provider status")
+	}
+
+	machine.Labels[v1beta1.MachineClusterIDLabel] = "cluster-b"
+	if err := reconciler.deleteRetainedDisks(); err == nil {
+		t.Errorf("expected deleting the disk of another cluster to fail")
+	}
+	if !mockComputeService.HasDisk("my-project", "us-east1-b", reconciler.providerStatus.Disks[0]) {
+		t.Errorf("expected the disk of another cluster not to be deleted")
+	}
+}
+
+func TestOrphanedInstances(t *testing.T) {
+	owned := map[string]string{"kubernetes-io-cluster-cluster-a": "owned"}
+	instances := []*compute.Instance{
+		{Name: "worker-0", Zone: "https://www.googleapis.com/compute/v1/projects/my-project/zones/us-east1-b", Labels: owned},
+		{Name: "worker-1", Zone: "us-east1-c", Labels: owned},
+		{Name: "bastion", Zone: "us-east1-b"},
+		{Name: "worker-0", Zone: "us-east1-d", Labels: owned},
+	}
+	orphans := orphanedInstances(instances, map[string]bool{"us-east1-b/worker-0": true, "us-east1-c/worker-1": true}, "cluster-a")
+	if len(orphans) != 1 || orphans[0] != instances[3] {
+		t.Errorf("expected only the instance without machine in its zone to be orphaned, got %v", orphans)
+	}
+}
+
+func TestOrphanScopes(t *testing.T) {
+	newMachine := func(name, clusterID string) v1beta1.Machine {
+		return v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{v1beta1.MachineClusterIDLabel: clusterID},
+			},
+		}
+	}
+	machines := []v1beta1.Machine{
+		newMachine("worker-0", "cluster-a"),
+		newMachine("worker-1", "cluster-a"),
+		newMachine("worker-0", "cluster-b"),
+		newMachine("broken", "cluster-b"),
+		newMachine("worker-1", "cluster-b"),
+	}
+	zones := map[string]string{"worker-0": "us-east1-b"}
+	scopes := orphanScopes(machines, func(machine *v1beta1.Machine) (*machineScope, error) {
+		if machine.Name == "broken" {
+			return nil, fmt.Errorf("failed to get machine config")
+		}
+		return &machineScope{
+			machine:   machine,
+			projectID: "my-project",
+			// worker-1 is a machine of a region whose zone is not selected yet.
+			providerSpec: &gcpv1beta1.GCPMachineProviderSpec{Region: "us-east1", Zone: zones[machine.Name]},
+		}, nil
+	})
+
+	if _, ok := scopes["my-project/cluster-b"]; ok || len(scopes) != 1 {
+		t.Fatalf("expected the cluster of the machine without scope to be skipped, got %v", scopes)
+	}
+	s := scopes["my-project/cluster-a"]
+	if s == nil {
+		t.Fatalf("expected the machines of the cluster to be grouped, got %v", scopes)
+	}
+	expected := map[string]bool{"us-east1-b/worker-0": true, "worker-1": true}
+	if !reflect.DeepEqual(s.instances, expected) {
+		t.Errorf("expected instances %v, got %v", expected, s.instances)
+	}
+
+	owned := map[string]string{"kubernetes-io-cluster-cluster-a": "owned"}
+	instances := []*compute.Instance{
+		{Name: "worker-0", Zone: "us-east1-b", Labels: owned},
+		{Name: "worker-1", Zone: "us-east1-c", Labels: owned},
+		{Name: "worker-2", Zone: "us-east1-c", Labels: owned},
+	}
+	orphans := orphanedInstances(instances, s.instances, "cluster-a")
+	if len(orphans) != 1 || orphans[0] != instances[2] {
+		t.Errorf("expected the instance of the machine without zone not to be orphaned, got %v", orphans)
+	}
+}
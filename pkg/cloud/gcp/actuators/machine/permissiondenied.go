@@ -0,0 +1,76 @@
+package machine
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	controllererror "github.com/openshift/cluster-api/pkg/controller/error"
+	"google.golang.org/api/googleapi"
+	apicorev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// permissionDeniedRequeueAfter is waited before retrying an operation denied by the GCP API, since IAM
+	// misconfigurations are not fixed by retrying and retries with the default backoff flood the API.
+	permissionDeniedRequeueAfter = 5 * time.Minute
+
+	// forbiddenErrorPrefix prefixes the message of 403 API errors, which are often wrapped in other errors.
+	forbiddenErrorPrefix = "googleapi: Error 403: "
+)
+
+// requiredPermissionRegex matches the permission named in permission denied messages, e.g.
+// "Required 'compute.instances.create' permission for 'projects/my-project/zones/us-east1-b/instances/worker-0'".
+var requiredPermissionRegex = regexp.MustCompile(`Required '([a-zA-Z0-9.]+)' permission`)
+
+// permissionDeniedMessage returns the message of a 403 API error in err, other than for rate limits.
+func permissionDeniedMessage(err error) (string, bool) {
+	if googleErr, ok := err.(*googleapi.Error); ok {
+		if googleErr.Code != http.StatusForbidden || errorClass(err) == errorClassRateLimited {
+			return "", false
+		}
+		return googleErr.Message, true
+	}
+	message := err.Error()
+	i := strings.Index(message, forbiddenErrorPrefix)
+	if i < 0 || strings.Contains(message, "rateLimitExceeded") || strings.Contains(message, "RateLimitExceeded") {
+		return "", false
+	}
+	return message[i+len(forbiddenErrorPrefix):], true
+}
+
+// handlePermissionDenied reports the permission denied errors of an actuator operation with the PermissionDenied
+// condition and an event naming the missing permission and the service account, and delays the next attempt.
+// Other errors are returned as is.
+func (s *machineScope) handlePermissionDenied(err error) error {
+	message, ok := permissionDeniedMessage(err)
+	if !ok {
+		return err
+	}
+	account := "the machine credentials"
+	if s.serviceAccount != "" {
+		account = "service account " + s.serviceAccount
+	}
+	description := fmt.Sprintf("GCP denied a request of %s", account)
+	if match := requiredPermissionRegex.FindStringSubmatch(message); match != nil {
+		description += fmt.Sprintf(", it is missing the %s permission", match[1])
+	}
+	description += ": " + message
+
+	setCondition(s.providerStatus, gcpproviderv1.MachinePermissionDenied, apicorev1.ConditionTrue, "PermissionDenied", description)
+	if s.eventRecorder != nil {
+		s.eventRecorder.Event(s.machine, apicorev1.EventTypeWarning, "PermissionDenied", description)
+	}
+	s.logger.Info("Permission denied, retrying later", "serviceAccount", s.serviceAccount, "requeueAfter", permissionDeniedRequeueAfter)
+	return &controllererror.RequeueAfterError{RequeueAfter: permissionDeniedRequeueAfter}
+}
+
+// clearPermissionDenied clears the PermissionDenied condition after a successful operation.
+func (s *machineScope) clearPermissionDenied() {
+	if hasCondition(s.providerStatus, gcpproviderv1.MachinePermissionDenied) {
+		setCondition(s.providerStatus, gcpproviderv1.MachinePermissionDenied, apicorev1.ConditionFalse, "PermissionGranted", "")
+	}
+}
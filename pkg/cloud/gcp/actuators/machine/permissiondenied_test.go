@@ -0,0 +1,89 @@
+package machine
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	controllererror "github.com/openshift/cluster-api/pkg/controller/error"
+	"google.golang.org/api/googleapi"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestPermissionDeniedMessage(t *testing.T) {
+	forbidden := &googleapi.Error{
+		Code:    http.StatusForbidden,
+		Message: "Required 'compute.instances.create' permission for 'projects/my-project/zones/us-east1-b/instances/worker-0'",
+		Errors:  []googleapi.ErrorItem{{Reason: "forbidden"}},
+	}
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "forbidden", err: forbidden, expected: true},
+		{name: "wrapped forbidden", err: fmt.Errorf("error getting instance %q: %v", "worker-0", forbidden), expected: true},
+		{name: "rate limited", err: &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}},
+		{name: "wrapped rate limited", err: fmt.Errorf("error: %v", &googleapi.Error{Code: http.StatusForbidden, Message: "Quota exceeded", Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}})},
+		{name: "not found", err: &googleapi.Error{Code: http.StatusNotFound}},
+		{name: "other", err: fmt.Errorf("boom")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			message, ok := permissionDeniedMessage(tc.err)
+			if ok != tc.expected {
+				t.Fatalf("expected permission denied: %v, got %v", tc.expected, ok)
+			}
+			if ok && !strings.Contains(message, "compute.instances.create") {
+				t.Errorf("expected the message of the API error, got %q", message)
+			}
+		})
+	}
+}
+
+func TestHandlePermissionDenied(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	scope := &machineScope{
+		machine:        &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+		providerStatus: &gcpv1beta1.GCPMachineProviderStatus{},
+		serviceAccount: "machines@my-project.iam.gserviceaccount.com",
+		eventRecorder:  recorder,
+		logger:         log,
+	}
+
+	other := fmt.Errorf("boom")
+	if err := scope.handlePermissionDenied(other); err != other {
+		t.Errorf("expected other errors to be returned as is, got %v", err)
+	}
+
+	err := scope.handlePermissionDenied(fmt.Errorf("error inserting instance: %v", &googleapi.Error{
+		Code:    http.StatusForbidden,
+		Message: "Required 'compute.instances.create' permission for 'projects/my-project/zones/us-east1-b/instances/worker-0'",
+	}))
+	requeue, ok := err.(*controllererror.RequeueAfterError)
+	if !ok || requeue.RequeueAfter != permissionDeniedRequeueAfter {
+		t.Errorf("expected to requeue after %v, got %v", permissionDeniedRequeueAfter, err)
+	}
+	if !hasCondition(scope.providerStatus, gcpv1beta1.MachinePermissionDenied) {
+		t.Error("expected the PermissionDenied condition to be set")
+	}
+	select {
+	case event := <-recorder.Events:
+		for _, expected := range []string{"PermissionDenied", "machines@my-project.iam.gserviceaccount.com", "missing the compute.instances.create permission"} {
+			if !strings.Contains(event, expected) {
+				t.Errorf("expected event to contain %q, got %q", expected, event)
+			}
+		}
+	default:
+		t.Error("expected a PermissionDenied event")
+	}
+
+	scope.clearPermissionDenied()
+	if hasCondition(scope.providerStatus, gcpv1beta1.MachinePermissionDenied) {
+		t.Error("expected the PermissionDenied condition to be cleared")
+	}
+}
@@ -0,0 +1,179 @@
+package machine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const resourceManagerEndpoint = "https://cloudresourcemanager.googleapis.com/v1/"
+
+// requiredPermissions are the IAM permissions the provider needs to manage machines.
+var requiredPermissions = []string{
+	"compute.disks.create",
+	"compute.images.get",
+	"compute.images.useReadOnly",
+	"compute.instances.create",
+	"compute.instances.delete",
+	"compute.instances.get",
+	"compute.instances.setLabels",
+	"compute.instances.setMetadata",
+	"compute.instances.setServiceAccount",
+	"compute.instances.setTags",
+	"compute.machineTypes.get",
+	"compute.subnetworks.get",
+	"compute.subnetworks.use",
+	"compute.subnetworks.useExternalIp",
+	"compute.zoneOperations.get",
+	"iam.serviceAccounts.actAs",
+}
+
+// PermissionsChecker periodically reports the required IAM permissions the credentials of the machines are missing
+// in their project, so missing permissions are reported clearly instead of the first create failing with a generic
+// 403. They are reported with the MissingPermissions condition of the machines, and an event when they change.
+type PermissionsChecker struct {
+	Actuator *Actuator
+	// Interval is the time between two checks.
+	Interval time.Duration
+}
+
+// Start checks the permissions on start and then every interval until stop is closed.
+func (c *PermissionsChecker) Start(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+	checker := newPermissionsChecker(c.Interval)
+	wait.Until(func() {
+		if err := c.Actuator.checkPermissions(ctx, checker); err != nil {
+			log.Error(err, "Failed to check the permissions of the machine credentials")
+		}
+	}, c.Interval, stop)
+	return nil
+}
+
+// checkPermissions reports the permissions the credentials of each machine are missing in its project.
+func (a *Actuator) checkPermissions(ctx context.Context, checker *permissionsChecker) error {
+	machines, err := a.machineClient.Machines(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing machines: %v", err)
+	}
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if machine.DeletionTimestamp != nil {
+			continue
+		}
+		scope, err := a.newMachineScope(ctx, machine, operationLogger("permissions", machine))
+		if err != nil {
+			log.Error(err, "Failed to create scope for machine, skipping it", "machine", machine.Name, "namespace", machine.Namespace)
+			continue
+		}
+		scope.checkPermissions(checker)
+		scope.Close()
+	}
+	return nil
+}
+
+// permissionsChecker checks credentials are granted the permissions the provider needs with
+// projects.testIamPermissions. Results are cached per credentials and project for the check interval, so the
+// machines sharing credentials are checked once.
+type permissionsChecker struct {
+	endpoint string
+	interval time.Duration
+
+	lock    sync.Mutex
+	checked map[string]permissionsCheck
+}
+
+type permissionsCheck struct {
+	missing []string
+	time    time.Time
+}
+
+func newPermissionsChecker(interval time.Duration) *permissionsChecker {
+	return &permissionsChecker{
+		endpoint: resourceManagerEndpoint,
+		interval: interval,
+		checked:  map[string]permissionsCheck{},
+	}
+}
+
+// missingPermissions returns the required permissions the client is not granted in the project.
+// The check is performed the first time credentials are used and then every interval. The lock is not held
+// while the permissions are tested, so checks of other credentials are not blocked by it.
+func (p *permissionsChecker) missingPermissions(ctx context.Context, client *http.Client, credentialsKey string, project string) ([]string, error) {
+	key := credentialsKey + "@" + project
+
+	p.lock.Lock()
+	check, ok := p.checked[key]
+	p.lock.Unlock()
+	if ok && time.Since(check.time) < p.interval {
+		return check.missing, nil
+	}
+
+	start := time.Now()
+	granted, err := p.testIamPermissions(ctx, client, project, requiredPermissions)
+	if err != nil {
+		return nil, err
+	}
+	grantedSet := map[string]bool{}
+	for _, permission := range granted {
+		grantedSet[permission] = true
+	}
+	var missing []string
+	for _, permission := range requiredPermissions {
+		if !grantedSet[permission] {
+			missing = append(missing, permission)
+		}
+	}
+	sort.Strings(missing)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.checked[key] = permissionsCheck{missing: missing, time: start}
+	return missing, nil
+}
+
+// testIamPermissions calls the projects.testIamPermissions REST method and returns the granted permissions.
+func (p *permissionsChecker) testIamPermissions(ctx context.Context, client *http.Client, project string, permissions []string) ([]string, error) {
+	body, err := json.Marshal(map[string][]string{"permissions": permissions})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%sprojects/%s:testIamPermissions", p.endpoint, project), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error testing IAM permissions in project %q: unexpected status %d: %s", project, res.StatusCode, data)
+	}
+
+	var response struct {
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	return response.Permissions, nil
+}
@@ -0,0 +1,162 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	apicorev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestMissingPermissions(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/projects/my-project:testIamPermissions" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		var request struct {
+			Permissions []string `json:"permissions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		// Grant everything but creating instances.
+		var granted []string
+		for _, permission := range request.Permissions {
+			if permission != "compute.instances.create" {
+				granted = append(granted, permission)
+			}
+		}
+		response, _ := json.Marshal(map[string][]string{"permissions": granted})
+		fmt.Fprint(w, string(response))
+	}))
+	defer server.Close()
+
+	checker := newPermissionsChecker(time.Hour)
+	checker.endpoint = server.URL + "/"
+	for i := 0; i < 2; i++ {
+		missing, err := checker.missingPermissions(context.Background(), server.Client(), "openshift-machine-api/gcp-sa", "my-project")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(missing, []string{"compute.instances.create"}) {
+			t.Errorf("expected compute.instances.create to be missing, got %v", missing)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected permissions to be checked once, got %d checks", requests)
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	denied := "compute.instances.create"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Permissions []string `json:"permissions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		var granted []string
+		for _, permission := range request.Permissions {
+			if permission != denied {
+				granted = append(granted, permission)
+			}
+		}
+		response, _ := json.Marshal(map[string][]string{"permissions": granted})
+		fmt.Fprint(w, string(response))
+	}))
+	defer server.Close()
+
+	recorder := record.NewFakeRecorder(10)
+	scope := &machineScope{
+		Context:        context.Background(),
+		machine:        &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+		projectID:      "my-project",
+		providerStatus: &gcpv1beta1.GCPMachineProviderStatus{},
+		eventRecorder:  recorder,
+		logger:         log,
+		httpClient:     server.Client(),
+		credentialsKey: "openshift-machine-api/gcp-sa",
+	}
+	providerStatus := scope.providerStatus
+	check := func() {
+		// A new checker per check, so the results are not cached.
+		checker := newPermissionsChecker(time.Hour)
+		checker.endpoint = server.URL + "/"
+		scope.checkPermissions(checker)
+	}
+
+	// The missing permissions are reported once, however many scopes are created.
+	check()
+	check()
+	if !hasCondition(providerStatus, gcpv1beta1.MachineMissingPermissions) {
+		t.Errorf("expected the MissingPermissions condition to be set, got %+v", providerStatus.Conditions)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a single MissingPermissions event, got %d", len(recorder.Events))
+	}
+	<-recorder.Events
+
+	// Other missing permissions are reported again.
+	denied = "compute.instances.delete"
+	check()
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a MissingPermissions event for the other missing permissions, got %d events", len(recorder.Events))
+	}
+	<-recorder.Events
+
+	// Granting the permissions clears the condition.
+	denied = ""
+	check()
+	if condition := getCondition(providerStatus, gcpv1beta1.MachineMissingPermissions); condition == nil || condition.Status != apicorev1.ConditionFalse {
+		t.Errorf("expected the MissingPermissions condition to be cleared, got %+v", condition)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no event once the permissions are granted, got %d", len(recorder.Events))
+	}
+}
+
+func TestMissingPermissionsConcurrentChecks(t *testing.T) {
+	started := make(chan struct{})
+	blocked := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/projects/slow-project:testIamPermissions" {
+			close(started)
+			<-blocked
+		}
+		fmt.Fprint(w, `{"permissions": []}`)
+	}))
+	defer server.Close()
+	defer close(blocked)
+
+	checker := newPermissionsChecker(time.Hour)
+	checker.endpoint = server.URL + "/"
+	go checker.missingPermissions(context.Background(), server.Client(), "openshift-machine-api/gcp-sa", "slow-project")
+	<-started
+
+	// The check of another project is not blocked by the pending check of the slow project.
+	done := make(chan error)
+	go func() {
+		_, err := checker.missingPermissions(context.Background(), server.Client(), "openshift-machine-api/gcp-sa", "my-project")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the check of my-project not to wait for the check of slow-project")
+	}
+}
@@ -0,0 +1,106 @@
+package machine
+
+import (
+	"fmt"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/features"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	apicorev1 "k8s.io/api/core/v1"
+)
+
+// PreemptibleNodeConfig are the labels and taints added to the machine spec of preemptible machines,
+// from where the machine controller propagates them to the node.
+type PreemptibleNodeConfig struct {
+	Labels map[string]string
+	Taints []apicorev1.Taint
+}
+
+// ensurePreemptibleNodeMetadata adds the configured labels and taints missing from the spec of a preemptible machine.
+// Labels and taints already set on the machine, including by the user, are left untouched.
+func (r *Reconciler) ensurePreemptibleNodeMetadata() error {
+	if !r.providerSpec.Preemptible {
+		return nil
+	}
+	changed := false
+	for key, value := range r.preemptibleNode.Labels {
+		if _, ok := r.machine.Spec.Labels[key]; ok {
+			continue
+		}
+		if r.machine.Spec.Labels == nil {
+			r.machine.Spec.Labels = map[string]string{}
+		}
+		r.machine.Spec.Labels[key] = value
+		changed = true
+	}
+	for _, taint := range r.preemptibleNode.Taints {
+		if hasTaint(r.machine.Spec.Taints, taint) {
+			continue
+		}
+		r.machine.Spec.Taints = append(r.machine.Spec.Taints, taint)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	updated, err := r.machineClient.Update(r.machine)
+	if err != nil {
+		return fmt.Errorf("failed to add preemptible labels and taints to machine: %v", err)
+	}
+	r.machine.ResourceVersion = updated.ResourceVersion
+	r.logger.Info("Added preemptible labels and taints to machine")
+	return nil
+}
+
+// handlePreemption deletes the instance of a preemptible machine once GCP stopped it and fails the machine,
+// so that its machine set replaces it right away rather than after a machine health check timeout.
+// The machine keeps failing, since create refuses to recreate the instance of a preempted machine.
+func (r *Reconciler) handlePreemption() error {
+	if !r.providerSpec.Preemptible || !r.featureGates.Enabled(features.SpotVMs) {
+		return nil
+	}
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	if !hasCondition(r.providerStatus, gcpproviderv1.MachinePreempted) {
+		instance, _, err := r.getInstance()
+		if err != nil {
+			if isNotFoundError(err) {
+				return nil
+			}
+			return fmt.Errorf("error getting instance %q in zone %q: %v", name, zone, err)
+		}
+		if instance.Status != "TERMINATED" {
+			return nil
+		}
+		// Record the preemption first, the machine is not recreated even if deleting the instance fails.
+		setCondition(r.providerStatus, gcpproviderv1.MachinePreempted, apicorev1.ConditionTrue, "InstancePreempted",
+			fmt.Sprintf("preemptible instance %q was stopped by GCP", name))
+		r.logger.Info("Preemptible instance was stopped, deleting it", "instance", name)
+		if r.eventRecorder != nil {
+			r.eventRecorder.Eventf(r.machine, apicorev1.EventTypeWarning, "Preempted", "Preemptible instance %s was stopped by GCP", name)
+		}
+	}
+
+	operation, err := r.computeService.InstancesDelete(r.Context, r.projectID, zone, name)
+	if err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("error deleting preempted instance %q in zone %q: %v", name, zone, err)
+	}
+	r.invalidateInstance()
+	if err == nil {
+		if err := r.waitUntilOperationCompleted(zone, operation.Name, operationTypeDelete); err != nil {
+			return err
+		}
+	}
+	return machineapierrors.UpdateMachine("preemptible instance %q was stopped by GCP, the machine needs to be replaced", name)
+}
+
+// hasTaint returns true if a taint with the same key and effect is in taints.
+func hasTaint(taints []apicorev1.Taint, taint apicorev1.Taint) bool {
+	for _, t := range taints {
+		if t.Key == taint.Key && t.Effect == taint.Effect {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package machine
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	computeAPIURL = "https://www.googleapis.com/compute/v1/"
+
+	// maxZoneSuggestions is the number of zones outside the machine region suggested for an unavailable
+	// accelerator type.
+	maxZoneSuggestions = 5
+)
+
+// preflightChecks verifies the GCP resources referenced by the provider spec before the instance is created,
+// so misconfigurations fail fast with a clear error instead of surfacing as an operation error.
+func (r *Reconciler) preflightChecks() error {
+	if !r.preflightChecksEnabled {
+		return nil
+	}
+	if err := r.validateMachineType(); err != nil {
+		return err
+	}
+	if err := r.validateAccelerators(); err != nil {
+		return err
+	}
+	if err := r.validateImages(); err != nil {
+		return err
+	}
+	if err := r.validateSourceDisks(); err != nil {
+		return err
+	}
+	if err := r.validateSubnetworks(); err != nil {
+		return err
+	}
+	r.checkPrivateEgress()
+	return nil
+}
+
+// validateMachineType checks the machine type is offered in the target zone.
+func (r *Reconciler) validateMachineType() error {
+	zone := r.providerSpec.Zone
+	machineType := r.providerSpec.MachineType
+	if _, err := r.computeService.MachineTypesGet(r.Context, r.projectID, zone, machineType); err != nil {
+		if isNotFoundError(err) {
+			return machineapierrors.InvalidMachineConfiguration("machine type %q is not available in zone %q", machineType, zone)
+		}
+		return fmt.Errorf("error getting machine type %q in zone %q: %v", machineType, zone, err)
+	}
+	return nil
+}
+
+// validateAccelerators checks the accelerator types of the GPUs are offered in the target zone, and that the
+// instance does not get more of them than the type allows. Unavailable types fail with the zones offering them.
+func (r *Reconciler) validateAccelerators() error {
+	zone := r.providerSpec.Zone
+	for _, gpu := range r.providerSpec.GPUs {
+		acceleratorType, err := r.computeService.AcceleratorTypesGet(r.Context, r.projectID, zone, gpu.Type)
+		if err != nil {
+			if isNotFoundError(err) {
+				return machineapierrors.InvalidMachineConfiguration("accelerator type %q is not available in zone %q%s", gpu.Type, zone, r.acceleratorZoneSuggestion(gpu.Type))
+			}
+			return fmt.Errorf("error getting accelerator type %q in zone %q: %v", gpu.Type, zone, err)
+		}
+		if limit := acceleratorType.MaximumCardsPerInstance; limit > 0 && gpu.Count > limit {
+			return machineapierrors.InvalidMachineConfiguration("accelerator type %q allows at most %d accelerators per instance, got %d", gpu.Type, limit, gpu.Count)
+		}
+	}
+	return nil
+}
+
+// acceleratorZoneSuggestion returns the zones offering the accelerator type, all the zones of the machine region
+// or else the first zones of other regions, to append to the error of an unavailable accelerator type. Failing to
+// list them only omits the suggestion.
+func (r *Reconciler) acceleratorZoneSuggestion(acceleratorType string) string {
+	acceleratorTypes, err := r.computeService.AcceleratorTypesAggregatedList(r.Context, r.projectID, fmt.Sprintf("name = %s", acceleratorType))
+	if err != nil {
+		r.logger.Error(err, "Failed to list the zones offering the accelerator type", "acceleratorType", acceleratorType)
+		return ""
+	}
+	region := r.region()
+	var inRegion, others []string
+	for _, t := range acceleratorTypes {
+		if t.Name != acceleratorType {
+			continue
+		}
+		zone := path.Base(t.Zone)
+		if strings.HasPrefix(zone, region+"-") {
+			inRegion = append(inRegion, zone)
+		} else {
+			others = append(others, zone)
+		}
+	}
+	sort.Strings(inRegion)
+	sort.Strings(others)
+	switch {
+	case len(inRegion) > 0:
+		return fmt.Sprintf(", it is offered in zones %s of region %q", strings.Join(inRegion, ", "), region)
+	case len(others) > maxZoneSuggestions:
+		return fmt.Sprintf(", it is not offered in region %q but in zones %s and %d more", region, strings.Join(others[:maxZoneSuggestions], ", "), len(others)-maxZoneSuggestions)
+	case len(others) > 0:
+		return fmt.Sprintf(", it is not offered in region %q but in zones %s", region, strings.Join(others, ", "))
+	}
+	return ", no zone offers it"
+}
+
+// validateImages checks every disk image, or the latest image of an image family, exists, is READY and matches the
+// architecture of the machine type.
+func (r *Reconciler) validateImages() error {
+	for _, disk := range r.providerSpec.Disks {
+		if disk.Image == "" {
+			continue
+		}
+		project, name, family := parseImage(disk.Image, r.projectID)
+
+		var image *compute.Image
+		var err error
+		if family {
+			image, err = r.computeService.ImagesGetFromFamily(r.Context, project, name)
+		} else {
+			image, err = r.computeService.ImagesGet(r.Context, project, name)
+		}
+		if err != nil {
+			if isNotFoundError(err) {
+				return machineapierrors.InvalidMachineConfiguration("image %q not found", imageURL(project, name, family))
+			}
+			return fmt.Errorf("error getting image %q: %v", imageURL(project, name, family), err)
+		}
+		if image.Status != "READY" {
+			return fmt.Errorf("image %q is not ready (status: %s)", imageURL(project, name, family), image.Status)
+		}
+		if err := r.validateImageArchitecture(project, name, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateImageArchitecture checks the architecture of the image matches the architecture of the machine type,
+// instances booting an image of another architecture never become ready. Images which do not set their
+// architecture are not checked.
+func (r *Reconciler) validateImageArchitecture(project, name string, family bool) error {
+	architecture, err := r.computeService.ImagesGetArchitecture(r.Context, project, name, family)
+	if err != nil {
+		return fmt.Errorf("error getting the architecture of image %q: %v", imageURL(project, name, family), err)
+	}
+	if architecture == "" || architecture == "ARCHITECTURE_UNSPECIFIED" {
+		return nil
+	}
+	machineArchitecture := v1beta1.MachineTypeArchitecture(r.providerSpec.MachineType)
+	if v1beta1.GCPArchitecture(architecture) != machineArchitecture {
+		return machineapierrors.InvalidMachineConfiguration("image %q architecture %s does not match the %s architecture of machine type %q", imageURL(project, name, family), architecture, machineArchitecture, r.providerSpec.MachineType)
+	}
+	return nil
+}
+
+// validateSourceDisks checks the existing disks of the provider spec exist in the target zone, and that the
+// disks attached read-write are not attached to other instances.
+func (r *Reconciler) validateSourceDisks() error {
+	zone := r.providerSpec.Zone
+	for _, disk := range r.providerSpec.Disks {
+		if disk.Source == "" {
+			continue
+		}
+		existing, err := r.computeService.DisksGet(r.Context, r.projectID, zone, disk.Source)
+		if err != nil {
+			if isNotFoundError(err) {
+				return machineapierrors.InvalidMachineConfiguration("disk %q not found in zone %q", disk.Source, zone)
+			}
+			return fmt.Errorf("error getting disk %q in zone %q: %v", disk.Source, zone, err)
+		}
+		if disk.Mode == v1beta1.DiskModeReadOnly {
+			continue
+		}
+		for _, user := range existing.Users {
+			if path.Base(user) != r.instanceName() {
+				return machineapierrors.InvalidMachineConfiguration("disk %q is in use by instance %q", disk.Source, path.Base(user))
+			}
+		}
+	}
+	return nil
+}
+
+// validateSubnetworks checks every subnetwork exists in the machine region and belongs to the specified network.
+func (r *Reconciler) validateSubnetworks() error {
+	region := r.region()
+	for _, nic := range r.providerSpec.NetworkInterfaces {
+		if nic.Subnetwork == "" {
+			continue
+		}
+		subnetwork, err := r.computeService.SubnetworksGet(r.Context, r.networkProjectID(), region, nic.Subnetwork)
+		if err != nil {
+			if isNotFoundError(err) {
+				return machineapierrors.InvalidMachineConfiguration("subnetwork %q not found in region %q", nic.Subnetwork, region)
+			}
+			return fmt.Errorf("error getting subnetwork %q in region %q: %v", nic.Subnetwork, region, err)
+		}
+		if nic.Network != "" && path.Base(subnetwork.Network) != nic.Network {
+			return machineapierrors.InvalidMachineConfiguration("subnetwork %q belongs to network %q, not %q", nic.Subnetwork, path.Base(subnetwork.Network), nic.Network)
+		}
+	}
+	return nil
+}
+
+// imageRegex matches image references relative to the compute API, e.g.
+// projects/rhcos-cloud/global/images/rhcos-410 or global/images/family/rhcos.
+var imageRegex = regexp.MustCompile(`^(?:projects/([^/]+)/)?global/images/(family/)?([^/]+)$`)
+
+// parseImage splits an image reference into its project, and the image or image family name.
+// Bare names and references without a project are resolved in the default project.
+func parseImage(image, defaultProject string) (project, name string, family bool) {
+	image = strings.TrimPrefix(image, computeAPIURL)
+	match := imageRegex.FindStringSubmatch(image)
+	if match == nil {
+		return defaultProject, image, false
+	}
+	project = match[1]
+	if project == "" {
+		project = defaultProject
+	}
+	return project, match[3], match[2] != ""
+}
+
+func imageURL(project, name string, family bool) string {
+	if family {
+		return fmt.Sprintf("%sprojects/%s/global/images/family/%s", computeAPIURL, project, name)
+	}
+	return fmt.Sprintf("%sprojects/%s/global/images/%s", computeAPIURL, project, name)
+}
+
+func isNotFoundError(err error) bool {
+	if googleErr, ok := err.(*googleapi.Error); ok {
+		return googleErr.Code == 404
+	}
+	return false
+}
+
+func isAlreadyExistsError(err error) bool {
+	if googleErr, ok := err.(*googleapi.Error); ok {
+		return googleErr.Code == 409
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package machine
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestValidateMachineType(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	providerSpec := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:        "us-east1-b",
+		MachineType: "c2-standard-8",
+	}
+
+	reconciler := newReconciler(&machineScope{
+		Context:        context.TODO(),
+		providerSpec:   providerSpec,
+		computeService: mockComputeService,
+	})
+	if err := reconciler.validateMachineType(); err != nil {
+		t.Errorf("expected machine type to be valid, got: %v", err)
+	}
+
+	mockComputeService.FailOn("MachineTypesGet", computeservice.APIError(http.StatusNotFound, "notFound", "machine type not found"))
+	reconciler = newReconciler(&machineScope{
+		Context:        context.TODO(),
+		providerSpec:   providerSpec,
+		computeService: mockComputeService,
+	})
+	err := reconciler.validateMachineType()
+	if _, ok := err.(*machineapierrors.MachineError); !ok {
+		t.Errorf("expected a terminal machine error for an unavailable machine type, got: %v", err)
+	}
+}
+
+func TestValidateAccelerators(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	mockComputeService.SetAcceleratorTypeZones("my-project", "nvidia-tesla-t4", "us-east1-d", "us-east1-c", "us-west1-a")
+	mockComputeService.SetAcceleratorTypeZones("my-project", "nvidia-tesla-a100", "europe-west4-a", "us-central1-a")
+	providerSpec := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:        "us-east1-b",
+		MachineType: "n1-standard-4",
+	}
+	reconciler := newReconciler(&machineScope{
+		Context:        context.TODO(),
+		projectID:      "my-project",
+		providerSpec:   providerSpec,
+		computeService: mockComputeService,
+	})
+
+	testCases := []struct {
+		gpuType     string
+		expectedErr string
+	}{
+		{
+			gpuType: "nvidia-tesla-k80",
+		},
+		{
+			gpuType:     "nvidia-tesla-t4",
+			expectedErr: `accelerator type "nvidia-tesla-t4" is not available in zone "us-east1-b", it is offered in zones us-east1-c, us-east1-d of region "us-east1"`,
+		},
+		{
+			gpuType:     "nvidia-tesla-a100",
+			expectedErr: `it is not offered in region "us-east1" but in zones europe-west4-a, us-central1-a`,
+		},
+	}
+	for _, tc := range testCases {
+		providerSpec.GPUs = []gcpv1beta1.GCPGPUConfig{{Count: 1, Type: tc.gpuType}}
+		err := reconciler.validateAccelerators()
+		if tc.expectedErr == "" {
+			if err != nil {
+				t.Errorf("%s: expected accelerator type to be valid, got: %v", tc.gpuType, err)
+			}
+			continue
+		}
+		if _, ok := err.(*machineapierrors.MachineError); !ok {
+			t.Errorf("%s: expected a terminal machine error for an unavailable accelerator type, got: %v", tc.gpuType, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("%s: expected error to contain %q, got: %v", tc.gpuType, tc.expectedErr, err)
+		}
+	}
+}
+
+func TestValidateSourceDisks(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	providerSpec := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:        "us-east1-b",
+		MachineType: "n1-standard-4",
+		Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Source: "worker-0-restored"}},
+	}
+	reconciler := newReconciler(&machineScope{
+		Context:        context.TODO(),
+		projectID:      "my-project",
+		providerSpec:   providerSpec,
+		computeService: mockComputeService,
+	})
+	if err := reconciler.validateSourceDisks(); err != nil {
+		t.Errorf("expected the existing disk to be valid, got: %v", err)
+	}
+
+	mockComputeService.FailOn("DisksGet", computeservice.APIError(http.StatusNotFound, "notFound", "disk not found"))
+	err := reconciler.validateSourceDisks()
+	if _, ok := err.(*machineapierrors.MachineError); !ok {
+		t.Errorf("expected a terminal machine error for a missing disk, got: %v", err)
+	}
+}
+
+func TestValidateImageArchitecture(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	providerSpec := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:        "us-east1-b",
+		MachineType: "t2a-standard-4",
+		Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "projects/rhcos-cloud/global/images/rhcos"}},
+	}
+	reconciler := newReconciler(&machineScope{
+		Context:        context.TODO(),
+		projectID:      "my-project",
+		providerSpec:   providerSpec,
+		computeService: mockComputeService,
+	})
+
+	// Images which do not set their architecture are not checked.
+	if err := reconciler.validateImages(); err != nil {
+		t.Errorf("expected an image without architecture to be valid, got: %v", err)
+	}
+
+	// The architecture of the image is checked even when its name does not tell it.
+	mockComputeService.SetImageArchitecture("rhcos-cloud", "rhcos", "X86_64")
+	err := reconciler.validateImages()
+	if _, ok := err.(*machineapierrors.MachineError); !ok {
+		t.Errorf("expected a terminal machine error for an x86_64 image on an arm64 machine type, got: %v", err)
+	}
+
+	mockComputeService.SetImageArchitecture("rhcos-cloud", "rhcos", "ARM64")
+	if err := reconciler.validateImages(); err != nil {
+		t.Errorf("expected an arm64 image to be valid on an arm64 machine type, got: %v", err)
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	testCases := []struct {
+		image   string
+		project string
+		name    string
+		family  bool
+	}{
+		{image: "rhcos-410", project: "my-project", name: "rhcos-410"},
+		{image: "global/images/rhcos-410", project: "my-project", name: "rhcos-410"},
+		{image: "projects/rhcos-cloud/global/images/rhcos-410", project: "rhcos-cloud", name: "rhcos-410"},
+		{image: "projects/rhcos-cloud/global/images/family/rhcos", project: "rhcos-cloud", name: "rhcos", family: true},
+		{image: "https://www.googleapis.com/compute/v1/projects/rhcos-cloud/global/images/rhcos-410", project: "rhcos-cloud", name: "rhcos-410"},
+	}
+
+	for _, tc := range testCases {
+		project, name, family := parseImage(tc.image, "my-project")
+		if project != tc.project || name != tc.name || family != tc.family {
+			t.Errorf("parseImage(%q): expected (%q, %q, %v), got (%q, %q, %v)", tc.image, tc.project, tc.name, tc.family, project, name, family)
+		}
+	}
+}
+
+func TestValidateSubnetworks(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	testCases := []struct {
+		name      string
+		nic       *gcpv1beta1.GCPNetworkInterface
+		zoneOnly  bool
+		expectErr bool
+	}{
+		{
+			name: "subnetwork in network",
+			nic:  &gcpv1beta1.GCPNetworkInterface{Network: "default", Subnetwork: "workers"},
+		},
+		{
+			name:     "subnetwork in the region of the zone",
+			nic:      &gcpv1beta1.GCPNetworkInterface{Network: "default", Subnetwork: "workers"},
+			zoneOnly: true,
+		},
+		{
+			name: "subnetwork without network",
+			nic:  &gcpv1beta1.GCPNetworkInterface{Subnetwork: "workers"},
+		},
+		{
+			name:      "subnetwork in another network",
+			nic:       &gcpv1beta1.GCPNetworkInterface{Network: "cluster-network", Subnetwork: "workers"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerSpec := &gcpv1beta1.GCPMachineProviderSpec{
+				Region:            "us-east1",
+				NetworkInterfaces: []*gcpv1beta1.GCPNetworkInterface{tc.nic},
+			}
+			if tc.zoneOnly {
+				providerSpec.Region = ""
+				providerSpec.Zone = "us-east1-b"
+			}
+			reconciler := newReconciler(&machineScope{
+				Context:        context.TODO(),
+				providerSpec:   providerSpec,
+				computeService: mockComputeService,
+			})
+			err := reconciler.validateSubnetworks()
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckPrivateEgress(t *testing.T) {
+	private := false
+	testCases := []struct {
+		name        string
+		nic         *gcpv1beta1.GCPNetworkInterface
+		routers     []*compute.Router
+		expectEvent bool
+	}{
+		{
+			name: "public interface",
+			nic:  &gcpv1beta1.GCPNetworkInterface{Subnetwork: "workers"},
+		},
+		{
+			name:        "private interface without NAT",
+			nic:         &gcpv1beta1.GCPNetworkInterface{Subnetwork: "workers", PublicIP: &private},
+			expectEvent: true,
+		},
+		{
+			name: "private interface with NAT of every subnetwork",
+			nic:  &gcpv1beta1.GCPNetworkInterface{Subnetwork: "workers", PublicIP: &private},
+			routers: []*compute.Router{{
+				Network: "projects/my-project/global/networks/default",
+				Nats:    []*compute.RouterNat{{SourceSubnetworkIpRangesToNat: "ALL_SUBNETWORKS_ALL_IP_RANGES"}},
+			}},
+		},
+		{
+			name: "private interface with NAT of the subnetwork",
+			nic:  &gcpv1beta1.GCPNetworkInterface{Subnetwork: "workers", PublicIP: &private},
+			routers: []*compute.Router{{
+				Network: "projects/my-project/global/networks/default",
+				Nats: []*compute.RouterNat{{
+					SourceSubnetworkIpRangesToNat: "LIST_OF_SUBNETWORKS",
+					Subnetworks:                   []*compute.RouterNatSubnetworkToNat{{Name: "projects/my-project/regions/us-east1/subnetworks/workers"}},
+				}},
+			}},
+		},
+		{
+			name: "private interface with NAT of other subnetworks",
+			nic:  &gcpv1beta1.GCPNetworkInterface{Subnetwork: "workers", PublicIP: &private},
+			routers: []*compute.Router{{
+				Network: "projects/my-project/global/networks/default",
+				Nats: []*compute.RouterNat{{
+					SourceSubnetworkIpRangesToNat: "LIST_OF_SUBNETWORKS",
+					Subnetworks:                   []*compute.RouterNatSubnetworkToNat{{Name: "projects/my-project/regions/us-east1/subnetworks/masters"}},
+				}},
+			}},
+			expectEvent: true,
+		},
+		{
+			name: "private interface with NAT in another network",
+			nic:  &gcpv1beta1.GCPNetworkInterface{Subnetwork: "workers", PublicIP: &private},
+			routers: []*compute.Router{{
+				Network: "projects/my-project/global/networks/cluster-network",
+				Nats:    []*compute.RouterNat{{SourceSubnetworkIpRangesToNat: "ALL_SUBNETWORKS_ALL_IP_RANGES"}},
+			}},
+			expectEvent: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, mockComputeService := computeservice.NewComputeServiceMock()
+			for _, router := range tc.routers {
+				mockComputeService.AddRouter("my-project", "us-east1", router)
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := newReconciler(&machineScope{
+				Context:   context.TODO(),
+				projectID: "my-project",
+				providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+					Region:            "us-east1",
+					Zone:              "us-east1-b",
+					NetworkInterfaces: []*gcpv1beta1.GCPNetworkInterface{tc.nic},
+				},
+				computeService: mockComputeService,
+				eventRecorder:  recorder,
+			})
+			reconciler.checkPrivateEgress()
+			if events := len(recorder.Events); tc.expectEvent != (events > 0) {
+				t.Errorf("expected warning event: %v, got %d events", tc.expectEvent, events)
+			}
+		})
+	}
+}
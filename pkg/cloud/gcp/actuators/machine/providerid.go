@@ -0,0 +1,82 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machineclient "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/typed/machine/v1beta1"
+)
+
+// providerIDPrefix is the prefix of the provider IDs the GCP cloud provider sets on nodes.
+const providerIDPrefix = "gce://"
+
+// ProviderID identifies a GCP instance by its project, zone and name, as the gce://project/zone/instance provider
+// ID the GCP cloud provider sets on nodes.
+type ProviderID struct {
+	Project  string
+	Zone     string
+	Instance string
+}
+
+// String returns the gce://project/zone/instance form of the provider ID.
+func (p ProviderID) String() string {
+	return fmt.Sprintf("%s%s/%s/%s", providerIDPrefix, p.Project, p.Zone, p.Instance)
+}
+
+// ParseProviderID parses a gce://project/zone/instance provider ID. It fails unless the project, zone and instance
+// are all set and free of slashes.
+func ParseProviderID(id string) (ProviderID, error) {
+	if !strings.HasPrefix(id, providerIDPrefix) {
+		return ProviderID{}, fmt.Errorf("provider ID %q does not start with %q", id, providerIDPrefix)
+	}
+	parts := strings.Split(strings.TrimPrefix(id, providerIDPrefix), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return ProviderID{}, fmt.Errorf("provider ID %q is not of the form %sproject/zone/instance", id, providerIDPrefix)
+	}
+	return ProviderID{Project: parts[0], Zone: parts[1], Instance: parts[2]}, nil
+}
+
+// providerID returns the provider ID of the machine instance.
+func (s *machineScope) providerID() ProviderID {
+	return ProviderID{Project: s.projectID, Zone: s.providerSpec.Zone, Instance: s.instanceName()}
+}
+
+// setProviderID sets the provider ID of the machine, unless already set.
+func setProviderID(machineClient machineclient.MachineInterface, machine *machinev1.Machine, providerID ProviderID) error {
+	if machine.Spec.ProviderID != nil && *machine.Spec.ProviderID != "" {
+		return nil
+	}
+	id := providerID.String()
+	machine.Spec.ProviderID = &id
+	updated, err := machineClient.Update(machine)
+	if err != nil {
+		return fmt.Errorf("failed to set provider ID of machine: %v", err)
+	}
+	machine.ResourceVersion = updated.ResourceVersion
+	return nil
+}
+
+// useProviderIDLocation makes the reconciler use the project and zone of the provider ID of the machine, when set,
+// instead of the ones of its provider spec. The instance stays where it was created even if the spec was edited
+// since, or the defaults changed.
+func (r *Reconciler) useProviderIDLocation() {
+	if r.machine.Spec.ProviderID == nil || *r.machine.Spec.ProviderID == "" {
+		return
+	}
+	providerID, err := ParseProviderID(*r.machine.Spec.ProviderID)
+	if err != nil {
+		r.logger.Error(err, "Ignoring invalid provider ID")
+		return
+	}
+	if providerID.Zone != r.providerSpec.Zone {
+		r.logger.Info("Using the zone of the provider ID", "zone", providerID.Zone, "providerSpecZone", r.providerSpec.Zone)
+		r.providerSpec.Zone = providerID.Zone
+		// The region of the provider spec may not match the zone of the provider ID either.
+		r.providerSpec.Region = ""
+	}
+	if providerID.Project != r.projectID {
+		r.logger.Info("Using the project of the provider ID", "project", providerID.Project, "providerSpecProject", r.projectID)
+		r.projectID = providerID.Project
+	}
+}
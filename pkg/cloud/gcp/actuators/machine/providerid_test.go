@@ -0,0 +1,105 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseProviderID(t *testing.T) {
+	testCases := []struct {
+		providerID  string
+		expected    ProviderID
+		expectError bool
+	}{
+		{
+			providerID: "gce://my-project/us-east1-b/worker-0",
+			expected:   ProviderID{Project: "my-project", Zone: "us-east1-b", Instance: "worker-0"},
+		},
+		{
+			providerID:  "aws:///us-east-1a/i-0123456789",
+			expectError: true,
+		},
+		{
+			providerID:  "gce://my-project/worker-0",
+			expectError: true,
+		},
+		{
+			providerID:  "gce://my-project//worker-0",
+			expectError: true,
+		},
+		{
+			providerID:  "gce://my-project/us-east1-b/worker-0/",
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		providerID, err := ParseProviderID(tc.providerID)
+		if tc.expectError != (err != nil) {
+			t.Errorf("%s: expected error: %v, got %v", tc.providerID, tc.expectError, err)
+			continue
+		}
+		if providerID != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v", tc.providerID, tc.expected, providerID)
+		}
+		if err == nil && providerID.String() != tc.providerID {
+			t.Errorf("%s: expected formatting the parsed provider ID to return it, got %s", tc.providerID, providerID)
+		}
+	}
+}
+
+func TestProviderIDLocation(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	machine := &v1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker-0",
+		},
+	}
+	providerSpec := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:        "us-east1-b",
+		MachineType: "n1-standard-4",
+		Disks: []*gcpv1beta1.GCPDisk{
+			{
+				Boot:  true,
+				Image: "rhcos",
+			},
+		},
+	}
+	reconciler := newReconciler(&machineScope{
+		Context:        context.TODO(),
+		machine:        machine,
+		coreClient:     controllerfake.NewFakeClient(),
+		projectID:      "my-project",
+		providerSpec:   providerSpec,
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+
+	// The zone of the provider spec was edited after the instance was created.
+	id := ProviderID{Project: "my-project", Zone: "us-east1-b", Instance: "worker-0"}.String()
+	machine.Spec.ProviderID = &id
+	providerSpec.Zone = "us-east1-c"
+	if exists, err := reconciler.exists(); err != nil || !exists {
+		t.Fatalf("expected the instance in the zone of the provider ID to exist, got %v, %v", exists, err)
+	}
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if providerSpec.Zone != "us-east1-b" {
+		t.Errorf("expected the zone of the provider ID to be used, got %q", providerSpec.Zone)
+	}
+	providerSpec.Zone = "us-east1-c"
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "worker-0"); status != "" {
+		t.Errorf("expected the instance in the zone of the provider ID to be deleted, got status %q", status)
+	}
+}
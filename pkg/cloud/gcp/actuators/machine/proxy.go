@@ -0,0 +1,90 @@
+package machine
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProxyConfig holds the proxy settings used for GCP API requests, typically populated
+// from the cluster-wide proxy configuration injected as HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+type ProxyConfig struct {
+	// HTTPProxy is the proxy URL used for plain HTTP requests.
+	HTTPProxy string
+	// HTTPSProxy is the proxy URL used for HTTPS requests.
+	HTTPSProxy string
+	// NoProxy is a comma-separated list of hosts, domains and CIDRs that must be reached directly.
+	NoProxy string
+}
+
+// proxyFunc returns the proxy selection function for an http.Transport.
+func (p ProxyConfig) proxyFunc() func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxy := p.HTTPProxy
+		if req.URL.Scheme == "https" {
+			proxy = p.HTTPSProxy
+		}
+		if proxy == "" || !p.useProxy(req.URL.Hostname()) {
+			return nil, nil
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			// Proxies are commonly given as host:port without scheme.
+			if proxyURL, err := url.Parse("http://" + proxy); err == nil {
+				return proxyURL, nil
+			}
+		}
+		return proxyURL, err
+	}
+}
+
+// useProxy reports whether requests to host should go through the proxy according to NoProxy.
+func (p ProxyConfig) useProxy(host string) bool {
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+
+	for _, entry := range strings.Split(p.NoProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return false
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return false
+			}
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		if host == entry || host == strings.TrimPrefix(entry, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return false
+		}
+	}
+	return true
+}
+
+// transport returns an HTTP transport with the default net/http settings using the proxy configuration.
+func (p ProxyConfig) transport() *http.Transport {
+	return &http.Transport{
+		Proxy: p.proxyFunc(),
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
@@ -0,0 +1,51 @@
+package machine
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProxyConfig(t *testing.T) {
+	proxy := ProxyConfig{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "proxy.example.com:3129",
+		NoProxy:    ".internal.example.com, metadata.google.internal,10.0.0.0/16",
+	}
+	testCases := []struct {
+		url           string
+		expectedProxy string
+	}{
+		{url: "https://www.googleapis.com/compute/v1/projects/p", expectedProxy: "http://proxy.example.com:3129"},
+		{url: "http://www.googleapis.com/compute/v1/projects/p", expectedProxy: "http://proxy.example.com:3128"},
+		{url: "https://api.internal.example.com/", expectedProxy: ""},
+		{url: "http://metadata.google.internal/computeMetadata/v1/", expectedProxy: ""},
+		{url: "https://10.0.3.4/", expectedProxy: ""},
+		{url: "https://10.1.3.4/", expectedProxy: "http://proxy.example.com:3129"},
+		{url: "http://localhost:8080/", expectedProxy: ""},
+	}
+
+	proxyFunc := proxy.proxyFunc()
+	for _, tc := range testCases {
+		req, err := http.NewRequest("GET", tc.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxyURL, err := proxyFunc(req)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.url, err)
+			continue
+		}
+		got := ""
+		if proxyURL != nil {
+			got = proxyURL.String()
+		}
+		if got != tc.expectedProxy {
+			t.Errorf("%s: expected proxy %q, got %q", tc.url, tc.expectedProxy, got)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "https://www.googleapis.com/", nil)
+	if proxyURL, _ := (ProxyConfig{}).proxyFunc()(req); proxyURL != nil {
+		t.Errorf("expected no proxy when unconfigured, got %v", proxyURL)
+	}
+}
@@ -0,0 +1,120 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	cpusQuotaMetric            = "CPUS"
+	preemptibleCPUsQuotaMetric = "PREEMPTIBLE_CPUS"
+	addressesQuotaMetric       = "IN_USE_ADDRESSES"
+	ssdQuotaMetric             = "SSD_TOTAL_GB"
+)
+
+// familyCPUsQuotaMetrics are the regional CPU quotas of the machine families not counted in the CPUS quota.
+var familyCPUsQuotaMetrics = map[string]string{
+	"a2":  "A2_CPUS",
+	"c2":  "C2_CPUS",
+	"c2d": "C2D_CPUS",
+	"c4a": "C4A_CPUS",
+	"m1":  "M1_CPUS",
+	"m2":  "M2_CPUS",
+	"n2":  "N2_CPUS",
+	"n2d": "N2D_CPUS",
+	"t2a": "T2A_CPUS",
+	"t2d": "T2D_CPUS",
+}
+
+// ssdDiskTypes are the disk types counted in the SSD_TOTAL_GB quota.
+var ssdDiskTypes = map[string]bool{
+	"pd-ssd":      true,
+	"pd-balanced": true,
+}
+
+// machineTypeCPUsQuotaMetric returns the regional quota metric the vCPUs of the machine type count against.
+func machineTypeCPUsQuotaMetric(machineType string) string {
+	family := strings.SplitN(machineType, "-", 2)[0]
+	if metric, ok := familyCPUsQuotaMetrics[family]; ok {
+		return metric
+	}
+	// Custom machine types without family prefix are N1 machine types.
+	return cpusQuotaMetric
+}
+
+// quotaRequirements returns the amount of each regional quota metric a machine of the provider spec uses.
+// Preemptible vCPUs count against the PREEMPTIBLE_CPUS quota when the region has one.
+func quotaRequirements(spec *gcpproviderv1.GCPMachineProviderSpec, machineType *compute.MachineType, quotas map[string]*compute.Quota) map[string]float64 {
+	requirements := map[string]float64{}
+
+	cpuMetric := machineTypeCPUsQuotaMetric(machineType.Name)
+	if quota, ok := quotas[preemptibleCPUsQuotaMetric]; spec.Preemptible && ok && quota.Limit > 0 {
+		cpuMetric = preemptibleCPUsQuotaMetric
+	}
+	requirements[cpuMetric] += float64(machineType.GuestCpus)
+
+	for _, nic := range spec.NetworkInterfaces {
+		if hasPublicIP(nic) {
+			requirements[addressesQuotaMetric]++
+		}
+	}
+
+	for _, disk := range spec.Disks {
+		// Disks without size take the size of their image, which is not known here.
+		if disk != nil && ssdDiskTypes[disk.Type] {
+			requirements[ssdQuotaMetric] += float64(disk.SizeGb)
+		}
+	}
+	return requirements
+}
+
+// quotaShortfalls returns the regional quotas which cannot fit the requirements of count machines, sorted by metric.
+// Metrics the region has no quota for are not limited.
+func quotaShortfalls(requirements map[string]float64, quotas map[string]*compute.Quota, count int64) []string {
+	var shortfalls []string
+	for metric, perMachine := range requirements {
+		quota, ok := quotas[metric]
+		required := perMachine * float64(count)
+		if !ok || required == 0 {
+			continue
+		}
+		if available := quota.Limit - quota.Usage; required > available {
+			shortfalls = append(shortfalls, fmt.Sprintf("%s: %g required, %g available of %g", metric, required, available, quota.Limit))
+		}
+	}
+	sort.Strings(shortfalls)
+	return shortfalls
+}
+
+// QuotaShortfalls returns the regional quotas which cannot fit count more machines of the provider spec of the
+// machine, e.g. the template of a machine set, as messages naming the metric, the required and available amounts.
+func (a *Actuator) QuotaShortfalls(ctx context.Context, machine *machinev1.Machine, count int64) ([]string, error) {
+	scope, err := a.newMachineScope(ctx, machine, operationLogger("quota", machine))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scope for machine %q: %v", machine.Name, err)
+	}
+	r := newReconciler(scope)
+	zone, err := r.machineTypeZone()
+	if err != nil {
+		return nil, err
+	}
+	machineType, err := r.computeService.MachineTypesGet(ctx, r.projectID, zone, r.providerSpec.MachineType)
+	if err != nil {
+		return nil, fmt.Errorf("error getting machine type %q in zone %q: %v", r.providerSpec.MachineType, zone, err)
+	}
+	region, err := r.computeService.RegionsGet(ctx, r.projectID, r.region())
+	if err != nil {
+		return nil, fmt.Errorf("error getting region %q: %v", r.region(), err)
+	}
+	quotas := map[string]*compute.Quota{}
+	for _, quota := range region.Quotas {
+		quotas[quota.Metric] = quota
+	}
+	return quotaShortfalls(quotaRequirements(r.providerSpec, machineType, quotas), quotas, count), nil
+}
@@ -0,0 +1,79 @@
+package machine
+
+import (
+	"reflect"
+	"testing"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"google.golang.org/api/compute/v1"
+)
+
+func TestQuotaShortfalls(t *testing.T) {
+	publicIP := false
+	quotas := map[string]*compute.Quota{
+		"CPUS":             {Metric: "CPUS", Limit: 24, Usage: 12},
+		"N2_CPUS":          {Metric: "N2_CPUS", Limit: 100, Usage: 0},
+		"PREEMPTIBLE_CPUS": {Metric: "PREEMPTIBLE_CPUS", Limit: 64, Usage: 0},
+		"IN_USE_ADDRESSES": {Metric: "IN_USE_ADDRESSES", Limit: 8, Usage: 6},
+		"SSD_TOTAL_GB":     {Metric: "SSD_TOTAL_GB", Limit: 500, Usage: 100},
+	}
+	testCases := []struct {
+		name        string
+		spec        *gcpproviderv1.GCPMachineProviderSpec
+		machineType *compute.MachineType
+		count       int64
+		expected    []string
+	}{
+		{
+			name:        "fits",
+			spec:        &gcpproviderv1.GCPMachineProviderSpec{},
+			machineType: &compute.MachineType{Name: "n1-standard-4", GuestCpus: 4},
+			count:       3,
+		},
+		{
+			name: "exceeds CPUs, addresses and SSD",
+			spec: &gcpproviderv1.GCPMachineProviderSpec{
+				NetworkInterfaces: []*gcpproviderv1.GCPNetworkInterface{{}},
+				Disks:             []*gcpproviderv1.GCPDisk{{Boot: true, Type: "pd-ssd", SizeGb: 128}},
+			},
+			machineType: &compute.MachineType{Name: "n1-standard-4", GuestCpus: 4},
+			count:       4,
+			expected: []string{
+				"CPUS: 16 required, 12 available of 24",
+				"IN_USE_ADDRESSES: 4 required, 2 available of 8",
+				"SSD_TOTAL_GB: 512 required, 400 available of 500",
+			},
+		},
+		{
+			name: "machines without external IP and standard disks",
+			spec: &gcpproviderv1.GCPMachineProviderSpec{
+				NetworkInterfaces: []*gcpproviderv1.GCPNetworkInterface{{PublicIP: &publicIP}},
+				Disks:             []*gcpproviderv1.GCPDisk{{Boot: true, Type: "pd-standard", SizeGb: 1024}},
+			},
+			machineType: &compute.MachineType{Name: "e2-standard-4", GuestCpus: 4},
+			count:       3,
+		},
+		{
+			name:        "family quota",
+			spec:        &gcpproviderv1.GCPMachineProviderSpec{},
+			machineType: &compute.MachineType{Name: "n2-standard-8", GuestCpus: 8},
+			count:       10,
+		},
+		{
+			name:        "preemptible quota",
+			spec:        &gcpproviderv1.GCPMachineProviderSpec{Preemptible: true},
+			machineType: &compute.MachineType{Name: "n1-standard-16", GuestCpus: 16},
+			count:       5,
+			expected:    []string{"PREEMPTIBLE_CPUS: 80 required, 64 available of 64"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			shortfalls := quotaShortfalls(quotaRequirements(tc.spec, tc.machineType, quotas), quotas, tc.count)
+			if !reflect.DeepEqual(shortfalls, tc.expected) {
+				t.Errorf("expected shortfalls %q, got %q", tc.expected, shortfalls)
+			}
+		})
+	}
+}
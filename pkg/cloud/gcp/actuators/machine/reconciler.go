@@ -2,23 +2,39 @@ package machine
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1/validation"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/features"
 	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"go.opencensus.io/trace"
 	"google.golang.org/api/compute/v1"
-	apicorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/klog"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// operationTimeOut is how long to wait for an operation in a single reconcile, a variable for the tests.
+var operationTimeOut = 180 * time.Second
+
 const (
-	userDataSecretKey  = "userData"
-	operationTimeOut   = 180 * time.Second
 	operationRetryWait = 5 * time.Second
+
+	// dryRunAnnotation makes create() validate the machine and log the rendered instance
+	// without creating it. Pre-flight checks are always run in dry-run mode.
+	dryRunAnnotation = "gcpprovider.machine.openshift.io/dry-run"
+
+	// forceDeleteAnnotation makes delete() disable the deletion protection of the instance
+	// before deleting it. Deleting protected instances fails otherwise.
+	forceDeleteAnnotation = "gcpprovider.machine.openshift.io/force-delete"
+
+	// Types of the operations recorded as pending in the provider status.
+	operationTypeInsert = "insert"
+	operationTypeDelete = "delete"
 )
 
 // Reconciler are list of services required by machine actuator, easy to create a fake
@@ -28,6 +44,12 @@ type Reconciler struct {
 
 // NewReconciler populates all the services based on input scope
 func newReconciler(scope *machineScope) *Reconciler {
+	if scope.logger == nil {
+		scope.logger = log
+	}
+	if scope.providerStatus == nil {
+		scope.providerStatus = &v1beta1.GCPMachineProviderStatus{}
+	}
 	return &Reconciler{
 		scope,
 	}
@@ -35,17 +57,41 @@ func newReconciler(scope *machineScope) *Reconciler {
 
 // Create creates machine if and only if machine exists, handled by cluster-api
 func (r *Reconciler) create() error {
+	if resumed, err := r.resumePendingOperation(); err != nil {
+		return err
+	} else if resumed == operationTypeInsert {
+		return r.ensureMemberships()
+	}
+	if hasCondition(r.providerStatus, v1beta1.MachinePreempted) {
+		return machineapierrors.CreateMachine("instance was preempted, the machine needs to be replaced")
+	}
 	if err := validateMachine(*r.machine, *r.providerSpec); err != nil {
 		return fmt.Errorf("failed validating machine provider spec: %v", err)
 	}
+	if r.providerSpec.Preemptible && !r.featureGates.Enabled(features.SpotVMs) {
+		return machineapierrors.InvalidMachineConfiguration("preemptible machines require the %s feature gate", features.SpotVMs)
+	}
+	dryRun := r.machine.Annotations[dryRunAnnotation] == "true"
+	if dryRun {
+		r.preflightChecksEnabled = true
+	}
+	if err := r.selectZone(); err != nil {
+		return err
+	}
+	if err := r.resolveStreamImages(); err != nil {
+		return err
+	}
+	if err := r.preflightChecks(); err != nil {
+		return err
+	}
 
 	zone := r.providerSpec.Zone
 	instance := &compute.Instance{
 		CanIpForward:       r.providerSpec.CanIPForward,
 		DeletionProtection: r.providerSpec.DeletionProtection,
-		Labels:             r.providerSpec.Labels,
+		Labels:             r.ownedLabels(r.providerSpec.Labels),
 		MachineType:        fmt.Sprintf("zones/%s/machineTypes/%s", zone, r.providerSpec.MachineType),
-		Name:               r.machine.Name,
+		Name:               r.instanceName(),
 		Tags: &compute.Tags{
 			Items: r.providerSpec.Tags,
 		},
@@ -53,31 +99,94 @@ func (r *Reconciler) create() error {
 
 	// disks
 	var disks = []*compute.AttachedDisk{}
-	for _, disk := range r.providerSpec.Disks {
-		disks = append(disks, &compute.AttachedDisk{
+	var retainedDisks []string
+	var multiWriterDisks []*compute.Disk
+	for i, disk := range r.providerSpec.Disks {
+		if disk.Type == v1beta1.LocalSSDDiskType {
+			disks = append(disks, &compute.AttachedDisk{
+				AutoDelete: true,
+				Interface:  "NVME",
+				Type:       "SCRATCH",
+				InitializeParams: &compute.AttachedDiskInitializeParams{
+					DiskType: fmt.Sprintf("zones/%s/diskTypes/%s", zone, disk.Type),
+				},
+			})
+			continue
+		}
+		if disk.Source != "" {
+			// Existing disks are not created by the controller, they are not recorded for deletion.
+			disks = append(disks, r.existingAttachedDisk(zone, disk))
+			continue
+		}
+		if disk.MultiWriter {
+			// Multi-writer disks cannot be created with the instance, they are inserted before it and deleted
+			// with the machine whether or not they are deleted with the instance.
+			name := diskName(instance.Name, i, false)
+			multiWriterDisks = append(multiWriterDisks, &compute.Disk{
+				Name:        name,
+				SizeGb:      disk.SizeGb,
+				Type:        fmt.Sprintf("zones/%s/diskTypes/%s", zone, disk.Type),
+				Labels:      r.ownedLabels(disk.Labels),
+				SourceImage: disk.Image,
+			})
+			disks = append(disks, &compute.AttachedDisk{
+				AutoDelete: disk.AutoDelete,
+				Mode:       "READ_WRITE",
+				Source:     fmt.Sprintf("projects/%s/zones/%s/disks/%s", r.projectID, zone, name),
+			})
+			retainedDisks = append(retainedDisks, name)
+			continue
+		}
+		attachedDisk := &compute.AttachedDisk{
 			AutoDelete: disk.AutoDelete,
 			Boot:       disk.Boot,
 			InitializeParams: &compute.AttachedDiskInitializeParams{
 				DiskSizeGb:  disk.SizeGb,
 				DiskType:    fmt.Sprintf("zones/%s/diskTypes/%s", zone, disk.Type),
-				Labels:      disk.Labels,
+				Labels:      r.ownedLabels(disk.Labels),
 				SourceImage: disk.Image,
 			},
-		})
+		}
+		if !disk.AutoDelete {
+			// Name the disks outliving the instance so they can be deleted with the machine.
+			attachedDisk.InitializeParams.DiskName = diskName(instance.Name, i, disk.Boot)
+			retainedDisks = append(retainedDisks, attachedDisk.InitializeParams.DiskName)
+		}
+		disks = append(disks, attachedDisk)
 	}
 	instance.Disks = disks
 
+	// accelerators
+	for _, gpu := range r.providerSpec.GPUs {
+		instance.GuestAccelerators = append(instance.GuestAccelerators, &compute.AcceleratorConfig{
+			AcceleratorCount: gpu.Count,
+			AcceleratorType:  fmt.Sprintf("zones/%s/acceleratorTypes/%s", zone, gpu.Type),
+		})
+	}
+	if len(instance.GuestAccelerators) > 0 || r.providerSpec.Preemptible || r.providerSpec.ConfidentialInstanceType != "" {
+		// Instances with accelerators, preemptible instances and Confidential VMs do not support live migration.
+		instance.Scheduling = &compute.Scheduling{
+			OnHostMaintenance: "TERMINATE",
+		}
+	}
+	if r.providerSpec.Preemptible {
+		automaticRestart := false
+		instance.Scheduling.Preemptible = true
+		instance.Scheduling.AutomaticRestart = &automaticRestart
+	}
+
 	// networking
 	var networkInterfaces = []*compute.NetworkInterface{}
 	for _, nic := range r.providerSpec.NetworkInterfaces {
-		computeNIC := &compute.NetworkInterface{
-			AccessConfigs: []*compute.AccessConfig{{}},
+		computeNIC := &compute.NetworkInterface{}
+		if hasPublicIP(nic) {
+			computeNIC.AccessConfigs = []*compute.AccessConfig{{}}
 		}
 		if len(nic.Network) != 0 {
-			computeNIC.Network = fmt.Sprintf("projects/%s/global/networks/%s", r.projectID, nic.Network)
+			computeNIC.Network = fmt.Sprintf("projects/%s/global/networks/%s", r.networkProjectID(), nic.Network)
 		}
 		if len(nic.Subnetwork) != 0 {
-			computeNIC.Subnetwork = fmt.Sprintf("regions/%s/subnetworks/%s", r.providerSpec.Region, nic.Subnetwork)
+			computeNIC.Subnetwork = fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", r.networkProjectID(), r.region(), nic.Subnetwork)
 		}
 		networkInterfaces = append(networkInterfaces, computeNIC)
 	}
@@ -88,7 +197,7 @@ func (r *Reconciler) create() error {
 	for _, sa := range r.providerSpec.ServiceAccounts {
 		serviceAccounts = append(serviceAccounts, &compute.ServiceAccount{
 			Email:  sa.Email,
-			Scopes: sa.Scopes,
+			Scopes: r.serviceAccountScopes(sa),
 		})
 	}
 	instance.ServiceAccounts = serviceAccounts
@@ -100,51 +209,381 @@ func (r *Reconciler) create() error {
 	}
 	var metadataItems = []*compute.MetadataItems{
 		{
-			Key:   "user-data",
+			Key:   userDataMetadataItemKey(r.userDataFormat()),
 			Value: &userData,
 		},
 	}
-	for _, metadata := range r.providerSpec.Metadata {
-		metadataItems = append(metadataItems, &compute.MetadataItems{
-			Key:   metadata.Key,
-			Value: metadata.Value,
-		})
+	items, err := r.metadataItems()
+	if err != nil {
+		return err
 	}
 	instance.Metadata = &compute.Metadata{
-		Items: metadataItems,
+		Items: append(metadataItems, items...),
+	}
+	if err := validateMetadataSize(instance.Metadata.Items); err != nil {
+		return err
+	}
+
+	if dryRun {
+		rendered, err := renderInstance(instance, r.secretMetadataKeys())
+		if err != nil {
+			return fmt.Errorf("error marshalling instance: %v", err)
+		}
+		r.logger.Info("Dry run: skipping instance creation", "instance", instance.Name, "insertRequest", string(rendered))
+		return nil
+	}
+
+	if err := r.ensureFirewallRules(); err != nil {
+		return err
+	}
+	// Record the instance name and disks before inserting the instance, they must be deleted even if the controller
+	// stops while waiting.
+	r.providerStatus.InstanceName = instance.Name
+	r.providerStatus.Disks = retainedDisks
+	r.providerStatus.AttachedDisks = existingDataDisks(r.providerSpec.Disks)
+	if err := r.reserveAddresses(instance); err != nil {
+		return err
+	}
+	if err := r.insertMultiWriterDisks(zone, multiWriterDisks); err != nil {
+		return err
+	}
+	operation, err := r.insertInstance(instance)
+	if err != nil {
+		return err
+	}
+	r.invalidateInstance()
+	r.setOperation(operation)
+	r.logger.Info("Inserting instance", "instance", instance.Name, "gcpOperation", operation.Name)
+	if err := r.waitUntilOperationCompleted(zone, operation.Name, operationTypeInsert); err != nil {
+		r.handleStockout(err)
+		return err
+	}
+	if err := r.ensurePreemptibleNodeMetadata(); err != nil {
+		return err
+	}
+	return r.ensureMemberships()
+}
+
+// update waits for the operation that was pending when the controller stopped, if any, fails the machine
+// if its preemptible instance was stopped and recreates a terminated instance with auto-repair. It then
+// takes the disk snapshot requested by the snapshot annotation, ensures the firewall rules of the instance,
+// its existing data disks and its registration in target pools and instance groups,
+// and reports whether the instance passes its health check. The instance is looked up in the project and
+// zone of the provider ID, when set.
+func (r *Reconciler) update() error {
+	r.useProviderIDLocation()
+	if found, err := r.locateZone(); err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("instance %q does not exist in region %q", r.instanceName(), r.region())
+	}
+	if _, err := r.resumePendingOperation(); err != nil {
+		return err
+	}
+	if err := r.handlePreemption(); err != nil {
+		return err
+	}
+	if err := r.repairInstance(); err != nil {
+		return err
+	}
+	if err := r.ensureSnapshot(); err != nil {
+		return err
+	}
+	if err := r.ensureFirewallRules(); err != nil {
+		return err
+	}
+	if err := r.ensurePreemptibleNodeMetadata(); err != nil {
+		return err
+	}
+	if err := r.ensureMetadata(); err != nil {
+		return err
+	}
+	if err := r.ensureExistingDisks(); err != nil {
+		return err
+	}
+	if err := r.ensureMemberships(); err != nil {
+		return err
+	}
+	r.updateReadyCondition()
+	return nil
+}
+
+// insertInstance inserts the instance, with the Resource Manager tags, advanced machine features and
+// confidential instance type of the provider spec when set.
+func (r *Reconciler) insertInstance(instance *compute.Instance) (*compute.Operation, error) {
+	params := &computeservice.InstanceParams{
+		ResourceManagerTags: r.providerSpec.ResourceManagerTags,
+	}
+	if features := r.providerSpec.AdvancedMachineFeatures; features != nil && features.PerformanceMonitoringUnit != "" {
+		params.AdvancedMachineFeatures = &computeservice.AdvancedMachineFeatures{
+			PerformanceMonitoringUnit: string(features.PerformanceMonitoringUnit),
+		}
 	}
+	if r.providerSpec.ConfidentialInstanceType != "" {
+		params.ConfidentialInstanceConfig = &computeservice.ConfidentialInstanceConfig{
+			ConfidentialInstanceType: string(r.providerSpec.ConfidentialInstanceType),
+		}
+	}
+	if len(params.ResourceManagerTags) == 0 && params.AdvancedMachineFeatures == nil && params.ConfidentialInstanceConfig == nil {
+		return r.computeService.InstancesInsert(r.Context, r.projectID, r.providerSpec.Zone, instance)
+	}
+	return r.computeService.InstancesInsertWithParams(r.Context, r.projectID, r.providerSpec.Zone, instance, params)
+}
 
-	operation, err := r.computeService.InstancesInsert(r.projectID, zone, instance)
+// ensureMemberships registers the instance in the target pools and instance groups of the provider spec and
+// attaches its instance schedule policy.
+func (r *Reconciler) ensureMemberships() error {
+	if err := r.ensureTargetPoolsMembership(); err != nil {
+		return err
+	}
+	if err := r.ensureInstanceGroupsMembership(); err != nil {
+		return err
+	}
+	return r.ensureInstanceSchedulePolicy()
+}
+
+// exists returns true if the machine instance exists in GCP, in the project and zone of the provider ID when set.
+func (r *Reconciler) exists() (bool, error) {
+	r.useProviderIDLocation()
+	if found, err := r.locateZone(); err != nil || !found {
+		return false, err
+	}
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	if _, _, err := r.getInstance(); err != nil {
+		if isNotFoundError(err) {
+			r.logger.Info("Instance does not exist", "instance", name)
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting instance %q in zone %q: %v", name, zone, err)
+	}
+	return true, nil
+}
+
+// delete deletes the machine instance and waits for the deletion to complete, then deletes the disks
+// of the instance that outlive it and releases its static addresses. A missing instance is treated as
+// already deleted. The instance is looked up in the project and zone of the provider ID, when set.
+func (r *Reconciler) delete() error {
+	r.useProviderIDLocation()
+	if found, err := r.locateZone(); err != nil {
+		return err
+	} else if !found {
+		r.logger.Info("Instance is already deleted", "instance", r.instanceName())
+		return r.deleteInstanceResources()
+	}
+	if resumed, err := r.resumePendingOperation(); err != nil {
+		return err
+	} else if resumed == operationTypeDelete {
+		return r.deleteInstanceResources()
+	}
+	instance, err := r.checkInstanceOwnership()
 	if err != nil {
 		return err
 	}
-	return r.waitUntilOperationCompleted(zone, operation.Name)
+	if err := r.removeFromTargetPools(); err != nil {
+		return err
+	}
+	if err := r.removeFromInstanceGroups(); err != nil {
+		return err
+	}
+	if err := r.detachExistingDisks(instance); err != nil {
+		return err
+	}
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	forceDelete := r.machine.Annotations[forceDeleteAnnotation] == "true"
+	if forceDelete {
+		if err := r.disableDeletionProtection(); err != nil {
+			return err
+		}
+	}
+	operation, err := r.computeService.InstancesDelete(r.Context, r.projectID, zone, name)
+	if err != nil {
+		if isNotFoundError(err) {
+			// The instance appears once its insert operation completes, e.g. after create timed out waiting for it.
+			inserting, err := r.runningInsertOperation()
+			if err != nil {
+				return err
+			}
+			if inserting != nil {
+				r.logger.Info("Waiting for the instance to be inserted before deleting it", "instance", name, "gcpOperation", inserting.Name)
+				if err := r.waitUntilOperationCompleted(zone, inserting.Name, operationTypeInsert); err != nil {
+					return err
+				}
+				return r.delete()
+			}
+			r.logger.Info("Instance is already deleted", "instance", name)
+			return r.deleteInstanceResources()
+		}
+		if r.providerSpec.DeletionProtection && !forceDelete {
+			return fmt.Errorf("error deleting instance %q in zone %q, set the %s annotation to \"true\" to disable its deletion protection: %v", name, zone, forceDeleteAnnotation, err)
+		}
+		return fmt.Errorf("error deleting instance %q in zone %q: %v", name, zone, err)
+	}
+	r.invalidateInstance()
+	r.setOperation(operation)
+	r.logger.Info("Deleting instance", "instance", name, "gcpOperation", operation.Name)
+	if err := r.waitUntilOperationCompleted(zone, operation.Name, operationTypeDelete); err != nil {
+		return err
+	}
+	return r.deleteInstanceResources()
 }
 
-func (r *Reconciler) getCustomUserData() (string, error) {
-	if r.providerSpec.UserDataSecret == nil {
+// runningInsertOperation returns the insert operation of the instance which is not done yet, if any.
+func (r *Reconciler) runningInsertOperation() (*compute.Operation, error) {
+	zone := r.providerSpec.Zone
+	operations, err := r.computeService.ZoneOperationsList(r.Context, r.projectID, zone, `(operationType = "insert") AND (status != "DONE")`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing operations in zone %q: %v", zone, err)
+	}
+	instanceURL := r.instanceURL()
+	for _, operation := range operations {
+		if operation.OperationType == "insert" && operation.Status != "DONE" && isInstanceURL(operation.TargetLink, instanceURL) {
+			return operation, nil
+		}
+	}
+	return nil, nil
+}
+
+// disableDeletionProtection disables the deletion protection of the instance, if enabled.
+func (r *Reconciler) disableDeletionProtection() error {
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	instance, err := r.computeService.InstancesGet(r.Context, r.projectID, zone, name)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil
+		}
+		return fmt.Errorf("error getting instance %q in zone %q: %v", name, zone, err)
+	}
+	if !instance.DeletionProtection {
+		return nil
+	}
+	operation, err := r.computeService.InstancesSetDeletionProtection(r.Context, r.projectID, zone, name, false)
+	if err != nil {
+		return fmt.Errorf("error disabling deletion protection of instance %q in zone %q: %v", name, zone, err)
+	}
+	r.logger.Info("Disabling deletion protection of instance", "instance", name, "gcpOperation", operation.Name)
+	if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+		return fmt.Errorf("error disabling deletion protection of instance %q in zone %q: %v", name, zone, err)
+	}
+	return nil
+}
+
+// deleteInstanceResources deletes the resources created for the instance which outlive it.
+func (r *Reconciler) deleteInstanceResources() error {
+	if err := r.deleteRetainedDisks(); err != nil {
+		return err
+	}
+	return r.releaseAddresses()
+}
+
+// resumePendingOperation waits for the operation the controller was waiting for when it stopped, if any.
+// It returns the type of the pending operation once it completed successfully, or an empty string
+// when there was no pending operation.
+func (r *Reconciler) resumePendingOperation() (string, error) {
+	pending := r.providerStatus.PendingOperation
+	if pending == nil {
 		return "", nil
 	}
-	var userDataSecret apicorev1.Secret
+	r.logger.Info("Resuming pending operation", "gcpOperation", pending.Name, "type", pending.Type)
+	if err := r.waitUntilOperationCompleted(pending.Zone, pending.Name, pending.Type); err != nil {
+		if isNotFoundError(err) {
+			// Operations are garbage collected eventually, reconcile from the state of the instance instead.
+			r.providerStatus.PendingOperation = nil
+			return "", nil
+		}
+		return "", err
+	}
+	return pending.Type, nil
+}
 
-	if err := r.coreClient.Get(context.Background(), client.ObjectKey{Namespace: r.machine.GetNamespace(), Name: r.providerSpec.UserDataSecret.Name}, &userDataSecret); err != nil {
-		return "", fmt.Errorf("error getting user data secret %q in namespace %q: %v", r.providerSpec.UserDataSecret.Name, r.machine.GetNamespace(), err)
+// renderInstance returns the JSON representation of the instance with the metadata values of redactedKeys redacted.
+func renderInstance(instance *compute.Instance, redactedKeys []string) ([]byte, error) {
+	redacted := *instance
+	if instance.Metadata != nil {
+		redacted.Metadata = &compute.Metadata{}
+		for _, item := range instance.Metadata.Items {
+			if hasString(redactedKeys, item.Key) {
+				value := "<redacted>"
+				item = &compute.MetadataItems{Key: item.Key, Value: &value}
+			}
+			redacted.Metadata.Items = append(redacted.Metadata.Items, item)
+		}
 	}
-	data, exists := userDataSecret.Data[userDataSecretKey]
-	if !exists {
-		return "", fmt.Errorf("secret %v/%v does not have %q field set. Thus, no user data applied when creating an instance", r.machine.GetNamespace(), r.providerSpec.UserDataSecret.Name, userDataSecretKey)
+	return json.Marshal(redacted)
+}
+
+// waitUntilOperationCompleted polls the zone operation until it is done, the operation
+// times out or the reconcile context is cancelled, e.g. on controller shutdown. When the
+// operation times out or the reconcile context is cancelled, the operation is recorded as
+// pending in the provider status so the next reconcile resumes waiting for it.
+func (r *Reconciler) waitUntilOperationCompleted(zone, operationName, operationType string) error {
+	done, err := r.pollOperation(zone, operationName, func(ctx context.Context) (*compute.Operation, error) {
+		return r.computeService.ZoneOperationsGet(ctx, r.projectID, zone, operationName)
+	})
+	if done {
+		r.providerStatus.PendingOperation = nil
+	} else if r.Context.Err() != nil {
+		r.providerStatus.PendingOperation = &v1beta1.GCPOperation{Name: operationName, Zone: zone, Type: operationType}
+		err = fmt.Errorf("stopped waiting for %s operation %q, it will be resumed by the next reconcile: %v", operationType, operationName, r.Context.Err())
+	} else if err == wait.ErrWaitTimeout {
+		// Keep waiting for the operation in the next reconcile rather than racing with it, e.g. deleting
+		// the instance before it is inserted.
+		r.providerStatus.PendingOperation = &v1beta1.GCPOperation{Name: operationName, Zone: zone, Type: operationType}
+		err = fmt.Errorf("timed out waiting for %s operation %q, it will be resumed by the next reconcile", operationType, operationName)
 	}
-	return base64.StdEncoding.EncodeToString(data), nil
+	return err
+}
+
+// waitUntilZoneOperationCompleted polls the zone operation like waitUntilOperationCompleted, without
+// recording it as pending since it only changes memberships, which the next reconcile ensures again.
+func (r *Reconciler) waitUntilZoneOperationCompleted(zone, operationName string) error {
+	_, err := r.pollOperation(zone, operationName, func(ctx context.Context) (*compute.Operation, error) {
+		return r.computeService.ZoneOperationsGet(ctx, r.projectID, zone, operationName)
+	})
+	return err
+}
+
+// waitUntilRegionOperationCompleted polls the region operation until it is done, the operation
+// times out or the reconcile context is cancelled. Region operations are not recorded as pending
+// since they only change memberships, which the next reconcile ensures again.
+func (r *Reconciler) waitUntilRegionOperationCompleted(region, operationName string) error {
+	_, err := r.pollOperation(region, operationName, func(ctx context.Context) (*compute.Operation, error) {
+		return r.computeService.RegionOperationsGet(ctx, r.projectID, region, operationName)
+	})
+	return err
+}
+
+// waitUntilGlobalOperationCompleted polls the global operation of the project until it is done, the operation
+// times out or the reconcile context is cancelled. The project is the one the operation was started in, e.g.
+// the Shared VPC host project for firewall rules. It is not recorded as pending since firewall rules are
+// ensured again by the next reconcile.
+func (r *Reconciler) waitUntilGlobalOperationCompleted(project, operationName string) error {
+	_, err := r.pollOperation("global", operationName, func(ctx context.Context) (*compute.Operation, error) {
+		return r.computeService.GlobalOperationsGet(ctx, project, operationName)
+	})
+	return err
 }
 
-func (r *Reconciler) waitUntilOperationCompleted(zone, operationName string) error {
-	return wait.Poll(operationRetryWait, operationTimeOut, func() (bool, error) {
-		op, err := r.computeService.ZoneOperationsGet(r.projectID, zone, operationName)
+// pollOperation polls the operation in the zone or region until it is done, the operation times out
+// or the reconcile context is cancelled. It returns whether the operation is done along with its error,
+// wait.ErrWaitTimeout when the operation timed out.
+func (r *Reconciler) pollOperation(location, operationName string, getOperation func(ctx context.Context) (*compute.Operation, error)) (bool, error) {
+	ctx, span := trace.StartSpan(r.Context, "gcp.waitOperation")
+	span.AddAttributes(trace.StringAttribute("operation", operationName), trace.StringAttribute("location", location))
+	ctx, cancel := context.WithTimeout(ctx, operationTimeOut)
+	defer cancel()
+	start := time.Now()
+	done := false
+	err := wait.PollImmediateUntil(operationRetryWait, func() (bool, error) {
+		op, err := getOperation(ctx)
 		if err != nil {
 			return false, err
 		}
-		klog.V(3).Infof("Waiting for operation to be completed... (status: %s)", op.Status)
+		r.logger.V(3).Info("Waiting for operation to be completed", "gcpOperation", operationName, "status", op.Status)
 		if op.Status == "DONE" {
+			done = true
 			if op.Error == nil {
 				return true, nil
 			}
@@ -155,12 +594,27 @@ func (r *Reconciler) waitUntilOperationCompleted(zone, operationName string) err
 			return false, fmt.Errorf("the following errors occurred: %+v", err)
 		}
 		return false, nil
-	})
+	}, ctx.Done())
+	if !done && err != nil && err != wait.ErrWaitTimeout && ctx.Err() != nil && r.Context.Err() == nil {
+		// The operation timed out while getting it, e.g. the request hung until the deadline.
+		err = wait.ErrWaitTimeout
+	}
+	observeOperationWait(r.machine.Namespace, start, err)
+	endSpan(span, &err)
+	return done, err
 }
 
+// validateMachine is a complementary validation to fail early in case
+// the validating webhook is not deployed, see pkg/webhooks.
 func validateMachine(machine machinev1.Machine, providerSpec v1beta1.GCPMachineProviderSpec) error {
-	// TODO (alberto): First validation should happen via webhook before the object is persisted.
-	// This is a complementary validation to fail early in case of lacking proper webhook validation.
-	// Default values can also be set here
+	// Prefixed instance names are valid once the prefix is, see the provider spec validation.
+	if providerSpec.InstanceNamePrefix == "" {
+		if err := validateInstanceName(machine.Name); err != nil {
+			return err
+		}
+	}
+	if errs := validation.ValidateGCPMachineProviderSpec(&providerSpec, field.NewPath("spec", "providerSpec", "value")); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
 	return nil
 }
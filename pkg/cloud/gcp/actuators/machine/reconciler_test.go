@@ -1,30 +1,1702 @@
 package machine
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
 	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/features"
+	machinecommon "github.com/openshift/cluster-api/pkg/apis/machine/common"
 	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machinefake "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/fake"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestCreate(t *testing.T) {
 	_, mockComputeService := computeservice.NewComputeServiceMock()
 	machineScope := machineScope{
+		Context: context.TODO(),
 		machine: &v1beta1.Machine{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      "",
+				Name:      "worker-us-east1-b-abcde",
 				Namespace: "",
 			},
 		},
-		coreClient:     controllerfake.NewFakeClient(),
-		providerSpec:   &gcpv1beta1.GCPMachineProviderSpec{},
+		coreClient: controllerfake.NewFakeClient(),
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+		},
+		computeService: mockComputeService,
+	}
+	reconciler := newReconciler(&machineScope)
+	if err := reconciler.create(); err != nil {
+		t.Errorf("reconciler was not expected to return error: %v", err)
+	}
+}
+
+func TestCreateDryRun(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	machineScope := machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "worker-us-east1-b-abcde",
+				Annotations: map[string]string{dryRunAnnotation: "true"},
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+		},
+		computeService: mockComputeService,
+	}
+	reconciler := newReconciler(&machineScope)
+	if err := reconciler.create(); err != nil {
+		t.Errorf("reconciler was not expected to return error: %v", err)
+	}
+	if receivedInstance.Name != "" {
+		t.Errorf("expected no instance to be inserted in dry-run mode, got %q", receivedInstance.Name)
+	}
+}
+
+func TestInstanceName(t *testing.T) {
+	if name := instanceName("worker-us-east1-b-abcde"); name != "worker-us-east1-b-abcde" {
+		t.Errorf("expected short names to be kept, got %q", name)
+	}
+
+	longName := "cluster-abcde-" + strings.Repeat("very-long-machineset-name-", 3) + "xyz12"
+	name := instanceName(longName)
+	if len(name) > 63 {
+		t.Errorf("expected name to be truncated to 63 characters, got %d: %q", len(name), name)
+	}
+	if name != instanceName(longName) {
+		t.Errorf("expected truncation to be deterministic")
+	}
+	if name == instanceName(longName+"0") {
+		t.Errorf("expected names sharing a prefix to map to different instance names")
+	}
+	if err := validateInstanceName(longName); err != nil {
+		t.Errorf("expected truncated name to be valid: %v", err)
+	}
+
+	if err := validateInstanceName("Worker.1"); err == nil {
+		t.Errorf("expected names with uppercase letters and dots to be rejected")
+	}
+}
+
+func TestDiskName(t *testing.T) {
+	if name := diskName("worker-0", 1, false); name != "worker-0-disk-1" {
+		t.Errorf("expected short disk names to be kept, got %q", name)
+	}
+
+	longName := instanceName("cluster-abcde-" + strings.Repeat("very-long-machineset-name-", 3) + "xyz12")
+	name := diskName(longName, 12, false)
+	if len(name) > 63 || !strings.HasSuffix(name, "-disk-12") {
+		t.Errorf("expected disk name to be truncated to 63 characters keeping its suffix, got %d: %q", len(name), name)
+	}
+	if errs := validation.IsDNS1035Label(name); len(errs) > 0 {
+		t.Errorf("expected truncated disk name to be valid: %v", errs)
+	}
+	if name == diskName(longName, 13, false) || name == diskName(longName[:len(longName)-1]+"0", 12, false) {
+		t.Errorf("expected truncated disk names to stay unique")
+	}
+}
+
+func TestAddressName(t *testing.T) {
+	if name := addressName("worker-0", 0, true); name != "worker-0-nic0-external" {
+		t.Errorf("expected short address names to be kept, got %q", name)
+	}
+
+	longName := instanceName("cluster-abcde-" + strings.Repeat("very-long-machineset-name-", 3) + "xyz12")
+	for _, external := range []bool{false, true} {
+		name := addressName(longName, 1, external)
+		if errs := validation.IsDNS1035Label(name); len(errs) > 0 {
+			t.Errorf("expected address name %q to be truncated to a valid name: %v", name, errs)
+		}
+	}
+	if addressName(longName, 1, true) == addressName(longName, 1, false) {
+		t.Errorf("expected truncated internal and external address names to differ")
+	}
+}
+
+func TestInstanceNamePrefix(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "worker-us-east1-b-abcde",
+				Namespace: "openshift-machine-api",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:               "us-east1-b",
+			MachineType:        "n1-standard-4",
+			InstanceNamePrefix: "prod-worker",
+			Disks:              []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+		},
+		providerStatus: &gcpv1beta1.GCPMachineProviderStatus{},
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	name := receivedInstance.Name
+	if !strings.HasPrefix(name, "prod-worker-") || len(name) != len("prod-worker-")+instanceNameHashLength {
+		t.Errorf("expected the instance name to be the prefix and a hash, got %q", name)
+	}
+	if reconciler.providerStatus.InstanceName != name {
+		t.Errorf("expected instance name %q to be recorded, got %q", name, reconciler.providerStatus.InstanceName)
+	}
+	other := &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-us-east1-b-abcde", Namespace: "other"}}
+	if prefixedInstanceName(other, "prod-worker") == name {
+		t.Errorf("expected machines of the same name in different namespaces to map to different instance names")
+	}
+
+	// The recorded name is kept once the prefix changes.
+	reconciler.providerSpec.InstanceNamePrefix = "worker"
+	if got := reconciler.instanceName(); got != name {
+		t.Errorf("expected recorded instance name %q, got %q", name, got)
+	}
+	exists, err := reconciler.exists()
+	if err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if !exists {
+		t.Errorf("expected the instance of the recorded name to exist")
+	}
+
+	// Machines created before the name was recorded use the instance of their provider ID.
+	reconciler.providerStatus.InstanceName = ""
+	providerID := ProviderID{Project: "my-project", Zone: "us-east1-b", Instance: name}.String()
+	reconciler.machine.Spec.ProviderID = &providerID
+	if got := reconciler.instanceName(); got != name {
+		t.Errorf("expected provider ID instance name %q, got %q", name, got)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	machineScope := machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-us-east1-b-abcde",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+		},
 		computeService: mockComputeService,
 	}
 	reconciler := newReconciler(&machineScope)
+
+	if exists, err := reconciler.exists(); err != nil || exists {
+		t.Fatalf("expected instance not to exist before create, got exists: %v, error: %v", exists, err)
+	}
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "worker-us-east1-b-abcde"); status != "PROVISIONING" {
+		t.Errorf("expected instance to be PROVISIONING after create, got %q", status)
+	}
+	if exists, err := reconciler.exists(); err != nil || !exists {
+		t.Fatalf("expected instance to exist after create, got exists: %v, error: %v", exists, err)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "worker-us-east1-b-abcde"); status != "RUNNING" {
+		t.Errorf("expected instance to be RUNNING once observed, got %q", status)
+	}
+	if err := reconciler.create(); !isAlreadyExistsError(err) {
+		t.Errorf("expected an already exists error creating the instance twice, got: %v", err)
+	}
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if exists, err := reconciler.exists(); err != nil || exists {
+		t.Errorf("expected instance not to exist after delete, got exists: %v, error: %v", exists, err)
+	}
+	if err := reconciler.delete(); err != nil {
+		t.Errorf("expected deleting a missing instance to succeed, got: %v", err)
+	}
+}
+
+func TestCreateFailures(t *testing.T) {
+	testCases := []struct {
+		name          string
+		inject        func(mock *computeservice.GCPComputeServiceMock)
+		timeout       time.Duration
+		expectedError string
+	}{
+		{
+			name: "rate limited insert",
+			inject: func(mock *computeservice.GCPComputeServiceMock) {
+				mock.FailOn("InstancesInsert", computeservice.APIError(http.StatusTooManyRequests, "rateLimitExceeded", "Rate Limit Exceeded"))
+			},
+			expectedError: "Rate Limit Exceeded",
+		},
+		{
+			name: "quota exceeded",
+			inject: func(mock *computeservice.GCPComputeServiceMock) {
+				mock.FailOperations("QUOTA_EXCEEDED", "Quota 'CPUS' exceeded. Limit: 24.0 in region us-east1.")
+			},
+			expectedError: "QUOTA_EXCEEDED",
+		},
+		{
+			name: "zone stockout",
+			inject: func(mock *computeservice.GCPComputeServiceMock) {
+				mock.FailOperations("ZONE_RESOURCE_POOL_EXHAUSTED", "The zone does not have enough resources available to fulfill the request.")
+			},
+			expectedError: "ZONE_RESOURCE_POOL_EXHAUSTED",
+		},
+		{
+			name: "operation poll timeout",
+			inject: func(mock *computeservice.GCPComputeServiceMock) {
+				mock.HangOn("ZoneOperationsGet")
+			},
+			timeout:       10 * time.Millisecond,
+			expectedError: context.DeadlineExceeded.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, mockComputeService := computeservice.NewComputeServiceMock()
+			tc.inject(mockComputeService)
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			reconciler := newReconciler(&machineScope{
+				Context: ctx,
+				machine: &v1beta1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "worker-us-east1-b-abcde",
+					},
+				},
+				coreClient: controllerfake.NewFakeClient(),
+				providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+					Zone:        "us-east1-b",
+					MachineType: "n1-standard-4",
+					Disks: []*gcpv1beta1.GCPDisk{
+						{
+							Boot:  true,
+							Image: "rhcos",
+						},
+					},
+				},
+				computeService: mockComputeService,
+			})
+			err := reconciler.create()
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("expected error containing %q, got: %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestResumePendingOperation(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	providerStatus := &gcpv1beta1.GCPMachineProviderStatus{}
+	newScope := func(ctx context.Context) *machineScope {
+		return &machineScope{
+			Context: ctx,
+			machine: &v1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "worker-us-east1-b-abcde",
+				},
+			},
+			coreClient: controllerfake.NewFakeClient(),
+			projectID:  "my-project",
+			providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+				Zone:        "us-east1-b",
+				MachineType: "n1-standard-4",
+				Disks: []*gcpv1beta1.GCPDisk{
+					{
+						Boot:  true,
+						Image: "rhcos",
+					},
+				},
+			},
+			providerStatus: providerStatus,
+			computeService: mockComputeService,
+		}
+	}
+
+	// Shut down while waiting for the insert operation.
+	mockComputeService.HangOn("ZoneOperationsGet")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := newReconciler(newScope(ctx)).create(); err == nil || !strings.Contains(err.Error(), "stopped waiting") {
+		t.Fatalf("expected an error about the interrupted wait, got: %v", err)
+	}
+	pending := providerStatus.PendingOperation
+	if pending == nil || pending.Type != operationTypeInsert || pending.Zone != "us-east1-b" || pending.Name == "" {
+		t.Fatalf("expected the insert operation to be pending, got %+v", pending)
+	}
+
+	// The next reconcile waits for the pending operation instead of inserting the instance again,
+	// which would fail since it already exists.
+	mockComputeService.ClearFailures()
+	if err := newReconciler(newScope(context.Background())).create(); err != nil {
+		t.Fatalf("expected the pending operation to be resumed, got: %v", err)
+	}
+	if providerStatus.PendingOperation != nil {
+		t.Errorf("expected the pending operation to be cleared, got %+v", providerStatus.PendingOperation)
+	}
+
+	// Operations which are not found anymore are forgotten.
+	providerStatus.PendingOperation = &gcpv1beta1.GCPOperation{Name: "gone", Zone: "us-east1-b", Type: operationTypeDelete}
+	mockComputeService.FailOn("ZoneOperationsGet", computeservice.APIError(http.StatusNotFound, "notFound", "operation not found"))
+	if err := newReconciler(newScope(context.Background())).update(); err != nil {
+		t.Errorf("expected a missing pending operation to be ignored, got: %v", err)
+	}
+	if providerStatus.PendingOperation != nil {
+		t.Errorf("expected the pending operation to be cleared, got %+v", providerStatus.PendingOperation)
+	}
+}
+
+func TestPendingOperationOnDeadline(t *testing.T) {
+	defer func(timeout time.Duration) { operationTimeOut = timeout }(operationTimeOut)
+	operationTimeOut = 10 * time.Millisecond
+
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	providerStatus := &gcpv1beta1.GCPMachineProviderStatus{}
+	r := newReconciler(&machineScope{
+		Context: context.Background(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-us-east1-b-abcde",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+		},
+		providerStatus: providerStatus,
+		computeService: mockComputeService,
+	})
+
+	// Getting the operation blocks until the operation deadline is exceeded.
+	mockComputeService.HangOn("ZoneOperationsGet")
+	if err := r.create(); err == nil || !strings.Contains(err.Error(), "timed out waiting") {
+		t.Fatalf("expected an error about the timed out wait, got: %v", err)
+	}
+	pending := providerStatus.PendingOperation
+	if pending == nil || pending.Type != operationTypeInsert || pending.Zone != "us-east1-b" || pending.Name == "" {
+		t.Fatalf("expected the insert operation to be pending, got %+v", pending)
+	}
+}
+
+func TestTargetPools(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "master-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			TargetPools: []string{"api", "api-internal"},
+		},
+		computeService: mockComputeService,
+	})
+	instanceURL := "projects/my-project/zones/us-east1-b/instances/master-0"
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	for _, pool := range []string{"api", "api-internal"} {
+		if members := mockComputeService.TargetPoolInstances("my-project", "us-east1", pool); !reflect.DeepEqual(members, []string{instanceURL}) {
+			t.Errorf("expected instance to be a member of target pool %q, got %v", pool, members)
+		}
+	}
+
+	// Updates do not register the instance twice.
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if members := mockComputeService.TargetPoolInstances("my-project", "us-east1", "api"); len(members) != 1 {
+		t.Errorf("expected instance to be registered once, got %v", members)
+	}
+	if expected := []string{"api", "api-internal"}; !reflect.DeepEqual(reconciler.providerStatus.TargetPools, expected) {
+		t.Errorf("expected target pools %v in provider status, got %v", expected, reconciler.providerStatus.TargetPools)
+	}
+
+	// The instance is removed from the target pools it was added to, even once removed from the provider spec.
+	reconciler.providerSpec.TargetPools = []string{"api"}
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	for _, pool := range []string{"api", "api-internal"} {
+		if members := mockComputeService.TargetPoolInstances("my-project", "us-east1", pool); len(members) != 0 {
+			t.Errorf("expected instance to be removed from target pool %q, got %v", pool, members)
+		}
+	}
+	if len(reconciler.providerStatus.TargetPools) != 0 {
+		t.Errorf("expected no target pools left in provider status, got %v", reconciler.providerStatus.TargetPools)
+	}
+}
+
+func TestInstanceGroups(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "master-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			NetworkInterfaces: []*gcpv1beta1.GCPNetworkInterface{
+				{
+					Network: "cluster-network",
+				},
+			},
+			TargetInstanceGroups: []string{"master-us-east1-b", "cluster-master-{zone}"},
+		},
+		computeService: mockComputeService,
+	})
+	mockComputeService.AddInstanceGroup("my-project", "us-east1-b", "master-us-east1-b")
+	instanceURL := "projects/my-project/zones/us-east1-b/instances/master-0"
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	for _, instanceGroup := range []string{"master-us-east1-b", "cluster-master-us-east1-b"} {
+		if members := mockComputeService.InstanceGroupInstances("my-project", "us-east1-b", instanceGroup); !reflect.DeepEqual(members, []string{instanceURL}) {
+			t.Errorf("expected instance to be a member of instance group %q, got %v", instanceGroup, members)
+		}
+	}
+	// The instance group missing in the machine zone is created in the network of the instance.
+	created := mockComputeService.InstanceGroup("my-project", "us-east1-b", "cluster-master-us-east1-b")
+	if created == nil {
+		t.Fatalf("expected instance group to be created")
+	}
+	if expected := "projects/my-project/global/networks/cluster-network"; created.Network != expected {
+		t.Errorf("expected created instance group network %q, got %q", expected, created.Network)
+	}
+
+	// Updates do not register the instance twice.
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if members := mockComputeService.InstanceGroupInstances("my-project", "us-east1-b", "master-us-east1-b"); len(members) != 1 {
+		t.Errorf("expected instance to be registered once, got %v", members)
+	}
+
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	for _, instanceGroup := range []string{"master-us-east1-b", "cluster-master-us-east1-b"} {
+		if members := mockComputeService.InstanceGroupInstances("my-project", "us-east1-b", instanceGroup); len(members) != 0 {
+			t.Errorf("expected instance to be removed from instance group %q, got %v", instanceGroup, members)
+		}
+	}
+}
+
+func TestFirewallRules(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	featureGates := features.NewFeatureGate()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "master-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			Tags: []string{"cluster-master"},
+			FirewallRules: []*gcpv1beta1.GCPFirewallRule{
+				{
+					Name:         "cluster-api",
+					Ports:        []string{"6443"},
+					SourceRanges: []string{"0.0.0.0/0"},
+				},
+			},
+		},
+		computeService: mockComputeService,
+		featureGates:   featureGates,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if firewall := mockComputeService.Firewall("my-project", "cluster-api"); firewall != nil {
+		t.Errorf("expected no firewall rule to be created with the feature gate disabled, got %+v", firewall)
+	}
+
+	if err := featureGates.Set("FirewallRules=true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	firewall := mockComputeService.Firewall("my-project", "cluster-api")
+	if firewall == nil {
+		t.Fatalf("expected firewall rule to be created")
+	}
+	if expected := "projects/my-project/global/networks/default"; firewall.Network != expected {
+		t.Errorf("expected firewall rule network %q, got %q", expected, firewall.Network)
+	}
+	if !reflect.DeepEqual(firewall.TargetTags, []string{"cluster-master"}) {
+		t.Errorf("expected firewall rule to target the machine tags, got %v", firewall.TargetTags)
+	}
+	if len(firewall.Allowed) != 1 || firewall.Allowed[0].IPProtocol != "tcp" || !reflect.DeepEqual(firewall.Allowed[0].Ports, []string{"6443"}) {
+		t.Errorf("expected firewall rule to allow tcp:6443, got %+v", firewall.Allowed)
+	}
+
+	// The rule follows the tags of the machine.
+	reconciler.providerSpec.Tags = []string{"cluster-control-plane"}
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if firewall := mockComputeService.Firewall("my-project", "cluster-api"); !reflect.DeepEqual(firewall.TargetTags, []string{"cluster-control-plane"}) {
+		t.Errorf("expected firewall rule to be updated to the machine tags, got %v", firewall.TargetTags)
+	}
+}
+
+func TestSharedVPCFirewallRules(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	featureGates := features.NewFeatureGate()
+	if err := featureGates.Set("FirewallRules=true"); err != nil {
+		t.Fatal(err)
+	}
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "master-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:             "us-east1-b",
+			MachineType:      "n1-standard-4",
+			NetworkProjectID: "host-project",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			Tags: []string{"cluster-master"},
+			FirewallRules: []*gcpv1beta1.GCPFirewallRule{
+				{
+					Name:         "cluster-api",
+					Ports:        []string{"6443"},
+					SourceRanges: []string{"0.0.0.0/0"},
+				},
+			},
+		},
+		computeService: mockComputeService,
+		featureGates:   featureGates,
+	})
+
+	// The operations of the firewall rules are polled in the host project they are started in.
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	firewall := mockComputeService.Firewall("host-project", "cluster-api")
+	if firewall == nil {
+		t.Fatalf("expected firewall rule to be created in the host project")
+	}
+	if expected := "projects/host-project/global/networks/default"; firewall.Network != expected {
+		t.Errorf("expected firewall rule network %q, got %q", expected, firewall.Network)
+	}
+	if firewall := mockComputeService.Firewall("my-project", "cluster-api"); firewall != nil {
+		t.Errorf("expected no firewall rule in the service project, got %+v", firewall)
+	}
+
+	reconciler.providerSpec.Tags = []string{"cluster-control-plane"}
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if firewall := mockComputeService.Firewall("host-project", "cluster-api"); !reflect.DeepEqual(firewall.TargetTags, []string{"cluster-control-plane"}) {
+		t.Errorf("expected firewall rule to be updated to the machine tags, got %v", firewall.TargetTags)
+	}
+}
+
+func TestUpdateReadyCondition(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "master-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			TargetInstanceGroups: []string{"master-{zone}"},
+			HealthCheck: &gcpv1beta1.GCPHealthCheck{
+				BackendService: "api-internal",
+				InstanceGroup:  "master-{zone}",
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	testCases := []struct {
+		name           string
+		healthState    string
+		expectedStatus corev1.ConditionStatus
+	}{
+		{
+			name:           "healthy instance",
+			healthState:    "HEALTHY",
+			expectedStatus: corev1.ConditionTrue,
+		},
+		{
+			name:           "unhealthy instance",
+			healthState:    "UNHEALTHY",
+			expectedStatus: corev1.ConditionFalse,
+		},
+	}
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockComputeService.SetInstanceHealth("projects/my-project/zones/us-east1-b/instances/master-0", tc.healthState)
+			if err := reconciler.update(); err != nil {
+				t.Fatalf("reconciler was not expected to return error: %v", err)
+			}
+			conditions := reconciler.providerStatus.Conditions
+			if len(conditions) != 1 || conditions[0].Type != gcpv1beta1.MachineReady {
+				t.Fatalf("expected a single Ready condition, got %+v", conditions)
+			}
+			if conditions[0].Status != tc.expectedStatus {
+				t.Errorf("expected Ready condition status %q, got %q", tc.expectedStatus, conditions[0].Status)
+			}
+		})
+	}
+}
+
+func TestPreemptible(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	machine := &v1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "worker-us-east1-b-abcde",
+			Namespace: "openshift-machine-api",
+		},
+		Spec: v1beta1.MachineSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"spot": "user-value"},
+			},
+		},
+	}
+	featureGates := features.NewFeatureGate()
+	reconciler := newReconciler(&machineScope{
+		Context:       context.TODO(),
+		machine:       machine,
+		machineClient: machinefake.NewSimpleClientset(machine).MachineV1beta1().Machines(machine.Namespace),
+		coreClient:    controllerfake.NewFakeClient(),
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			Preemptible: true,
+		},
+		computeService: mockComputeService,
+		featureGates:   featureGates,
+		preemptibleNode: PreemptibleNodeConfig{
+			Labels: map[string]string{
+				"machine.openshift.io/interruptible-instance": "",
+				"spot": "true",
+			},
+			Taints: []corev1.Taint{{Key: "preemptible", Value: "true", Effect: corev1.TaintEffectNoSchedule}},
+		},
+	})
+
+	if err := reconciler.create(); err == nil || !strings.Contains(err.Error(), "SpotVMs") {
+		t.Fatalf("expected create to fail with the SpotVMs feature gate disabled, got %v", err)
+	}
+
+	if err := featureGates.Set("SpotVMs=true"); err != nil {
+		t.Fatal(err)
+	}
 	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	scheduling := receivedInstance.Scheduling
+	if scheduling == nil || !scheduling.Preemptible || scheduling.AutomaticRestart == nil || *scheduling.AutomaticRestart || scheduling.OnHostMaintenance != "TERMINATE" {
+		t.Errorf("expected preemptible scheduling without automatic restart, got %+v", scheduling)
+	}
+
+	expectedLabels := map[string]string{
+		"machine.openshift.io/interruptible-instance": "",
+		"spot": "user-value",
+	}
+	updated, err := reconciler.machineClient.Get(machine.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(updated.Spec.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, updated.Spec.Labels)
+	}
+	if len(updated.Spec.Taints) != 1 || updated.Spec.Taints[0].Key != "preemptible" {
+		t.Errorf("expected the preemptible taint, got %v", updated.Spec.Taints)
+	}
+
+	// The labels and taints are only added once.
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if len(machine.Spec.Taints) != 1 {
+		t.Errorf("expected a single taint after update, got %v", machine.Spec.Taints)
+	}
+
+	// GCP stops preempted instances.
+	instance := instanceName(machine.Name)
+	if _, err := mockComputeService.InstancesStop(context.TODO(), "", "us-east1-b", instance); err != nil {
+		t.Fatal(err)
+	}
+	err = reconciler.update()
+	if machineErr, ok := err.(*machineapierrors.MachineError); !ok || machineErr.Reason != machinecommon.UpdateMachineError {
+		t.Fatalf("expected update to fail the machine, got %v", err)
+	}
+	if status := mockComputeService.InstanceStatus("", "us-east1-b", instance); status != "" {
+		t.Errorf("expected the preempted instance to be deleted, got status %q", status)
+	}
+	if !hasCondition(reconciler.providerStatus, gcpv1beta1.MachinePreempted) {
+		t.Errorf("expected the Preempted condition, got %+v", reconciler.providerStatus.Conditions)
+	}
+	if err := reconciler.create(); err == nil {
+		t.Errorf("expected create to refuse recreating the instance of a preempted machine")
+	}
+}
+
+func TestRepairInstance(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-us-east1-b-abcde",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	instance := instanceName(reconciler.machine.Name)
+	if _, err := mockComputeService.InstancesStop(context.TODO(), "", "us-east1-b", instance); err != nil {
+		t.Fatal(err)
+	}
+
+	// Terminated instances are left alone without auto-repair.
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if status := mockComputeService.InstanceStatus("", "us-east1-b", instance); status != "TERMINATED" {
+		t.Errorf("expected instance to stay terminated, got status %q", status)
+	}
+
+	reconciler.providerSpec.AutoRepair = true
+	*receivedInstance = compute.Instance{}
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if receivedInstance.Name != instance {
+		t.Errorf("expected instance %q to be recreated, got %q", instance, receivedInstance.Name)
+	}
+	if status := mockComputeService.InstanceStatus("", "us-east1-b", instance); status == "TERMINATED" || status == "" {
+		t.Errorf("expected recreated instance to be running, got status %q", status)
+	}
+}
+
+func TestRetainedDisks(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-us-east1-b-abcde",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					AutoDelete: true,
+					Boot:       true,
+					Image:      "rhcos",
+				},
+				{
+					SizeGb: 100,
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if name := receivedInstance.Disks[0].InitializeParams.DiskName; name != "" {
+		t.Errorf("expected the auto-deleted boot disk to keep the default name, got %q", name)
+	}
+	if name := receivedInstance.Disks[1].InitializeParams.DiskName; name != "worker-us-east1-b-abcde-disk-1" {
+		t.Errorf("expected the retained disk to be named after the instance, got %q", name)
+	}
+	if expected := []string{"worker-us-east1-b-abcde-disk-1"}; !reflect.DeepEqual(reconciler.providerStatus.Disks, expected) {
+		t.Errorf("expected disks %v in provider status, got %v", expected, reconciler.providerStatus.Disks)
+	}
+	if !mockComputeService.HasDisk("my-project", "us-east1-b", "worker-us-east1-b-abcde-disk-1") {
+		t.Fatalf("expected the retained disk to be created")
+	}
+
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if mockComputeService.HasDisk("my-project", "us-east1-b", "worker-us-east1-b-abcde-disk-1") {
+		t.Errorf("expected the retained disk to be deleted with the machine")
+	}
+	if len(reconciler.providerStatus.Disks) != 0 {
+		t.Errorf("expected no disks left in provider status, got %v", reconciler.providerStatus.Disks)
+	}
+}
+
+func TestStaticAddresses(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-us-east1-b-abcde",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			NetworkInterfaces: []*gcpv1beta1.GCPNetworkInterface{
+				{
+					Network:    "default",
+					Subnetwork: "workers",
+					StaticIP:   true,
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	internal := mockComputeService.Address("my-project", "us-east1", "worker-us-east1-b-abcde-nic0")
+	external := mockComputeService.Address("my-project", "us-east1", "worker-us-east1-b-abcde-nic0-external")
+	if internal == nil || external == nil {
+		t.Fatalf("expected internal and external addresses to be reserved, got %v and %v", internal, external)
+	}
+	nic := receivedInstance.NetworkInterfaces[0]
+	if nic.NetworkIP != internal.Address {
+		t.Errorf("expected network IP %q, got %q", internal.Address, nic.NetworkIP)
+	}
+	if nic.AccessConfigs[0].NatIP != external.Address {
+		t.Errorf("expected NAT IP %q, got %q", external.Address, nic.AccessConfigs[0].NatIP)
+	}
+	expected := []string{"worker-us-east1-b-abcde-nic0", "worker-us-east1-b-abcde-nic0-external"}
+	if !reflect.DeepEqual(reconciler.providerStatus.Addresses, expected) {
+		t.Errorf("expected addresses %v in provider status, got %v", expected, reconciler.providerStatus.Addresses)
+	}
+
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if mockComputeService.Address("my-project", "us-east1", "worker-us-east1-b-abcde-nic0") != nil ||
+		mockComputeService.Address("my-project", "us-east1", "worker-us-east1-b-abcde-nic0-external") != nil {
+		t.Errorf("expected addresses to be released with the machine")
+	}
+	if len(reconciler.providerStatus.Addresses) != 0 {
+		t.Errorf("expected no addresses left in provider status, got %v", reconciler.providerStatus.Addresses)
+	}
+}
+
+func TestForceDelete(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "master-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:               "us-east1-b",
+			MachineType:        "n1-standard-4",
+			DeletionProtection: true,
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+
+	if err := reconciler.delete(); err == nil || !strings.Contains(err.Error(), forceDeleteAnnotation) {
+		t.Errorf("expected deleting a protected instance to fail mentioning %s, got %v", forceDeleteAnnotation, err)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "master-0"); status == "" {
+		t.Fatalf("expected protected instance not to be deleted")
+	}
+
+	reconciler.machine.Annotations = map[string]string{forceDeleteAnnotation: "true"}
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "master-0"); status != "" {
+		t.Errorf("expected instance to be force deleted, got status %q", status)
+	}
+}
+
+func TestDeleteWaitsForPendingInsert(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+		},
+		computeService: mockComputeService,
+	})
+	// The machine is deleted while the insert operation of its instance is still running.
+	operation := mockComputeService.AddPendingInsert("my-project", "us-east1-b", &compute.Instance{Name: "worker-0"})
+
+	if err := reconciler.delete(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	// Had delete not waited for it, the instance would appear when the operation completes.
+	if _, err := mockComputeService.ZoneOperationsGet(context.TODO(), "my-project", "us-east1-b", operation.Name); err != nil {
+		t.Fatal(err)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-b", "worker-0"); status != "" {
+		t.Errorf("expected the instance inserted during deletion to be deleted, got status %q", status)
+	}
+}
+
+func TestCreateUserData(t *testing.T) {
+	largeConfig := `{"ignition":{"version":"3.1.0","security":{"tls":{"certificateAuthorities":[{"source":"data:,ca"}]}}},"storage":{"files":[{"path":"/etc/large","contents":{"source":"data:,` +
+		strings.Repeat("a", maxMetadataValueSize) + `"}}]}}`
+	userDataSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-user-data", Namespace: "openshift-machine-api"},
+		Data: map[string][]byte{
+			"userData":   []byte("{}"),
+			"startup.sh": []byte("#!/bin/bash"),
+			"cloud-init": []byte("#cloud-config"),
+			"windows":    []byte("Write-Host"),
+			"large.ign":  []byte(largeConfig),
+		},
+	}
+	testCases := []struct {
+		name          string
+		key           string
+		format        gcpv1beta1.GCPUserDataFormat
+		raw           bool
+		osFamily      gcpv1beta1.GCPOSFamily
+		source        string
+		expectedKey   string
+		expectedValue string
+		expectedError bool
+	}{
+		{
+			name:          "default key and format",
+			expectedKey:   "user-data",
+			expectedValue: "e30=",
+		},
+		{
+			name:          "cloud-init",
+			key:           "cloud-init",
+			format:        gcpv1beta1.UserDataFormatCloudInit,
+			expectedKey:   "user-data",
+			expectedValue: "I2Nsb3VkLWNvbmZpZw==",
+		},
+		{
+			name:          "raw cloud-init",
+			key:           "cloud-init",
+			format:        gcpv1beta1.UserDataFormatCloudInit,
+			raw:           true,
+			expectedKey:   "user-data",
+			expectedValue: "#cloud-config",
+		},
+		{
+			name:          "startup script",
+			key:           "startup.sh",
+			format:        gcpv1beta1.UserDataFormatStartupScript,
+			expectedKey:   "startup-script",
+			expectedValue: "#!/bin/bash",
+		},
+		{
+			name:          "windows startup script",
+			key:           "windows",
+			osFamily:      gcpv1beta1.OSFamilyWindows,
+			expectedKey:   "windows-startup-script-ps1",
+			expectedValue: "Write-Host",
+		},
+		{
+			name:          "windows sysprep specialize script",
+			key:           "windows",
+			osFamily:      gcpv1beta1.OSFamilyWindows,
+			format:        gcpv1beta1.UserDataFormatSysprepSpecializeScript,
+			expectedKey:   "sysprep-specialize-script-ps1",
+			expectedValue: "Write-Host",
+		},
+		{
+			name:          "large ignition config",
+			key:           "large.ign",
+			source:        "https://api-int.example.com:22623/config/worker",
+			expectedKey:   "user-data",
+			expectedValue: base64.StdEncoding.EncodeToString([]byte(`{"ignition":{"config":{"replace":{"source":"https://api-int.example.com:22623/config/worker"}},"security":{"tls":{"certificateAuthorities":[{"source":"data:,ca"}]}},"version":"3.1.0"}}`)),
+		},
+		{
+			name:          "large ignition config without source",
+			key:           "large.ign",
+			expectedError: true,
+		},
+		{
+			name:          "missing key",
+			key:           "ignition",
+			expectedError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+			machineScope := machineScope{
+				Context: context.TODO(),
+				machine: &v1beta1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "worker-us-east1-b-abcde",
+						Namespace: "openshift-machine-api",
+					},
+				},
+				coreClient: controllerfake.NewFakeClient(userDataSecret),
+				providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+					Zone:                 "us-east1-b",
+					MachineType:          "n1-standard-4",
+					Disks:                []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+					UserDataSecret:       &corev1.LocalObjectReference{Name: "worker-user-data"},
+					UserDataSecretKey:    tc.key,
+					UserDataFormat:       tc.format,
+					RawUserData:          tc.raw,
+					OSFamily:             tc.osFamily,
+					IgnitionConfigSource: tc.source,
+				},
+				computeService: mockComputeService,
+			}
+			reconciler := newReconciler(&machineScope)
+			err := reconciler.create()
+			if tc.expectedError {
+				if err == nil {
+					t.Fatal("reconciler was expected to return error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reconciler was not expected to return error: %v", err)
+			}
+			if len(receivedInstance.Metadata.Items) != 1 {
+				t.Fatalf("expected one metadata item, got %d", len(receivedInstance.Metadata.Items))
+			}
+			item := receivedInstance.Metadata.Items[0]
+			if item.Key != tc.expectedKey || *item.Value != tc.expectedValue {
+				t.Errorf("expected metadata %s=%q, got %s=%q", tc.expectedKey, tc.expectedValue, item.Key, *item.Value)
+			}
+		})
+	}
+}
+
+func TestSharedVPC(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-us-east1-b-abcde",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "service-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			NetworkProjectID: "host-project",
+			Region:           "us-east1",
+			Zone:             "us-east1-b",
+			MachineType:      "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			NetworkInterfaces: []*gcpv1beta1.GCPNetworkInterface{
+				{
+					Network:    "shared",
+					Subnetwork: "workers",
+					StaticIP:   true,
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	nic := receivedInstance.NetworkInterfaces[0]
+	if expected := "projects/host-project/global/networks/shared"; nic.Network != expected {
+		t.Errorf("expected network %q, got %q", expected, nic.Network)
+	}
+	if expected := "projects/host-project/regions/us-east1/subnetworks/workers"; nic.Subnetwork != expected {
+		t.Errorf("expected subnetwork %q, got %q", expected, nic.Subnetwork)
+	}
+	// The address is reserved in the project of the instance, in the subnetwork of the host project.
+	address := mockComputeService.Address("service-project", "us-east1", "worker-us-east1-b-abcde-nic0")
+	if address == nil {
+		t.Fatalf("expected the internal address to be reserved in the project of the instance")
+	}
+	if expected := "projects/host-project/regions/us-east1/subnetworks/workers"; address.Subnetwork != expected {
+		t.Errorf("expected address subnetwork %q, got %q", expected, address.Subnetwork)
+	}
+}
+
+func TestResourceManagerTags(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			ResourceManagerTags: map[string]string{"tagKeys/123": "tagValues/456"},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	params := mockComputeService.InstanceParams("my-project", "us-east1-b", "worker-0")
+	if params == nil || !reflect.DeepEqual(params.ResourceManagerTags, map[string]string{"tagKeys/123": "tagValues/456"}) {
+		t.Errorf("expected the instance to be inserted with the resource manager tags, got %+v", params)
+	}
+}
+
+func TestPerformanceMonitoringUnit(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "c3-standard-8",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			AdvancedMachineFeatures: &gcpv1beta1.GCPAdvancedMachineFeatures{
+				PerformanceMonitoringUnit: gcpv1beta1.PerformanceMonitoringUnitEnhanced,
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	params := mockComputeService.InstanceParams("my-project", "us-east1-b", "worker-0")
+	if params == nil || params.AdvancedMachineFeatures == nil || params.AdvancedMachineFeatures.PerformanceMonitoringUnit != "ENHANCED" {
+		t.Errorf("expected the instance to be inserted with the ENHANCED performance monitoring unit, got %+v", params)
+	}
+}
+
+func TestConfidentialInstanceType(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n2d-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+			},
+			ConfidentialInstanceType: gcpv1beta1.ConfidentialInstanceTypeSEVSNP,
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	params := mockComputeService.InstanceParams("my-project", "us-east1-b", "worker-0")
+	if params == nil || params.ConfidentialInstanceConfig == nil || params.ConfidentialInstanceConfig.ConfidentialInstanceType != "SEV_SNP" {
+		t.Errorf("expected the instance to be inserted as a SEV_SNP Confidential VM, got %+v", params)
+	}
+	if receivedInstance.Scheduling == nil || receivedInstance.Scheduling.OnHostMaintenance != "TERMINATE" {
+		t.Errorf("expected the Confidential VM to be terminated on host maintenance, got %+v", receivedInstance.Scheduling)
+	}
+}
+
+func TestLocalSSDs(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n2-standard-8",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:  true,
+					Image: "rhcos",
+				},
+				{
+					AutoDelete: true,
+					Type:       gcpv1beta1.LocalSSDDiskType,
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if len(receivedInstance.Disks) != 2 {
+		t.Fatalf("expected 2 disks, got %d", len(receivedInstance.Disks))
+	}
+	disk := receivedInstance.Disks[1]
+	if disk.Type != "SCRATCH" || disk.Interface != "NVME" || disk.InitializeParams.DiskType != "zones/us-east1-b/diskTypes/local-ssd" {
+		t.Errorf("expected an NVMe local SSD scratch disk, got %+v", disk)
+	}
+	if disk.InitializeParams.SourceImage != "" || disk.InitializeParams.DiskName != "" {
+		t.Errorf("expected the local SSD to have neither image nor name, got %+v", disk.InitializeParams)
+	}
+}
+
+func TestMultiWriterDisks(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					AutoDelete: true,
+					Boot:       true,
+					Image:      "rhcos",
+				},
+				{
+					SizeGb:      100,
+					Type:        "pd-ssd",
+					MultiWriter: true,
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if !mockComputeService.IsMultiWriterDisk("my-project", "us-east1-b", "worker-0-disk-1") {
+		t.Errorf("expected disk worker-0-disk-1 to be created in multi-writer mode")
+	}
+	if len(receivedInstance.Disks) != 2 || receivedInstance.Disks[1].Source != "projects/my-project/zones/us-east1-b/disks/worker-0-disk-1" {
+		t.Errorf("expected the multi-writer disk to be attached to the instance, got %+v", receivedInstance.Disks)
+	}
+	if !reflect.DeepEqual(reconciler.providerStatus.Disks, []string{"worker-0-disk-1"}) {
+		t.Errorf("expected the multi-writer disk to be recorded for deletion, got %v", reconciler.providerStatus.Disks)
+	}
+
+	// Disks inserted by a previous attempt are reused.
+	if err := reconciler.insertMultiWriterDisks("us-east1-b", []*compute.Disk{{Name: "worker-0-disk-1"}}); err != nil {
+		t.Errorf("expected the existing multi-writer disk to be reused, got %v", err)
+	}
+}
+
+func TestBootFromExistingDisk(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					Boot:   true,
+					Source: "worker-0-restored",
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if len(receivedInstance.Disks) != 1 {
+		t.Fatalf("expected 1 disk, got %d", len(receivedInstance.Disks))
+	}
+	disk := receivedInstance.Disks[0]
+	if !disk.Boot || disk.InitializeParams != nil || disk.Source != "projects/my-project/zones/us-east1-b/disks/worker-0-restored" {
+		t.Errorf("expected the instance to boot from the existing disk, got %+v", disk)
+	}
+	if len(reconciler.providerStatus.Disks) != 0 {
+		t.Errorf("expected the existing disk not to be recorded for deletion, got %v", reconciler.providerStatus.Disks)
+	}
+}
+
+// instanceGetCounter counts the instance gets of the compute service.
+type instanceGetCounter struct {
+	*computeservice.GCPComputeServiceMock
+	gets int
+}
+
+func (c *instanceGetCounter) InstancesGet(ctx context.Context, project string, zone string, instance string) (*compute.Instance, error) {
+	c.gets++
+	return c.GCPComputeServiceMock.InstancesGet(ctx, project, zone, instance)
+}
+
+func TestDeleteInstanceGets(t *testing.T) {
+	testCases := []struct {
+		name              string
+		clusterOwnedLabel bool
+		existingDisk      bool
+		expectedGets      int
+	}{
+		{
+			name: "no ownership check nor existing disks",
+		},
+		{
+			name:         "existing disks",
+			existingDisk: true,
+			expectedGets: 1,
+		},
+		{
+			name:              "ownership check and existing disks",
+			clusterOwnedLabel: true,
+			existingDisk:      true,
+			expectedGets:      1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, mockComputeService := computeservice.NewComputeServiceMock()
+			counter := &instanceGetCounter{GCPComputeServiceMock: mockComputeService}
+			disks := []*gcpv1beta1.GCPDisk{
+				{
+					AutoDelete: true,
+					Boot:       true,
+					Image:      "rhcos",
+				},
+			}
+			if tc.existingDisk {
+				disks = append(disks, &gcpv1beta1.GCPDisk{Source: "shared-data"})
+			}
+			reconciler := newReconciler(&machineScope{
+				Context: context.TODO(),
+				machine: &v1beta1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   "worker-0",
+						Labels: map[string]string{v1beta1.MachineClusterIDLabel: "cluster-a"},
+					},
+				},
+				coreClient: controllerfake.NewFakeClient(),
+				projectID:  "my-project",
+				providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+					Zone:        "us-east1-b",
+					MachineType: "n1-standard-4",
+					Disks:       disks,
+				},
+				computeService:    counter,
+				clusterOwnedLabel: tc.clusterOwnedLabel,
+			})
+			if err := reconciler.create(); err != nil {
+				t.Fatalf("reconciler was not expected to return error: %v", err)
+			}
+
+			counter.gets = 0
+			if err := reconciler.delete(); err != nil {
+				t.Fatalf("reconciler was not expected to return error: %v", err)
+			}
+			if counter.gets != tc.expectedGets {
+				t.Errorf("expected %d instance gets on delete, got %d", tc.expectedGets, counter.gets)
+			}
+		})
+	}
+}
+
+func TestExistingDataDisks(t *testing.T) {
+	receivedInstance, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "worker-0",
+			},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					AutoDelete: true,
+					Boot:       true,
+					Image:      "rhcos",
+				},
+				{
+					Source: "shared-data",
+					Mode:   gcpv1beta1.DiskModeReadOnly,
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if len(receivedInstance.Disks) != 2 {
+		t.Fatalf("expected 2 disks, got %d", len(receivedInstance.Disks))
+	}
+	disk := receivedInstance.Disks[1]
+	if disk.Mode != "READ_ONLY" || disk.DeviceName != "shared-data" || disk.Source != "projects/my-project/zones/us-east1-b/disks/shared-data" {
+		t.Errorf("expected the existing disk to be attached read-only, got %+v", disk)
+	}
+	if len(reconciler.providerStatus.Disks) != 0 {
+		t.Errorf("expected the existing disk not to be recorded for deletion, got %v", reconciler.providerStatus.Disks)
+	}
+
+	if !reflect.DeepEqual(reconciler.providerStatus.AttachedDisks, []string{"shared-data"}) {
+		t.Errorf("expected the existing disk to be recorded as attached, got %v", reconciler.providerStatus.AttachedDisks)
+	}
+
+	// Changes of the existing disks of the provider spec are applied to the instance, the disks attached by
+	// others are left alone.
+	if _, err := mockComputeService.InstancesAttachDisk(context.TODO(), "my-project", "us-east1-b", "worker-0", &compute.AttachedDisk{
+		DeviceName: "pvc-123",
+		Source:     "projects/my-project/zones/us-east1-b/disks/pvc-123",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	reconciler.providerSpec.Disks[1] = &gcpv1beta1.GCPDisk{Source: "extra-data"}
+	if err := reconciler.ensureExistingDisks(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	instance, err := mockComputeService.InstancesGet(context.TODO(), "my-project", "us-east1-b", "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attached []string
+	for _, disk := range instance.Disks {
+		attached = append(attached, disk.DeviceName)
+	}
+	if expected := []string{"", "pvc-123", "extra-data"}; !reflect.DeepEqual(attached, expected) {
+		t.Errorf("expected disks %v to be attached, got %v", expected, attached)
+	}
+	if !reflect.DeepEqual(reconciler.providerStatus.AttachedDisks, []string{"extra-data"}) {
+		t.Errorf("expected the attached disks to be recorded, got %v", reconciler.providerStatus.AttachedDisks)
+	}
+
+	// The existing disks are detached before the instance is deleted.
+	mockComputeService.FailOn("DisksDelete", errors.New("existing disks must not be deleted"))
+	if err := reconciler.detachExistingDisks(nil); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	instance, err = mockComputeService.InstancesGet(context.TODO(), "my-project", "us-east1-b", "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instance.Disks) != 2 || !instance.Disks[0].Boot || instance.Disks[1].DeviceName != "pvc-123" {
+		t.Errorf("expected only the boot disk and the disks attached by others to remain attached, got %+v", instance.Disks)
+	}
+	if err := reconciler.delete(); err != nil {
 		t.Errorf("reconciler was not expected to return error: %v", err)
 	}
 }
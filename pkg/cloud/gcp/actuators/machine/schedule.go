@@ -0,0 +1,47 @@
+package machine
+
+import (
+	"fmt"
+)
+
+// resourcePolicyURL returns the partial URL of the resource policy in the region of the machine.
+func (r *Reconciler) resourcePolicyURL(name string) string {
+	return fmt.Sprintf("projects/%s/regions/%s/resourcePolicies/%s", r.projectID, r.region(), name)
+}
+
+// ensureInstanceSchedulePolicy attaches the instance schedule policy of the provider spec to the instance, in
+// place of the policy recorded in the provider status when the spec names another one.
+func (r *Reconciler) ensureInstanceSchedulePolicy() error {
+	desired := r.providerSpec.InstanceSchedulePolicy
+	attached := r.providerStatus.InstanceSchedulePolicy
+	if desired == attached {
+		return nil
+	}
+	zone := r.providerSpec.Zone
+	name := r.instanceName()
+	// An instance has at most one instance schedule policy, detach the previous one first.
+	if attached != "" {
+		operation, err := r.computeService.InstancesRemoveResourcePolicies(r.Context, r.projectID, zone, name, []string{r.resourcePolicyURL(attached)})
+		if err != nil {
+			return fmt.Errorf("error detaching instance schedule policy %q from instance %q: %v", attached, name, err)
+		}
+		r.logger.Info("Detaching instance schedule policy", "resourcePolicy", attached, "gcpOperation", operation.Name)
+		if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+			return fmt.Errorf("error detaching instance schedule policy %q from instance %q: %v", attached, name, err)
+		}
+		r.providerStatus.InstanceSchedulePolicy = ""
+	}
+	if desired == "" {
+		return nil
+	}
+	operation, err := r.computeService.InstancesAddResourcePolicies(r.Context, r.projectID, zone, name, []string{r.resourcePolicyURL(desired)})
+	if err != nil {
+		return fmt.Errorf("error attaching instance schedule policy %q to instance %q: %v", desired, name, err)
+	}
+	r.logger.Info("Attaching instance schedule policy", "resourcePolicy", desired, "gcpOperation", operation.Name)
+	if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+		return fmt.Errorf("error attaching instance schedule policy %q to instance %q: %v", desired, name, err)
+	}
+	r.providerStatus.InstanceSchedulePolicy = desired
+	return nil
+}
@@ -0,0 +1,64 @@
+package machine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInstanceSchedulePolicy(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context: context.TODO(),
+		machine: &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-us-east1-b-abcde"},
+		},
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:                   "us-east1-b",
+			MachineType:            "n1-standard-4",
+			Disks:                  []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			InstanceSchedulePolicy: "business-hours",
+		},
+		computeService: mockComputeService,
+	})
+	expectPolicies := func(expected ...string) {
+		t.Helper()
+		policies := mockComputeService.InstanceResourcePolicies("my-project", "us-east1-b", "worker-us-east1-b-abcde")
+		if (len(policies) > 0 || len(expected) > 0) && !reflect.DeepEqual(policies, expected) {
+			t.Errorf("expected resource policies %v, got %v", expected, policies)
+		}
+	}
+
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectPolicies("projects/my-project/regions/us-east1/resourcePolicies/business-hours")
+	if reconciler.providerStatus.InstanceSchedulePolicy != "business-hours" {
+		t.Errorf("expected the attached policy to be recorded, got %q", reconciler.providerStatus.InstanceSchedulePolicy)
+	}
+
+	// Changing the policy replaces the attached one.
+	reconciler.providerSpec.InstanceSchedulePolicy = "weekdays"
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectPolicies("projects/my-project/regions/us-east1/resourcePolicies/weekdays")
+
+	// Removing the policy detaches it.
+	reconciler.providerSpec.InstanceSchedulePolicy = ""
+	if err := reconciler.update(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	expectPolicies()
+	if reconciler.providerStatus.InstanceSchedulePolicy != "" {
+		t.Errorf("expected no attached policy to be recorded, got %q", reconciler.providerStatus.InstanceSchedulePolicy)
+	}
+}
@@ -0,0 +1,87 @@
+package machine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// snapshotAnnotation requests a snapshot of a disk of the instance, e.g. before a risky node operation.
+	// Any new value, e.g. a timestamp, takes a new snapshot; the snapshot of the last value is recorded in
+	// the provider status.
+	snapshotAnnotation = "gcpprovider.machine.openshift.io/snapshot"
+	// snapshotDiskAnnotation names the disk of the instance the snapshot annotation takes a snapshot of.
+	// Defaults to the boot disk.
+	snapshotDiskAnnotation = "gcpprovider.machine.openshift.io/snapshot-disk"
+)
+
+// snapshotName returns the name of the snapshot of the disk taken for the request: the disk name, truncated
+// to fit in 63 characters, suffixed with a hash of the request so each request takes its own snapshot.
+func snapshotName(disk, request string) string {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(request)))[:instanceNameHashLength]
+	prefix := disk
+	if max := validation.DNS1035LabelMaxLength - instanceNameHashLength - 1; len(prefix) > max {
+		prefix = prefix[:max]
+	}
+	return fmt.Sprintf("%s-%s", strings.TrimRight(prefix, "-"), hash)
+}
+
+// ensureSnapshot takes the snapshot requested by the snapshot annotation of the machine, unless it was taken
+// already. The snapshot is recorded in the provider status once requested, so it is not requested again.
+func (r *Reconciler) ensureSnapshot() error {
+	request := r.machine.Annotations[snapshotAnnotation]
+	if request == "" || r.providerStatus.LastSnapshot != nil && r.providerStatus.LastSnapshot.Request == request {
+		return nil
+	}
+	instance, _, err := r.getInstance()
+	if err != nil {
+		return fmt.Errorf("error getting instance %q in zone %q: %v", r.instanceName(), r.providerSpec.Zone, err)
+	}
+	disk, err := snapshotDisk(instance, r.machine.Annotations[snapshotDiskAnnotation])
+	if err != nil {
+		return err
+	}
+
+	zone := r.providerSpec.Zone
+	name := snapshotName(disk, request)
+	operation, err := r.computeService.DisksCreateSnapshot(r.Context, r.projectID, zone, disk, &compute.Snapshot{
+		Name:   name,
+		Labels: r.ownedLabels(nil),
+	})
+	if err != nil && !isAlreadyExistsError(err) {
+		return fmt.Errorf("error creating snapshot %q of disk %q: %v", name, disk, err)
+	}
+	r.providerStatus.LastSnapshot = &v1beta1.GCPSnapshot{
+		Request: request,
+		Disk:    disk,
+		Name:    name,
+	}
+	if err != nil {
+		return nil
+	}
+	r.logger.Info("Creating snapshot", "disk", disk, "snapshot", name, "gcpOperation", operation.Name)
+	if err := r.waitUntilZoneOperationCompleted(zone, operation.Name); err != nil {
+		return fmt.Errorf("error creating snapshot %q of disk %q: %v", name, disk, err)
+	}
+	return nil
+}
+
+// snapshotDisk returns the name of the disk of the instance to take a snapshot of: the named disk, or the boot
+// disk when no name is given.
+func snapshotDisk(instance *compute.Instance, name string) (string, error) {
+	for _, disk := range instance.Disks {
+		diskName := disk.Source[strings.LastIndex(disk.Source, "/")+1:]
+		if name == "" && disk.Boot || name != "" && diskName == name {
+			return diskName, nil
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("instance %q has no boot disk to take a snapshot of", instance.Name)
+	}
+	return "", fmt.Errorf("disk %q of the %s annotation is not attached to instance %q", name, snapshotDiskAnnotation, instance.Name)
+}
@@ -0,0 +1,93 @@
+package machine
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSnapshotName(t *testing.T) {
+	name := snapshotName(strings.Repeat("a", 70), "2026-10-16T10:00:00Z")
+	if len(name) > 63 {
+		t.Errorf("expected snapshot name of at most 63 characters, got %q", name)
+	}
+	if snapshotName("worker-0", "a") == snapshotName("worker-0", "b") {
+		t.Errorf("expected requests to take distinct snapshots")
+	}
+}
+
+func TestEnsureSnapshot(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	machine := &v1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker-0",
+		},
+	}
+	reconciler := newReconciler(&machineScope{
+		Context:    context.TODO(),
+		machine:    machine,
+		coreClient: controllerfake.NewFakeClient(),
+		projectID:  "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks: []*gcpv1beta1.GCPDisk{
+				{
+					AutoDelete: true,
+					Boot:       true,
+					Image:      "rhcos",
+				},
+				{
+					SizeGb: 100,
+					Type:   "pd-ssd",
+				},
+			},
+		},
+		computeService: mockComputeService,
+	})
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+
+	// The boot disk is snapshotted by default.
+	machine.Annotations = map[string]string{snapshotAnnotation: "before-upgrade"}
+	if err := reconciler.ensureSnapshot(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	snapshot := reconciler.providerStatus.LastSnapshot
+	if snapshot == nil || snapshot.Request != "before-upgrade" || snapshot.Disk != "worker-0" {
+		t.Fatalf("expected the snapshot of the boot disk to be recorded, got %+v", snapshot)
+	}
+	if mockComputeService.Snapshot("my-project", snapshot.Name) == nil {
+		t.Errorf("expected snapshot %q to be created", snapshot.Name)
+	}
+
+	// The same request does not take another snapshot.
+	mockComputeService.FailOn("DisksCreateSnapshot", computeservice.APIError(http.StatusInternalServerError, "backendError", "unexpected snapshot"))
+	if err := reconciler.ensureSnapshot(); err != nil {
+		t.Errorf("expected the snapshot not to be taken again, got %v", err)
+	}
+	mockComputeService.ClearFailures()
+
+	// A new request snapshots the named disk.
+	machine.Annotations = map[string]string{snapshotAnnotation: "before-resize", snapshotDiskAnnotation: "worker-0-disk-1"}
+	if err := reconciler.ensureSnapshot(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if snapshot := reconciler.providerStatus.LastSnapshot; snapshot.Request != "before-resize" || snapshot.Disk != "worker-0-disk-1" {
+		t.Errorf("expected the snapshot of the named disk to be recorded, got %+v", snapshot)
+	}
+
+	// Disks which are not attached to the instance are rejected.
+	machine.Annotations = map[string]string{snapshotAnnotation: "other", snapshotDiskAnnotation: "other-disk"}
+	if err := reconciler.ensureSnapshot(); err == nil {
+		t.Errorf("expected an error for a disk not attached to the instance")
+	}
+}
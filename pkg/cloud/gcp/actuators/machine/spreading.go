@@ -0,0 +1,94 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// zonesAnnotation is set on machine sets to spread their machines across zones, e.g.
+// "us-east1-b,us-east1-c,us-east1-d". Each machine is created in the zone with the fewest machines of the
+// machine set, overriding the zone of the provider spec of the machine set template.
+const zonesAnnotation = "gcpprovider.machine.openshift.io/zones"
+
+// machineSetZones returns the machine set owning the machine and the zones of its zones annotation. It returns no
+// zones when the machine is not owned by a machine set, the machine set no longer exists or it does not spread its
+// machines.
+func (r *Reconciler) machineSetZones() (*machinev1.MachineSet, []string, error) {
+	owner := metav1.GetControllerOf(r.machine)
+	if owner == nil || owner.Kind != "MachineSet" {
+		return nil, nil, nil
+	}
+	machineSet, err := r.machineSetClient.Get(owner.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("error getting machine set %q: %v", owner.Name, err)
+	}
+	value := machineSet.Annotations[zonesAnnotation]
+	if value == "" {
+		return nil, nil, nil
+	}
+	region := r.region()
+	var zones []string
+	for _, zone := range strings.Split(value, ",") {
+		zone = strings.TrimSpace(zone)
+		if !strings.HasPrefix(zone, region+"-") {
+			return nil, nil, machineapierrors.InvalidMachineConfiguration("zone %q of the %s annotation of machine set %q is not in region %q", zone, zonesAnnotation, machineSet.Name, region)
+		}
+		zones = append(zones, zone)
+	}
+	return machineSet, zones, nil
+}
+
+// spreadZones returns the zones of the zones annotation of the machine set owning the machine, with the number
+// of other machines of the machine set in each of them. It returns no zones when the machine set does not
+// spread its machines.
+func (r *Reconciler) spreadZones() ([]string, map[string]int, error) {
+	machineSet, zones, err := r.machineSetZones()
+	if err != nil || len(zones) == 0 {
+		return nil, nil, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&machineSet.Spec.Selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid selector of machine set %q: %v", machineSet.Name, err)
+	}
+	machines, err := r.machineClient.List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing the machines of machine set %q: %v", machineSet.Name, err)
+	}
+	counts := map[string]int{}
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if machine.Name == r.machine.Name || !metav1.IsControlledBy(machine, machineSet) || machine.DeletionTimestamp != nil {
+			continue
+		}
+		if zone := machineZone(machine); zone != "" {
+			counts[zone]++
+		}
+	}
+	return zones, counts, nil
+}
+
+// machineZone returns the zone of the instance of the machine: the zone of its provider ID, else the zone recorded
+// in its provider status, else the zone of its provider spec. It returns an empty zone when none is known.
+func machineZone(machine *machinev1.Machine) string {
+	if machine.Spec.ProviderID != nil {
+		if providerID, err := ParseProviderID(*machine.Spec.ProviderID); err == nil {
+			return providerID.Zone
+		}
+	}
+	if providerStatus, err := providerStatusFromRawExtension(machine.Status.ProviderStatus); err == nil && providerStatus.Zone != "" {
+		return providerStatus.Zone
+	}
+	if providerSpec, err := machineConfigFromProviderSpec(machine.Spec.ProviderSpec); err == nil {
+		return providerSpec.Zone
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// region returns the region of the machine, which defaults to the region of its zone.
+func (r *Reconciler) region() string {
+	if r.providerSpec.Region != "" {
+		return r.providerSpec.Region
+	}
+	if i := strings.LastIndex(r.providerSpec.Zone, "-"); i > 0 {
+		return r.providerSpec.Zone[:i]
+	}
+	return ""
+}
+
+// networkProjectID returns the project of the networks and subnetworks of the machine, the Shared VPC host
+// project when set.
+func (r *Reconciler) networkProjectID() string {
+	if r.providerSpec.NetworkProjectID != "" {
+		return r.providerSpec.NetworkProjectID
+	}
+	return r.projectID
+}
+
+// instanceURL returns the partial URL of the machine instance, as referenced by target pools and instance groups.
+func (r *Reconciler) instanceURL() string {
+	return fmt.Sprintf("projects/%s/zones/%s/instances/%s", r.projectID, r.providerSpec.Zone, r.instanceName())
+}
+
+// isInstanceURL returns true if the full or partial URL references the instance partial URL.
+func isInstanceURL(url string, instanceURL string) bool {
+	return url == instanceURL || strings.HasSuffix(url, "/"+instanceURL)
+}
+
+// hasInstance returns true if the target pool members include the instance partial URL.
+func hasInstance(targetPool *compute.TargetPool, instanceURL string) bool {
+	for _, member := range targetPool.Instances {
+		if isInstanceURL(member, instanceURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureTargetPoolsMembership registers the instance in the target pools of the provider spec it is not a member of yet.
+func (r *Reconciler) ensureTargetPoolsMembership() error {
+	region := r.region()
+	instanceURL := r.instanceURL()
+	for _, name := range r.providerSpec.TargetPools {
+		targetPool, err := r.computeService.TargetPoolsGet(r.Context, r.projectID, region, name)
+		if err != nil {
+			return fmt.Errorf("error getting target pool %q in region %q: %v", name, region, err)
+		}
+		if !hasString(r.providerStatus.TargetPools, name) {
+			r.providerStatus.TargetPools = append(r.providerStatus.TargetPools, name)
+		}
+		if hasInstance(targetPool, instanceURL) {
+			continue
+		}
+		operation, err := r.computeService.TargetPoolsAddInstance(r.Context, r.projectID, region, name, &compute.TargetPoolsAddInstanceRequest{
+			Instances: []*compute.InstanceReference{{Instance: instanceURL}},
+		})
+		if err != nil {
+			return fmt.Errorf("error adding instance to target pool %q in region %q: %v", name, region, err)
+		}
+		r.logger.Info("Adding instance to target pool", "targetPool", name, "gcpOperation", operation.Name)
+		if err := r.waitUntilRegionOperationCompleted(region, operation.Name); err != nil {
+			return fmt.Errorf("error adding instance to target pool %q in region %q: %v", name, region, err)
+		}
+	}
+	return nil
+}
+
+// removeFromTargetPools removes the instance from the target pools of the provider spec and provider status
+// it is a member of. Target pools which do not exist anymore are skipped.
+func (r *Reconciler) removeFromTargetPools() error {
+	region := r.region()
+	instanceURL := r.instanceURL()
+	for _, name := range mergeStrings(r.providerSpec.TargetPools, r.providerStatus.TargetPools) {
+		targetPool, err := r.computeService.TargetPoolsGet(r.Context, r.projectID, region, name)
+		if err != nil {
+			if isNotFoundError(err) {
+				r.providerStatus.TargetPools = removeString(r.providerStatus.TargetPools, name)
+				continue
+			}
+			return fmt.Errorf("error getting target pool %q in region %q: %v", name, region, err)
+		}
+		if !hasInstance(targetPool, instanceURL) {
+			r.providerStatus.TargetPools = removeString(r.providerStatus.TargetPools, name)
+			continue
+		}
+		operation, err := r.computeService.TargetPoolsRemoveInstance(r.Context, r.projectID, region, name, &compute.TargetPoolsRemoveInstanceRequest{
+			Instances: []*compute.InstanceReference{{Instance: instanceURL}},
+		})
+		if err != nil {
+			return fmt.Errorf("error removing instance from target pool %q in region %q: %v", name, region, err)
+		}
+		r.logger.Info("Removing instance from target pool", "targetPool", name, "gcpOperation", operation.Name)
+		if err := r.waitUntilRegionOperationCompleted(region, operation.Name); err != nil {
+			return fmt.Errorf("error removing instance from target pool %q in region %q: %v", name, region, err)
+		}
+		r.providerStatus.TargetPools = removeString(r.providerStatus.TargetPools, name)
+	}
+	return nil
+}
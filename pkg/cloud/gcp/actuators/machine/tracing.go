@@ -0,0 +1,40 @@
+package machine
+
+import (
+	"context"
+	"net/http"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+// startOperationSpan starts the span of an actuator operation on the machine. The GCP API calls
+// and operation waits of the operation are traced as its children.
+func startOperationSpan(ctx context.Context, operation string, machine *machinev1.Machine) (context.Context, *trace.Span) {
+	ctx, span := trace.StartSpan(ctx, "machine."+operation)
+	span.AddAttributes(
+		trace.StringAttribute("machine", machine.Name),
+		trace.StringAttribute("namespace", machine.Namespace),
+	)
+	return ctx, span
+}
+
+// endSpan ends the span, recording the error of the operation if any.
+// It is meant to be deferred with a pointer to the named error result of the operation.
+func endSpan(span *trace.Span, err *error) {
+	if *err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: (*err).Error()})
+	}
+	span.End()
+}
+
+// tracingTransport traces the GCP API requests sent through base.
+func tracingTransport(base http.RoundTripper) http.RoundTripper {
+	return &ochttp.Transport{
+		Base: base,
+		FormatSpanName: func(req *http.Request) string {
+			return "gcp " + req.Method + " " + req.URL.Path
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package machine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	apicorev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultUserDataSecretKey is the key of the user data secret read when the provider spec sets none.
+	defaultUserDataSecretKey = "userData"
+
+	userDataMetadataKey                = "user-data"
+	startupScriptMetadataKey           = "startup-script"
+	windowsStartupScriptMetadataKey    = "windows-startup-script-ps1"
+	sysprepSpecializeScriptMetadataKey = "sysprep-specialize-script-ps1"
+)
+
+// userDataMetadataKeys are the metadata keys holding user data, which are redacted when rendering instances.
+var userDataMetadataKeys = []string{
+	userDataMetadataKey,
+	startupScriptMetadataKey,
+	windowsStartupScriptMetadataKey,
+	sysprepSpecializeScriptMetadataKey,
+}
+
+// userDataFormat returns the format of the user data, defaulting to the format of the OS family.
+func (r *Reconciler) userDataFormat() v1beta1.GCPUserDataFormat {
+	if r.providerSpec.UserDataFormat != "" {
+		return r.providerSpec.UserDataFormat
+	}
+	if r.providerSpec.OSFamily == v1beta1.OSFamilyWindows {
+		return v1beta1.UserDataFormatWindowsStartupScript
+	}
+	return v1beta1.UserDataFormatIgnition
+}
+
+// userDataMetadataItemKey returns the instance metadata key the user data of the given format is written to.
+func userDataMetadataItemKey(format v1beta1.GCPUserDataFormat) string {
+	switch format {
+	case v1beta1.UserDataFormatStartupScript:
+		return startupScriptMetadataKey
+	case v1beta1.UserDataFormatWindowsStartupScript:
+		return windowsStartupScriptMetadataKey
+	case v1beta1.UserDataFormatSysprepSpecializeScript:
+		return sysprepSpecializeScriptMetadataKey
+	default:
+		return userDataMetadataKey
+	}
+}
+
+// isScriptFormat returns true for user data run by the guest agent, which must not be base64 encoded.
+func isScriptFormat(format v1beta1.GCPUserDataFormat) bool {
+	switch format {
+	case v1beta1.UserDataFormatStartupScript, v1beta1.UserDataFormatWindowsStartupScript, v1beta1.UserDataFormatSysprepSpecializeScript:
+		return true
+	default:
+		return false
+	}
+}
+
+// getCustomUserData returns the user data read from the user data secret, encoded for its metadata key.
+// Ignition and cloud-init configs are base64 encoded unless raw user data is requested,
+// startup scripts are run by the guest agent as is.
+func (r *Reconciler) getCustomUserData() (string, error) {
+	if r.providerSpec.UserDataSecret == nil {
+		return "", nil
+	}
+	var userDataSecret apicorev1.Secret
+
+	if err := r.coreClient.Get(r.Context, client.ObjectKey{Namespace: r.machine.GetNamespace(), Name: r.providerSpec.UserDataSecret.Name}, &userDataSecret); err != nil {
+		return "", fmt.Errorf("error getting user data secret %q in namespace %q: %v", r.providerSpec.UserDataSecret.Name, r.machine.GetNamespace(), err)
+	}
+	key := r.providerSpec.UserDataSecretKey
+	if key == "" {
+		key = defaultUserDataSecretKey
+	}
+	data, exists := userDataSecret.Data[key]
+	if !exists {
+		return "", fmt.Errorf("secret %v/%v does not have %q field set. Thus, no user data applied when creating an instance", r.machine.GetNamespace(), r.providerSpec.UserDataSecret.Name, key)
+	}
+	value := r.encodeUserData(data)
+	// Oversized user data of other formats is reported by validateMetadataSize.
+	if len(value) <= maxMetadataValueSize || r.userDataFormat() != v1beta1.UserDataFormatIgnition {
+		return value, nil
+	}
+	if r.providerSpec.IgnitionConfigSource == "" {
+		return "", fmt.Errorf("user data of %d bytes exceeds the %d bytes limit of instance metadata values, set ignitionConfigSource to fetch the Ignition config from a URL instead", len(value), maxMetadataValueSize)
+	}
+	pointer, err := ignitionPointerConfig(data, r.providerSpec.IgnitionConfigSource)
+	if err != nil {
+		return "", err
+	}
+	r.logger.Info("User data exceeds the metadata size limit, pointing Ignition to the config source", "size", len(value), "source", r.providerSpec.IgnitionConfigSource)
+	return r.encodeUserData(pointer), nil
+}
+
+func (r *Reconciler) encodeUserData(data []byte) string {
+	if r.providerSpec.RawUserData || isScriptFormat(r.userDataFormat()) {
+		return string(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// ignitionPointerConfig returns an Ignition config replacing itself with the config fetched from source.
+// It keeps the version and the security settings, e.g. the CA of the machine config server, of the full config.
+func ignitionPointerConfig(config []byte, source string) ([]byte, error) {
+	var full struct {
+		Ignition map[string]interface{} `json:"ignition"`
+	}
+	if err := json.Unmarshal(config, &full); err != nil {
+		return nil, fmt.Errorf("error parsing Ignition config from user data: %v", err)
+	}
+	version, _ := full.Ignition["version"].(string)
+	if version == "" {
+		return nil, fmt.Errorf("no version in Ignition config from user data")
+	}
+	ignition := map[string]interface{}{
+		"version": version,
+		"config": map[string]interface{}{
+			"replace": map[string]interface{}{"source": source},
+		},
+	}
+	if security, ok := full.Ignition["security"]; ok {
+		ignition["security"] = security
+	}
+	return json.Marshal(map[string]interface{}{"ignition": ignition})
+}
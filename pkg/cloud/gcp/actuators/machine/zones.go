@@ -0,0 +1,218 @@
+package machine
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// stockoutWindow is how long a stockout in a zone makes the selection of zones prefer the other zones of its region.
+const stockoutWindow = 30 * time.Minute
+
+// zoneSelector selects the zones of the machines whose provider spec sets a region but no zone, or whose machine
+// set spreads them across zones. It spreads them round-robin across the zones with the fewest recent stockouts.
+type zoneSelector struct {
+	lock sync.Mutex
+	// next is the round-robin position by project/region.
+	next map[string]int
+	// stockouts are the times of the recent stockouts by project/zone.
+	stockouts map[string][]time.Time
+}
+
+func newZoneSelector() *zoneSelector {
+	return &zoneSelector{
+		next:      map[string]int{},
+		stockouts: map[string][]time.Time{},
+	}
+}
+
+// recordStockout records that creating an instance in the zone failed for lack of resources.
+func (s *zoneSelector) recordStockout(project, zone string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := path.Join(project, zone)
+	s.stockouts[key] = append(s.stockouts[key], time.Now())
+}
+
+// selectZone returns one of the zones, sorted, with the fewest stockouts in the stockout window and then the
+// fewest machines by zone, if counted, the next round-robin among equal zones. A nil selector returns the first
+// of these zones.
+func (s *zoneSelector) selectZone(project, region string, zones []string, machines map[string]int) string {
+	stockouts := map[string]int{}
+	if s != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		for _, zone := range zones {
+			stockouts[zone] = s.recentStockouts(path.Join(project, zone))
+		}
+	}
+	candidates := fewest(fewest(zones, stockouts), machines)
+	if s == nil {
+		return candidates[0]
+	}
+	key := path.Join(project, region)
+	zone := candidates[s.next[key]%len(candidates)]
+	s.next[key]++
+	return zone
+}
+
+// fewest returns the zones with the lowest count.
+func fewest(zones []string, counts map[string]int) []string {
+	var result []string
+	for _, zone := range zones {
+		if len(result) == 0 || counts[zone] < counts[result[0]] {
+			result = nil
+		}
+		if len(result) == 0 || counts[zone] == counts[result[0]] {
+			result = append(result, zone)
+		}
+	}
+	return result
+}
+
+// recentStockouts returns the number of stockouts of the project/zone key in the stockout window, forgetting
+// older ones.
+func (s *zoneSelector) recentStockouts(key string) int {
+	var recent []time.Time
+	for _, t := range s.stockouts[key] {
+		if time.Since(t) < stockoutWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(s.stockouts, key)
+	} else {
+		s.stockouts[key] = recent
+	}
+	return len(recent)
+}
+
+// isStockoutError returns true if the error is a failure to create an instance for lack of resources in its zone.
+func isStockoutError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ZONE_RESOURCE_POOL_EXHAUSTED")
+}
+
+// regionZones returns the sorted names of the zones of the machine region which are up.
+func (r *Reconciler) regionZones() ([]string, error) {
+	region := r.region()
+	zones, err := r.computeService.ZonesList(r.Context, r.projectID, "")
+	if err != nil {
+		return nil, fmt.Errorf("error listing the zones of region %q: %v", region, err)
+	}
+	var names []string
+	for _, zone := range zones {
+		if strings.HasPrefix(zone.Name, region+"-") && zone.Status == "UP" {
+			names = append(names, zone.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// selectZone selects the zone of a machine whose machine set spreads its machines across zones, or whose provider
+// spec sets a region but no zone, and records it in the provider status so the instance is looked up in it from
+// then on.
+func (r *Reconciler) selectZone() error {
+	if r.providerStatus.Zone != "" || (r.machine.Spec.ProviderID != nil && *r.machine.Spec.ProviderID != "") {
+		return nil
+	}
+	zones, machines, err := r.spreadZones()
+	if err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		if r.providerSpec.Zone != "" {
+			return nil
+		}
+		if zones, err = r.regionZones(); err != nil {
+			return err
+		}
+		if len(zones) == 0 {
+			return machineapierrors.InvalidMachineConfiguration("no zone of region %q is up", r.region())
+		}
+	}
+	zone := r.zoneSelector.selectZone(r.projectID, r.region(), zones, machines)
+	r.logger.Info("Selected zone", "zone", zone, "region", r.region())
+	r.providerSpec.Zone = zone
+	r.providerStatus.Zone = zone
+	return nil
+}
+
+// handleStockout records a stockout in the selected zone of the machine, and forgets the zone so the next
+// create selects a zone again, preferring the other zones of the region.
+func (r *Reconciler) handleStockout(err error) {
+	if r.providerStatus.Zone == "" || !isStockoutError(err) {
+		return
+	}
+	r.logger.Info("Selected zone is out of resources, selecting a zone again on the next create", "zone", r.providerStatus.Zone)
+	r.zoneSelector.recordStockout(r.projectID, r.providerStatus.Zone)
+	r.providerStatus.Zone = ""
+}
+
+// locateZone finds the zone of the instance of a machine whose zone is selected, when the selected zone was not
+// recorded, e.g. when the controller stopped before recording it. It returns false when the zone is unknown and
+// the instance does not exist in the region.
+func (r *Reconciler) locateZone() (bool, error) {
+	if r.providerStatus.Zone != "" || (r.machine.Spec.ProviderID != nil && *r.machine.Spec.ProviderID != "") {
+		return true, nil
+	}
+	if r.providerSpec.Zone != "" {
+		// The instance of a machine spread across zones may not be in the zone of its provider spec. The zones of
+		// its machine set are only looked up when the instance is not in that zone.
+		if metav1.GetControllerOf(r.machine) == nil {
+			return true, nil
+		}
+		if _, _, err := r.getInstance(); err == nil {
+			return true, nil
+		} else if !isNotFoundError(err) {
+			return false, fmt.Errorf("error getting instance %q in zone %q: %v", r.instanceName(), r.providerSpec.Zone, err)
+		}
+		_, zones, err := r.machineSetZones()
+		if err != nil {
+			return false, err
+		}
+		if len(zones) == 0 {
+			return true, nil
+		}
+	}
+	name := r.instanceName()
+	region := r.region()
+	instances, err := r.computeService.InstancesAggregatedList(r.Context, r.projectID, fmt.Sprintf("name = %s", name))
+	if err != nil {
+		return false, fmt.Errorf("error looking up instance %q in region %q: %v", name, region, err)
+	}
+	for _, instance := range instances {
+		zone := path.Base(instance.Zone)
+		if instance.Name == name && strings.HasPrefix(zone, region+"-") {
+			r.providerSpec.Zone = zone
+			r.providerStatus.Zone = zone
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// machineTypeZone returns the zone to get the machine type of the provider spec in: its zone, or the first zone
+// of its region, e.g. for the templates of machine sets whose machines select their zone.
+func (r *Reconciler) machineTypeZone() (string, error) {
+	if r.providerSpec.Zone != "" {
+		return r.providerSpec.Zone, nil
+	}
+	zones, err := r.regionZones()
+	if err != nil {
+		return "", err
+	}
+	if len(zones) == 0 {
+		return "", fmt.Errorf("no zone of region %q is up", r.region())
+	}
+	return zones[0], nil
+}
@@ -0,0 +1,213 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	computeservice "github.com/openshift/cluster-api-provider-gcp/pkg/cloud/gcp/actuators/services/compute"
+	"github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machinefake "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestZoneSelector(t *testing.T) {
+	selector := newZoneSelector()
+	zones := []string{"us-east1-b", "us-east1-c", "us-east1-d"}
+	for _, expected := range []string{"us-east1-b", "us-east1-c", "us-east1-d", "us-east1-b"} {
+		if zone := selector.selectZone("my-project", "us-east1", zones, nil); zone != expected {
+			t.Errorf("expected zone %q, got %q", expected, zone)
+		}
+	}
+
+	// Zones with recent stockouts are avoided.
+	selector.recordStockout("my-project", "us-east1-c")
+	for i := 0; i < 4; i++ {
+		if zone := selector.selectZone("my-project", "us-east1", zones, nil); zone == "us-east1-c" {
+			t.Errorf("expected the zone out of resources to be avoided")
+		}
+	}
+	// Other projects are not affected.
+	if zone := selector.selectZone("other-project", "us-east1", zones, nil); zone != "us-east1-b" {
+		t.Errorf("expected zone %q, got %q", "us-east1-b", zone)
+	}
+}
+
+func TestZoneAutoSelection(t *testing.T) {
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	selector := newZoneSelector()
+	newMachineReconciler := func(name string, providerStatus *gcpv1beta1.GCPMachineProviderStatus) *Reconciler {
+		return newReconciler(&machineScope{
+			Context: context.TODO(),
+			machine: &v1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+			},
+			coreClient: controllerfake.NewFakeClient(),
+			projectID:  "my-project",
+			providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+				Region:      "us-east1",
+				MachineType: "n1-standard-4",
+				Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			},
+			providerStatus: providerStatus,
+			computeService: mockComputeService,
+			zoneSelector:   selector,
+		})
+	}
+
+	// Machines are spread across the zones of the region.
+	for _, tc := range []struct{ name, zone string }{{"worker-0", "us-east1-b"}, {"worker-1", "us-east1-c"}} {
+		reconciler := newMachineReconciler(tc.name, &gcpv1beta1.GCPMachineProviderStatus{})
+		if err := reconciler.create(); err != nil {
+			t.Fatalf("reconciler was not expected to return error: %v", err)
+		}
+		if reconciler.providerStatus.Zone != tc.zone {
+			t.Errorf("expected zone %q to be recorded, got %q", tc.zone, reconciler.providerStatus.Zone)
+		}
+		if status := mockComputeService.InstanceStatus("my-project", tc.zone, tc.name); status == "" {
+			t.Errorf("expected instance %q in zone %q", tc.name, tc.zone)
+		}
+	}
+
+	// The instance is found in the region when its zone was not recorded.
+	reconciler := newMachineReconciler("worker-1", &gcpv1beta1.GCPMachineProviderStatus{})
+	exists, err := reconciler.exists()
+	if err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if !exists || reconciler.providerStatus.Zone != "us-east1-c" {
+		t.Errorf("expected the instance to be found in zone us-east1-c, got %v in %q", exists, reconciler.providerStatus.Zone)
+	}
+	reconciler = newMachineReconciler("worker-2", &gcpv1beta1.GCPMachineProviderStatus{})
+	if exists, err := reconciler.exists(); err != nil || exists {
+		t.Errorf("expected the instance of a new machine not to exist, got %v, %v", exists, err)
+	}
+
+	// A stockout forgets the zone, the next create selects another zone.
+	mockComputeService.FailOperations("ZONE_RESOURCE_POOL_EXHAUSTED", "The zone does not have enough resources available to fulfill the request.")
+	if err := reconciler.create(); err == nil {
+		t.Fatalf("expected create to fail")
+	}
+	if reconciler.providerStatus.Zone != "" {
+		t.Errorf("expected the zone out of resources to be forgotten, got %q", reconciler.providerStatus.Zone)
+	}
+	mockComputeService.ClearFailures()
+	if _, err := mockComputeService.InstancesDelete(context.TODO(), "my-project", "us-east1-b", "worker-2"); err != nil {
+		t.Fatal(err)
+	}
+	reconciler = newMachineReconciler("worker-2", reconciler.providerStatus)
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if reconciler.providerStatus.Zone != "us-east1-c" {
+		t.Errorf("expected zone us-east1-c after the stockout of us-east1-b, got %q", reconciler.providerStatus.Zone)
+	}
+}
+
+func TestZoneSpreading(t *testing.T) {
+	machineSet := &v1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "workers",
+			Namespace:   "default",
+			Annotations: map[string]string{zonesAnnotation: "us-east1-b, us-east1-c,us-east1-d"},
+		},
+		Spec: v1beta1.MachineSetSpec{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"machineset": "workers"}},
+		},
+	}
+	newMachine := func(name, zone string) *v1beta1.Machine {
+		machine := &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				Labels:          map[string]string{"machineset": "workers"},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(machineSet, v1beta1.SchemeGroupVersion.WithKind("MachineSet"))},
+			},
+		}
+		if zone != "" {
+			providerID := "gce://my-project/" + zone + "/" + name
+			machine.Spec.ProviderID = &providerID
+		}
+		return machine
+	}
+	machine := newMachine("workers-new", "")
+	machineClient := machinefake.NewSimpleClientset(machineSet, machine, newMachine("workers-0", "us-east1-b"), newMachine("workers-1", "us-east1-c"), newMachine("workers-2", "us-east1-b")).MachineV1beta1()
+
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context:          context.TODO(),
+		machine:          machine,
+		coreClient:       controllerfake.NewFakeClient(),
+		machineClient:    machineClient.Machines("default"),
+		machineSetClient: machineClient.MachineSets("default"),
+		projectID:        "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+		},
+		providerStatus: &gcpv1beta1.GCPMachineProviderStatus{},
+		computeService: mockComputeService,
+		zoneSelector:   newZoneSelector(),
+	})
+
+	// The machine is created in the zone with the fewest machines of the machine set.
+	if err := reconciler.create(); err != nil {
+		t.Fatalf("reconciler was not expected to return error: %v", err)
+	}
+	if reconciler.providerStatus.Zone != "us-east1-d" {
+		t.Errorf("expected zone us-east1-d to be recorded, got %q", reconciler.providerStatus.Zone)
+	}
+	if status := mockComputeService.InstanceStatus("my-project", "us-east1-d", "workers-new"); status == "" {
+		t.Errorf("expected instance workers-new in zone us-east1-d")
+	}
+
+	// Zones outside of the region of the machine are rejected.
+	machineSet.Annotations[zonesAnnotation] = "us-east1-b,us-west1-a"
+	if _, err := machineClient.MachineSets("default").Update(machineSet); err != nil {
+		t.Fatal(err)
+	}
+	reconciler.providerStatus = &gcpv1beta1.GCPMachineProviderStatus{}
+	if _, _, err := reconciler.spreadZones(); err == nil {
+		t.Errorf("expected an error for a zone outside of the region")
+	}
+}
+
+func TestDeleteWithoutMachineSet(t *testing.T) {
+	// The machine set owning the machine was deleted, and the instance of the machine was never created.
+	machineSet := &v1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "default", UID: "workers-uid"}}
+	machine := &v1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "workers-0",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(machineSet, v1beta1.SchemeGroupVersion.WithKind("MachineSet"))},
+		},
+	}
+	machineClient := machinefake.NewSimpleClientset(machine).MachineV1beta1()
+
+	_, mockComputeService := computeservice.NewComputeServiceMock()
+	reconciler := newReconciler(&machineScope{
+		Context:          context.TODO(),
+		machine:          machine,
+		coreClient:       controllerfake.NewFakeClient(),
+		machineClient:    machineClient.Machines("default"),
+		machineSetClient: machineClient.MachineSets("default"),
+		projectID:        "my-project",
+		providerSpec: &gcpv1beta1.GCPMachineProviderSpec{
+			Zone:        "us-east1-b",
+			MachineType: "n1-standard-4",
+			Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+		},
+		providerStatus: &gcpv1beta1.GCPMachineProviderStatus{},
+		computeService: mockComputeService,
+		zoneSelector:   newZoneSelector(),
+	})
+
+	if exists, err := reconciler.exists(); err != nil || exists {
+		t.Errorf("expected the instance not to exist, got %v, %v", exists, err)
+	}
+	if err := reconciler.delete(); err != nil {
+		t.Errorf("reconciler was not expected to return error: %v", err)
+	}
+}
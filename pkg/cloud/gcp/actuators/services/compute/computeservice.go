@@ -1,39 +1,780 @@
 package computeservice
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"k8s.io/apimachinery/pkg/util/cache"
 )
 
+const (
+	machineTypesCacheSize = 1024
+	machineTypesCacheTTL  = time.Hour
+	// machineTypesNotFoundCacheTTL is shorter so a machine type becoming available in a zone is picked up soon.
+	machineTypesNotFoundCacheTTL = 5 * time.Minute
+)
+
+// machineTypesCache is shared by all compute services since machine types
+// rarely change and are looked up for every machine of a MachineSet, by pre-flight
+// validation and on every resync of machine set capacity annotations. Machine types
+// missing in a zone are cached too, so invalid machine sets do not hammer the API.
+var machineTypesCache = cache.NewLRUExpireCache(machineTypesCacheSize)
+
 // GCPComputeService is a pass through wrapper for google.golang.org/api/compute/v1/compute
 // to enable tests to mock this struct and control behavior.
 type GCPComputeService interface {
-	InstancesInsert(project string, zone string, instance *compute.Instance) (*compute.Operation, error)
-	ZoneOperationsGet(project string, zone string, operation string) (*compute.Operation, error)
+	InstancesInsert(ctx context.Context, project string, zone string, instance *compute.Instance) (*compute.Operation, error)
+	InstancesInsertWithParams(ctx context.Context, project string, zone string, instance *compute.Instance, params *InstanceParams) (*compute.Operation, error)
+	ZoneOperationsGet(ctx context.Context, project string, zone string, operation string) (*compute.Operation, error)
+	MachineTypesGet(ctx context.Context, project string, zone string, machineType string) (*compute.MachineType, error)
+	ImagesGet(ctx context.Context, project string, image string) (*compute.Image, error)
+	ImagesGetFromFamily(ctx context.Context, project string, family string) (*compute.Image, error)
+	ImagesGetArchitecture(ctx context.Context, project string, image string, family bool) (string, error)
+	ImagesListByLabels(ctx context.Context, project string, labels map[string]string) ([]*compute.Image, error)
+	SubnetworksGet(ctx context.Context, project string, region string, subnetwork string) (*compute.Subnetwork, error)
+	DisksGet(ctx context.Context, project string, zone string, disk string) (*compute.Disk, error)
+	DisksInsert(ctx context.Context, project string, zone string, disk *compute.Disk) (*compute.Operation, error)
+	DisksDelete(ctx context.Context, project string, zone string, disk string) (*compute.Operation, error)
+	DisksResize(ctx context.Context, project string, zone string, disk string, request *compute.DisksResizeRequest) (*compute.Operation, error)
+	AddressesGet(ctx context.Context, project string, region string, address string) (*compute.Address, error)
+	AddressesInsert(ctx context.Context, project string, region string, address *compute.Address) (*compute.Operation, error)
+	AddressesDelete(ctx context.Context, project string, region string, address string) (*compute.Operation, error)
+	GlobalAddressesGet(ctx context.Context, project string, address string) (*compute.Address, error)
+	GlobalAddressesInsert(ctx context.Context, project string, address *compute.Address) (*compute.Operation, error)
+	GlobalAddressesDelete(ctx context.Context, project string, address string) (*compute.Operation, error)
+	TargetPoolsGet(ctx context.Context, project string, region string, targetPool string) (*compute.TargetPool, error)
+	TargetPoolsAddInstance(ctx context.Context, project string, region string, targetPool string, request *compute.TargetPoolsAddInstanceRequest) (*compute.Operation, error)
+	TargetPoolsRemoveInstance(ctx context.Context, project string, region string, targetPool string, request *compute.TargetPoolsRemoveInstanceRequest) (*compute.Operation, error)
+	InstanceGroupsGet(ctx context.Context, project string, zone string, instanceGroup string) (*compute.InstanceGroup, error)
+	InstanceGroupsAddInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsAddInstancesRequest) (*compute.Operation, error)
+	InstanceGroupsRemoveInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsRemoveInstancesRequest) (*compute.Operation, error)
+	InstanceGroupsListInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsListInstancesRequest) ([]*compute.InstanceWithNamedPorts, error)
+	InstanceTemplatesGet(ctx context.Context, project string, instanceTemplate string) (*compute.InstanceTemplate, error)
+	InstanceTemplatesInsert(ctx context.Context, project string, instanceTemplate *compute.InstanceTemplate) (*compute.Operation, error)
+	InstanceTemplatesDelete(ctx context.Context, project string, instanceTemplate string) (*compute.Operation, error)
+	InstancesGetSerialPortOutput(ctx context.Context, project string, zone string, instance string) (*compute.SerialPortOutput, error)
+	InstancesSetMetadata(ctx context.Context, project string, zone string, instance string, metadata *compute.Metadata) (*compute.Operation, error)
+	InstancesSetLabels(ctx context.Context, project string, zone string, instance string, request *compute.InstancesSetLabelsRequest) (*compute.Operation, error)
+	InstancesSetTags(ctx context.Context, project string, zone string, instance string, tags *compute.Tags) (*compute.Operation, error)
+	InstancesSetMachineType(ctx context.Context, project string, zone string, instance string, request *compute.InstancesSetMachineTypeRequest) (*compute.Operation, error)
+	InstancesStop(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error)
+	InstancesStart(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error)
+	InstancesReset(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error)
+	InstancesSuspend(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error)
+	InstancesResume(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error)
+	InstancesAggregatedList(ctx context.Context, project string, filter string) ([]*compute.Instance, error)
+	InstancesGet(ctx context.Context, project string, zone string, instance string) (*compute.Instance, error)
+	InstancesDelete(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error)
+	InstancesList(ctx context.Context, project string, zone string, filter string) ([]*compute.Instance, error)
+	AddressesList(ctx context.Context, project string, region string, filter string) ([]*compute.Address, error)
+	GlobalAddressesList(ctx context.Context, project string, filter string) ([]*compute.Address, error)
+	ZonesList(ctx context.Context, project string, filter string) ([]*compute.Zone, error)
+	RegionOperationsGet(ctx context.Context, project string, region string, operation string) (*compute.Operation, error)
+	InstanceGroupsInsert(ctx context.Context, project string, zone string, instanceGroup *compute.InstanceGroup) (*compute.Operation, error)
+	FirewallsGet(ctx context.Context, project string, firewall string) (*compute.Firewall, error)
+	FirewallsInsert(ctx context.Context, project string, firewall *compute.Firewall) (*compute.Operation, error)
+	FirewallsPatch(ctx context.Context, project string, firewall string, patch *compute.Firewall) (*compute.Operation, error)
+	GlobalOperationsGet(ctx context.Context, project string, operation string) (*compute.Operation, error)
+	RoutersList(ctx context.Context, project string, region string) ([]*compute.Router, error)
+	RegionBackendServicesGetHealth(ctx context.Context, project string, region string, backendService string, group *compute.ResourceGroupReference) (*compute.BackendServiceGroupHealth, error)
+	InstancesSetDeletionProtection(ctx context.Context, project string, zone string, instance string, deletionProtection bool) (*compute.Operation, error)
+	ZoneOperationsList(ctx context.Context, project string, zone string, filter string) ([]*compute.Operation, error)
+	NetworksGet(ctx context.Context, project string, network string) (*compute.Network, error)
+	NetworksInsert(ctx context.Context, project string, network *compute.Network) (*compute.Operation, error)
+	NetworksDelete(ctx context.Context, project string, network string) (*compute.Operation, error)
+	SubnetworksInsert(ctx context.Context, project string, region string, subnetwork *compute.Subnetwork) (*compute.Operation, error)
+	SubnetworksDelete(ctx context.Context, project string, region string, subnetwork string) (*compute.Operation, error)
+	RoutersGet(ctx context.Context, project string, region string, router string) (*compute.Router, error)
+	RoutersInsert(ctx context.Context, project string, region string, router *compute.Router) (*compute.Operation, error)
+	RoutersPatch(ctx context.Context, project string, region string, router string, patch *compute.Router) (*compute.Operation, error)
+	RoutersDelete(ctx context.Context, project string, region string, router string) (*compute.Operation, error)
+	FirewallsDelete(ctx context.Context, project string, firewall string) (*compute.Operation, error)
+	ProjectsGet(ctx context.Context, project string) (*compute.Project, error)
+	ProjectsSetCommonInstanceMetadata(ctx context.Context, project string, metadata *compute.Metadata) (*compute.Operation, error)
+	RegionsGet(ctx context.Context, project string, region string) (*compute.Region, error)
+	InstancesAddResourcePolicies(ctx context.Context, project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error)
+	InstancesRemoveResourcePolicies(ctx context.Context, project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error)
+	AcceleratorTypesGet(ctx context.Context, project string, zone string, acceleratorType string) (*compute.AcceleratorType, error)
+	AcceleratorTypesAggregatedList(ctx context.Context, project string, filter string) ([]*compute.AcceleratorType, error)
+	DisksInsertMultiWriter(ctx context.Context, project string, zone string, disk *compute.Disk) (*compute.Operation, error)
+	InstancesDetachDisk(ctx context.Context, project string, zone string, instance string, deviceName string) (*compute.Operation, error)
+	InstancesAttachDisk(ctx context.Context, project string, zone string, instance string, disk *compute.AttachedDisk) (*compute.Operation, error)
+	DisksCreateSnapshot(ctx context.Context, project string, zone string, disk string, snapshot *compute.Snapshot) (*compute.Operation, error)
 }
 
 type computeService struct {
 	service *compute.Service
+	client  *http.Client
+}
+
+// ServiceOptions configures the compute service.
+type ServiceOptions struct {
+	// Endpoint overrides the compute API base path, e.g. https://restricted.googleapis.com/compute/v1/projects/
+	// for Private Google Access restricted VIPs or the URL of a local emulator. Empty means the public endpoint.
+	Endpoint string
+	// UserAgent is appended to the User-Agent header of every request.
+	UserAgent string
 }
 
 // NewComputeService return a new computeService
-func NewComputeService(oauthClient *http.Client) (*computeService, error) {
+func NewComputeService(oauthClient *http.Client, options ServiceOptions) (*computeService, error) {
 	service, err := compute.New(oauthClient)
 	if err != nil {
 		return nil, err
 	}
+	if options.Endpoint != "" {
+		service.BasePath = strings.TrimSuffix(options.Endpoint, "/") + "/"
+	}
+	service.UserAgent = options.UserAgent
 	return &computeService{
 		service: service,
+		client:  oauthClient,
 	}, nil
 }
 
 // InstancesInsert is a pass through wrapper for compute.Service.Instances.Insert(...)
-func (c *computeService) InstancesInsert(project string, zone string, instance *compute.Instance) (*compute.Operation, error) {
-	return c.service.Instances.Insert(project, zone, instance).Do()
+func (c *computeService) InstancesInsert(ctx context.Context, project string, zone string, instance *compute.Instance) (*compute.Operation, error) {
+	return c.service.Instances.Insert(project, zone, instance).Context(ctx).Do()
+}
+
+// InstanceParams are the parameters of an instance insert request which are not part of the instance, e.g.
+// its Resource Manager tags. The vendored compute client does not support them yet.
+type InstanceParams struct {
+	// ResourceManagerTags are the tag values bound to the instance by tag key, e.g. tagKeys/123: tagValues/456.
+	ResourceManagerTags map[string]string `json:"resourceManagerTags,omitempty"`
+	// AdvancedMachineFeatures are sent as the advancedMachineFeatures field of the instance, which the
+	// vendored compute.Instance lacks.
+	AdvancedMachineFeatures *AdvancedMachineFeatures `json:"-"`
+	// ConfidentialInstanceConfig is sent as the confidentialInstanceConfig field of the instance, which the
+	// vendored compute.Instance lacks.
+	ConfidentialInstanceConfig *ConfidentialInstanceConfig `json:"-"`
+}
+
+// ConfidentialInstanceConfig is the Confidential VM configuration of an instance.
+type ConfidentialInstanceConfig struct {
+	// ConfidentialInstanceType is the confidential computing technology: SEV, SEV_SNP or TDX.
+	ConfidentialInstanceType string `json:"confidentialInstanceType,omitempty"`
+}
+
+// AdvancedMachineFeatures are the advanced machine features of an instance.
+type AdvancedMachineFeatures struct {
+	// PerformanceMonitoringUnit is the PMU exposed to the guest: ARCHITECTURAL, STANDARD or ENHANCED.
+	PerformanceMonitoringUnit string `json:"performanceMonitoringUnit,omitempty"`
+}
+
+// InstancesInsertWithParams calls the compute.instances.insert REST method with the params of the request,
+// which the vendored compute client does not support yet.
+func (c *computeService) InstancesInsertWithParams(ctx context.Context, project string, zone string, instance *compute.Instance, params *InstanceParams) (*compute.Operation, error) {
+	// Marshal the instance with its own marshaller, which honors ForceSendFields, then add the params.
+	data, err := json.Marshal(instance)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	if body["params"], err = json.Marshal(params); err != nil {
+		return nil, err
+	}
+	if params.AdvancedMachineFeatures != nil {
+		if body["advancedMachineFeatures"], err = json.Marshal(params.AdvancedMachineFeatures); err != nil {
+			return nil, err
+		}
+	}
+	if params.ConfidentialInstanceConfig != nil {
+		if body["confidentialInstanceConfig"], err = json.Marshal(params.ConfidentialInstanceConfig); err != nil {
+			return nil, err
+		}
+	}
+	return c.doOperationRequest(ctx, "POST", "{project}/zones/{zone}/instances", map[string]string{
+		"project": project,
+		"zone":    zone,
+	}, body)
 }
 
 // ZoneOperationsGet is a pass through wrapper for compute.Service.ZoneOperations.Get(...)
-func (c *computeService) ZoneOperationsGet(project string, zone string, operation string) (*compute.Operation, error) {
-	return c.service.ZoneOperations.Get(project, zone, operation).Do()
+func (c *computeService) ZoneOperationsGet(ctx context.Context, project string, zone string, operation string) (*compute.Operation, error) {
+	return c.service.ZoneOperations.Get(project, zone, operation).Context(ctx).Do()
+}
+
+// MachineTypesGet is a caching wrapper for compute.Service.MachineTypes.Get(...)
+// The returned machine type carries the guest CPU count and memory used for capacity lookups.
+func (c *computeService) MachineTypesGet(ctx context.Context, project string, zone string, machineType string) (*compute.MachineType, error) {
+	key := path.Join(project, zone, machineType)
+	if cached, ok := machineTypesCache.Get(key); ok {
+		if err, ok := cached.(error); ok {
+			return nil, err
+		}
+		return cached.(*compute.MachineType), nil
+	}
+	result, err := c.service.MachineTypes.Get(project, zone, machineType).Context(ctx).Do()
+	if err != nil {
+		if googleErr, ok := err.(*googleapi.Error); ok && googleErr.Code == http.StatusNotFound {
+			machineTypesCache.Add(key, err, machineTypesNotFoundCacheTTL)
+		}
+		return nil, err
+	}
+	machineTypesCache.Add(key, result, machineTypesCacheTTL)
+	return result, nil
+}
+
+// ImagesGet is a pass through wrapper for compute.Service.Images.Get(...)
+func (c *computeService) ImagesGet(ctx context.Context, project string, image string) (*compute.Image, error) {
+	return c.service.Images.Get(project, image).Context(ctx).Do()
+}
+
+// ImagesGetFromFamily is a pass through wrapper for compute.Service.Images.GetFromFamily(...)
+func (c *computeService) ImagesGetFromFamily(ctx context.Context, project string, family string) (*compute.Image, error) {
+	return c.service.Images.GetFromFamily(project, family).Context(ctx).Do()
+}
+
+// ImagesGetArchitecture returns the architecture of the image, or of the latest image of the image family when
+// family is true, e.g. ARM64. It is empty when the image does not set it. The vendored compute client does not
+// implement the architecture field of images.
+func (c *computeService) ImagesGetArchitecture(ctx context.Context, project string, image string, family bool) (string, error) {
+	relPath := "{project}/global/images/{image}"
+	if family {
+		relPath = "{project}/global/images/family/{image}"
+	}
+	var result struct {
+		Architecture string `json:"architecture"`
+	}
+	if err := c.doGetRequest(ctx, relPath, map[string]string{
+		"project": project,
+		"image":   image,
+	}, &result); err != nil {
+		return "", err
+	}
+	return result.Architecture, nil
+}
+
+// ImagesListByLabels is a wrapper for compute.Service.Images.List(...) filtering images carrying all the given labels
+// It iterates over all result pages.
+func (c *computeService) ImagesListByLabels(ctx context.Context, project string, labels map[string]string) ([]*compute.Image, error) {
+	var images []*compute.Image
+	err := c.service.Images.List(project).Filter(labelsFilter(labels)).Pages(ctx, func(list *compute.ImageList) error {
+		images = append(images, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// labelsFilter returns a list filter expression matching resources carrying all the given labels.
+func labelsFilter(labels map[string]string) string {
+	var keys []string
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var filters []string
+	for _, key := range keys {
+		filters = append(filters, fmt.Sprintf("(labels.%s = %q)", key, labels[key]))
+	}
+	return strings.Join(filters, " ")
+}
+
+// SubnetworksGet is a pass through wrapper for compute.Service.Subnetworks.Get(...)
+func (c *computeService) SubnetworksGet(ctx context.Context, project string, region string, subnetwork string) (*compute.Subnetwork, error) {
+	return c.service.Subnetworks.Get(project, region, subnetwork).Context(ctx).Do()
+}
+
+// DisksGet is a pass through wrapper for compute.Service.Disks.Get(...)
+func (c *computeService) DisksGet(ctx context.Context, project string, zone string, disk string) (*compute.Disk, error) {
+	return c.service.Disks.Get(project, zone, disk).Context(ctx).Do()
+}
+
+// DisksInsert is a pass through wrapper for compute.Service.Disks.Insert(...)
+func (c *computeService) DisksInsert(ctx context.Context, project string, zone string, disk *compute.Disk) (*compute.Operation, error) {
+	return c.service.Disks.Insert(project, zone, disk).Context(ctx).Do()
+}
+
+// DisksDelete is a pass through wrapper for compute.Service.Disks.Delete(...)
+func (c *computeService) DisksDelete(ctx context.Context, project string, zone string, disk string) (*compute.Operation, error) {
+	return c.service.Disks.Delete(project, zone, disk).Context(ctx).Do()
+}
+
+// DisksResize is a pass through wrapper for compute.Service.Disks.Resize(...)
+func (c *computeService) DisksResize(ctx context.Context, project string, zone string, disk string, request *compute.DisksResizeRequest) (*compute.Operation, error) {
+	return c.service.Disks.Resize(project, zone, disk, request).Context(ctx).Do()
+}
+
+// AddressesGet is a pass through wrapper for compute.Service.Addresses.Get(...)
+func (c *computeService) AddressesGet(ctx context.Context, project string, region string, address string) (*compute.Address, error) {
+	return c.service.Addresses.Get(project, region, address).Context(ctx).Do()
+}
+
+// AddressesInsert is a pass through wrapper for compute.Service.Addresses.Insert(...)
+func (c *computeService) AddressesInsert(ctx context.Context, project string, region string, address *compute.Address) (*compute.Operation, error) {
+	return c.service.Addresses.Insert(project, region, address).Context(ctx).Do()
+}
+
+// AddressesDelete is a pass through wrapper for compute.Service.Addresses.Delete(...)
+func (c *computeService) AddressesDelete(ctx context.Context, project string, region string, address string) (*compute.Operation, error) {
+	return c.service.Addresses.Delete(project, region, address).Context(ctx).Do()
+}
+
+// GlobalAddressesGet is a pass through wrapper for compute.Service.GlobalAddresses.Get(...)
+func (c *computeService) GlobalAddressesGet(ctx context.Context, project string, address string) (*compute.Address, error) {
+	return c.service.GlobalAddresses.Get(project, address).Context(ctx).Do()
+}
+
+// GlobalAddressesInsert is a pass through wrapper for compute.Service.GlobalAddresses.Insert(...)
+func (c *computeService) GlobalAddressesInsert(ctx context.Context, project string, address *compute.Address) (*compute.Operation, error) {
+	return c.service.GlobalAddresses.Insert(project, address).Context(ctx).Do()
+}
+
+// GlobalAddressesDelete is a pass through wrapper for compute.Service.GlobalAddresses.Delete(...)
+func (c *computeService) GlobalAddressesDelete(ctx context.Context, project string, address string) (*compute.Operation, error) {
+	return c.service.GlobalAddresses.Delete(project, address).Context(ctx).Do()
+}
+
+// TargetPoolsGet is a pass through wrapper for compute.Service.TargetPools.Get(...)
+func (c *computeService) TargetPoolsGet(ctx context.Context, project string, region string, targetPool string) (*compute.TargetPool, error) {
+	return c.service.TargetPools.Get(project, region, targetPool).Context(ctx).Do()
+}
+
+// TargetPoolsAddInstance is a pass through wrapper for compute.Service.TargetPools.AddInstance(...)
+func (c *computeService) TargetPoolsAddInstance(ctx context.Context, project string, region string, targetPool string, request *compute.TargetPoolsAddInstanceRequest) (*compute.Operation, error) {
+	return c.service.TargetPools.AddInstance(project, region, targetPool, request).Context(ctx).Do()
+}
+
+// TargetPoolsRemoveInstance is a pass through wrapper for compute.Service.TargetPools.RemoveInstance(...)
+func (c *computeService) TargetPoolsRemoveInstance(ctx context.Context, project string, region string, targetPool string, request *compute.TargetPoolsRemoveInstanceRequest) (*compute.Operation, error) {
+	return c.service.TargetPools.RemoveInstance(project, region, targetPool, request).Context(ctx).Do()
+}
+
+// InstanceGroupsGet is a pass through wrapper for compute.Service.InstanceGroups.Get(...)
+func (c *computeService) InstanceGroupsGet(ctx context.Context, project string, zone string, instanceGroup string) (*compute.InstanceGroup, error) {
+	return c.service.InstanceGroups.Get(project, zone, instanceGroup).Context(ctx).Do()
+}
+
+// InstanceGroupsAddInstances is a pass through wrapper for compute.Service.InstanceGroups.AddInstances(...)
+func (c *computeService) InstanceGroupsAddInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsAddInstancesRequest) (*compute.Operation, error) {
+	return c.service.InstanceGroups.AddInstances(project, zone, instanceGroup, request).Context(ctx).Do()
+}
+
+// InstanceGroupsRemoveInstances is a pass through wrapper for compute.Service.InstanceGroups.RemoveInstances(...)
+func (c *computeService) InstanceGroupsRemoveInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsRemoveInstancesRequest) (*compute.Operation, error) {
+	return c.service.InstanceGroups.RemoveInstances(project, zone, instanceGroup, request).Context(ctx).Do()
+}
+
+// InstanceGroupsListInstances is a wrapper for compute.Service.InstanceGroups.ListInstances(...)
+// It iterates over all result pages.
+func (c *computeService) InstanceGroupsListInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsListInstancesRequest) ([]*compute.InstanceWithNamedPorts, error) {
+	var instances []*compute.InstanceWithNamedPorts
+	err := c.service.InstanceGroups.ListInstances(project, zone, instanceGroup, request).Pages(ctx, func(list *compute.InstanceGroupsListInstances) error {
+		instances = append(instances, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// InstanceTemplatesGet is a pass through wrapper for compute.Service.InstanceTemplates.Get(...)
+func (c *computeService) InstanceTemplatesGet(ctx context.Context, project string, instanceTemplate string) (*compute.InstanceTemplate, error) {
+	return c.service.InstanceTemplates.Get(project, instanceTemplate).Context(ctx).Do()
+}
+
+// InstanceTemplatesInsert is a pass through wrapper for compute.Service.InstanceTemplates.Insert(...)
+func (c *computeService) InstanceTemplatesInsert(ctx context.Context, project string, instanceTemplate *compute.InstanceTemplate) (*compute.Operation, error) {
+	return c.service.InstanceTemplates.Insert(project, instanceTemplate).Context(ctx).Do()
+}
+
+// InstanceTemplatesDelete is a pass through wrapper for compute.Service.InstanceTemplates.Delete(...)
+func (c *computeService) InstanceTemplatesDelete(ctx context.Context, project string, instanceTemplate string) (*compute.Operation, error) {
+	return c.service.InstanceTemplates.Delete(project, instanceTemplate).Context(ctx).Do()
+}
+
+// InstancesGetSerialPortOutput is a pass through wrapper for compute.Service.Instances.GetSerialPortOutput(...)
+func (c *computeService) InstancesGetSerialPortOutput(ctx context.Context, project string, zone string, instance string) (*compute.SerialPortOutput, error) {
+	return c.service.Instances.GetSerialPortOutput(project, zone, instance).Context(ctx).Do()
+}
+
+// InstancesSetMetadata is a pass through wrapper for compute.Service.Instances.SetMetadata(...)
+func (c *computeService) InstancesSetMetadata(ctx context.Context, project string, zone string, instance string, metadata *compute.Metadata) (*compute.Operation, error) {
+	return c.service.Instances.SetMetadata(project, zone, instance, metadata).Context(ctx).Do()
+}
+
+// InstancesSetLabels is a pass through wrapper for compute.Service.Instances.SetLabels(...)
+func (c *computeService) InstancesSetLabels(ctx context.Context, project string, zone string, instance string, request *compute.InstancesSetLabelsRequest) (*compute.Operation, error) {
+	return c.service.Instances.SetLabels(project, zone, instance, request).Context(ctx).Do()
+}
+
+// InstancesSetTags is a pass through wrapper for compute.Service.Instances.SetTags(...)
+func (c *computeService) InstancesSetTags(ctx context.Context, project string, zone string, instance string, tags *compute.Tags) (*compute.Operation, error) {
+	return c.service.Instances.SetTags(project, zone, instance, tags).Context(ctx).Do()
+}
+
+// InstancesSetMachineType is a pass through wrapper for compute.Service.Instances.SetMachineType(...)
+func (c *computeService) InstancesSetMachineType(ctx context.Context, project string, zone string, instance string, request *compute.InstancesSetMachineTypeRequest) (*compute.Operation, error) {
+	return c.service.Instances.SetMachineType(project, zone, instance, request).Context(ctx).Do()
+}
+
+// InstancesStop is a pass through wrapper for compute.Service.Instances.Stop(...)
+func (c *computeService) InstancesStop(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	return c.service.Instances.Stop(project, zone, instance).Context(ctx).Do()
+}
+
+// InstancesStart is a pass through wrapper for compute.Service.Instances.Start(...)
+func (c *computeService) InstancesStart(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	return c.service.Instances.Start(project, zone, instance).Context(ctx).Do()
+}
+
+// InstancesReset is a pass through wrapper for compute.Service.Instances.Reset(...)
+func (c *computeService) InstancesReset(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	return c.service.Instances.Reset(project, zone, instance).Context(ctx).Do()
+}
+
+// InstancesSuspend calls the compute.instances.suspend REST method, which the vendored compute client does not support yet.
+func (c *computeService) InstancesSuspend(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	return c.doOperationRequest(ctx, "POST", "{project}/zones/{zone}/instances/{instance}/suspend", map[string]string{
+		"project":  project,
+		"zone":     zone,
+		"instance": instance,
+	}, nil)
+}
+
+// InstancesResume calls the compute.instances.resume REST method, which the vendored compute client does not support yet.
+func (c *computeService) InstancesResume(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	return c.doOperationRequest(ctx, "POST", "{project}/zones/{zone}/instances/{instance}/resume", map[string]string{
+		"project":  project,
+		"zone":     zone,
+		"instance": instance,
+	}, nil)
+}
+
+// InstancesAggregatedList is a wrapper for compute.Service.Instances.AggregatedList(...)
+// It iterates over all result pages and returns the instances of all zones matching the filter.
+func (c *computeService) InstancesAggregatedList(ctx context.Context, project string, filter string) ([]*compute.Instance, error) {
+	var instances []*compute.Instance
+	call := c.service.Instances.AggregatedList(project)
+	if filter != "" {
+		call = call.Filter(filter)
+	}
+	err := call.Pages(ctx, func(list *compute.InstanceAggregatedList) error {
+		for _, scopedList := range list.Items {
+			instances = append(instances, scopedList.Instances...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// InstancesGet is a pass through wrapper for compute.Service.Instances.Get(...)
+func (c *computeService) InstancesGet(ctx context.Context, project string, zone string, instance string) (*compute.Instance, error) {
+	return c.service.Instances.Get(project, zone, instance).Context(ctx).Do()
+}
+
+// InstancesDelete is a pass through wrapper for compute.Service.Instances.Delete(...)
+func (c *computeService) InstancesDelete(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	return c.service.Instances.Delete(project, zone, instance).Context(ctx).Do()
+}
+
+// InstancesList is a wrapper for compute.Service.Instances.List(...)
+// It iterates over all result pages and returns the resources matching the filter.
+func (c *computeService) InstancesList(ctx context.Context, project string, zone string, filter string) ([]*compute.Instance, error) {
+	var instances []*compute.Instance
+	call := c.service.Instances.List(project, zone)
+	if filter != "" {
+		call = call.Filter(filter)
+	}
+	err := call.Pages(ctx, func(list *compute.InstanceList) error {
+		instances = append(instances, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// AddressesList is a wrapper for compute.Service.Addresses.List(...)
+// It iterates over all result pages and returns the resources matching the filter.
+func (c *computeService) AddressesList(ctx context.Context, project string, region string, filter string) ([]*compute.Address, error) {
+	var addresses []*compute.Address
+	call := c.service.Addresses.List(project, region)
+	if filter != "" {
+		call = call.Filter(filter)
+	}
+	err := call.Pages(ctx, func(list *compute.AddressList) error {
+		addresses = append(addresses, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GlobalAddressesList is a wrapper for compute.Service.GlobalAddresses.List(...)
+// It iterates over all result pages and returns the resources matching the filter.
+func (c *computeService) GlobalAddressesList(ctx context.Context, project string, filter string) ([]*compute.Address, error) {
+	var addresses []*compute.Address
+	call := c.service.GlobalAddresses.List(project)
+	if filter != "" {
+		call = call.Filter(filter)
+	}
+	err := call.Pages(ctx, func(list *compute.AddressList) error {
+		addresses = append(addresses, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// ZonesList is a wrapper for compute.Service.Zones.List(...)
+// It iterates over all result pages and returns the resources matching the filter.
+func (c *computeService) ZonesList(ctx context.Context, project string, filter string) ([]*compute.Zone, error) {
+	var zones []*compute.Zone
+	call := c.service.Zones.List(project)
+	if filter != "" {
+		call = call.Filter(filter)
+	}
+	err := call.Pages(ctx, func(list *compute.ZoneList) error {
+		zones = append(zones, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// RegionOperationsGet is a pass through wrapper for compute.Service.RegionOperations.Get(...)
+func (c *computeService) RegionOperationsGet(ctx context.Context, project string, region string, operation string) (*compute.Operation, error) {
+	return c.service.RegionOperations.Get(project, region, operation).Context(ctx).Do()
+}
+
+// InstanceGroupsInsert is a pass through wrapper for compute.Service.InstanceGroups.Insert(...)
+func (c *computeService) InstanceGroupsInsert(ctx context.Context, project string, zone string, instanceGroup *compute.InstanceGroup) (*compute.Operation, error) {
+	return c.service.InstanceGroups.Insert(project, zone, instanceGroup).Context(ctx).Do()
+}
+
+// FirewallsGet is a pass through wrapper for compute.Service.Firewalls.Get(...)
+func (c *computeService) FirewallsGet(ctx context.Context, project string, firewall string) (*compute.Firewall, error) {
+	return c.service.Firewalls.Get(project, firewall).Context(ctx).Do()
+}
+
+// FirewallsInsert is a pass through wrapper for compute.Service.Firewalls.Insert(...)
+func (c *computeService) FirewallsInsert(ctx context.Context, project string, firewall *compute.Firewall) (*compute.Operation, error) {
+	return c.service.Firewalls.Insert(project, firewall).Context(ctx).Do()
+}
+
+// FirewallsPatch is a pass through wrapper for compute.Service.Firewalls.Patch(...)
+func (c *computeService) FirewallsPatch(ctx context.Context, project string, firewall string, patch *compute.Firewall) (*compute.Operation, error) {
+	return c.service.Firewalls.Patch(project, firewall, patch).Context(ctx).Do()
+}
+
+// GlobalOperationsGet is a pass through wrapper for compute.Service.GlobalOperations.Get(...)
+func (c *computeService) GlobalOperationsGet(ctx context.Context, project string, operation string) (*compute.Operation, error) {
+	return c.service.GlobalOperations.Get(project, operation).Context(ctx).Do()
+}
+
+// RoutersList is a wrapper for compute.Service.Routers.List(...)
+// It iterates over all result pages and returns the routers of the region.
+func (c *computeService) RoutersList(ctx context.Context, project string, region string) ([]*compute.Router, error) {
+	var routers []*compute.Router
+	err := c.service.Routers.List(project, region).Pages(ctx, func(list *compute.RouterList) error {
+		routers = append(routers, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return routers, nil
+}
+
+// RegionBackendServicesGetHealth is a pass through wrapper for compute.Service.RegionBackendServices.GetHealth(...)
+func (c *computeService) RegionBackendServicesGetHealth(ctx context.Context, project string, region string, backendService string, group *compute.ResourceGroupReference) (*compute.BackendServiceGroupHealth, error) {
+	return c.service.RegionBackendServices.GetHealth(project, region, backendService, group).Context(ctx).Do()
+}
+
+// InstancesSetDeletionProtection is a pass through wrapper for compute.Service.Instances.SetDeletionProtection(...)
+func (c *computeService) InstancesSetDeletionProtection(ctx context.Context, project string, zone string, instance string, deletionProtection bool) (*compute.Operation, error) {
+	return c.service.Instances.SetDeletionProtection(project, zone, instance).DeletionProtection(deletionProtection).Context(ctx).Do()
+}
+
+// ZoneOperationsList is a wrapper for compute.Service.ZoneOperations.List(...)
+// It iterates over all result pages and returns the operations matching the filter.
+func (c *computeService) ZoneOperationsList(ctx context.Context, project string, zone string, filter string) ([]*compute.Operation, error) {
+	var operations []*compute.Operation
+	call := c.service.ZoneOperations.List(project, zone)
+	if filter != "" {
+		call = call.Filter(filter)
+	}
+	err := call.Pages(ctx, func(list *compute.OperationList) error {
+		operations = append(operations, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// NetworksGet is a pass through wrapper for compute.Service.Networks.Get(...)
+func (c *computeService) NetworksGet(ctx context.Context, project string, network string) (*compute.Network, error) {
+	return c.service.Networks.Get(project, network).Context(ctx).Do()
+}
+
+// NetworksInsert is a pass through wrapper for compute.Service.Networks.Insert(...)
+func (c *computeService) NetworksInsert(ctx context.Context, project string, network *compute.Network) (*compute.Operation, error) {
+	return c.service.Networks.Insert(project, network).Context(ctx).Do()
+}
+
+// NetworksDelete is a pass through wrapper for compute.Service.Networks.Delete(...)
+func (c *computeService) NetworksDelete(ctx context.Context, project string, network string) (*compute.Operation, error) {
+	return c.service.Networks.Delete(project, network).Context(ctx).Do()
+}
+
+// SubnetworksInsert is a pass through wrapper for compute.Service.Subnetworks.Insert(...)
+func (c *computeService) SubnetworksInsert(ctx context.Context, project string, region string, subnetwork *compute.Subnetwork) (*compute.Operation, error) {
+	return c.service.Subnetworks.Insert(project, region, subnetwork).Context(ctx).Do()
+}
+
+// SubnetworksDelete is a pass through wrapper for compute.Service.Subnetworks.Delete(...)
+func (c *computeService) SubnetworksDelete(ctx context.Context, project string, region string, subnetwork string) (*compute.Operation, error) {
+	return c.service.Subnetworks.Delete(project, region, subnetwork).Context(ctx).Do()
+}
+
+// RoutersGet is a pass through wrapper for compute.Service.Routers.Get(...)
+func (c *computeService) RoutersGet(ctx context.Context, project string, region string, router string) (*compute.Router, error) {
+	return c.service.Routers.Get(project, region, router).Context(ctx).Do()
+}
+
+// RoutersInsert is a pass through wrapper for compute.Service.Routers.Insert(...)
+func (c *computeService) RoutersInsert(ctx context.Context, project string, region string, router *compute.Router) (*compute.Operation, error) {
+	return c.service.Routers.Insert(project, region, router).Context(ctx).Do()
+}
+
+// RoutersPatch is a pass through wrapper for compute.Service.Routers.Patch(...)
+func (c *computeService) RoutersPatch(ctx context.Context, project string, region string, router string, patch *compute.Router) (*compute.Operation, error) {
+	return c.service.Routers.Patch(project, region, router, patch).Context(ctx).Do()
+}
+
+// RoutersDelete is a pass through wrapper for compute.Service.Routers.Delete(...)
+func (c *computeService) RoutersDelete(ctx context.Context, project string, region string, router string) (*compute.Operation, error) {
+	return c.service.Routers.Delete(project, region, router).Context(ctx).Do()
+}
+
+// FirewallsDelete is a pass through wrapper for compute.Service.Firewalls.Delete(...)
+func (c *computeService) FirewallsDelete(ctx context.Context, project string, firewall string) (*compute.Operation, error) {
+	return c.service.Firewalls.Delete(project, firewall).Context(ctx).Do()
+}
+
+// ProjectsGet is a pass through wrapper for compute.Service.Projects.Get(...)
+func (c *computeService) ProjectsGet(ctx context.Context, project string) (*compute.Project, error) {
+	return c.service.Projects.Get(project).Context(ctx).Do()
+}
+
+// ProjectsSetCommonInstanceMetadata is a pass through wrapper for compute.Service.Projects.SetCommonInstanceMetadata(...)
+func (c *computeService) ProjectsSetCommonInstanceMetadata(ctx context.Context, project string, metadata *compute.Metadata) (*compute.Operation, error) {
+	return c.service.Projects.SetCommonInstanceMetadata(project, metadata).Context(ctx).Do()
+}
+
+// RegionsGet is a pass through wrapper for compute.Service.Regions.Get(...)
+func (c *computeService) RegionsGet(ctx context.Context, project string, region string) (*compute.Region, error) {
+	return c.service.Regions.Get(project, region).Context(ctx).Do()
+}
+
+// InstancesAddResourcePolicies calls the compute.instances.addResourcePolicies REST method, which the vendored compute
+// client does not support yet.
+func (c *computeService) InstancesAddResourcePolicies(ctx context.Context, project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error) {
+	return c.doOperationRequest(ctx, "POST", "{project}/zones/{zone}/instances/{instance}/addResourcePolicies", map[string]string{
+		"project":  project,
+		"zone":     zone,
+		"instance": instance,
+	}, map[string][]string{"resourcePolicies": resourcePolicies})
+}
+
+// InstancesRemoveResourcePolicies calls the compute.instances.removeResourcePolicies REST method, which the vendored
+// compute client does not support yet.
+func (c *computeService) InstancesRemoveResourcePolicies(ctx context.Context, project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error) {
+	return c.doOperationRequest(ctx, "POST", "{project}/zones/{zone}/instances/{instance}/removeResourcePolicies", map[string]string{
+		"project":  project,
+		"zone":     zone,
+		"instance": instance,
+	}, map[string][]string{"resourcePolicies": resourcePolicies})
+}
+
+// AcceleratorTypesGet is a pass through wrapper for compute.Service.AcceleratorTypes.Get(...)
+func (c *computeService) AcceleratorTypesGet(ctx context.Context, project string, zone string, acceleratorType string) (*compute.AcceleratorType, error) {
+	return c.service.AcceleratorTypes.Get(project, zone, acceleratorType).Context(ctx).Do()
+}
+
+// AcceleratorTypesAggregatedList is a wrapper for compute.Service.AcceleratorTypes.AggregatedList(...)
+// It iterates over all result pages and returns the accelerator types of all zones matching the filter.
+func (c *computeService) AcceleratorTypesAggregatedList(ctx context.Context, project string, filter string) ([]*compute.AcceleratorType, error) {
+	var acceleratorTypes []*compute.AcceleratorType
+	call := c.service.AcceleratorTypes.AggregatedList(project)
+	if filter != "" {
+		call = call.Filter(filter)
+	}
+	err := call.Pages(ctx, func(list *compute.AcceleratorTypeAggregatedList) error {
+		for _, scopedList := range list.Items {
+			acceleratorTypes = append(acceleratorTypes, scopedList.AcceleratorTypes...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acceleratorTypes, nil
+}
+
+// DisksInsertMultiWriter calls the compute.disks.insert REST method with the disk in multi-writer mode, which
+// the vendored compute.Disk lacks: persistent disks set multiWriter, Hyperdisks the READ_WRITE_MANY access mode.
+func (c *computeService) DisksInsertMultiWriter(ctx context.Context, project string, zone string, disk *compute.Disk) (*compute.Operation, error) {
+	data, err := json.Marshal(disk)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	if strings.Contains(path.Base(disk.Type), "hyperdisk") {
+		body["accessMode"] = "READ_WRITE_MANY"
+	} else {
+		body["multiWriter"] = true
+	}
+	return c.doOperationRequest(ctx, "POST", "{project}/zones/{zone}/disks", map[string]string{
+		"project": project,
+		"zone":    zone,
+	}, body)
+}
+
+// InstancesDetachDisk is a pass through wrapper for compute.Service.Instances.DetachDisk(...)
+func (c *computeService) InstancesDetachDisk(ctx context.Context, project string, zone string, instance string, deviceName string) (*compute.Operation, error) {
+	return c.service.Instances.DetachDisk(project, zone, instance, deviceName).Context(ctx).Do()
+}
+
+// InstancesAttachDisk is a pass through wrapper for compute.Service.Instances.AttachDisk(...)
+func (c *computeService) InstancesAttachDisk(ctx context.Context, project string, zone string, instance string, disk *compute.AttachedDisk) (*compute.Operation, error) {
+	return c.service.Instances.AttachDisk(project, zone, instance, disk).Context(ctx).Do()
+}
+
+// DisksCreateSnapshot is a pass through wrapper for compute.Service.Disks.CreateSnapshot(...)
+func (c *computeService) DisksCreateSnapshot(ctx context.Context, project string, zone string, disk string, snapshot *compute.Snapshot) (*compute.Operation, error) {
+	return c.service.Disks.CreateSnapshot(project, zone, disk, snapshot).Context(ctx).Do()
 }
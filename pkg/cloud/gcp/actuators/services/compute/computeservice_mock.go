@@ -1,42 +1,1939 @@
 package computeservice
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
 	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 )
 
-type GCPComputeServiceMock struct {
-	mockInstancesInsert   func(project string, zone string, instance *compute.Instance) (*compute.Operation, error)
-	mockZoneOperationsGet func(project string, zone string, operation string) (*compute.Operation, error)
+type GCPComputeServiceMock struct {
+	// instances tracks the inserted instances by project/zone/instance.
+	instances map[string]*compute.Instance
+	// disks tracks the disks of the inserted instances that are not deleted with them, by project/zone/disk.
+	disks map[string]bool
+	// diskLabels tracks the labels of the disks created with the inserted instances, by project/zone/disk.
+	diskLabels map[string]map[string]string
+	// addresses tracks the reserved regional addresses by project/region/address.
+	addresses map[string]*compute.Address
+	// zoneOperations tracks the running operations added by AddPendingInsert by project/zone/operation.
+	zoneOperations map[string]*compute.Operation
+	// pendingInserts holds the instances inserted when the tracked operations complete by project/zone/operation.
+	pendingInserts map[string]*compute.Instance
+	// targetPools tracks the target pools by project/region/targetPool. Any target pool exists.
+	targetPools map[string]*compute.TargetPool
+	// instanceGroups tracks the inserted instance groups by project/zone/instanceGroup.
+	instanceGroups map[string]*compute.InstanceGroup
+	// instanceGroupMembers tracks the instance URLs of the instance groups by project/zone/instanceGroup.
+	instanceGroupMembers map[string][]string
+	// firewalls tracks the inserted firewall rules by project/firewall.
+	firewalls map[string]*compute.Firewall
+	// routers tracks the routers by project/region.
+	routers map[string][]*compute.Router
+	// networks tracks the inserted networks by project/network.
+	networks map[string]*compute.Network
+	// subnetworks tracks the inserted subnetworks by project/region/subnetwork.
+	subnetworks map[string]*compute.Subnetwork
+	// networkProjects are the projects networks were ever inserted in.
+	networkProjects map[string]bool
+	// projectMetadata tracks the common instance metadata of projects by project.
+	projectMetadata map[string]*compute.Metadata
+	// acceleratorTypeZones holds the zones accelerator types are offered in by project/type, all zones when unset.
+	acceleratorTypeZones map[string][]string
+	// regionQuotas holds the quotas of regions by project/region.
+	regionQuotas map[string][]*compute.Quota
+	// projectMetadataUpdates records the common instance metadata requests of projects by project.
+	projectMetadataUpdates map[string][]*compute.Metadata
+	// instanceParams records the params of the instances inserted with params by project/zone/instance.
+	instanceParams map[string]*InstanceParams
+	// instanceResourcePolicies holds the resource policies attached to instances by project/zone/instance.
+	instanceResourcePolicies map[string][]string
+	// snapshots holds the snapshots created from disks by project/snapshot.
+	snapshots map[string]*compute.Snapshot
+	// multiWriterDisks tracks the disks created in multi-writer mode by project/zone/disk.
+	multiWriterDisks map[string]bool
+	// imageArchitectures holds the architecture of images and image families by project/image, unset when missing.
+	imageArchitectures map[string]string
+	// instanceHealth holds the health state reported by backend services by instance URL, HEALTHY when unset.
+	instanceHealth map[string]string
+	// failures holds the failures injected by method name.
+	failures map[string]*failure
+	// operationError is set on the operations returned by ZoneOperationsGet when not nil.
+	operationError *compute.OperationError
+
+	mockInstancesInsert                   func(project string, zone string, instance *compute.Instance) (*compute.Operation, error)
+	mockInstancesInsertWithParams         func(project string, zone string, instance *compute.Instance, params *InstanceParams) (*compute.Operation, error)
+	mockZoneOperationsGet                 func(project string, zone string, operation string) (*compute.Operation, error)
+	mockMachineTypesGet                   func(project string, zone string, machineType string) (*compute.MachineType, error)
+	mockImagesGet                         func(project string, image string) (*compute.Image, error)
+	mockImagesGetFromFamily               func(project string, family string) (*compute.Image, error)
+	mockImagesGetArchitecture             func(project string, image string, family bool) (string, error)
+	mockImagesListByLabels                func(project string, labels map[string]string) ([]*compute.Image, error)
+	mockSubnetworksGet                    func(project string, region string, subnetwork string) (*compute.Subnetwork, error)
+	mockDisksGet                          func(project string, zone string, disk string) (*compute.Disk, error)
+	mockDisksInsert                       func(project string, zone string, disk *compute.Disk) (*compute.Operation, error)
+	mockDisksDelete                       func(project string, zone string, disk string) (*compute.Operation, error)
+	mockDisksResize                       func(project string, zone string, disk string, request *compute.DisksResizeRequest) (*compute.Operation, error)
+	mockAddressesGet                      func(project string, region string, address string) (*compute.Address, error)
+	mockAddressesInsert                   func(project string, region string, address *compute.Address) (*compute.Operation, error)
+	mockAddressesDelete                   func(project string, region string, address string) (*compute.Operation, error)
+	mockGlobalAddressesGet                func(project string, address string) (*compute.Address, error)
+	mockGlobalAddressesInsert             func(project string, address *compute.Address) (*compute.Operation, error)
+	mockGlobalAddressesDelete             func(project string, address string) (*compute.Operation, error)
+	mockTargetPoolsGet                    func(project string, region string, targetPool string) (*compute.TargetPool, error)
+	mockTargetPoolsAddInstance            func(project string, region string, targetPool string, request *compute.TargetPoolsAddInstanceRequest) (*compute.Operation, error)
+	mockTargetPoolsRemoveInstance         func(project string, region string, targetPool string, request *compute.TargetPoolsRemoveInstanceRequest) (*compute.Operation, error)
+	mockInstanceGroupsGet                 func(project string, zone string, instanceGroup string) (*compute.InstanceGroup, error)
+	mockInstanceGroupsAddInstances        func(project string, zone string, instanceGroup string, request *compute.InstanceGroupsAddInstancesRequest) (*compute.Operation, error)
+	mockInstanceGroupsRemoveInstances     func(project string, zone string, instanceGroup string, request *compute.InstanceGroupsRemoveInstancesRequest) (*compute.Operation, error)
+	mockInstanceGroupsListInstances       func(project string, zone string, instanceGroup string, request *compute.InstanceGroupsListInstancesRequest) ([]*compute.InstanceWithNamedPorts, error)
+	mockInstanceTemplatesGet              func(project string, instanceTemplate string) (*compute.InstanceTemplate, error)
+	mockInstanceTemplatesInsert           func(project string, instanceTemplate *compute.InstanceTemplate) (*compute.Operation, error)
+	mockInstanceTemplatesDelete           func(project string, instanceTemplate string) (*compute.Operation, error)
+	mockInstancesGetSerialPortOutput      func(project string, zone string, instance string) (*compute.SerialPortOutput, error)
+	mockInstancesSetMetadata              func(project string, zone string, instance string, metadata *compute.Metadata) (*compute.Operation, error)
+	mockInstancesSetLabels                func(project string, zone string, instance string, request *compute.InstancesSetLabelsRequest) (*compute.Operation, error)
+	mockInstancesSetTags                  func(project string, zone string, instance string, tags *compute.Tags) (*compute.Operation, error)
+	mockInstancesSetMachineType           func(project string, zone string, instance string, request *compute.InstancesSetMachineTypeRequest) (*compute.Operation, error)
+	mockInstancesStop                     func(project string, zone string, instance string) (*compute.Operation, error)
+	mockInstancesStart                    func(project string, zone string, instance string) (*compute.Operation, error)
+	mockInstancesReset                    func(project string, zone string, instance string) (*compute.Operation, error)
+	mockInstancesSuspend                  func(project string, zone string, instance string) (*compute.Operation, error)
+	mockInstancesResume                   func(project string, zone string, instance string) (*compute.Operation, error)
+	mockInstancesAggregatedList           func(project string, filter string) ([]*compute.Instance, error)
+	mockInstancesGet                      func(project string, zone string, instance string) (*compute.Instance, error)
+	mockInstancesDelete                   func(project string, zone string, instance string) (*compute.Operation, error)
+	mockInstancesList                     func(project string, zone string, filter string) ([]*compute.Instance, error)
+	mockAddressesList                     func(project string, region string, filter string) ([]*compute.Address, error)
+	mockGlobalAddressesList               func(project string, filter string) ([]*compute.Address, error)
+	mockZonesList                         func(project string, filter string) ([]*compute.Zone, error)
+	mockRegionOperationsGet               func(project string, region string, operation string) (*compute.Operation, error)
+	mockInstanceGroupsInsert              func(project string, zone string, instanceGroup *compute.InstanceGroup) (*compute.Operation, error)
+	mockFirewallsGet                      func(project string, firewall string) (*compute.Firewall, error)
+	mockFirewallsInsert                   func(project string, firewall *compute.Firewall) (*compute.Operation, error)
+	mockFirewallsPatch                    func(project string, firewall string, patch *compute.Firewall) (*compute.Operation, error)
+	mockGlobalOperationsGet               func(project string, operation string) (*compute.Operation, error)
+	mockRoutersList                       func(project string, region string) ([]*compute.Router, error)
+	mockRegionBackendServicesGetHealth    func(project string, region string, backendService string, group *compute.ResourceGroupReference) (*compute.BackendServiceGroupHealth, error)
+	mockInstancesSetDeletionProtection    func(project string, zone string, instance string, deletionProtection bool) (*compute.Operation, error)
+	mockZoneOperationsList                func(project string, zone string, filter string) ([]*compute.Operation, error)
+	mockNetworksGet                       func(project string, network string) (*compute.Network, error)
+	mockNetworksInsert                    func(project string, network *compute.Network) (*compute.Operation, error)
+	mockNetworksDelete                    func(project string, network string) (*compute.Operation, error)
+	mockSubnetworksInsert                 func(project string, region string, subnetwork *compute.Subnetwork) (*compute.Operation, error)
+	mockSubnetworksDelete                 func(project string, region string, subnetwork string) (*compute.Operation, error)
+	mockRoutersGet                        func(project string, region string, router string) (*compute.Router, error)
+	mockRoutersInsert                     func(project string, region string, router *compute.Router) (*compute.Operation, error)
+	mockRoutersPatch                      func(project string, region string, router string, patch *compute.Router) (*compute.Operation, error)
+	mockRoutersDelete                     func(project string, region string, router string) (*compute.Operation, error)
+	mockFirewallsDelete                   func(project string, firewall string) (*compute.Operation, error)
+	mockProjectsGet                       func(project string) (*compute.Project, error)
+	mockProjectsSetCommonInstanceMetadata func(project string, metadata *compute.Metadata) (*compute.Operation, error)
+	mockRegionsGet                        func(project string, region string) (*compute.Region, error)
+	mockInstancesAddResourcePolicies      func(project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error)
+	mockInstancesRemoveResourcePolicies   func(project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error)
+	mockAcceleratorTypesGet               func(project string, zone string, acceleratorType string) (*compute.AcceleratorType, error)
+	mockAcceleratorTypesAggregatedList    func(project string, filter string) ([]*compute.AcceleratorType, error)
+	mockDisksInsertMultiWriter            func(project string, zone string, disk *compute.Disk) (*compute.Operation, error)
+	mockInstancesDetachDisk               func(project string, zone string, instance string, deviceName string) (*compute.Operation, error)
+	mockInstancesAttachDisk               func(project string, zone string, instance string, disk *compute.AttachedDisk) (*compute.Operation, error)
+	mockDisksCreateSnapshot               func(project string, zone string, disk string, snapshot *compute.Snapshot) (*compute.Operation, error)
+}
+
+func (c *GCPComputeServiceMock) InstancesInsert(ctx context.Context, project string, zone string, instance *compute.Instance) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesInsert == nil {
+		return nil, nil
+	}
+	return c.mockInstancesInsert(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesInsertWithParams(ctx context.Context, project string, zone string, instance *compute.Instance, params *InstanceParams) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesInsertWithParams"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesInsertWithParams == nil {
+		return nil, nil
+	}
+	return c.mockInstancesInsertWithParams(project, zone, instance, params)
+}
+
+func (c *GCPComputeServiceMock) ZoneOperationsGet(ctx context.Context, project string, zone string, operation string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "ZoneOperationsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockZoneOperationsGet == nil {
+		return nil, nil
+	}
+	op, err := c.mockZoneOperationsGet(project, zone, operation)
+	if err == nil && op != nil && c.operationError != nil {
+		op.Status = "DONE"
+		op.Error = c.operationError
+	}
+	return op, err
+}
+
+func (c *GCPComputeServiceMock) MachineTypesGet(ctx context.Context, project string, zone string, machineType string) (*compute.MachineType, error) {
+	if err := c.injectedFailure(ctx, "MachineTypesGet"); err != nil {
+		return nil, err
+	}
+	if c.mockMachineTypesGet == nil {
+		return nil, nil
+	}
+	return c.mockMachineTypesGet(project, zone, machineType)
+}
+
+func (c *GCPComputeServiceMock) ImagesGet(ctx context.Context, project string, image string) (*compute.Image, error) {
+	if err := c.injectedFailure(ctx, "ImagesGet"); err != nil {
+		return nil, err
+	}
+	if c.mockImagesGet == nil {
+		return nil, nil
+	}
+	return c.mockImagesGet(project, image)
+}
+
+func (c *GCPComputeServiceMock) ImagesGetFromFamily(ctx context.Context, project string, family string) (*compute.Image, error) {
+	if err := c.injectedFailure(ctx, "ImagesGetFromFamily"); err != nil {
+		return nil, err
+	}
+	if c.mockImagesGetFromFamily == nil {
+		return nil, nil
+	}
+	return c.mockImagesGetFromFamily(project, family)
+}
+
+func (c *GCPComputeServiceMock) ImagesGetArchitecture(ctx context.Context, project string, image string, family bool) (string, error) {
+	if err := c.injectedFailure(ctx, "ImagesGetArchitecture"); err != nil {
+		return "", err
+	}
+	if c.mockImagesGetArchitecture == nil {
+		return "", nil
+	}
+	return c.mockImagesGetArchitecture(project, image, family)
+}
+
+func (c *GCPComputeServiceMock) ImagesListByLabels(ctx context.Context, project string, labels map[string]string) ([]*compute.Image, error) {
+	if err := c.injectedFailure(ctx, "ImagesListByLabels"); err != nil {
+		return nil, err
+	}
+	if c.mockImagesListByLabels == nil {
+		return nil, nil
+	}
+	return c.mockImagesListByLabels(project, labels)
+}
+
+func (c *GCPComputeServiceMock) SubnetworksGet(ctx context.Context, project string, region string, subnetwork string) (*compute.Subnetwork, error) {
+	if err := c.injectedFailure(ctx, "SubnetworksGet"); err != nil {
+		return nil, err
+	}
+	if c.mockSubnetworksGet == nil {
+		return nil, nil
+	}
+	return c.mockSubnetworksGet(project, region, subnetwork)
+}
+
+func (c *GCPComputeServiceMock) DisksGet(ctx context.Context, project string, zone string, disk string) (*compute.Disk, error) {
+	if err := c.injectedFailure(ctx, "DisksGet"); err != nil {
+		return nil, err
+	}
+	if c.mockDisksGet == nil {
+		return nil, nil
+	}
+	return c.mockDisksGet(project, zone, disk)
+}
+
+func (c *GCPComputeServiceMock) DisksInsert(ctx context.Context, project string, zone string, disk *compute.Disk) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "DisksInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockDisksInsert == nil {
+		return nil, nil
+	}
+	return c.mockDisksInsert(project, zone, disk)
+}
+
+func (c *GCPComputeServiceMock) DisksDelete(ctx context.Context, project string, zone string, disk string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "DisksDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockDisksDelete == nil {
+		return nil, nil
+	}
+	return c.mockDisksDelete(project, zone, disk)
+}
+
+func (c *GCPComputeServiceMock) DisksResize(ctx context.Context, project string, zone string, disk string, request *compute.DisksResizeRequest) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "DisksResize"); err != nil {
+		return nil, err
+	}
+	if c.mockDisksResize == nil {
+		return nil, nil
+	}
+	return c.mockDisksResize(project, zone, disk, request)
+}
+
+func (c *GCPComputeServiceMock) AddressesGet(ctx context.Context, project string, region string, address string) (*compute.Address, error) {
+	if err := c.injectedFailure(ctx, "AddressesGet"); err != nil {
+		return nil, err
+	}
+	if c.mockAddressesGet == nil {
+		return nil, nil
+	}
+	return c.mockAddressesGet(project, region, address)
+}
+
+func (c *GCPComputeServiceMock) AddressesInsert(ctx context.Context, project string, region string, address *compute.Address) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "AddressesInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockAddressesInsert == nil {
+		return nil, nil
+	}
+	return c.mockAddressesInsert(project, region, address)
+}
+
+func (c *GCPComputeServiceMock) AddressesDelete(ctx context.Context, project string, region string, address string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "AddressesDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockAddressesDelete == nil {
+		return nil, nil
+	}
+	return c.mockAddressesDelete(project, region, address)
+}
+
+func (c *GCPComputeServiceMock) GlobalAddressesGet(ctx context.Context, project string, address string) (*compute.Address, error) {
+	if err := c.injectedFailure(ctx, "GlobalAddressesGet"); err != nil {
+		return nil, err
+	}
+	if c.mockGlobalAddressesGet == nil {
+		return nil, nil
+	}
+	return c.mockGlobalAddressesGet(project, address)
+}
+
+func (c *GCPComputeServiceMock) GlobalAddressesInsert(ctx context.Context, project string, address *compute.Address) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "GlobalAddressesInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockGlobalAddressesInsert == nil {
+		return nil, nil
+	}
+	return c.mockGlobalAddressesInsert(project, address)
+}
+
+func (c *GCPComputeServiceMock) GlobalAddressesDelete(ctx context.Context, project string, address string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "GlobalAddressesDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockGlobalAddressesDelete == nil {
+		return nil, nil
+	}
+	return c.mockGlobalAddressesDelete(project, address)
+}
+
+func (c *GCPComputeServiceMock) TargetPoolsGet(ctx context.Context, project string, region string, targetPool string) (*compute.TargetPool, error) {
+	if err := c.injectedFailure(ctx, "TargetPoolsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockTargetPoolsGet == nil {
+		return nil, nil
+	}
+	return c.mockTargetPoolsGet(project, region, targetPool)
+}
+
+func (c *GCPComputeServiceMock) TargetPoolsAddInstance(ctx context.Context, project string, region string, targetPool string, request *compute.TargetPoolsAddInstanceRequest) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "TargetPoolsAddInstance"); err != nil {
+		return nil, err
+	}
+	if c.mockTargetPoolsAddInstance == nil {
+		return nil, nil
+	}
+	return c.mockTargetPoolsAddInstance(project, region, targetPool, request)
+}
+
+func (c *GCPComputeServiceMock) TargetPoolsRemoveInstance(ctx context.Context, project string, region string, targetPool string, request *compute.TargetPoolsRemoveInstanceRequest) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "TargetPoolsRemoveInstance"); err != nil {
+		return nil, err
+	}
+	if c.mockTargetPoolsRemoveInstance == nil {
+		return nil, nil
+	}
+	return c.mockTargetPoolsRemoveInstance(project, region, targetPool, request)
+}
+
+func (c *GCPComputeServiceMock) InstanceGroupsGet(ctx context.Context, project string, zone string, instanceGroup string) (*compute.InstanceGroup, error) {
+	if err := c.injectedFailure(ctx, "InstanceGroupsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceGroupsGet == nil {
+		return nil, nil
+	}
+	return c.mockInstanceGroupsGet(project, zone, instanceGroup)
+}
+
+func (c *GCPComputeServiceMock) InstanceGroupsAddInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsAddInstancesRequest) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstanceGroupsAddInstances"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceGroupsAddInstances == nil {
+		return nil, nil
+	}
+	return c.mockInstanceGroupsAddInstances(project, zone, instanceGroup, request)
+}
+
+func (c *GCPComputeServiceMock) InstanceGroupsRemoveInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsRemoveInstancesRequest) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstanceGroupsRemoveInstances"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceGroupsRemoveInstances == nil {
+		return nil, nil
+	}
+	return c.mockInstanceGroupsRemoveInstances(project, zone, instanceGroup, request)
+}
+
+func (c *GCPComputeServiceMock) InstanceGroupsListInstances(ctx context.Context, project string, zone string, instanceGroup string, request *compute.InstanceGroupsListInstancesRequest) ([]*compute.InstanceWithNamedPorts, error) {
+	if err := c.injectedFailure(ctx, "InstanceGroupsListInstances"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceGroupsListInstances == nil {
+		return nil, nil
+	}
+	return c.mockInstanceGroupsListInstances(project, zone, instanceGroup, request)
+}
+
+func (c *GCPComputeServiceMock) InstanceTemplatesGet(ctx context.Context, project string, instanceTemplate string) (*compute.InstanceTemplate, error) {
+	if err := c.injectedFailure(ctx, "InstanceTemplatesGet"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceTemplatesGet == nil {
+		return nil, nil
+	}
+	return c.mockInstanceTemplatesGet(project, instanceTemplate)
+}
+
+func (c *GCPComputeServiceMock) InstanceTemplatesInsert(ctx context.Context, project string, instanceTemplate *compute.InstanceTemplate) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstanceTemplatesInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceTemplatesInsert == nil {
+		return nil, nil
+	}
+	return c.mockInstanceTemplatesInsert(project, instanceTemplate)
+}
+
+func (c *GCPComputeServiceMock) InstanceTemplatesDelete(ctx context.Context, project string, instanceTemplate string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstanceTemplatesDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceTemplatesDelete == nil {
+		return nil, nil
+	}
+	return c.mockInstanceTemplatesDelete(project, instanceTemplate)
+}
+
+func (c *GCPComputeServiceMock) InstancesGetSerialPortOutput(ctx context.Context, project string, zone string, instance string) (*compute.SerialPortOutput, error) {
+	if err := c.injectedFailure(ctx, "InstancesGetSerialPortOutput"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesGetSerialPortOutput == nil {
+		return nil, nil
+	}
+	return c.mockInstancesGetSerialPortOutput(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesSetMetadata(ctx context.Context, project string, zone string, instance string, metadata *compute.Metadata) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesSetMetadata"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesSetMetadata == nil {
+		return nil, nil
+	}
+	return c.mockInstancesSetMetadata(project, zone, instance, metadata)
+}
+
+func (c *GCPComputeServiceMock) InstancesSetLabels(ctx context.Context, project string, zone string, instance string, request *compute.InstancesSetLabelsRequest) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesSetLabels"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesSetLabels == nil {
+		return nil, nil
+	}
+	return c.mockInstancesSetLabels(project, zone, instance, request)
+}
+
+func (c *GCPComputeServiceMock) InstancesSetTags(ctx context.Context, project string, zone string, instance string, tags *compute.Tags) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesSetTags"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesSetTags == nil {
+		return nil, nil
+	}
+	return c.mockInstancesSetTags(project, zone, instance, tags)
+}
+
+func (c *GCPComputeServiceMock) InstancesSetMachineType(ctx context.Context, project string, zone string, instance string, request *compute.InstancesSetMachineTypeRequest) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesSetMachineType"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesSetMachineType == nil {
+		return nil, nil
+	}
+	return c.mockInstancesSetMachineType(project, zone, instance, request)
+}
+
+func (c *GCPComputeServiceMock) InstancesStop(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesStop"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesStop == nil {
+		return nil, nil
+	}
+	return c.mockInstancesStop(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesStart(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesStart"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesStart == nil {
+		return nil, nil
+	}
+	return c.mockInstancesStart(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesReset(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesReset"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesReset == nil {
+		return nil, nil
+	}
+	return c.mockInstancesReset(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesSuspend(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesSuspend"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesSuspend == nil {
+		return nil, nil
+	}
+	return c.mockInstancesSuspend(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesResume(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesResume"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesResume == nil {
+		return nil, nil
+	}
+	return c.mockInstancesResume(project, zone, instance)
+}
+
+// AddPendingInsert adds a running insert operation of the instance, which inserts the instance once polled.
+func (c *GCPComputeServiceMock) AddPendingInsert(project string, zone string, instance *compute.Instance) *compute.Operation {
+	inserted := *instance
+	inserted.Zone = zone
+	inserted.Status = "RUNNING"
+	inserted.SelfLink = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s", project, zone, instance.Name)
+	operation := &compute.Operation{
+		Name:          "operation-insert-" + instance.Name,
+		OperationType: "insert",
+		Status:        "RUNNING",
+		TargetLink:    inserted.SelfLink,
+	}
+	key := path.Join(project, zone, operation.Name)
+	c.zoneOperations[key] = operation
+	c.pendingInserts[key] = &inserted
+	return operation
+}
+
+// Address returns a reserved regional address, or nil if it does not exist.
+func (c *GCPComputeServiceMock) Address(project string, region string, address string) *compute.Address {
+	return c.addresses[path.Join(project, region, address)]
+}
+
+// HasDisk returns true if an inserted instance created the disk and the disk was not deleted.
+func (c *GCPComputeServiceMock) HasDisk(project string, zone string, disk string) bool {
+	return c.disks[path.Join(project, zone, disk)]
+}
+
+// IsMultiWriterDisk returns true if the disk was created in multi-writer mode.
+func (c *GCPComputeServiceMock) IsMultiWriterDisk(project string, zone string, disk string) bool {
+	return c.multiWriterDisks[path.Join(project, zone, disk)]
+}
+
+// Snapshot returns a snapshot created from a disk, nil if it does not exist.
+func (c *GCPComputeServiceMock) Snapshot(project string, snapshot string) *compute.Snapshot {
+	return c.snapshots[path.Join(project, snapshot)]
+}
+
+// InstanceStatus returns the status of an inserted instance, or an empty string if it does not exist.
+func (c *GCPComputeServiceMock) InstanceStatus(project string, zone string, instance string) string {
+	if instance, ok := c.instances[path.Join(project, zone, instance)]; ok {
+		return instance.Status
+	}
+	return ""
+}
+
+// InstanceParams returns the params an instance was inserted with, nil when it was inserted without.
+func (c *GCPComputeServiceMock) InstanceParams(project string, zone string, instance string) *InstanceParams {
+	return c.instanceParams[path.Join(project, zone, instance)]
+}
+
+// InstanceResourcePolicies returns the resource policies attached to the instance.
+func (c *GCPComputeServiceMock) InstanceResourcePolicies(project string, zone string, instance string) []string {
+	return c.instanceResourcePolicies[path.Join(project, zone, instance)]
+}
+
+func (c *GCPComputeServiceMock) InstancesAggregatedList(ctx context.Context, project string, filter string) ([]*compute.Instance, error) {
+	if err := c.injectedFailure(ctx, "InstancesAggregatedList"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesAggregatedList == nil {
+		return nil, nil
+	}
+	return c.mockInstancesAggregatedList(project, filter)
+}
+
+func (c *GCPComputeServiceMock) InstancesGet(ctx context.Context, project string, zone string, instance string) (*compute.Instance, error) {
+	if err := c.injectedFailure(ctx, "InstancesGet"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesGet == nil {
+		return nil, nil
+	}
+	return c.mockInstancesGet(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesDelete(ctx context.Context, project string, zone string, instance string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesDelete == nil {
+		return nil, nil
+	}
+	return c.mockInstancesDelete(project, zone, instance)
+}
+
+func (c *GCPComputeServiceMock) InstancesList(ctx context.Context, project string, zone string, filter string) ([]*compute.Instance, error) {
+	if err := c.injectedFailure(ctx, "InstancesList"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesList == nil {
+		return nil, nil
+	}
+	return c.mockInstancesList(project, zone, filter)
+}
+
+func (c *GCPComputeServiceMock) AddressesList(ctx context.Context, project string, region string, filter string) ([]*compute.Address, error) {
+	if err := c.injectedFailure(ctx, "AddressesList"); err != nil {
+		return nil, err
+	}
+	if c.mockAddressesList == nil {
+		return nil, nil
+	}
+	return c.mockAddressesList(project, region, filter)
+}
+
+func (c *GCPComputeServiceMock) GlobalAddressesList(ctx context.Context, project string, filter string) ([]*compute.Address, error) {
+	if err := c.injectedFailure(ctx, "GlobalAddressesList"); err != nil {
+		return nil, err
+	}
+	if c.mockGlobalAddressesList == nil {
+		return nil, nil
+	}
+	return c.mockGlobalAddressesList(project, filter)
+}
+
+func (c *GCPComputeServiceMock) ZonesList(ctx context.Context, project string, filter string) ([]*compute.Zone, error) {
+	if err := c.injectedFailure(ctx, "ZonesList"); err != nil {
+		return nil, err
+	}
+	if c.mockZonesList == nil {
+		return nil, nil
+	}
+	return c.mockZonesList(project, filter)
+}
+
+func (c *GCPComputeServiceMock) RegionOperationsGet(ctx context.Context, project string, region string, operation string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "RegionOperationsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockRegionOperationsGet == nil {
+		return nil, nil
+	}
+	return c.mockRegionOperationsGet(project, region, operation)
+}
+
+func (c *GCPComputeServiceMock) InstanceGroupsInsert(ctx context.Context, project string, zone string, instanceGroup *compute.InstanceGroup) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstanceGroupsInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockInstanceGroupsInsert == nil {
+		return nil, nil
+	}
+	return c.mockInstanceGroupsInsert(project, zone, instanceGroup)
+}
+
+func (c *GCPComputeServiceMock) FirewallsGet(ctx context.Context, project string, firewall string) (*compute.Firewall, error) {
+	if err := c.injectedFailure(ctx, "FirewallsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockFirewallsGet == nil {
+		return nil, nil
+	}
+	return c.mockFirewallsGet(project, firewall)
+}
+
+func (c *GCPComputeServiceMock) FirewallsInsert(ctx context.Context, project string, firewall *compute.Firewall) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "FirewallsInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockFirewallsInsert == nil {
+		return nil, nil
+	}
+	return c.mockFirewallsInsert(project, firewall)
+}
+
+func (c *GCPComputeServiceMock) FirewallsPatch(ctx context.Context, project string, firewall string, patch *compute.Firewall) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "FirewallsPatch"); err != nil {
+		return nil, err
+	}
+	if c.mockFirewallsPatch == nil {
+		return nil, nil
+	}
+	return c.mockFirewallsPatch(project, firewall, patch)
+}
+
+func (c *GCPComputeServiceMock) GlobalOperationsGet(ctx context.Context, project string, operation string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "GlobalOperationsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockGlobalOperationsGet == nil {
+		return nil, nil
+	}
+	return c.mockGlobalOperationsGet(project, operation)
+}
+
+func (c *GCPComputeServiceMock) RoutersList(ctx context.Context, project string, region string) ([]*compute.Router, error) {
+	if err := c.injectedFailure(ctx, "RoutersList"); err != nil {
+		return nil, err
+	}
+	if c.mockRoutersList == nil {
+		return nil, nil
+	}
+	return c.mockRoutersList(project, region)
+}
+
+func (c *GCPComputeServiceMock) RegionBackendServicesGetHealth(ctx context.Context, project string, region string, backendService string, group *compute.ResourceGroupReference) (*compute.BackendServiceGroupHealth, error) {
+	if err := c.injectedFailure(ctx, "RegionBackendServicesGetHealth"); err != nil {
+		return nil, err
+	}
+	if c.mockRegionBackendServicesGetHealth == nil {
+		return nil, nil
+	}
+	return c.mockRegionBackendServicesGetHealth(project, region, backendService, group)
+}
+
+func (c *GCPComputeServiceMock) InstancesSetDeletionProtection(ctx context.Context, project string, zone string, instance string, deletionProtection bool) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesSetDeletionProtection"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesSetDeletionProtection == nil {
+		return nil, nil
+	}
+	return c.mockInstancesSetDeletionProtection(project, zone, instance, deletionProtection)
+}
+
+func (c *GCPComputeServiceMock) ZoneOperationsList(ctx context.Context, project string, zone string, filter string) ([]*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "ZoneOperationsList"); err != nil {
+		return nil, err
+	}
+	if c.mockZoneOperationsList == nil {
+		return nil, nil
+	}
+	return c.mockZoneOperationsList(project, zone, filter)
+}
+
+func (c *GCPComputeServiceMock) NetworksGet(ctx context.Context, project string, network string) (*compute.Network, error) {
+	if err := c.injectedFailure(ctx, "NetworksGet"); err != nil {
+		return nil, err
+	}
+	if c.mockNetworksGet == nil {
+		return nil, nil
+	}
+	return c.mockNetworksGet(project, network)
+}
+
+func (c *GCPComputeServiceMock) NetworksInsert(ctx context.Context, project string, network *compute.Network) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "NetworksInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockNetworksInsert == nil {
+		return nil, nil
+	}
+	return c.mockNetworksInsert(project, network)
+}
+
+func (c *GCPComputeServiceMock) NetworksDelete(ctx context.Context, project string, network string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "NetworksDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockNetworksDelete == nil {
+		return nil, nil
+	}
+	return c.mockNetworksDelete(project, network)
+}
+
+func (c *GCPComputeServiceMock) SubnetworksInsert(ctx context.Context, project string, region string, subnetwork *compute.Subnetwork) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "SubnetworksInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockSubnetworksInsert == nil {
+		return nil, nil
+	}
+	return c.mockSubnetworksInsert(project, region, subnetwork)
+}
+
+func (c *GCPComputeServiceMock) SubnetworksDelete(ctx context.Context, project string, region string, subnetwork string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "SubnetworksDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockSubnetworksDelete == nil {
+		return nil, nil
+	}
+	return c.mockSubnetworksDelete(project, region, subnetwork)
+}
+
+func (c *GCPComputeServiceMock) RoutersGet(ctx context.Context, project string, region string, router string) (*compute.Router, error) {
+	if err := c.injectedFailure(ctx, "RoutersGet"); err != nil {
+		return nil, err
+	}
+	if c.mockRoutersGet == nil {
+		return nil, nil
+	}
+	return c.mockRoutersGet(project, region, router)
+}
+
+func (c *GCPComputeServiceMock) RoutersInsert(ctx context.Context, project string, region string, router *compute.Router) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "RoutersInsert"); err != nil {
+		return nil, err
+	}
+	if c.mockRoutersInsert == nil {
+		return nil, nil
+	}
+	return c.mockRoutersInsert(project, region, router)
+}
+
+func (c *GCPComputeServiceMock) RoutersPatch(ctx context.Context, project string, region string, router string, patch *compute.Router) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "RoutersPatch"); err != nil {
+		return nil, err
+	}
+	if c.mockRoutersPatch == nil {
+		return nil, nil
+	}
+	return c.mockRoutersPatch(project, region, router, patch)
+}
+
+func (c *GCPComputeServiceMock) RoutersDelete(ctx context.Context, project string, region string, router string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "RoutersDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockRoutersDelete == nil {
+		return nil, nil
+	}
+	return c.mockRoutersDelete(project, region, router)
+}
+
+func (c *GCPComputeServiceMock) FirewallsDelete(ctx context.Context, project string, firewall string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "FirewallsDelete"); err != nil {
+		return nil, err
+	}
+	if c.mockFirewallsDelete == nil {
+		return nil, nil
+	}
+	return c.mockFirewallsDelete(project, firewall)
+}
+
+func (c *GCPComputeServiceMock) ProjectsGet(ctx context.Context, project string) (*compute.Project, error) {
+	if err := c.injectedFailure(ctx, "ProjectsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockProjectsGet == nil {
+		return nil, nil
+	}
+	return c.mockProjectsGet(project)
+}
+
+func (c *GCPComputeServiceMock) ProjectsSetCommonInstanceMetadata(ctx context.Context, project string, metadata *compute.Metadata) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "ProjectsSetCommonInstanceMetadata"); err != nil {
+		return nil, err
+	}
+	if c.mockProjectsSetCommonInstanceMetadata == nil {
+		return nil, nil
+	}
+	return c.mockProjectsSetCommonInstanceMetadata(project, metadata)
+}
+
+func (c *GCPComputeServiceMock) RegionsGet(ctx context.Context, project string, region string) (*compute.Region, error) {
+	if err := c.injectedFailure(ctx, "RegionsGet"); err != nil {
+		return nil, err
+	}
+	if c.mockRegionsGet == nil {
+		return nil, nil
+	}
+	return c.mockRegionsGet(project, region)
+}
+
+func (c *GCPComputeServiceMock) InstancesAddResourcePolicies(ctx context.Context, project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesAddResourcePolicies"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesAddResourcePolicies == nil {
+		return nil, nil
+	}
+	return c.mockInstancesAddResourcePolicies(project, zone, instance, resourcePolicies)
+}
+
+func (c *GCPComputeServiceMock) InstancesRemoveResourcePolicies(ctx context.Context, project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesRemoveResourcePolicies"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesRemoveResourcePolicies == nil {
+		return nil, nil
+	}
+	return c.mockInstancesRemoveResourcePolicies(project, zone, instance, resourcePolicies)
 }
 
-func (c *GCPComputeServiceMock) InstancesInsert(project string, zone string, instance *compute.Instance) (*compute.Operation, error) {
-	if c.mockInstancesInsert == nil {
+func (c *GCPComputeServiceMock) AcceleratorTypesGet(ctx context.Context, project string, zone string, acceleratorType string) (*compute.AcceleratorType, error) {
+	if err := c.injectedFailure(ctx, "AcceleratorTypesGet"); err != nil {
+		return nil, err
+	}
+	if c.mockAcceleratorTypesGet == nil {
 		return nil, nil
 	}
-	return c.mockInstancesInsert(project, zone, instance)
+	return c.mockAcceleratorTypesGet(project, zone, acceleratorType)
 }
 
-func (c *GCPComputeServiceMock) ZoneOperationsGet(project string, zone string, operation string) (*compute.Operation, error) {
-	if c.mockZoneOperationsGet == nil {
+func (c *GCPComputeServiceMock) AcceleratorTypesAggregatedList(ctx context.Context, project string, filter string) ([]*compute.AcceleratorType, error) {
+	if err := c.injectedFailure(ctx, "AcceleratorTypesAggregatedList"); err != nil {
+		return nil, err
+	}
+	if c.mockAcceleratorTypesAggregatedList == nil {
+		return nil, nil
+	}
+	return c.mockAcceleratorTypesAggregatedList(project, filter)
+}
+
+func (c *GCPComputeServiceMock) DisksInsertMultiWriter(ctx context.Context, project string, zone string, disk *compute.Disk) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "DisksInsertMultiWriter"); err != nil {
+		return nil, err
+	}
+	if c.mockDisksInsertMultiWriter == nil {
+		return nil, nil
+	}
+	return c.mockDisksInsertMultiWriter(project, zone, disk)
+}
+
+func (c *GCPComputeServiceMock) InstancesDetachDisk(ctx context.Context, project string, zone string, instance string, deviceName string) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesDetachDisk"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesDetachDisk == nil {
+		return nil, nil
+	}
+	return c.mockInstancesDetachDisk(project, zone, instance, deviceName)
+}
+
+func (c *GCPComputeServiceMock) InstancesAttachDisk(ctx context.Context, project string, zone string, instance string, disk *compute.AttachedDisk) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "InstancesAttachDisk"); err != nil {
+		return nil, err
+	}
+	if c.mockInstancesAttachDisk == nil {
+		return nil, nil
+	}
+	return c.mockInstancesAttachDisk(project, zone, instance, disk)
+}
+
+func (c *GCPComputeServiceMock) DisksCreateSnapshot(ctx context.Context, project string, zone string, disk string, snapshot *compute.Snapshot) (*compute.Operation, error) {
+	if err := c.injectedFailure(ctx, "DisksCreateSnapshot"); err != nil {
+		return nil, err
+	}
+	if c.mockDisksCreateSnapshot == nil {
 		return nil, nil
 	}
-	return c.mockZoneOperationsGet(project, zone, operation)
+	return c.mockDisksCreateSnapshot(project, zone, disk, snapshot)
 }
 
 func NewComputeServiceMock() (*compute.Instance, *GCPComputeServiceMock) {
 	var receivedInstance compute.Instance
+	instances := map[string]*compute.Instance{}
+	disks := map[string]bool{}
+	diskLabels := map[string]map[string]string{}
+	addresses := map[string]*compute.Address{}
+	zoneOperations := map[string]*compute.Operation{}
+	pendingInserts := map[string]*compute.Instance{}
+	targetPools := map[string]*compute.TargetPool{}
+	instanceGroups := map[string]*compute.InstanceGroup{}
+	instanceGroupMembers := map[string][]string{}
+	firewalls := map[string]*compute.Firewall{}
+	routers := map[string][]*compute.Router{}
+	networks := map[string]*compute.Network{}
+	subnetworks := map[string]*compute.Subnetwork{}
+	networkProjects := map[string]bool{}
+	projectMetadata := map[string]*compute.Metadata{}
+	projectMetadataUpdates := map[string][]*compute.Metadata{}
+	acceleratorTypeZones := map[string][]string{}
+	regionQuotas := map[string][]*compute.Quota{}
+	instanceParams := map[string]*InstanceParams{}
+	instanceResourcePolicies := map[string][]string{}
+	snapshots := map[string]*compute.Snapshot{}
+	multiWriterDisks := map[string]bool{}
+	instanceHealth := map[string]string{}
+	imageArchitectures := map[string]string{}
+	// globalOperations are the global operations started by the mock, per project.
+	globalOperations := map[string]bool{}
+	globalOperation := func(project, name string) *compute.Operation {
+		globalOperations[path.Join(project, name)] = true
+		return &compute.Operation{
+			Name:   name,
+			Status: "DONE",
+		}
+	}
 	computeServiceMock := GCPComputeServiceMock{
+		instances:                instances,
+		disks:                    disks,
+		diskLabels:               diskLabels,
+		addresses:                addresses,
+		zoneOperations:           zoneOperations,
+		pendingInserts:           pendingInserts,
+		targetPools:              targetPools,
+		instanceGroups:           instanceGroups,
+		instanceGroupMembers:     instanceGroupMembers,
+		firewalls:                firewalls,
+		routers:                  routers,
+		networks:                 networks,
+		subnetworks:              subnetworks,
+		networkProjects:          networkProjects,
+		projectMetadata:          projectMetadata,
+		projectMetadataUpdates:   projectMetadataUpdates,
+		acceleratorTypeZones:     acceleratorTypeZones,
+		regionQuotas:             regionQuotas,
+		instanceParams:           instanceParams,
+		instanceResourcePolicies: instanceResourcePolicies,
+		snapshots:                snapshots,
+		multiWriterDisks:         multiWriterDisks,
+		instanceHealth:           instanceHealth,
+		imageArchitectures:       imageArchitectures,
 		mockInstancesInsert: func(project string, zone string, instance *compute.Instance) (*compute.Operation, error) {
 			receivedInstance = *instance
+			key := path.Join(project, zone, instance.Name)
+			if _, ok := instances[key]; ok {
+				return nil, alreadyExistsError("instance", key)
+			}
+			inserted := *instance
+			inserted.Zone = zone
+			inserted.Status = "PROVISIONING"
+			inserted.SelfLink = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s", project, zone, instance.Name)
+			// GCP names the disks created with the instance and reports their URL, the boot disk after the instance.
+			inserted.Disks = nil
+			for _, disk := range instance.Disks {
+				attached := *disk
+				if attached.Source == "" && attached.InitializeParams != nil {
+					name := attached.InitializeParams.DiskName
+					if name == "" && attached.Boot {
+						name = instance.Name
+					}
+					if name != "" {
+						attached.Source = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/disks/%s", project, zone, name)
+					}
+				}
+				inserted.Disks = append(inserted.Disks, &attached)
+			}
+			instances[key] = &inserted
+			for _, disk := range instance.Disks {
+				if !disk.AutoDelete && disk.InitializeParams != nil && disk.InitializeParams.DiskName != "" {
+					disks[path.Join(project, zone, disk.InitializeParams.DiskName)] = true
+					diskLabels[path.Join(project, zone, disk.InitializeParams.DiskName)] = disk.InitializeParams.Labels
+				}
+			}
 			return &compute.Operation{
-				Status: "DONE",
+				Name:     "operation-insert-" + instance.Name,
+				Status:   "DONE",
+				SelfLink: fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/operations/operation-insert-%s", project, zone, instance.Name),
 			}, nil
 		},
 		mockZoneOperationsGet: func(project string, zone string, operation string) (*compute.Operation, error) {
+			key := path.Join(project, zone, operation)
+			if tracked, ok := zoneOperations[key]; ok {
+				// Tracked operations complete when polled.
+				if instance, ok := pendingInserts[key]; ok {
+					instances[path.Join(project, zone, instance.Name)] = instance
+					delete(pendingInserts, key)
+				}
+				tracked.Status = "DONE"
+				result := *tracked
+				return &result, nil
+			}
+			return &compute.Operation{
+				Name:   operation,
+				Status: "DONE",
+			}, nil
+		},
+		mockMachineTypesGet: func(project string, zone string, machineType string) (*compute.MachineType, error) {
+			return &compute.MachineType{
+				Name: machineType,
+				Zone: zone,
+			}, nil
+		},
+		mockImagesGet: func(project string, image string) (*compute.Image, error) {
+			return &compute.Image{
+				Name:   image,
+				Status: "READY",
+			}, nil
+		},
+		mockImagesGetFromFamily: func(project string, family string) (*compute.Image, error) {
+			return &compute.Image{
+				Name:   family,
+				Family: family,
+				Status: "READY",
+			}, nil
+		},
+		mockImagesGetArchitecture: func(project string, image string, family bool) (string, error) {
+			return imageArchitectures[path.Join(project, image)], nil
+		},
+		mockImagesListByLabels: func(project string, labels map[string]string) ([]*compute.Image, error) {
+			return []*compute.Image{}, nil
+		},
+		mockSubnetworksGet: func(project string, region string, subnetwork string) (*compute.Subnetwork, error) {
+			key := path.Join(project, region, subnetwork)
+			if region == "" {
+				return nil, notFoundError("subnetwork", key)
+			}
+			if found, ok := subnetworks[key]; ok {
+				result := *found
+				return &result, nil
+			}
+			// Any subnetwork of the default network exists in projects no network was ever inserted in.
+			if networkProjects[project] {
+				return nil, notFoundError("subnetwork", key)
+			}
+			return &compute.Subnetwork{
+				Name:    subnetwork,
+				Region:  fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/regions/%s", project, region),
+				Network: fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/networks/default", project),
+			}, nil
+		},
+		mockDisksGet: func(project string, zone string, disk string) (*compute.Disk, error) {
+			return &compute.Disk{
+				Name:   disk,
+				Zone:   zone,
+				Status: "READY",
+				Labels: diskLabels[path.Join(project, zone, disk)],
+			}, nil
+		},
+		mockDisksInsert: func(project string, zone string, disk *compute.Disk) (*compute.Operation, error) {
+			return &compute.Operation{
+				Status: "DONE",
+			}, nil
+		},
+		mockDisksDelete: func(project string, zone string, disk string) (*compute.Operation, error) {
+			delete(disks, path.Join(project, zone, disk))
+			return &compute.Operation{
+				Status: "DONE",
+			}, nil
+		},
+		mockDisksResize: func(project string, zone string, disk string, request *compute.DisksResizeRequest) (*compute.Operation, error) {
+			return &compute.Operation{
+				Status: "DONE",
+			}, nil
+		},
+		mockAddressesGet: func(project string, region string, address string) (*compute.Address, error) {
+			key := path.Join(project, region, address)
+			found, ok := addresses[key]
+			if !ok {
+				return nil, notFoundError("address", key)
+			}
+			result := *found
+			return &result, nil
+		},
+		mockAddressesInsert: func(project string, region string, address *compute.Address) (*compute.Operation, error) {
+			key := path.Join(project, region, address.Name)
+			if _, ok := addresses[key]; ok {
+				return nil, alreadyExistsError("address", key)
+			}
+			inserted := *address
+			inserted.Region = region
+			inserted.Status = "RESERVED"
+			if inserted.Address == "" {
+				inserted.Address = fmt.Sprintf("10.0.0.%d", len(addresses)+2)
+			}
+			addresses[key] = &inserted
+			return &compute.Operation{
+				Name:   "operation-insert-" + address.Name,
+				Status: "DONE",
+			}, nil
+		},
+		mockAddressesDelete: func(project string, region string, address string) (*compute.Operation, error) {
+			key := path.Join(project, region, address)
+			if _, ok := addresses[key]; !ok {
+				return nil, notFoundError("address", key)
+			}
+			delete(addresses, key)
+			return &compute.Operation{
+				Name:   "operation-delete-" + address,
+				Status: "DONE",
+			}, nil
+		},
+		mockGlobalAddressesGet: func(project string, address string) (*compute.Address, error) {
+			return &compute.Address{
+				Name:   address,
+				Status: "RESERVED",
+			}, nil
+		},
+		mockGlobalAddressesInsert: func(project string, address *compute.Address) (*compute.Operation, error) {
+			return globalOperation(project, "operation-insert-"+address.Name), nil
+		},
+		mockGlobalAddressesDelete: func(project string, address string) (*compute.Operation, error) {
+			return globalOperation(project, "operation-delete-"+address), nil
+		},
+		mockTargetPoolsGet: func(project string, region string, targetPool string) (*compute.TargetPool, error) {
+			pool := *getTargetPool(targetPools, project, region, targetPool)
+			pool.Instances = append([]string{}, pool.Instances...)
+			return &pool, nil
+		},
+		mockTargetPoolsAddInstance: func(project string, region string, targetPool string, request *compute.TargetPoolsAddInstanceRequest) (*compute.Operation, error) {
+			pool := getTargetPool(targetPools, project, region, targetPool)
+			for _, instance := range request.Instances {
+				pool.Instances = append(pool.Instances, instance.Instance)
+			}
+			return &compute.Operation{
+				Name:   "operation-addinstance-" + targetPool,
+				Status: "DONE",
+			}, nil
+		},
+		mockTargetPoolsRemoveInstance: func(project string, region string, targetPool string, request *compute.TargetPoolsRemoveInstanceRequest) (*compute.Operation, error) {
+			pool := getTargetPool(targetPools, project, region, targetPool)
+			for _, instance := range request.Instances {
+				pool.Instances = removeString(pool.Instances, instance.Instance)
+			}
+			return &compute.Operation{
+				Name:   "operation-removeinstance-" + targetPool,
+				Status: "DONE",
+			}, nil
+		},
+		mockInstanceGroupsGet: func(project string, zone string, instanceGroup string) (*compute.InstanceGroup, error) {
+			key := path.Join(project, zone, instanceGroup)
+			group, ok := instanceGroups[key]
+			if !ok {
+				return nil, notFoundError("instance group", key)
+			}
+			result := *group
+			result.Size = int64(len(instanceGroupMembers[key]))
+			return &result, nil
+		},
+		mockInstanceGroupsAddInstances: func(project string, zone string, instanceGroup string, request *compute.InstanceGroupsAddInstancesRequest) (*compute.Operation, error) {
+			key := path.Join(project, zone, instanceGroup)
+			if _, ok := instanceGroups[key]; !ok {
+				return nil, notFoundError("instance group", key)
+			}
+			for _, instance := range request.Instances {
+				instanceGroupMembers[key] = append(instanceGroupMembers[key], instance.Instance)
+			}
+			return &compute.Operation{
+				Name:   "operation-addinstances-" + instanceGroup,
+				Status: "DONE",
+			}, nil
+		},
+		mockInstanceGroupsRemoveInstances: func(project string, zone string, instanceGroup string, request *compute.InstanceGroupsRemoveInstancesRequest) (*compute.Operation, error) {
+			key := path.Join(project, zone, instanceGroup)
+			if _, ok := instanceGroups[key]; !ok {
+				return nil, notFoundError("instance group", key)
+			}
+			for _, instance := range request.Instances {
+				instanceGroupMembers[key] = removeString(instanceGroupMembers[key], instance.Instance)
+			}
+			return &compute.Operation{
+				Name:   "operation-removeinstances-" + instanceGroup,
+				Status: "DONE",
+			}, nil
+		},
+		mockInstanceGroupsListInstances: func(project string, zone string, instanceGroup string, request *compute.InstanceGroupsListInstancesRequest) ([]*compute.InstanceWithNamedPorts, error) {
+			key := path.Join(project, zone, instanceGroup)
+			if _, ok := instanceGroups[key]; !ok {
+				return nil, notFoundError("instance group", key)
+			}
+			result := []*compute.InstanceWithNamedPorts{}
+			for _, instance := range instanceGroupMembers[key] {
+				result = append(result, &compute.InstanceWithNamedPorts{Instance: instance})
+			}
+			return result, nil
+		},
+		mockInstanceTemplatesGet: func(project string, instanceTemplate string) (*compute.InstanceTemplate, error) {
+			return &compute.InstanceTemplate{
+				Name: instanceTemplate,
+			}, nil
+		},
+		mockInstanceTemplatesInsert: func(project string, instanceTemplate *compute.InstanceTemplate) (*compute.Operation, error) {
+			return globalOperation(project, "operation-insert-"+instanceTemplate.Name), nil
+		},
+		mockInstanceTemplatesDelete: func(project string, instanceTemplate string) (*compute.Operation, error) {
+			return globalOperation(project, "operation-delete-"+instanceTemplate), nil
+		},
+		mockInstancesGetSerialPortOutput: func(project string, zone string, instance string) (*compute.SerialPortOutput, error) {
+			return &compute.SerialPortOutput{}, nil
+		},
+		mockInstancesSetMetadata: func(project string, zone string, instance string, metadata *compute.Metadata) (*compute.Operation, error) {
+			key := path.Join(project, zone, instance)
+			found, ok := instances[key]
+			if !ok {
+				return nil, notFoundError("instance", key)
+			}
+			found.Metadata = metadata
+			return &compute.Operation{
+				Name:   "operation-setmetadata-" + instance,
+				Status: "DONE",
+			}, nil
+		},
+		mockInstancesSetLabels: func(project string, zone string, instance string, request *compute.InstancesSetLabelsRequest) (*compute.Operation, error) {
+			return &compute.Operation{
+				Status: "DONE",
+			}, nil
+		},
+		mockInstancesSetTags: func(project string, zone string, instance string, tags *compute.Tags) (*compute.Operation, error) {
+			return &compute.Operation{
+				Status: "DONE",
+			}, nil
+		},
+		mockInstancesSetMachineType: func(project string, zone string, instance string, request *compute.InstancesSetMachineTypeRequest) (*compute.Operation, error) {
+			return &compute.Operation{
+				Status: "DONE",
+			}, nil
+		},
+		mockInstancesStop: func(project string, zone string, instance string) (*compute.Operation, error) {
+			return setInstanceStatus(instances, path.Join(project, zone, instance), "TERMINATED")
+		},
+		mockInstancesStart: func(project string, zone string, instance string) (*compute.Operation, error) {
+			return setInstanceStatus(instances, path.Join(project, zone, instance), "RUNNING")
+		},
+		mockInstancesReset: func(project string, zone string, instance string) (*compute.Operation, error) {
+			return setInstanceStatus(instances, path.Join(project, zone, instance), "RUNNING")
+		},
+		mockInstancesSuspend: func(project string, zone string, instance string) (*compute.Operation, error) {
+			return setInstanceStatus(instances, path.Join(project, zone, instance), "SUSPENDED")
+		},
+		mockInstancesResume: func(project string, zone string, instance string) (*compute.Operation, error) {
+			return setInstanceStatus(instances, path.Join(project, zone, instance), "RUNNING")
+		},
+		mockInstancesAggregatedList: func(project string, filter string) ([]*compute.Instance, error) {
+			// The filter is ignored, all instances of the project are returned.
+			result := []*compute.Instance{}
+			for key, instance := range instances {
+				if strings.HasPrefix(key, project+"/") {
+					instance := *instance
+					result = append(result, &instance)
+				}
+			}
+			return result, nil
+		},
+		mockInstancesGet: func(project string, zone string, instance string) (*compute.Instance, error) {
+			key := path.Join(project, zone, instance)
+			found, ok := instances[key]
+			if !ok {
+				return nil, notFoundError("instance", key)
+			}
+			result := *found
+			if found.Status == "PROVISIONING" {
+				found.Status = "RUNNING"
+			}
+			return &result, nil
+		},
+		mockInstancesDelete: func(project string, zone string, instance string) (*compute.Operation, error) {
+			key := path.Join(project, zone, instance)
+			found, ok := instances[key]
+			if !ok {
+				return nil, notFoundError("instance", key)
+			}
+			if found.DeletionProtection {
+				return nil, APIError(http.StatusBadRequest, "resourceInUseByAnotherResource", fmt.Sprintf("The instance '%s' is protected from deletion", key))
+			}
+			delete(instances, key)
+			return &compute.Operation{
+				Name:   "operation-delete-" + instance,
+				Status: "DONE",
+			}, nil
+		},
+		mockInstancesList: func(project string, zone string, filter string) ([]*compute.Instance, error) {
+			// The filter is ignored, all instances of the zone are returned.
+			result := []*compute.Instance{}
+			for key, instance := range instances {
+				if strings.HasPrefix(key, path.Join(project, zone)+"/") {
+					instance := *instance
+					result = append(result, &instance)
+				}
+			}
+			return result, nil
+		},
+		mockAddressesList: func(project string, region string, filter string) ([]*compute.Address, error) {
+			return []*compute.Address{}, nil
+		},
+		mockGlobalAddressesList: func(project string, filter string) ([]*compute.Address, error) {
+			return []*compute.Address{}, nil
+		},
+		mockZonesList: func(project string, filter string) ([]*compute.Zone, error) {
+			return []*compute.Zone{{Name: "us-east1-b", Status: "UP"}, {Name: "us-east1-c", Status: "UP"}}, nil
+		},
+		mockRegionOperationsGet: func(project string, region string, operation string) (*compute.Operation, error) {
+			return &compute.Operation{
+				Name:   operation,
+				Status: "DONE",
+			}, nil
+		},
+		mockInstanceGroupsInsert: func(project string, zone string, instanceGroup *compute.InstanceGroup) (*compute.Operation, error) {
+			key := path.Join(project, zone, instanceGroup.Name)
+			if _, ok := instanceGroups[key]; ok {
+				return nil, alreadyExistsError("instance group", key)
+			}
+			inserted := *instanceGroup
+			inserted.Zone = zone
+			instanceGroups[key] = &inserted
+			return &compute.Operation{
+				Name:   "operation-insert-" + instanceGroup.Name,
+				Status: "DONE",
+			}, nil
+		},
+		mockFirewallsGet: func(project string, firewall string) (*compute.Firewall, error) {
+			key := path.Join(project, firewall)
+			rule, ok := firewalls[key]
+			if !ok {
+				return nil, notFoundError("firewall", key)
+			}
+			result := *rule
+			return &result, nil
+		},
+		mockFirewallsInsert: func(project string, firewall *compute.Firewall) (*compute.Operation, error) {
+			key := path.Join(project, firewall.Name)
+			if _, ok := firewalls[key]; ok {
+				return nil, alreadyExistsError("firewall", key)
+			}
+			inserted := *firewall
+			firewalls[key] = &inserted
+			return globalOperation(project, "operation-insert-"+firewall.Name), nil
+		},
+		mockFirewallsPatch: func(project string, firewall string, patch *compute.Firewall) (*compute.Operation, error) {
+			key := path.Join(project, firewall)
+			rule, ok := firewalls[key]
+			if !ok {
+				return nil, notFoundError("firewall", key)
+			}
+			rule.Allowed = patch.Allowed
+			rule.SourceRanges = patch.SourceRanges
+			rule.SourceTags = patch.SourceTags
+			rule.TargetTags = patch.TargetTags
+			return globalOperation(project, "operation-patch-"+firewall), nil
+		},
+		mockGlobalOperationsGet: func(project string, operation string) (*compute.Operation, error) {
+			// Operations are only found in the project they were started in, e.g. the Shared VPC host project.
+			key := path.Join(project, operation)
+			if !globalOperations[key] {
+				return nil, notFoundError("operation", key)
+			}
+			return &compute.Operation{
+				Name:   operation,
+				Status: "DONE",
+			}, nil
+		},
+		mockRoutersList: func(project string, region string) ([]*compute.Router, error) {
+			return routers[path.Join(project, region)], nil
+		},
+		mockRegionBackendServicesGetHealth: func(project string, region string, backendService string, group *compute.ResourceGroupReference) (*compute.BackendServiceGroupHealth, error) {
+			// Groups are referenced by partial URL, e.g. projects/my-project/zones/us-east1-b/instanceGroups/masters.
+			parts := strings.Split(strings.TrimPrefix(group.Group, "https://www.googleapis.com/compute/v1/"), "/")
+			if len(parts) != 6 {
+				return nil, notFoundError("instance group", group.Group)
+			}
+			result := &compute.BackendServiceGroupHealth{}
+			for _, instance := range instanceGroupMembers[path.Join(parts[1], parts[3], parts[5])] {
+				state, ok := instanceHealth[instance]
+				if !ok {
+					state = "HEALTHY"
+				}
+				result.HealthStatus = append(result.HealthStatus, &compute.HealthStatus{Instance: instance, HealthState: state})
+			}
+			return result, nil
+		},
+		mockInstancesSetDeletionProtection: func(project string, zone string, instance string, deletionProtection bool) (*compute.Operation, error) {
+			key := path.Join(project, zone, instance)
+			found, ok := instances[key]
+			if !ok {
+				return nil, notFoundError("instance", key)
+			}
+			found.DeletionProtection = deletionProtection
+			return &compute.Operation{
+				Name:   "operation-setDeletionProtection-" + instance,
+				Status: "DONE",
+			}, nil
+		},
+		mockZoneOperationsList: func(project string, zone string, filter string) ([]*compute.Operation, error) {
+			// The filter is ignored, all tracked operations of the zone are returned.
+			result := []*compute.Operation{}
+			for key, operation := range zoneOperations {
+				if strings.HasPrefix(key, path.Join(project, zone)+"/") {
+					operation := *operation
+					result = append(result, &operation)
+				}
+			}
+			return result, nil
+		},
+		mockNetworksGet: func(project string, network string) (*compute.Network, error) {
+			key := path.Join(project, network)
+			found, ok := networks[key]
+			if !ok {
+				return nil, notFoundError("network", key)
+			}
+			result := *found
+			return &result, nil
+		},
+		mockNetworksInsert: func(project string, network *compute.Network) (*compute.Operation, error) {
+			key := path.Join(project, network.Name)
+			if _, ok := networks[key]; ok {
+				return nil, alreadyExistsError("network", key)
+			}
+			inserted := *network
+			inserted.SelfLink = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/networks/%s", project, network.Name)
+			networks[key] = &inserted
+			networkProjects[project] = true
+			return globalOperation(project, "operation-insert-"+network.Name), nil
+		},
+		mockNetworksDelete: func(project string, network string) (*compute.Operation, error) {
+			key := path.Join(project, network)
+			if _, ok := networks[key]; !ok {
+				return nil, notFoundError("network", key)
+			}
+			delete(networks, key)
+			return globalOperation(project, "operation-delete-"+network), nil
+		},
+		mockSubnetworksInsert: func(project string, region string, subnetwork *compute.Subnetwork) (*compute.Operation, error) {
+			key := path.Join(project, region, subnetwork.Name)
+			if _, ok := subnetworks[key]; ok {
+				return nil, alreadyExistsError("subnetwork", key)
+			}
+			inserted := *subnetwork
+			inserted.Region = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/regions/%s", project, region)
+			inserted.SelfLink = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/regions/%s/subnetworks/%s", project, region, subnetwork.Name)
+			subnetworks[key] = &inserted
+			return &compute.Operation{
+				Name:   "operation-insert-" + subnetwork.Name,
+				Status: "DONE",
+			}, nil
+		},
+		mockSubnetworksDelete: func(project string, region string, subnetwork string) (*compute.Operation, error) {
+			key := path.Join(project, region, subnetwork)
+			if _, ok := subnetworks[key]; !ok {
+				return nil, notFoundError("subnetwork", key)
+			}
+			delete(subnetworks, key)
 			return &compute.Operation{
+				Name:   "operation-delete-" + subnetwork,
 				Status: "DONE",
 			}, nil
 		},
+		mockRoutersGet: func(project string, region string, router string) (*compute.Router, error) {
+			for _, found := range routers[path.Join(project, region)] {
+				if found.Name == router {
+					result := *found
+					return &result, nil
+				}
+			}
+			return nil, notFoundError("router", path.Join(project, region, router))
+		},
+		mockRoutersInsert: func(project string, region string, router *compute.Router) (*compute.Operation, error) {
+			key := path.Join(project, region)
+			for _, found := range routers[key] {
+				if found.Name == router.Name {
+					return nil, alreadyExistsError("router", path.Join(key, router.Name))
+				}
+			}
+			inserted := *router
+			inserted.SelfLink = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/regions/%s/routers/%s", project, region, router.Name)
+			routers[key] = append(routers[key], &inserted)
+			return &compute.Operation{
+				Name:   "operation-insert-" + router.Name,
+				Status: "DONE",
+			}, nil
+		},
+		mockRoutersPatch: func(project string, region string, router string, patch *compute.Router) (*compute.Operation, error) {
+			for _, found := range routers[path.Join(project, region)] {
+				if found.Name == router {
+					found.Nats = patch.Nats
+					return &compute.Operation{
+						Name:   "operation-patch-" + router,
+						Status: "DONE",
+					}, nil
+				}
+			}
+			return nil, notFoundError("router", path.Join(project, region, router))
+		},
+		mockRoutersDelete: func(project string, region string, router string) (*compute.Operation, error) {
+			key := path.Join(project, region)
+			for i, found := range routers[key] {
+				if found.Name == router {
+					routers[key] = append(routers[key][:i], routers[key][i+1:]...)
+					return &compute.Operation{
+						Name:   "operation-delete-" + router,
+						Status: "DONE",
+					}, nil
+				}
+			}
+			return nil, notFoundError("router", path.Join(key, router))
+		},
+		mockFirewallsDelete: func(project string, firewall string) (*compute.Operation, error) {
+			key := path.Join(project, firewall)
+			if _, ok := firewalls[key]; !ok {
+				return nil, notFoundError("firewall", key)
+			}
+			delete(firewalls, key)
+			return globalOperation(project, "operation-delete-"+firewall), nil
+		},
+		mockProjectsGet: func(project string) (*compute.Project, error) {
+			metadata, ok := projectMetadata[project]
+			if !ok {
+				metadata = &compute.Metadata{}
+			}
+			result := *metadata
+			result.Items = append([]*compute.MetadataItems{}, metadata.Items...)
+			return &compute.Project{
+				Name:                   project,
+				CommonInstanceMetadata: &result,
+			}, nil
+		},
+		mockProjectsSetCommonInstanceMetadata: func(project string, metadata *compute.Metadata) (*compute.Operation, error) {
+			projectMetadataUpdates[project] = append(projectMetadataUpdates[project], metadata)
+			updated := *metadata
+			updated.Fingerprint = fmt.Sprintf("fingerprint-%d", len(projectMetadataUpdates[project]))
+			projectMetadata[project] = &updated
+			return globalOperation(project, "operation-setcommoninstancemetadata-"+project), nil
+		},
+		mockRegionsGet: func(project string, region string) (*compute.Region, error) {
+			return &compute.Region{
+				Name:   region,
+				Quotas: regionQuotas[path.Join(project, region)],
+			}, nil
+		},
+		mockInstancesAddResourcePolicies: func(project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error) {
+			key := path.Join(project, zone, instance)
+			if _, ok := instances[key]; !ok {
+				return nil, notFoundError("instance", key)
+			}
+			for _, policy := range resourcePolicies {
+				instanceResourcePolicies[key] = append(removeString(instanceResourcePolicies[key], policy), policy)
+			}
+			return &compute.Operation{Status: "DONE"}, nil
+		},
+		mockInstancesRemoveResourcePolicies: func(project string, zone string, instance string, resourcePolicies []string) (*compute.Operation, error) {
+			key := path.Join(project, zone, instance)
+			if _, ok := instances[key]; !ok {
+				return nil, notFoundError("instance", key)
+			}
+			for _, policy := range resourcePolicies {
+				instanceResourcePolicies[key] = removeString(instanceResourcePolicies[key], policy)
+			}
+			return &compute.Operation{Status: "DONE"}, nil
+		},
+		mockAcceleratorTypesGet: func(project string, zone string, acceleratorType string) (*compute.AcceleratorType, error) {
+			if zones, ok := acceleratorTypeZones[path.Join(project, acceleratorType)]; ok && !hasZone(zones, zone) {
+				return nil, notFoundError("accelerator type", path.Join(project, zone, acceleratorType))
+			}
+			return &compute.AcceleratorType{
+				Name: acceleratorType,
+				Zone: zone,
+			}, nil
+		},
+		mockAcceleratorTypesAggregatedList: func(project string, filter string) ([]*compute.AcceleratorType, error) {
+			// The filter is ignored, the accelerator types restricted to zones are returned.
+			result := []*compute.AcceleratorType{}
+			for key, zones := range acceleratorTypeZones {
+				if path.Dir(key) != project {
+					continue
+				}
+				for _, zone := range zones {
+					result = append(result, &compute.AcceleratorType{
+						Name: path.Base(key),
+						Zone: "https://www.googleapis.com/compute/v1/projects/" + project + "/zones/" + zone,
+					})
+				}
+			}
+			return result, nil
+		},
+		mockDisksInsertMultiWriter: func(project string, zone string, disk *compute.Disk) (*compute.Operation, error) {
+			key := path.Join(project, zone, disk.Name)
+			if disks[key] {
+				return nil, alreadyExistsError("disk", key)
+			}
+			disks[key] = true
+			diskLabels[key] = disk.Labels
+			multiWriterDisks[key] = true
+			return &compute.Operation{
+				Name:   "operation-insert-" + disk.Name,
+				Status: "DONE",
+			}, nil
+		},
+		mockInstancesDetachDisk: func(project string, zone string, instance string, deviceName string) (*compute.Operation, error) {
+			key := path.Join(project, zone, instance)
+			inserted, ok := instances[key]
+			if !ok {
+				return nil, notFoundError("instance", key)
+			}
+			for i, disk := range inserted.Disks {
+				if disk.DeviceName == deviceName {
+					inserted.Disks = append(inserted.Disks[:i:i], inserted.Disks[i+1:]...)
+					return &compute.Operation{
+						Name:   "operation-detach-" + deviceName,
+						Status: "DONE",
+					}, nil
+				}
+			}
+			return nil, APIError(http.StatusBadRequest, "invalid", fmt.Sprintf("No attached disk found with device name '%s'", deviceName))
+		},
+		mockInstancesAttachDisk: func(project string, zone string, instance string, disk *compute.AttachedDisk) (*compute.Operation, error) {
+			key := path.Join(project, zone, instance)
+			inserted, ok := instances[key]
+			if !ok {
+				return nil, notFoundError("instance", key)
+			}
+			for _, attached := range inserted.Disks {
+				if attached.DeviceName == disk.DeviceName || attached.Source == disk.Source {
+					return nil, APIError(http.StatusBadRequest, "invalid", fmt.Sprintf("The disk '%s' is already attached", disk.Source))
+				}
+			}
+			inserted.Disks = append(inserted.Disks, disk)
+			return &compute.Operation{
+				Name:   "operation-attach-" + disk.DeviceName,
+				Status: "DONE",
+			}, nil
+		},
+		mockDisksCreateSnapshot: func(project string, zone string, disk string, snapshot *compute.Snapshot) (*compute.Operation, error) {
+			key := path.Join(project, snapshot.Name)
+			if _, ok := snapshots[key]; ok {
+				return nil, alreadyExistsError("snapshot", key)
+			}
+			created := *snapshot
+			created.SourceDisk = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/disks/%s", project, zone, disk)
+			snapshots[key] = &created
+			return &compute.Operation{
+				Name:   "operation-snapshot-" + snapshot.Name,
+				Status: "DONE",
+			}, nil
+		},
+	}
+	computeServiceMock.mockInstancesInsertWithParams = func(project string, zone string, instance *compute.Instance, params *InstanceParams) (*compute.Operation, error) {
+		operation, err := computeServiceMock.mockInstancesInsert(project, zone, instance)
+		if err == nil {
+			instanceParams[path.Join(project, zone, instance.Name)] = params
+		}
+		return operation, err
 	}
 	return &receivedInstance, &computeServiceMock
 }
+
+// failure is a failure injected into a mocked method.
+type failure struct {
+	err error
+	// hang blocks calls until their context is done, simulating an API call timing out.
+	hang bool
+	// remaining is the number of calls left to fail, negative means every call fails.
+	remaining int
+}
+
+// FailOn makes every call of the named method, e.g. "InstancesInsert", return err.
+func (c *GCPComputeServiceMock) FailOn(method string, err error) {
+	c.injectFailure(method, &failure{err: err, remaining: -1})
+}
+
+// FailOnce makes the next call of the named method return err.
+func (c *GCPComputeServiceMock) FailOnce(method string, err error) {
+	c.injectFailure(method, &failure{err: err, remaining: 1})
+}
+
+// HangOn makes every call of the named method block until its context is done and return the context error.
+func (c *GCPComputeServiceMock) HangOn(method string) {
+	c.injectFailure(method, &failure{hang: true, remaining: -1})
+}
+
+// FailOperations makes the operations returned by ZoneOperationsGet complete with the given error, e.g.
+// "ZONE_RESOURCE_POOL_EXHAUSTED" for a stockout or "QUOTA_EXCEEDED". An empty code clears the error.
+func (c *GCPComputeServiceMock) FailOperations(code string, message string) {
+	if code == "" {
+		c.operationError = nil
+		return
+	}
+	c.operationError = &compute.OperationError{
+		Errors: []*compute.OperationErrorErrors{{Code: code, Message: message}},
+	}
+}
+
+// ClearFailures removes all injected failures.
+func (c *GCPComputeServiceMock) ClearFailures() {
+	c.failures = nil
+	c.operationError = nil
+}
+
+func (c *GCPComputeServiceMock) injectFailure(method string, f *failure) {
+	if c.failures == nil {
+		c.failures = map[string]*failure{}
+	}
+	c.failures[method] = f
+}
+
+// injectedFailure returns the error injected into the named method, if any.
+func (c *GCPComputeServiceMock) injectedFailure(ctx context.Context, method string) error {
+	f, ok := c.failures[method]
+	if !ok {
+		return nil
+	}
+	if f.remaining > 0 {
+		f.remaining--
+		if f.remaining == 0 {
+			delete(c.failures, method)
+		}
+	}
+	if f.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.err
+}
+
+// APIError returns a googleapi error as returned by the compute API, e.g.
+// APIError(http.StatusTooManyRequests, "rateLimitExceeded", "Rate Limit Exceeded").
+func APIError(code int, reason string, message string) *googleapi.Error {
+	return &googleapi.Error{
+		Code:    code,
+		Message: message,
+		Errors:  []googleapi.ErrorItem{{Reason: reason, Message: message}},
+	}
+}
+
+// getTargetPool returns the tracked target pool, tracking a new empty one if needed.
+func getTargetPool(targetPools map[string]*compute.TargetPool, project string, region string, name string) *compute.TargetPool {
+	key := path.Join(project, region, name)
+	if _, ok := targetPools[key]; !ok {
+		targetPools[key] = &compute.TargetPool{
+			Name:   name,
+			Region: region,
+		}
+	}
+	return targetPools[key]
+}
+
+// TargetPoolInstances returns the instance URLs of the target pool members.
+func (c *GCPComputeServiceMock) TargetPoolInstances(project string, region string, targetPool string) []string {
+	return getTargetPool(c.targetPools, project, region, targetPool).Instances
+}
+
+// AddInstanceGroup tracks an existing empty unmanaged instance group.
+func (c *GCPComputeServiceMock) AddInstanceGroup(project string, zone string, instanceGroup string) {
+	c.instanceGroups[path.Join(project, zone, instanceGroup)] = &compute.InstanceGroup{
+		Name: instanceGroup,
+		Zone: zone,
+	}
+}
+
+// InstanceGroup returns the tracked instance group, nil if it was not inserted.
+func (c *GCPComputeServiceMock) InstanceGroup(project string, zone string, instanceGroup string) *compute.InstanceGroup {
+	return c.instanceGroups[path.Join(project, zone, instanceGroup)]
+}
+
+// InstanceGroupInstances returns the instance URLs of the instance group members.
+func (c *GCPComputeServiceMock) InstanceGroupInstances(project string, zone string, instanceGroup string) []string {
+	return c.instanceGroupMembers[path.Join(project, zone, instanceGroup)]
+}
+
+// Firewall returns the tracked firewall rule, nil if it was not inserted.
+func (c *GCPComputeServiceMock) Firewall(project string, firewall string) *compute.Firewall {
+	return c.firewalls[path.Join(project, firewall)]
+}
+
+// AddRouter tracks an existing router of the region.
+func (c *GCPComputeServiceMock) AddRouter(project string, region string, router *compute.Router) {
+	key := path.Join(project, region)
+	c.routers[key] = append(c.routers[key], router)
+}
+
+// ProjectMetadataUpdates returns the common instance metadata requests of the project, in order.
+func (c *GCPComputeServiceMock) ProjectMetadataUpdates(project string) []*compute.Metadata {
+	return c.projectMetadataUpdates[project]
+}
+
+// SetRegionQuota sets the limit and usage of the quota metric of the region, e.g. CPUS.
+func (c *GCPComputeServiceMock) SetRegionQuota(project string, region string, metric string, limit float64, usage float64) {
+	key := path.Join(project, region)
+	c.regionQuotas[key] = append(c.regionQuotas[key], &compute.Quota{Metric: metric, Limit: limit, Usage: usage})
+}
+
+// SetAcceleratorTypeZones restricts the zones the accelerator type is offered in.
+func (c *GCPComputeServiceMock) SetAcceleratorTypeZones(project string, acceleratorType string, zones ...string) {
+	c.acceleratorTypeZones[path.Join(project, acceleratorType)] = zones
+}
+
+// SetImageArchitecture sets the architecture of the image or image family, e.g. ARM64.
+func (c *GCPComputeServiceMock) SetImageArchitecture(project string, image string, architecture string) {
+	c.imageArchitectures[path.Join(project, image)] = architecture
+}
+
+// SetInstanceHealth sets the health state backend services report for the instance URL, e.g. UNHEALTHY.
+func (c *GCPComputeServiceMock) SetInstanceHealth(instance string, state string) {
+	c.instanceHealth[instance] = state
+}
+
+// hasZone returns true if zone is in zones.
+func hasZone(zones []string, zone string) bool {
+	for _, z := range zones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// removeString returns the values without the first occurrence of value.
+func removeString(values []string, value string) []string {
+	for i, v := range values {
+		if v == value {
+			return append(values[:i], values[i+1:]...)
+		}
+	}
+	return values
+}
+
+// setInstanceStatus transitions an inserted instance to the given status.
+func setInstanceStatus(instances map[string]*compute.Instance, key string, status string) (*compute.Operation, error) {
+	instance, ok := instances[key]
+	if !ok {
+		return nil, notFoundError("instance", key)
+	}
+	instance.Status = status
+	return &compute.Operation{
+		Status: "DONE",
+	}, nil
+}
+
+// notFoundError returns the error the compute API returns for a missing resource.
+func notFoundError(kind string, key string) error {
+	return APIError(http.StatusNotFound, "notFound", fmt.Sprintf("The resource '%s %s' was not found", kind, key))
+}
+
+// alreadyExistsError returns the error the compute API returns when inserting a resource which already exists.
+func alreadyExistsError(kind string, key string) error {
+	return APIError(http.StatusConflict, "alreadyExists", fmt.Sprintf("The resource '%s %s' already exists", kind, key))
+}
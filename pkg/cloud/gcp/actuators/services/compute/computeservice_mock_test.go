@@ -0,0 +1,73 @@
+package computeservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func errorCode(err error) int {
+	if googleErr, ok := err.(*googleapi.Error); ok {
+		return googleErr.Code
+	}
+	return 0
+}
+
+func TestComputeServiceMockInstances(t *testing.T) {
+	ctx := context.TODO()
+	_, mock := NewComputeServiceMock()
+
+	if _, err := mock.InstancesGet(ctx, "my-project", "us-east1-b", "worker-0"); errorCode(err) != http.StatusNotFound {
+		t.Errorf("expected a not found error getting a missing instance, got: %v", err)
+	}
+	if _, err := mock.InstancesInsert(ctx, "my-project", "us-east1-b", &compute.Instance{Name: "worker-0"}); err != nil {
+		t.Fatalf("unexpected error inserting the instance: %v", err)
+	}
+	if status := mock.InstanceStatus("my-project", "us-east1-b", "worker-0"); status != "PROVISIONING" {
+		t.Errorf("expected the inserted instance to be PROVISIONING, got %q", status)
+	}
+	if _, err := mock.InstancesInsert(ctx, "my-project", "us-east1-b", &compute.Instance{Name: "worker-0"}); errorCode(err) != http.StatusConflict {
+		t.Errorf("expected an already exists error inserting the instance twice, got: %v", err)
+	}
+
+	instance, err := mock.InstancesGet(ctx, "my-project", "us-east1-b", "worker-0")
+	if err != nil {
+		t.Fatalf("unexpected error getting the instance: %v", err)
+	}
+	if instance.Zone != "us-east1-b" || instance.SelfLink == "" {
+		t.Errorf("expected the zone and self link of the instance to be set, got %q and %q", instance.Zone, instance.SelfLink)
+	}
+	if status := mock.InstanceStatus("my-project", "us-east1-b", "worker-0"); status != "RUNNING" {
+		t.Errorf("expected the instance to be RUNNING once observed, got %q", status)
+	}
+
+	if _, err := mock.InstancesStop(ctx, "my-project", "us-east1-b", "worker-0"); err != nil {
+		t.Fatalf("unexpected error stopping the instance: %v", err)
+	}
+	if status := mock.InstanceStatus("my-project", "us-east1-b", "worker-0"); status != "TERMINATED" {
+		t.Errorf("expected the stopped instance to be TERMINATED, got %q", status)
+	}
+	if _, err := mock.InstancesStart(ctx, "my-project", "us-east1-c", "worker-0"); errorCode(err) != http.StatusNotFound {
+		t.Errorf("expected a not found error starting an instance in another zone, got: %v", err)
+	}
+
+	if instances, err := mock.InstancesAggregatedList(ctx, "my-project", ""); err != nil || len(instances) != 1 {
+		t.Errorf("expected the instance to be listed, got %d instances, error: %v", len(instances), err)
+	}
+	if instances, err := mock.InstancesAggregatedList(ctx, "other-project", ""); err != nil || len(instances) != 0 {
+		t.Errorf("expected no instances in other projects, got %d instances, error: %v", len(instances), err)
+	}
+
+	if _, err := mock.InstancesDelete(ctx, "my-project", "us-east1-b", "worker-0"); err != nil {
+		t.Fatalf("unexpected error deleting the instance: %v", err)
+	}
+	if status := mock.InstanceStatus("my-project", "us-east1-b", "worker-0"); status != "" {
+		t.Errorf("expected the deleted instance not to exist, got %q", status)
+	}
+	if _, err := mock.InstancesDelete(ctx, "my-project", "us-east1-b", "worker-0"); errorCode(err) != http.StatusNotFound {
+		t.Errorf("expected a not found error deleting a missing instance, got: %v", err)
+	}
+}
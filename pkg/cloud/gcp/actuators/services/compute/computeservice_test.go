@@ -0,0 +1,312 @@
+package computeservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestMachineTypesGetIsCached(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"name": "n1-standard-4", "guestCpus": 4, "memoryMb": 15360}`)
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		machineType, err := c.MachineTypesGet(context.Background(), "cached-project", "us-east1-b", "n1-standard-4")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if machineType.GuestCpus != 4 || machineType.MemoryMb != 15360 {
+			t.Errorf("unexpected machine type: %+v", machineType)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected a single API request, got %d", requests)
+	}
+}
+
+func TestMachineTypesGetCachesNotFound(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": 404, "message": "The resource 'n1-standard-4' was not found"}}`)
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := c.MachineTypesGet(context.Background(), "not-found-project", "us-east1-b", "n1-standard-4")
+		if googleErr, ok := err.(*googleapi.Error); !ok || googleErr.Code != http.StatusNotFound {
+			t.Fatalf("expected not found error, got: %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected a single API request, got %d", requests)
+	}
+}
+
+func TestLabelsFilter(t *testing.T) {
+	filter := labelsFilter(map[string]string{"os": "rhcos", "arch": "x86_64"})
+	expected := `(labels.arch = "x86_64") (labels.os = "rhcos")`
+	if filter != expected {
+		t.Errorf("expected filter %q, got %q", expected, filter)
+	}
+}
+
+func TestInstancesSuspend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/my-project/zones/us-east1-b/instances/worker-0/suspend" {
+			http.Error(w, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"name": "operation-suspend", "status": "PENDING"}`)
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	operation, err := c.InstancesSuspend(context.Background(), "my-project", "us-east1-b", "worker-0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if operation.Name != "operation-suspend" {
+		t.Errorf("unexpected operation: %+v", operation)
+	}
+}
+
+func TestImagesGetArchitecture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/rhcos-cloud/global/images/rhcos-412":
+			fmt.Fprint(w, `{"name": "rhcos-412", "status": "READY", "architecture": "ARM64"}`)
+		case r.Method == "GET" && r.URL.Path == "/debian-cloud/global/images/family/debian-12":
+			fmt.Fprint(w, `{"name": "debian-12-bookworm-v20240110", "status": "READY"}`)
+		default:
+			http.Error(w, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	architecture, err := c.ImagesGetArchitecture(context.Background(), "rhcos-cloud", "rhcos-412", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if architecture != "ARM64" {
+		t.Errorf("expected architecture ARM64, got %q", architecture)
+	}
+	architecture, err = c.ImagesGetArchitecture(context.Background(), "debian-cloud", "debian-12", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if architecture != "" {
+		t.Errorf("expected no architecture for an image which does not set it, got %q", architecture)
+	}
+	if _, err := c.ImagesGetArchitecture(context.Background(), "debian-cloud", "missing", false); err == nil {
+		t.Errorf("expected an error for a missing image")
+	}
+}
+
+func TestInstancesInsertWithParams(t *testing.T) {
+	var body struct {
+		Name         string `json:"name"`
+		CanIpForward *bool  `json:"canIpForward"`
+		Params       struct {
+			ResourceManagerTags map[string]string `json:"resourceManagerTags"`
+		} `json:"params"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/my-project/zones/us-east1-b/instances" {
+			http.Error(w, fmt.Sprintf("unexpected request %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"name": "operation-insert", "status": "PENDING"}`)
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	instance := &compute.Instance{Name: "worker-0", ForceSendFields: []string{"CanIpForward"}}
+	operation, err := c.InstancesInsertWithParams(context.Background(), "my-project", "us-east1-b", instance, &InstanceParams{
+		ResourceManagerTags: map[string]string{"tagKeys/123": "tagValues/456"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if operation.Name != "operation-insert" {
+		t.Errorf("unexpected operation: %+v", operation)
+	}
+	if body.Name != "worker-0" || body.CanIpForward == nil || *body.CanIpForward {
+		t.Errorf("expected the instance to be sent with its force sent fields, got %+v", body)
+	}
+	if body.Params.ResourceManagerTags["tagKeys/123"] != "tagValues/456" {
+		t.Errorf("expected the resource manager tags to be sent, got %+v", body.Params)
+	}
+}
+
+func TestInstancesAggregatedList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			fmt.Fprint(w, `{"items": {"zones/us-east1-b": {"instances": [{"name": "worker-0"}]}, "zones/us-east1-c": {"warning": {"code": "NO_RESULTS_ON_PAGE"}}}, "nextPageToken": "page-2"}`)
+		case "page-2":
+			fmt.Fprint(w, `{"items": {"zones/us-east1-c": {"instances": [{"name": "worker-1"}]}}}`)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	instances, err := c.InstancesAggregatedList(context.Background(), "my-project", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(instances) != 2 {
+		t.Errorf("expected instances of both pages, got %d", len(instances))
+	}
+}
+
+func TestRateLimitedTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "worker-0"}`)
+	}))
+	defer server.Close()
+
+	limiter := rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+	client := &http.Client{Transport: NewRateLimitedTransport(limiter, server.Client().Transport)}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		res, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected requests to be throttled, 3 requests took %v", elapsed)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "operation-1", "status": "DONE"}`)
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.ZoneOperationsGet(ctx, "my-project", "us-east1-b", "operation-1"); err == nil {
+		t.Errorf("expected an error for a cancelled context")
+	}
+	if _, err := c.InstancesSuspend(ctx, "my-project", "us-east1-b", "worker-0"); err == nil {
+		t.Errorf("expected an error for a cancelled context")
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		fmt.Fprint(w, `{"name": "operation-1", "status": "DONE"}`)
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{
+		Endpoint:  server.URL,
+		UserAgent: "cluster-api-provider-gcp/v0.1.0 cluster/abc",
+	})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+	if _, err := c.ZoneOperationsGet(context.Background(), "my-project", "us-east1-b", "operation-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.InstancesSuspend(context.Background(), "my-project", "us-east1-b", "worker-0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, userAgent := range userAgents {
+		if !strings.HasSuffix(userAgent, " cluster-api-provider-gcp/v0.1.0 cluster/abc") {
+			t.Errorf("expected User-Agent to identify the provider and cluster, got %q", userAgent)
+		}
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("pageToken") {
+		case "":
+			fmt.Fprint(w, `{"items": [{"name": "image-0"}, {"name": "image-1"}], "nextPageToken": "page-2"}`)
+		case "page-2":
+			fmt.Fprint(w, `{"items": [{"name": "image-2"}]}`)
+		default:
+			t.Errorf("unexpected page token %q", r.URL.Query().Get("pageToken"))
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewComputeService(server.Client(), ServiceOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("failed to create compute service: %v", err)
+	}
+
+	images, err := c.ImagesListByLabels(context.Background(), "my-project", map[string]string{"os": "rhcos"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(images) != 3 {
+		t.Errorf("expected images of all pages, got %d", len(images))
+	}
+
+	addresses, err := c.AddressesList(context.Background(), "my-project", "us-east1", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addresses) != 3 {
+		t.Errorf("expected addresses of all pages, got %d", len(addresses))
+	}
+}
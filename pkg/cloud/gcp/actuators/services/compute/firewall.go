@@ -0,0 +1,28 @@
+package computeservice
+
+import (
+	"reflect"
+	"sort"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// FirewallUpToDate returns true if the existing firewall rule allows the traffic of the desired one, so the
+// machine and cluster actuators only patch the firewall rules which drifted.
+func FirewallUpToDate(existing, desired *compute.Firewall) bool {
+	return reflect.DeepEqual(existing.Allowed, desired.Allowed) &&
+		stringSetsEqual(existing.SourceRanges, desired.SourceRanges) &&
+		stringSetsEqual(existing.SourceTags, desired.SourceTags) &&
+		stringSetsEqual(existing.TargetTags, desired.TargetTags)
+}
+
+func stringSetsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
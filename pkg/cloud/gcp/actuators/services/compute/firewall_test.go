@@ -0,0 +1,28 @@
+package computeservice
+
+import (
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+)
+
+func TestFirewallUpToDate(t *testing.T) {
+	desired := &compute.Firewall{
+		Allowed:      []*compute.FirewallAllowed{{IPProtocol: "tcp", Ports: []string{"443"}}},
+		SourceRanges: []string{"10.0.0.0/16", "10.1.0.0/16"},
+		TargetTags:   []string{"worker"},
+	}
+	existing := &compute.Firewall{
+		Allowed:      []*compute.FirewallAllowed{{IPProtocol: "tcp", Ports: []string{"443"}}},
+		SourceRanges: []string{"10.1.0.0/16", "10.0.0.0/16"},
+		TargetTags:   []string{"worker"},
+	}
+	if !FirewallUpToDate(existing, desired) {
+		t.Errorf("expected firewall rules differing in the order of their source ranges to be up to date")
+	}
+
+	existing.TargetTags = []string{"master"}
+	if FirewallUpToDate(existing, desired) {
+		t.Errorf("expected firewall rules with other target tags not to be up to date")
+	}
+}
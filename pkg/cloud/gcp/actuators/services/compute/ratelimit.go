@@ -0,0 +1,33 @@
+package computeservice
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedTransport delays requests to the GCP APIs according to a token bucket
+// shared by all machines, so large scale-ups don't exhaust the project API quota.
+type rateLimitedTransport struct {
+	limiter *rate.Limiter
+	next    http.RoundTripper
+}
+
+// NewRateLimitedTransport returns a transport waiting for a token of the limiter before sending each request through next.
+func NewRateLimitedTransport(limiter *rate.Limiter, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &rateLimitedTransport{
+		limiter: limiter,
+		next:    next,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
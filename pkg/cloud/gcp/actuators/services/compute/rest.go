@@ -0,0 +1,81 @@
+package computeservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// doOperationRequest calls a compute REST method returning an operation. It is meant for
+// methods or fields the vendored compute client does not implement yet; relPath is relative to
+// the compute API base path and its {placeholders} are expanded from params. A non-nil body is
+// sent as JSON.
+func (c *computeService) doOperationRequest(ctx context.Context, method string, relPath string, params map[string]string, body interface{}) (*compute.Operation, error) {
+	res, err := c.doRequest(ctx, method, relPath, params, body)
+	if err != nil {
+		return nil, err
+	}
+	defer googleapi.CloseBody(res)
+
+	operation := &compute.Operation{
+		ServerResponse: googleapi.ServerResponse{
+			Header:         res.Header,
+			HTTPStatusCode: res.StatusCode,
+		},
+	}
+	if err := json.NewDecoder(res.Body).Decode(operation); err != nil {
+		return nil, err
+	}
+	return operation, nil
+}
+
+// doGetRequest gets a compute resource and decodes it into result, e.g. to read fields the vendored
+// compute client does not implement yet. relPath and params are as for doOperationRequest.
+func (c *computeService) doGetRequest(ctx context.Context, relPath string, params map[string]string, result interface{}) error {
+	res, err := c.doRequest(ctx, "GET", relPath, params, nil)
+	if err != nil {
+		return err
+	}
+	defer googleapi.CloseBody(res)
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// doRequest sends a compute REST request and returns its successful response, whose body the caller closes.
+func (c *computeService) doRequest(ctx context.Context, method string, relPath string, params map[string]string, body interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, googleapi.ResolveRelative(c.service.BasePath, relPath), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	googleapi.Expand(req.URL, params)
+	userAgent := googleapi.UserAgent
+	if c.service.UserAgent != "" {
+		userAgent += " " + c.service.UserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if err := googleapi.CheckResponse(res); err != nil {
+		googleapi.CloseBody(res)
+		return nil, err
+	}
+	return res, nil
+}
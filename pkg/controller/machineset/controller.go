@@ -0,0 +1,241 @@
+package machineset
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machineapierrors "github.com/openshift/cluster-api/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	apicorev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	controllerName = "machineset-capacity-controller"
+
+	// The capacity annotations the cluster-autoscaler reads to scale machine sets from zero.
+	cpuKey    = "machine.openshift.io/vCPU"
+	memoryKey = "machine.openshift.io/memoryMb"
+	gpuKey    = "machine.openshift.io/GPU"
+
+	// The GPU annotations of the upstream cluster-autoscaler clusterapi provider.
+	gpuCountKey = "capacity.cluster-autoscaler.kubernetes.io/gpu-count"
+	gpuTypeKey  = "capacity.cluster-autoscaler.kubernetes.io/gpu-type"
+	labelsKey   = "capacity.cluster-autoscaler.kubernetes.io/labels"
+
+	gpuResourceName = "nvidia.com/gpu"
+	// acceleratorLabel is the node label the cluster-autoscaler matches GPU types with on GCP.
+	acceleratorLabel = "cloud.google.com/gke-accelerator"
+)
+
+var log = logf.Log.WithName(controllerName)
+
+// MachineTypeGetter returns the GCP machine type of the provider spec of a machine.
+type MachineTypeGetter interface {
+	MachineType(ctx context.Context, machine *machinev1.Machine) (*compute.MachineType, error)
+}
+
+// Reconciler annotates machine sets with the capacity of the machine type of their template.
+type Reconciler struct {
+	client        client.Client
+	machineTypes  MachineTypeGetter
+	eventRecorder record.EventRecorder
+}
+
+// Add creates the machine set capacity controller and adds it to the manager.
+func Add(mgr manager.Manager, machineTypes MachineTypeGetter) error {
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		Reconciler: &Reconciler{
+			client:        mgr.GetClient(),
+			machineTypes:  machineTypes,
+			eventRecorder: mgr.GetRecorder(controllerName),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &machinev1.MachineSet{}}, &handler.EnqueueRequestForObject{})
+}
+
+// Reconcile sets the capacity annotations of the machine set.
+func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx := context.TODO()
+	logger := log.WithValues("machineset", request.Name, "namespace", request.Namespace)
+
+	machineSet := &machinev1.MachineSet{}
+	if err := r.client.Get(ctx, request.NamespacedName, machineSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if machineSet.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+
+	providerSpec, err := templateProviderSpec(machineSet)
+	if err != nil {
+		return r.handleError(ctx, logger, machineSet, err)
+	}
+
+	machineType, err := r.machineTypes.MachineType(ctx, templateMachine(machineSet))
+	if err != nil {
+		return r.handleError(ctx, logger, machineSet, err)
+	}
+
+	annotations := capacityAnnotations(machineType, providerSpec.GPUs, machineSet.Annotations[labelsKey])
+	if hasAnnotations(machineSet, annotations) {
+		return reconcile.Result{}, nil
+	}
+	logger.Info("Updating machine set capacity annotations", "machineType", machineType.Name, "annotations", annotations)
+	return reconcile.Result{}, r.updateAnnotations(ctx, machineSet, annotations)
+}
+
+// handleError requeues the machine set on transient errors. Permanent errors, e.g. a machine type which does not
+// exist or a provider spec which is not a GCP provider spec, cannot be fixed by retrying until the machine set
+// changes: they are recorded with a warning event, and the capacity annotations, which are stale, are removed.
+func (r *Reconciler) handleError(ctx context.Context, logger logr.Logger, machineSet *machinev1.MachineSet, err error) (reconcile.Result, error) {
+	if !isPermanentError(err) {
+		logger.Error(err, "Failed to get the capacity of the machine set")
+		return reconcile.Result{}, err
+	}
+	logger.Info("Capacity of the machine set cannot be determined", "reason", err.Error())
+	if r.eventRecorder != nil {
+		r.eventRecorder.Eventf(machineSet, apicorev1.EventTypeWarning, "CapacityUnknown", "Failed to get the capacity of the machine set: %v", err)
+	}
+	annotations := map[string]string{
+		cpuKey:      "",
+		memoryKey:   "",
+		gpuKey:      "",
+		gpuCountKey: "",
+		gpuTypeKey:  "",
+		labelsKey:   withLabel(machineSet.Annotations[labelsKey], acceleratorLabel, ""),
+	}
+	return reconcile.Result{}, r.updateAnnotations(ctx, machineSet, annotations)
+}
+
+// isPermanentError returns true if the error is caused by the machine set itself, e.g. an invalid provider spec or
+// a machine type GCP does not know, rather than by the API being unavailable.
+func isPermanentError(err error) bool {
+	if _, ok := err.(*machineapierrors.MachineError); ok {
+		return true
+	}
+	if googleErr, ok := err.(*googleapi.Error); ok {
+		return googleErr.Code == http.StatusBadRequest || googleErr.Code == http.StatusNotFound
+	}
+	return false
+}
+
+// updateAnnotations sets the annotations of the machine set, removing the ones with empty values, unless it
+// already has them.
+func (r *Reconciler) updateAnnotations(ctx context.Context, machineSet *machinev1.MachineSet, annotations map[string]string) error {
+	if hasAnnotations(machineSet, annotations) {
+		return nil
+	}
+	updated := machineSet.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		if value == "" {
+			delete(updated.Annotations, key)
+			continue
+		}
+		updated.Annotations[key] = value
+	}
+	return r.client.Update(ctx, updated)
+}
+
+// templateMachine returns a machine of the template of the machine set.
+func templateMachine(machineSet *machinev1.MachineSet) *machinev1.Machine {
+	return &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machineSet.Name,
+			Namespace: machineSet.Namespace,
+			Labels:    machineSet.Spec.Template.Labels,
+		},
+		Spec: machineSet.Spec.Template.Spec,
+	}
+}
+
+// templateProviderSpec decodes the provider spec of the template of the machine set.
+func templateProviderSpec(machineSet *machinev1.MachineSet) (*gcpproviderv1.GCPMachineProviderSpec, error) {
+	providerSpec := &gcpproviderv1.GCPMachineProviderSpec{}
+	if value := machineSet.Spec.Template.Spec.ProviderSpec.Value; value != nil {
+		if err := yaml.Unmarshal(value.Raw, providerSpec); err != nil {
+			return nil, machineapierrors.InvalidMachineConfiguration("error unmarshalling providerSpec: %v", err)
+		}
+	}
+	if providerSpec.MachineType == "" {
+		return nil, machineapierrors.InvalidMachineConfiguration("providerSpec has no GCP machine type")
+	}
+	return providerSpec, nil
+}
+
+// capacityAnnotations returns the capacity annotations of the machine type and GPUs, merging the
+// accelerator node label into the existing labels annotation. Empty values are annotations to remove.
+func capacityAnnotations(machineType *compute.MachineType, gpus []gcpproviderv1.GCPGPUConfig, labels string) map[string]string {
+	var gpuCount int64
+	gpuType := ""
+	for _, gpu := range gpus {
+		gpuCount += gpu.Count
+		// Instances only support a single accelerator type.
+		if gpuType == "" {
+			gpuType = gpu.Type
+		}
+	}
+	annotations := map[string]string{
+		cpuKey:      strconv.FormatInt(machineType.GuestCpus, 10),
+		memoryKey:   strconv.FormatInt(machineType.MemoryMb, 10),
+		gpuKey:      strconv.FormatInt(gpuCount, 10),
+		gpuCountKey: "",
+		gpuTypeKey:  "",
+		labelsKey:   withLabel(labels, acceleratorLabel, gpuType),
+	}
+	if gpuCount > 0 {
+		annotations[gpuCountKey] = strconv.FormatInt(gpuCount, 10)
+		annotations[gpuTypeKey] = gpuResourceName
+	}
+	return annotations
+}
+
+// withLabel sets the label in the comma-separated key=value labels, or removes it when value is empty.
+func withLabel(labels string, key, value string) string {
+	var result []string
+	for _, label := range strings.Split(labels, ",") {
+		if label == "" || strings.SplitN(label, "=", 2)[0] == key {
+			continue
+		}
+		result = append(result, label)
+	}
+	if value != "" {
+		result = append(result, key+"="+value)
+	}
+	return strings.Join(result, ",")
+}
+
+// hasAnnotations returns true if the machine set has the annotations, and none of the ones with empty values.
+func hasAnnotations(machineSet *machinev1.MachineSet, annotations map[string]string) bool {
+	for key, value := range annotations {
+		if current, ok := machineSet.Annotations[key]; current != value || (value == "" && ok) {
+			return false
+		}
+	}
+	return true
+}
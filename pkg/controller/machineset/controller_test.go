@@ -0,0 +1,241 @@
+package machineset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	gcpproviderv1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type fakeMachineTypes struct {
+	machineTypes map[string]*compute.MachineType
+	err          error
+}
+
+func (f *fakeMachineTypes) MachineType(ctx context.Context, machine *machinev1.Machine) (*compute.MachineType, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	machineType, ok := f.machineTypes[machine.Name]
+	if !ok {
+		return nil, fmt.Errorf("machine type of %q not found", machine.Name)
+	}
+	return machineType, nil
+}
+
+func TestReconcile(t *testing.T) {
+	if err := machinev1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name                string
+		annotations         map[string]string
+		gpus                []gcpproviderv1.GCPGPUConfig
+		expectedAnnotations map[string]string
+	}{
+		{
+			name: "annotates the capacity",
+			expectedAnnotations: map[string]string{
+				cpuKey:    "4",
+				memoryKey: "15360",
+				gpuKey:    "0",
+			},
+		},
+		{
+			name:        "keeps other annotations",
+			annotations: map[string]string{"team": "infra", cpuKey: "2"},
+			expectedAnnotations: map[string]string{
+				"team":    "infra",
+				cpuKey:    "4",
+				memoryKey: "15360",
+				gpuKey:    "0",
+			},
+		},
+		{
+			name:        "annotates the GPUs",
+			annotations: map[string]string{labelsKey: "node-role=gpu"},
+			gpus:        []gcpproviderv1.GCPGPUConfig{{Count: 2, Type: "nvidia-tesla-t4"}},
+			expectedAnnotations: map[string]string{
+				cpuKey:      "4",
+				memoryKey:   "15360",
+				gpuKey:      "2",
+				gpuCountKey: "2",
+				gpuTypeKey:  "nvidia.com/gpu",
+				labelsKey:   "node-role=gpu,cloud.google.com/gke-accelerator=nvidia-tesla-t4",
+			},
+		},
+		{
+			name: "removes the annotations of removed GPUs",
+			annotations: map[string]string{
+				gpuKey:      "2",
+				gpuCountKey: "2",
+				gpuTypeKey:  "nvidia.com/gpu",
+				labelsKey:   "cloud.google.com/gke-accelerator=nvidia-tesla-t4",
+			},
+			expectedAnnotations: map[string]string{
+				cpuKey:    "4",
+				memoryKey: "15360",
+				gpuKey:    "0",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerSpec, err := json.Marshal(&gcpproviderv1.GCPMachineProviderSpec{MachineType: "n1-standard-4", GPUs: tc.gpus})
+			if err != nil {
+				t.Fatal(err)
+			}
+			machineSet := &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "workers",
+					Namespace:   "openshift-machine-api",
+					Annotations: tc.annotations,
+				},
+				Spec: machinev1.MachineSetSpec{
+					Template: machinev1.MachineTemplateSpec{
+						Spec: machinev1.MachineSpec{
+							ProviderSpec: machinev1.ProviderSpec{
+								Value: &runtime.RawExtension{Raw: providerSpec},
+							},
+						},
+					},
+				},
+			}
+			reconciler := &Reconciler{
+				client: controllerfake.NewFakeClient(machineSet),
+				machineTypes: &fakeMachineTypes{machineTypes: map[string]*compute.MachineType{
+					"workers": {Name: "n1-standard-4", GuestCpus: 4, MemoryMb: 15360},
+				}},
+			}
+			key := types.NamespacedName{Name: "workers", Namespace: "openshift-machine-api"}
+			if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("reconciler was not expected to return error: %v", err)
+			}
+			updated := &machinev1.MachineSet{}
+			if err := reconciler.client.Get(context.TODO(), key, updated); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(updated.Annotations, tc.expectedAnnotations) {
+				t.Errorf("expected annotations %v, got %v", tc.expectedAnnotations, updated.Annotations)
+			}
+		})
+	}
+}
+
+func TestReconcileMissingMachineSet(t *testing.T) {
+	reconciler := &Reconciler{
+		client:       controllerfake.NewFakeClient(),
+		machineTypes: &fakeMachineTypes{},
+	}
+	if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "workers", Namespace: "openshift-machine-api"}}); err != nil {
+		t.Errorf("expected deleted machine sets to be ignored, got: %v", err)
+	}
+}
+
+func TestReconcileErrors(t *testing.T) {
+	if err := machinev1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	capacity := map[string]string{
+		"team":      "infra",
+		cpuKey:      "4",
+		memoryKey:   "15360",
+		gpuKey:      "2",
+		gpuCountKey: "2",
+		gpuTypeKey:  "nvidia.com/gpu",
+		labelsKey:   "node-role=gpu,cloud.google.com/gke-accelerator=nvidia-tesla-t4",
+	}
+	testCases := []struct {
+		name                string
+		providerSpec        string
+		err                 error
+		expectErr           bool
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:         "machine type not found",
+			providerSpec: `{"machineType": "n1-standrd-4"}`,
+			err:          &googleapi.Error{Code: http.StatusNotFound, Message: "The resource 'n1-standrd-4' was not found"},
+			expectedAnnotations: map[string]string{
+				"team":    "infra",
+				labelsKey: "node-role=gpu",
+			},
+		},
+		{
+			name:         "provider spec of another provider",
+			providerSpec: `{"instanceType": "m5.large"}`,
+			expectedAnnotations: map[string]string{
+				"team":    "infra",
+				labelsKey: "node-role=gpu",
+			},
+		},
+		{
+			name:                "transient error",
+			providerSpec:        `{"machineType": "n1-standard-4"}`,
+			err:                 &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "backend error"},
+			expectErr:           true,
+			expectedAnnotations: capacity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			for key, value := range capacity {
+				annotations[key] = value
+			}
+			machineSet := &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "workers",
+					Namespace:   "openshift-machine-api",
+					Annotations: annotations,
+				},
+				Spec: machinev1.MachineSetSpec{
+					Template: machinev1.MachineTemplateSpec{
+						Spec: machinev1.MachineSpec{
+							ProviderSpec: machinev1.ProviderSpec{
+								Value: &runtime.RawExtension{Raw: []byte(tc.providerSpec)},
+							},
+						},
+					},
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &Reconciler{
+				client:        controllerfake.NewFakeClient(machineSet),
+				machineTypes:  &fakeMachineTypes{err: tc.err},
+				eventRecorder: recorder,
+			}
+			key := types.NamespacedName{Name: "workers", Namespace: "openshift-machine-api"}
+			_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error %v, got: %v", tc.expectErr, err)
+			}
+			if !tc.expectErr && len(recorder.Events) != 1 {
+				t.Errorf("expected a warning event for a permanent error, got %d events", len(recorder.Events))
+			}
+			updated := &machinev1.MachineSet{}
+			if err := reconciler.client.Get(context.TODO(), key, updated); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(updated.Annotations, tc.expectedAnnotations) {
+				t.Errorf("expected annotations %v, got %v", tc.expectedAnnotations, updated.Annotations)
+			}
+		})
+	}
+}
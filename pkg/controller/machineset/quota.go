@@ -0,0 +1,146 @@
+package machineset
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	apicorev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	quotaControllerName = "machineset-quota-controller"
+
+	// quotaExceededKey is set on machine sets whose scale-up does not fit the regional quotas, with the
+	// quotas which are exceeded. It is removed once the scale-up fits or is done.
+	quotaExceededKey = "gcpprovider.openshift.io/quota-exceeded"
+)
+
+var quotaLog = logf.Log.WithName(quotaControllerName)
+
+// QuotaChecker returns the regional quotas which cannot fit more machines of the provider spec of a machine.
+type QuotaChecker interface {
+	QuotaShortfalls(ctx context.Context, machine *machinev1.Machine, count int64) ([]string, error)
+}
+
+// QuotaReconciler annotates machine sets with the regional quotas their scale-up exceeds.
+type QuotaReconciler struct {
+	client        client.Client
+	quotas        QuotaChecker
+	eventRecorder record.EventRecorder
+}
+
+// AddQuotaController creates the machine set quota controller and adds it to the manager.
+func AddQuotaController(mgr manager.Manager, quotas QuotaChecker) error {
+	c, err := controller.New(quotaControllerName, mgr, controller.Options{
+		Reconciler: &QuotaReconciler{
+			client:        mgr.GetClient(),
+			quotas:        quotas,
+			eventRecorder: mgr.GetRecorder(quotaControllerName),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &machinev1.MachineSet{}}, &handler.EnqueueRequestForObject{})
+}
+
+// Reconcile sets the quota exceeded annotation of the machine set.
+func (r *QuotaReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx := context.TODO()
+
+	machineSet := &machinev1.MachineSet{}
+	if err := r.client.Get(ctx, request.NamespacedName, machineSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if machineSet.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+
+	message := r.checkQuotas(ctx, machineSet)
+	if machineSet.Annotations[quotaExceededKey] == message {
+		return reconcile.Result{}, nil
+	}
+	updated := machineSet.DeepCopy()
+	if message == "" {
+		delete(updated.Annotations, quotaExceededKey)
+	} else {
+		if updated.Annotations == nil {
+			updated.Annotations = map[string]string{}
+		}
+		updated.Annotations[quotaExceededKey] = message
+	}
+	quotaLog.Info("Updating machine set quota annotation", "machineset", request.Name, "namespace", request.Namespace, "quotaExceeded", message)
+	return reconcile.Result{}, r.client.Update(ctx, updated)
+}
+
+// checkQuotas returns why the scale-up of the machine set does not fit the regional quotas, empty when it
+// fits, and records a warning event when the reason changed. The replicas which are not created yet and the
+// machines without instance make up the scale-up, so machines still provisioning are not counted while their
+// usage already is, and the annotation stays while instances fail to be created for lack of quota. Failing to
+// check quotas is only logged.
+func (r *QuotaReconciler) checkQuotas(ctx context.Context, machineSet *machinev1.MachineSet) string {
+	if machineSet.Spec.Replicas == nil {
+		return ""
+	}
+	pending, err := r.machinesWithoutInstance(ctx, machineSet)
+	if err != nil {
+		quotaLog.Error(err, "Failed to list the machines of the machine set", "machineset", machineSet.Name, "namespace", machineSet.Namespace)
+		return machineSet.Annotations[quotaExceededKey]
+	}
+	scaleUp := int64(*machineSet.Spec.Replicas) - int64(machineSet.Status.Replicas)
+	if scaleUp < 0 {
+		scaleUp = 0
+	}
+	scaleUp += pending
+	if scaleUp <= 0 {
+		return ""
+	}
+	shortfalls, err := r.quotas.QuotaShortfalls(ctx, templateMachine(machineSet), scaleUp)
+	if err != nil {
+		quotaLog.Error(err, "Failed to check the regional quotas of the machine set", "machineset", machineSet.Name, "namespace", machineSet.Namespace)
+		return machineSet.Annotations[quotaExceededKey]
+	}
+	if len(shortfalls) == 0 {
+		return ""
+	}
+	message := fmt.Sprintf("Scaling up by %d machines exceeds the regional quotas: %s", scaleUp, strings.Join(shortfalls, "; "))
+	if message != machineSet.Annotations[quotaExceededKey] && r.eventRecorder != nil {
+		r.eventRecorder.Event(machineSet, apicorev1.EventTypeWarning, "QuotaExceeded", message)
+	}
+	return message
+}
+
+// machinesWithoutInstance returns the number of machines of the machine set whose instance was not created yet,
+// the machines being deleted aside.
+func (r *QuotaReconciler) machinesWithoutInstance(ctx context.Context, machineSet *machinev1.MachineSet) (int64, error) {
+	machines := &machinev1.MachineList{}
+	if err := r.client.List(ctx, &client.ListOptions{Namespace: machineSet.Namespace}, machines); err != nil {
+		return 0, err
+	}
+	var count int64
+	for _, machine := range machines.Items {
+		owner := metav1.GetControllerOf(&machine)
+		if owner == nil || owner.Kind != "MachineSet" || owner.Name != machineSet.Name || machine.DeletionTimestamp != nil {
+			continue
+		}
+		if machine.Spec.ProviderID == nil || *machine.Spec.ProviderID == "" {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,112 @@
+package machineset
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	controllerfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type fakeQuotas struct {
+	shortfalls []string
+	counts     []int64
+}
+
+func (f *fakeQuotas) QuotaShortfalls(ctx context.Context, machine *machinev1.Machine, count int64) ([]string, error) {
+	f.counts = append(f.counts, count)
+	return f.shortfalls, nil
+}
+
+func TestReconcileQuotas(t *testing.T) {
+	if err := machinev1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	replicas := int32(10)
+	machineSet := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "workers",
+			Namespace: "openshift-machine-api",
+		},
+		Spec: machinev1.MachineSetSpec{
+			Replicas: &replicas,
+		},
+		Status: machinev1.MachineSetStatus{
+			Replicas:      8,
+			ReadyReplicas: 4,
+		},
+	}
+	// Of the 4 machines which are not ready, 2 have an instance still provisioning whose usage is already
+	// counted by GCP.
+	objects := []runtime.Object{machineSet}
+	for i, providerID := range []string{"", "", "gce://my-project/us-east1-b/workers-2", "gce://my-project/us-east1-b/workers-3"} {
+		machine := &machinev1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            fmt.Sprintf("workers-%d", i),
+				Namespace:       "openshift-machine-api",
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(machineSet, machinev1.SchemeGroupVersion.WithKind("MachineSet"))},
+			},
+		}
+		if providerID != "" {
+			machine.Spec.ProviderID = &providerID
+		}
+		objects = append(objects, machine)
+	}
+	// Machines of other machine sets are not counted.
+	objects = append(objects, &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "infra-0",
+			Namespace:       "openshift-machine-api",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "machine.openshift.io/v1beta1", Kind: "MachineSet", Name: "infra", Controller: &[]bool{true}[0]}},
+		},
+	})
+	quotas := &fakeQuotas{shortfalls: []string{"CPUS: 24 required, 12 available of 24"}}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &QuotaReconciler{
+		client:        controllerfake.NewFakeClient(objects...),
+		quotas:        quotas,
+		eventRecorder: recorder,
+	}
+	key := types.NamespacedName{Name: "workers", Namespace: "openshift-machine-api"}
+	reconcileQuotas := func() string {
+		if _, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("reconciler was not expected to return error: %v", err)
+		}
+		updated := &machinev1.MachineSet{}
+		if err := reconciler.client.Get(context.TODO(), key, updated); err != nil {
+			t.Fatal(err)
+		}
+		return updated.Annotations[quotaExceededKey]
+	}
+
+	expected := "Scaling up by 4 machines exceeds the regional quotas: CPUS: 24 required, 12 available of 24"
+	if message := reconcileQuotas(); message != expected {
+		t.Errorf("expected quota annotation %q, got %q", expected, message)
+	}
+	if !reflect.DeepEqual(quotas.counts, []int64{4}) {
+		t.Errorf("expected the replicas not created yet and the machines without instance to be checked, got counts %v", quotas.counts)
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected a QuotaExceeded event, got %d events", len(recorder.Events))
+	}
+	<-recorder.Events
+
+	// The same shortfall does not record another event.
+	reconcileQuotas()
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no event for an unchanged shortfall, got %q", <-recorder.Events)
+	}
+
+	quotas.shortfalls = nil
+	if message := reconcileQuotas(); message != "" {
+		t.Errorf("expected the quota annotation to be removed once the scale-up fits, got %q", message)
+	}
+}
@@ -0,0 +1,116 @@
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Feature is the name of a feature gate.
+type Feature string
+
+const (
+	// SpotVMs allows machines to run on Spot VMs.
+	SpotVMs Feature = "SpotVMs"
+
+	// FirewallRules reconciles the firewall rules of the provider spec of machines.
+	FirewallRules Feature = "FirewallRules"
+)
+
+// FeatureSpec describes a feature gate.
+type FeatureSpec struct {
+	// Default is whether the feature is enabled when not set with --feature-gates.
+	Default bool
+	// PreRelease is the maturity of the feature, e.g. Alpha or Beta.
+	PreRelease string
+}
+
+// defaultFeatureGates are the known feature gates. Risky capabilities ship disabled by default
+// and are enabled per cluster with --feature-gates.
+var defaultFeatureGates = map[Feature]FeatureSpec{
+	SpotVMs:       {Default: false, PreRelease: "Alpha"},
+	FirewallRules: {Default: false, PreRelease: "Alpha"},
+}
+
+// FeatureGate holds the enabled state of the known features. It implements flag.Value so it can be
+// set with a comma-separated list of key=value pairs, e.g. --feature-gates=SpotVMs=true.
+type FeatureGate struct {
+	lock    sync.RWMutex
+	enabled map[Feature]bool
+}
+
+// NewFeatureGate returns a feature gate with every known feature set to its default.
+func NewFeatureGate() *FeatureGate {
+	return &FeatureGate{enabled: map[Feature]bool{}}
+}
+
+// Set parses a comma-separated list of key=value pairs and sets the enabled state of the features.
+// Unknown features are rejected so typos do not silently leave a feature disabled.
+func (g *FeatureGate) Set(value string) error {
+	enabled := map[Feature]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("missing value for feature gate %q, expected key=value", parts[0])
+		}
+		feature := Feature(strings.TrimSpace(parts[0]))
+		if _, ok := defaultFeatureGates[feature]; !ok {
+			return fmt.Errorf("unknown feature gate %q, known feature gates are %s", feature, strings.Join(KnownFeatures(), ", "))
+		}
+		state, err := strconv.ParseBool(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("invalid value %q for feature gate %q: %v", parts[1], feature, err)
+		}
+		enabled[feature] = state
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for feature, state := range enabled {
+		g.enabled[feature] = state
+	}
+	return nil
+}
+
+// String returns the features explicitly set, as a comma-separated list of key=value pairs.
+func (g *FeatureGate) String() string {
+	if g == nil {
+		return ""
+	}
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	var pairs []string
+	for feature, state := range g.enabled {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", feature, state))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Enabled returns whether the feature is enabled. A nil gate reports the defaults.
+func (g *FeatureGate) Enabled(feature Feature) bool {
+	if g != nil {
+		g.lock.RLock()
+		defer g.lock.RUnlock()
+		if state, ok := g.enabled[feature]; ok {
+			return state
+		}
+	}
+	return defaultFeatureGates[feature].Default
+}
+
+// KnownFeatures returns a description of the known feature gates, e.g. for flag usage.
+func KnownFeatures() []string {
+	var known []string
+	for feature, spec := range defaultFeatureGates {
+		known = append(known, fmt.Sprintf("%s=true|false (%s - default=%t)", feature, spec.PreRelease, spec.Default))
+	}
+	sort.Strings(known)
+	return known
+}
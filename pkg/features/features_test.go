@@ -0,0 +1,70 @@
+package features
+
+import (
+	"testing"
+)
+
+func TestFeatureGate(t *testing.T) {
+	cases := []struct {
+		name          string
+		value         string
+		expectedError bool
+		expected      map[Feature]bool
+	}{
+		{
+			name:     "defaults",
+			value:    "",
+			expected: map[Feature]bool{SpotVMs: false},
+		},
+		{
+			name:     "enable a feature",
+			value:    "SpotVMs=true",
+			expected: map[Feature]bool{SpotVMs: true},
+		},
+		{
+			name:     "whitespace",
+			value:    " SpotVMs = true , ",
+			expected: map[Feature]bool{SpotVMs: true},
+		},
+		{
+			name:          "unknown feature",
+			value:         "SpotVMs=true,Unknown=true",
+			expectedError: true,
+			expected:      map[Feature]bool{SpotVMs: false},
+		},
+		{
+			name:          "missing value",
+			value:         "SpotVMs",
+			expectedError: true,
+			expected:      map[Feature]bool{SpotVMs: false},
+		},
+		{
+			name:          "invalid value",
+			value:         "SpotVMs=yes please",
+			expectedError: true,
+			expected:      map[Feature]bool{SpotVMs: false},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gate := NewFeatureGate()
+			err := gate.Set(tc.value)
+			if tc.expectedError != (err != nil) {
+				t.Errorf("expected error: %v, got %v", tc.expectedError, err)
+			}
+			for feature, expected := range tc.expected {
+				if gate.Enabled(feature) != expected {
+					t.Errorf("expected %s enabled: %v, got %v", feature, expected, gate.Enabled(feature))
+				}
+			}
+		})
+	}
+}
+
+func TestNilFeatureGate(t *testing.T) {
+	var gate *FeatureGate
+	if gate.Enabled(SpotVMs) != defaultFeatureGates[SpotVMs].Default {
+		t.Errorf("expected nil feature gate to report the default")
+	}
+}
@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	checkTimeout    = 30 * time.Second
+	shutdownTimeout = 10 * time.Second
+)
+
+// Check returns an error when the controller is not ready.
+type Check func(ctx context.Context) error
+
+// Server serves the /healthz liveness and /readyz readiness probes over HTTP.
+type Server struct {
+	// Port is the port the probe server listens on.
+	Port int
+	// ReadinessChecks are run by /readyz, which fails if any of them fails. They are named in the response.
+	ReadinessChecks map[string]Check
+}
+
+// Start runs the probe server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.Port),
+		Handler: s.handler(),
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		klog.Infof("Starting health probe server on port %d", s.Port)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("health probe server failed: %v", err)
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	// The liveness probe only checks the process serves requests: restarting the
+	// controller does not help when GCP is unreachable.
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	mux.HandleFunc("/readyz", s.serveReadiness)
+	return mux
+}
+
+func (s *Server) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(s.ReadinessChecks))
+	for name := range s.ReadinessChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := s.ReadinessChecks[name](ctx); err != nil {
+			klog.Warningf("Readiness check %q failed: %v", name, err)
+			http.Error(w, fmt.Sprintf("%s check failed: %v", name, err), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprint(w, "ok")
+}
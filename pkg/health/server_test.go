@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	cases := []struct {
+		name           string
+		path           string
+		checks         map[string]Check
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "liveness ignores readiness checks",
+			path:           "/healthz",
+			checks:         map[string]Check{"gcp": func(context.Context) error { return errors.New("unreachable") }},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "ready without checks",
+			path:           "/readyz",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "ready when checks pass",
+			path:           "/readyz",
+			checks:         map[string]Check{"gcp": func(context.Context) error { return nil }},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "not ready when a check fails",
+			path:           "/readyz",
+			checks:         map[string]Check{"gcp": func(context.Context) error { return errors.New("invalid_grant") }},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "gcp check failed: invalid_grant",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{ReadinessChecks: tc.checks}
+			recorder := httptest.NewRecorder()
+			server.handler().ServeHTTP(recorder, httptest.NewRequest("GET", tc.path, nil))
+			if recorder.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, recorder.Code)
+			}
+			if body := strings.TrimSpace(recorder.Body.String()); body != tc.expectedBody {
+				t.Errorf("expected body %q, got %q", tc.expectedBody, body)
+			}
+		})
+	}
+}
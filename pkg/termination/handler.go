@@ -0,0 +1,125 @@
+package termination
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	machineclient "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/typed/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// preemptedMetadataKey is the metadata server key set to TRUE when GCP starts preempting the instance.
+	preemptedMetadataKey = "instance/preempted"
+	// machineAnnotationKey links a node to its machine, as set by the machine API node controller.
+	machineAnnotationKey = "machine.openshift.io/machine"
+
+	// deleteTimeout bounds the attempts to delete the machine, GCP stops the instance 30 seconds after the notice.
+	deleteTimeout   = 30 * time.Second
+	deleteRetryWait = 2 * time.Second
+	// subscribeRetryWait is waited before watching the metadata server again when the watch ends.
+	subscribeRetryWait = 5 * time.Second
+)
+
+// errPreempted stops watching the metadata server once the machine is deleted.
+var errPreempted = errors.New("instance preempted")
+
+// MetadataSubscriber watches a key of the GCE metadata server, it is implemented by *metadata.Client.
+type MetadataSubscriber interface {
+	Subscribe(suffix string, fn func(v string, ok bool) error) error
+}
+
+// Handler deletes the machine of the node it runs on as soon as the metadata server announces the preemption
+// of the instance, so the machine controller drains the node during the 30 seconds before the instance stops.
+type Handler struct {
+	// NodeName is the name of the node the handler runs on.
+	NodeName      string
+	Metadata      MetadataSubscriber
+	NodeClient    corev1client.NodesGetter
+	MachineClient machineclient.MachinesGetter
+}
+
+// Run watches the preemption notice until the machine is deleted or the stop channel is closed.
+func (h *Handler) Run(stop <-chan struct{}) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.watch(stop)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-stop:
+		return nil
+	}
+}
+
+func (h *Handler) watch(stop <-chan struct{}) error {
+	for {
+		err := h.Metadata.Subscribe(preemptedMetadataKey, func(value string, ok bool) error {
+			if !ok || !strings.EqualFold(strings.TrimSpace(value), "TRUE") {
+				return nil
+			}
+			klog.Infof("Instance of node %s is being preempted", h.NodeName)
+			if err := h.deleteMachine(); err != nil {
+				return err
+			}
+			return errPreempted
+		})
+		if err == errPreempted {
+			return nil
+		}
+		if err != nil {
+			klog.Errorf("Failed to watch preemption notice: %v", err)
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(subscribeRetryWait):
+		}
+	}
+}
+
+// deleteMachine deletes the machine of the node, retrying until the instance is expected to stop.
+func (h *Handler) deleteMachine() error {
+	var lastErr error
+	err := wait.PollImmediate(deleteRetryWait, deleteTimeout, func() (bool, error) {
+		if lastErr = h.tryDeleteMachine(); lastErr != nil {
+			klog.Errorf("Failed to delete machine of node %s: %v", h.NodeName, lastErr)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete machine of node %s: %v", h.NodeName, lastErr)
+	}
+	return nil
+}
+
+func (h *Handler) tryDeleteMachine() error {
+	node, err := h.NodeClient.Nodes().Get(h.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	annotation, ok := node.Annotations[machineAnnotationKey]
+	if !ok {
+		return fmt.Errorf("node has no %s annotation", machineAnnotationKey)
+	}
+	parts := strings.Split(annotation, "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid %s annotation %q, expected namespace/name", machineAnnotationKey, annotation)
+	}
+	namespace, name := parts[0], parts[1]
+
+	err = h.MachineClient.Machines(namespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	klog.Infof("Deleted machine %s/%s of preempted node %s", namespace, name, h.NodeName)
+	return nil
+}
@@ -0,0 +1,96 @@
+package termination
+
+import (
+	"testing"
+
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	machinefake "github.com/openshift/cluster-api/pkg/client/clientset_generated/clientset/fake"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeMetadata calls the subscription callback with each of its values in order.
+type fakeMetadata struct {
+	values []string
+}
+
+func (m *fakeMetadata) Subscribe(suffix string, fn func(v string, ok bool) error) error {
+	for _, value := range m.values {
+		if err := fn(value, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "worker-0",
+			Annotations: map[string]string{machineAnnotationKey: "openshift-machine-api/worker-0"},
+		},
+	}
+	machine := &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "worker-0",
+			Namespace: "openshift-machine-api",
+		},
+	}
+	machineClient := machinefake.NewSimpleClientset(machine).MachineV1beta1()
+	handler := &Handler{
+		NodeName:      "worker-0",
+		Metadata:      &fakeMetadata{values: []string{"FALSE", "TRUE"}},
+		NodeClient:    kubefake.NewSimpleClientset(node).CoreV1(),
+		MachineClient: machineClient,
+	}
+
+	if err := handler.Run(make(chan struct{})); err != nil {
+		t.Fatalf("handler was not expected to return error: %v", err)
+	}
+	if _, err := machineClient.Machines(machine.Namespace).Get(machine.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected machine to be deleted, got %v", err)
+	}
+}
+
+func TestTryDeleteMachine(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expectError bool
+	}{
+		{
+			name:        "machine already deleted",
+			annotations: map[string]string{machineAnnotationKey: "openshift-machine-api/worker-0"},
+		},
+		{
+			name:        "missing annotation",
+			expectError: true,
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{machineAnnotationKey: "worker-0"},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "worker-0",
+					Annotations: tc.annotations,
+				},
+			}
+			handler := &Handler{
+				NodeName:      "worker-0",
+				NodeClient:    kubefake.NewSimpleClientset(node).CoreV1(),
+				MachineClient: machinefake.NewSimpleClientset().MachineV1beta1(),
+			}
+			err := handler.tryDeleteMachine()
+			if tc.expectError != (err != nil) {
+				t.Errorf("expected error: %v, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"k8s.io/klog"
+)
+
+const (
+	tracesPath = "/v1/traces"
+	// flushInterval is how often the buffered spans are sent to the collector.
+	flushInterval = 5 * time.Second
+	// maxBufferedSpans bounds the memory used by spans when the collector is unreachable.
+	maxBufferedSpans = 2048
+	exportTimeout    = 10 * time.Second
+)
+
+// Exporter sends the spans to an OpenTelemetry collector with the OTLP/HTTP protocol in its JSON
+// encoding, see https://opentelemetry.io/docs/specs/otlp/#otlphttp.
+// It implements the opencensus trace.Exporter interface and buffers spans until they are flushed.
+type Exporter struct {
+	// url is the OTLP traces endpoint, e.g. http://otel-collector:4318/v1/traces.
+	url         string
+	serviceName string
+	client      *http.Client
+
+	lock    sync.Mutex
+	spans   []*trace.SpanData
+	dropped int
+}
+
+// NewExporter returns an exporter sending spans to the OTLP/HTTP endpoint, e.g. http://otel-collector:4318.
+func NewExporter(endpoint string, serviceName string) *Exporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, tracesPath) {
+		url += tracesPath
+	}
+	return &Exporter{
+		url:         url,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+	}
+}
+
+// Register registers the exporter and samples the given fraction of traces.
+func Register(exporter *Exporter, sampleRatio float64) {
+	trace.RegisterExporter(exporter)
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(sampleRatio)})
+}
+
+// ExportSpan implements trace.Exporter.
+func (e *Exporter) ExportSpan(span *trace.SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if len(e.spans) >= maxBufferedSpans {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, span)
+}
+
+// Start flushes the buffered spans periodically until the stop channel is closed, then flushes them a last time.
+// It implements the controller-runtime manager.Runnable interface.
+func (e *Exporter) Start(stop <-chan struct{}) error {
+	klog.Infof("Exporting traces to %s", e.url)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.logFlushError(e.Flush(context.Background()))
+		case <-stop:
+			e.logFlushError(e.Flush(context.Background()))
+			return nil
+		}
+	}
+}
+
+func (e *Exporter) logFlushError(err error) {
+	if err != nil {
+		klog.Warningf("Failed to export traces: %v", err)
+	}
+}
+
+// Flush sends the buffered spans to the collector. Spans are dropped when they cannot be sent.
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.lock.Lock()
+	spans, dropped := e.spans, e.dropped
+	e.spans, e.dropped = nil, 0
+	e.lock.Unlock()
+
+	if dropped > 0 {
+		klog.Warningf("Dropped %d spans, the trace buffer was full", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, data)
+	}
+	return nil
+}
+
+// The OTLP JSON types below only hold the fields the exporter sets.
+
+type exportTraceServiceRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope instrumentationScope `json:"scope"`
+	Spans []span               `json:"spans"`
+}
+
+type instrumentationScope struct {
+	Name string `json:"name"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// OTLP span kinds and status codes.
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	statusCodeError = 2
+)
+
+func (e *Exporter) request(spans []*trace.SpanData) exportTraceServiceRequest {
+	converted := make([]span, 0, len(spans))
+	for _, data := range spans {
+		converted = append(converted, convertSpan(data))
+	}
+	return exportTraceServiceRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{Attributes: []keyValue{attribute("service.name", e.serviceName)}},
+			ScopeSpans: []scopeSpans{{
+				Scope: instrumentationScope{Name: "go.opencensus.io/trace"},
+				Spans: converted,
+			}},
+		}},
+	}
+}
+
+func convertSpan(data *trace.SpanData) span {
+	converted := span{
+		TraceID:           hex.EncodeToString(data.TraceID[:]),
+		SpanID:            hex.EncodeToString(data.SpanID[:]),
+		Name:              data.Name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(data.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(data.EndTime.UnixNano(), 10),
+	}
+	if data.ParentSpanID != (trace.SpanID{}) {
+		converted.ParentSpanID = hex.EncodeToString(data.ParentSpanID[:])
+	}
+	switch data.SpanKind {
+	case trace.SpanKindServer:
+		converted.Kind = spanKindServer
+	case trace.SpanKindClient:
+		converted.Kind = spanKindClient
+	}
+	// Opencensus uses the gRPC status codes, where 0 is OK.
+	if data.Code != trace.StatusCodeOK {
+		converted.Status = status{Code: statusCodeError, Message: data.Message}
+	}
+	for key, value := range data.Attributes {
+		converted.Attributes = append(converted.Attributes, attribute(key, value))
+	}
+	return converted
+}
+
+func attribute(key string, value interface{}) keyValue {
+	var converted anyValue
+	switch v := value.(type) {
+	case bool:
+		converted.BoolValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		converted.IntValue = &s
+	case float64:
+		converted.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		converted.StringValue = &s
+	}
+	return keyValue{Key: key, Value: converted}
+}
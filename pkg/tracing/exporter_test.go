@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestExporter(t *testing.T) {
+	var received []exportTraceServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		var request exportTraceServiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, request)
+	}))
+	defer server.Close()
+
+	exporter := NewExporter(server.URL, "test")
+	if err := exporter.Flush(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 0 {
+		t.Errorf("expected no request without spans, got %d", len(received))
+	}
+
+	start := time.Unix(100, 0)
+	exporter.ExportSpan(&trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:  trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		ParentSpanID: trace.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+		SpanKind:     trace.SpanKindClient,
+		Name:         "machine.create",
+		StartTime:    start,
+		EndTime:      start.Add(time.Second),
+		Attributes:   map[string]interface{}{"machine": "worker-0", "retries": int64(2)},
+		Status:       trace.Status{Code: trace.StatusCodeUnknown, Message: "quota exceeded"},
+	})
+	if err := exporter.Flush(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(received))
+	}
+
+	resourceSpans := received[0].ResourceSpans[0]
+	if attr := resourceSpans.Resource.Attributes[0]; attr.Key != "service.name" || *attr.Value.StringValue != "test" {
+		t.Errorf("expected service.name resource attribute, got %+v", attr)
+	}
+	span := resourceSpans.ScopeSpans[0].Spans[0]
+	if span.TraceID != "0102030405060708090a0b0c0d0e0f10" || span.SpanID != "0102030405060708" || span.ParentSpanID != "0807060504030201" {
+		t.Errorf("unexpected span ids: %+v", span)
+	}
+	if span.Kind != spanKindClient {
+		t.Errorf("expected client span kind, got %d", span.Kind)
+	}
+	if span.StartTimeUnixNano != "100000000000" || span.EndTimeUnixNano != "101000000000" {
+		t.Errorf("unexpected span times: %s - %s", span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+	if span.Status.Code != statusCodeError || span.Status.Message != "quota exceeded" {
+		t.Errorf("expected error status, got %+v", span.Status)
+	}
+	attributes := map[string]anyValue{}
+	for _, attr := range span.Attributes {
+		attributes[attr.Key] = attr.Value
+	}
+	if v := attributes["machine"].StringValue; v == nil || *v != "worker-0" {
+		t.Errorf("expected machine attribute, got %+v", attributes["machine"])
+	}
+	if v := attributes["retries"].IntValue; v == nil || *v != "2" {
+		t.Errorf("expected retries attribute, got %+v", attributes["retries"])
+	}
+}
+
+func TestExporterBufferLimit(t *testing.T) {
+	exporter := NewExporter("http://localhost:4318/", "test")
+	if exporter.url != "http://localhost:4318/v1/traces" {
+		t.Errorf("unexpected url %q", exporter.url)
+	}
+	for i := 0; i < maxBufferedSpans+10; i++ {
+		exporter.ExportSpan(&trace.SpanData{})
+	}
+	if len(exporter.spans) != maxBufferedSpans || exporter.dropped != 10 {
+		t.Errorf("expected %d buffered and 10 dropped spans, got %d and %d", maxBufferedSpans, len(exporter.spans), exporter.dropped)
+	}
+}
@@ -0,0 +1,12 @@
+package version
+
+var (
+	// Raw is the string representation of the version. This will be replaced
+	// with the calculated version at build time.
+	Raw = "was not built properly"
+)
+
+// UserAgent returns the default User-Agent product identifying the provider in GCP API requests.
+func UserAgent() string {
+	return "cluster-api-provider-gcp/" + Raw
+}
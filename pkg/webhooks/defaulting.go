@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/appscode/jsonpatch"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/types"
+)
+
+const (
+	defaultingWebhookPath = "/mutate-gcp-providerspec"
+
+	defaultBootDiskType   = "pd-ssd"
+	defaultBootDiskSizeGb = 128
+	defaultScope          = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// providerSpecDefaulter fills unset GCPMachineProviderSpec fields with sensible defaults.
+type providerSpecDefaulter struct{}
+
+var _ admission.Handler = &providerSpecDefaulter{}
+
+// Handle defaults the providerSpec embedded in the admitted object. Only the defaulted fields are patched, the
+// rest of the providerSpec is left as the user wrote it.
+func (d *providerSpecDefaulter) Handle(ctx context.Context, req atypes.Request) atypes.Response {
+	obj, err := decodeObject(req)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	value, fldPath := providerSpecField(obj)
+	if value == nil {
+		// Nothing to default, the validating webhook rejects the object.
+		return admission.ValidationResponse(true, "")
+	}
+
+	spec, err := unmarshalProviderSpec(value)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+	patches, err := defaultingPatches(spec, clusterIDOf(obj), "/"+strings.Replace(fldPath.String(), ".", "/", -1))
+	if err != nil {
+		return admission.ErrorResponse(http.StatusInternalServerError, err)
+	}
+
+	return atypes.Response{
+		Patches: patches,
+		Response: &admissionv1beta1.AdmissionResponse{
+			Allowed:   true,
+			PatchType: func() *admissionv1beta1.PatchType { pt := admissionv1beta1.PatchTypeJSONPatch; return &pt }(),
+		},
+	}
+}
+
+// defaultingPatches returns the JSON patches setting the defaults of the fields of the providerSpec at path
+// left empty by the user.
+func defaultingPatches(spec *v1beta1.GCPMachineProviderSpec, clusterID, path string) ([]jsonpatch.JsonPatchOperation, error) {
+	original, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling providerSpec: %v", err)
+	}
+	defaultProviderSpec(spec, clusterID)
+	defaulted, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling providerSpec: %v", err)
+	}
+	patches, err := jsonpatch.CreatePatch(original, defaulted)
+	if err != nil {
+		return nil, fmt.Errorf("error computing providerSpec defaults: %v", err)
+	}
+	for i := range patches {
+		patches[i].Path = path + patches[i].Path
+		// Defaulted fields may be missing from the providerSpec of the user, where replace operations fail.
+		if patches[i].Operation == "replace" {
+			patches[i].Operation = "add"
+		}
+	}
+	return patches, nil
+}
+
+// defaultProviderSpec sets defaults for fields left empty by the user.
+func defaultProviderSpec(spec *v1beta1.GCPMachineProviderSpec, clusterID string) {
+	if spec.Region == "" && spec.Zone != "" {
+		if i := strings.LastIndex(spec.Zone, "-"); i > 0 {
+			spec.Region = spec.Zone[:i]
+		}
+	}
+
+	for _, disk := range spec.Disks {
+		// Existing boot disks keep their own type and size.
+		if disk == nil || !disk.Boot || disk.Source != "" {
+			continue
+		}
+		if disk.Type == "" {
+			disk.Type = defaultBootDiskType
+		}
+		if disk.SizeGb == 0 {
+			disk.SizeGb = defaultBootDiskSizeGb
+		}
+	}
+
+	if clusterID != "" {
+		if spec.Labels == nil {
+			spec.Labels = map[string]string{}
+		}
+		key := v1beta1.ClusterOwnedLabel(clusterID)
+		if _, ok := spec.Labels[key]; !ok {
+			spec.Labels[key] = v1beta1.ClusterOwnedLabelValue
+		}
+	}
+
+	for i := range spec.ServiceAccounts {
+		if len(spec.ServiceAccounts[i].Scopes) == 0 {
+			spec.ServiceAccounts[i].Scopes = []string{defaultScope}
+		}
+	}
+}
+
+// NewDefaultingWebhook returns the webhook defaulting GCP provider specs of Machines and MachineSets.
+func NewDefaultingWebhook() *admission.Webhook {
+	return &admission.Webhook{
+		Name:     "default.gcpprovider.machine.openshift.io",
+		Type:     types.WebhookTypeMutating,
+		Path:     defaultingWebhookPath,
+		Rules:    providerSpecRules(),
+		Handlers: []admission.Handler{&providerSpecDefaulter{}},
+	}
+}
@@ -0,0 +1,130 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestDefaultProviderSpec(t *testing.T) {
+	spec := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:            "us-east1-b",
+		Disks:           []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}, {Type: "pd-standard", SizeGb: 10}},
+		ServiceAccounts: []gcpv1beta1.GCPServiceAccount{{Email: "sa@project.iam.gserviceaccount.com"}},
+	}
+	defaultProviderSpec(spec, "cluster-abc")
+
+	expected := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:   "us-east1-b",
+		Region: "us-east1",
+		Disks: []*gcpv1beta1.GCPDisk{
+			{Boot: true, Image: "rhcos", Type: defaultBootDiskType, SizeGb: defaultBootDiskSizeGb},
+			{Type: "pd-standard", SizeGb: 10},
+		},
+		Labels: map[string]string{"kubernetes-io-cluster-cluster-abc": "owned"},
+		ServiceAccounts: []gcpv1beta1.GCPServiceAccount{
+			{Email: "sa@project.iam.gserviceaccount.com", Scopes: []string{defaultScope}},
+		},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("expected %+v, got %+v", expected, spec)
+	}
+
+	existingBootDisk := &gcpv1beta1.GCPDisk{Boot: true, Source: "worker-0-restored"}
+	defaultProviderSpec(&gcpv1beta1.GCPMachineProviderSpec{Disks: []*gcpv1beta1.GCPDisk{existingBootDisk}}, "")
+	if existingBootDisk.Type != "" || existingBootDisk.SizeGb != 0 {
+		t.Errorf("expected the existing boot disk not to be defaulted, got %+v", existingBootDisk)
+	}
+}
+
+func TestProviderSpecDefaulter(t *testing.T) {
+	defaulter := &providerSpecDefaulter{}
+
+	resp := defaulter.Handle(context.Background(), machineRequest(t, &gcpv1beta1.GCPMachineProviderSpec{Zone: "us-east1-b"}))
+	if !resp.Response.Allowed {
+		t.Fatalf("expected request to be allowed: %+v", resp.Response.Result)
+	}
+	if len(resp.Patches) == 0 {
+		t.Errorf("expected the region to be defaulted through a patch")
+	}
+
+	resp = defaulter.Handle(context.Background(), machineRequest(t, nil))
+	if !resp.Response.Allowed || len(resp.Patches) != 0 {
+		t.Errorf("expected a machine without providerSpec to be allowed without patches")
+	}
+
+	// Only the defaulted fields are patched, fields unknown to the provider are left alone.
+	machine := machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{machinev1.MachineClusterIDLabel: "cluster-abc"}},
+	}
+	machine.Spec.ProviderSpec.Value = &runtime.RawExtension{
+		Raw: []byte(`{"zone":"us-east1-b","machineType":"n1-standard-4","disks":[{"boot":true,"image":"rhcos","type":"pd-standard"}],"future":"field"}`),
+	}
+	raw, err := json.Marshal(machine)
+	if err != nil {
+		t.Fatalf("failed to marshal machine: %v", err)
+	}
+	req := machineRequest(t, nil)
+	req.AdmissionRequest.Object.Raw = raw
+	resp = defaulter.Handle(context.Background(), req)
+	if !resp.Response.Allowed {
+		t.Fatalf("expected request to be allowed: %+v", resp.Response.Result)
+	}
+	expectedPaths := map[string]bool{
+		"/spec/providerSpec/value/region":         true,
+		"/spec/providerSpec/value/disks/0/sizeGb": true,
+		"/spec/providerSpec/value/labels":         true,
+	}
+	for _, patch := range resp.Patches {
+		if !expectedPaths[patch.Path] {
+			t.Errorf("unexpected patch of %s", patch.Path)
+		}
+	}
+	patches, err := json.Marshal(resp.Patches)
+	if err != nil {
+		t.Fatalf("failed to marshal patches: %v", err)
+	}
+	patch, err := jsonpatch.DecodePatch(patches)
+	if err != nil {
+		t.Fatalf("failed to decode patches: %v", err)
+	}
+	patched, err := patch.Apply(raw)
+	if err != nil {
+		t.Fatalf("failed to apply patches: %v", err)
+	}
+	var patchedMachine machinev1.Machine
+	if err := json.Unmarshal(patched, &patchedMachine); err != nil {
+		t.Fatalf("failed to unmarshal patched machine: %v", err)
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal(patchedMachine.Spec.ProviderSpec.Value.Raw, &value); err != nil {
+		t.Fatalf("failed to unmarshal patched providerSpec: %v", err)
+	}
+	if value["region"] != "us-east1" || value["future"] != "field" {
+		t.Errorf("expected the region to be defaulted and unknown fields to be kept, got %v", value)
+	}
+	if disk := value["disks"].([]interface{})[0].(map[string]interface{}); disk["type"] != "pd-standard" || disk["sizeGb"] != float64(defaultBootDiskSizeGb) {
+		t.Errorf("expected the boot disk size to be defaulted and its type to be kept, got %v", disk)
+	}
+}
+
+func TestDefaultClusterOwnedLabelLength(t *testing.T) {
+	spec := &gcpv1beta1.GCPMachineProviderSpec{}
+	clusterID := "a-very-long-cluster-name-exceeding-the-gcp-label-key-limit-xk2p9"
+	defaultProviderSpec(spec, clusterID)
+	for key := range spec.Labels {
+		if len(key) > 63 {
+			t.Errorf("expected the cluster owned label key to fit in 63 characters, got %q", key)
+		}
+	}
+	if gcpv1beta1.ClusterOwnedLabel(clusterID) == gcpv1beta1.ClusterOwnedLabel(clusterID+"-2") {
+		t.Errorf("expected truncated cluster owned label keys of distinct clusters to differ")
+	}
+}
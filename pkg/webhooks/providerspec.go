@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+	"sigs.k8s.io/yaml"
+)
+
+// decodeObject decodes the Machine or MachineSet carried by the admission request.
+func decodeObject(req atypes.Request) (runtime.Object, error) {
+	return decodeRaw(req.AdmissionRequest.Kind.Kind, req.AdmissionRequest.Object.Raw)
+}
+
+// decodeOldObject decodes the Machine or MachineSet replaced by the admission request of an update.
+func decodeOldObject(req atypes.Request) (runtime.Object, error) {
+	return decodeRaw(req.AdmissionRequest.Kind.Kind, req.AdmissionRequest.OldObject.Raw)
+}
+
+func decodeRaw(kind string, raw []byte) (runtime.Object, error) {
+	switch kind {
+	case "Machine":
+		machine := &machinev1.Machine{}
+		if err := json.Unmarshal(raw, machine); err != nil {
+			return nil, fmt.Errorf("error decoding machine: %v", err)
+		}
+		return machine, nil
+	case "MachineSet":
+		machineSet := &machinev1.MachineSet{}
+		if err := json.Unmarshal(raw, machineSet); err != nil {
+			return nil, fmt.Errorf("error decoding machineset: %v", err)
+		}
+		return machineSet, nil
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", kind)
+	}
+}
+
+// providerSpecField returns the providerSpec value embedded in a Machine or MachineSet
+// together with its field path, so errors can point at the offending field.
+func providerSpecField(obj runtime.Object) (*runtime.RawExtension, *field.Path) {
+	switch o := obj.(type) {
+	case *machinev1.Machine:
+		return o.Spec.ProviderSpec.Value, field.NewPath("spec", "providerSpec", "value")
+	case *machinev1.MachineSet:
+		return o.Spec.Template.Spec.ProviderSpec.Value, field.NewPath("spec", "template", "spec", "providerSpec", "value")
+	}
+	return nil, nil
+}
+
+// isDeleting returns whether the Machine or MachineSet is being deleted.
+func isDeleting(obj runtime.Object) bool {
+	switch o := obj.(type) {
+	case *machinev1.Machine:
+		return o.DeletionTimestamp != nil
+	case *machinev1.MachineSet:
+		return o.DeletionTimestamp != nil
+	}
+	return false
+}
+
+// clusterIDOf returns the cluster ID label of a Machine or MachineSet.
+func clusterIDOf(obj runtime.Object) string {
+	switch o := obj.(type) {
+	case *machinev1.Machine:
+		return o.Labels[machinev1.MachineClusterIDLabel]
+	case *machinev1.MachineSet:
+		if clusterID, ok := o.Spec.Template.Labels[machinev1.MachineClusterIDLabel]; ok {
+			return clusterID
+		}
+		return o.Labels[machinev1.MachineClusterIDLabel]
+	}
+	return ""
+}
+
+func unmarshalProviderSpec(value *runtime.RawExtension) (*v1beta1.GCPMachineProviderSpec, error) {
+	var spec v1beta1.GCPMachineProviderSpec
+	if err := yaml.Unmarshal(value.Raw, &spec); err != nil {
+		return nil, fmt.Errorf("error unmarshalling providerSpec: %v", err)
+	}
+	return &spec, nil
+}
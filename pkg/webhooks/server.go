@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	certFileName    = "tls.crt"
+	keyFileName     = "tls.key"
+	shutdownTimeout = 10 * time.Second
+)
+
+// Server serves admission webhooks over HTTPS.
+type Server struct {
+	// Port is the port the webhook server listens on.
+	Port int
+	// CertDir is the directory containing the serving certificate (tls.crt) and key (tls.key).
+	CertDir string
+	// Webhooks are the admission webhooks to serve, each on its own path.
+	Webhooks []*admission.Webhook
+}
+
+// Start runs the webhook server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	for _, wh := range s.Webhooks {
+		mux.Handle(wh.Path, wh)
+	}
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.Port),
+		Handler: mux,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		klog.Infof("Starting webhook server on port %d", s.Port)
+		errCh <- srv.ListenAndServeTLS(filepath.Join(s.CertDir, certFileName), filepath.Join(s.CertDir, keyFileName))
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("webhook server failed: %v", err)
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	"github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1/validation"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/types"
+)
+
+const validatingWebhookPath = "/validate-gcp-providerspec"
+
+// providerSpecValidator rejects Machines and MachineSets carrying an invalid GCPMachineProviderSpec.
+type providerSpecValidator struct{}
+
+var _ admission.Handler = &providerSpecValidator{}
+
+// Handle validates the providerSpec embedded in the admitted object. Updates leaving the providerSpec unchanged
+// and updates of objects being deleted are allowed, so objects created before stricter validation rules can
+// still be updated, e.g. to remove their finalizers.
+func (v *providerSpecValidator) Handle(ctx context.Context, req atypes.Request) atypes.Response {
+	obj, err := decodeObject(req)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+	update := req.AdmissionRequest.Operation == admissionv1beta1.Update
+	if update && isDeleting(obj) {
+		return admission.ValidationResponse(true, "")
+	}
+
+	value, fldPath := providerSpecField(obj)
+	if value == nil {
+		return admission.ErrorResponse(http.StatusUnprocessableEntity, field.Required(fldPath, "providerSpec value is required"))
+	}
+
+	spec, err := unmarshalProviderSpec(value)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	if update {
+		unchanged, err := providerSpecUnchanged(req, spec)
+		if err != nil {
+			return admission.ErrorResponse(http.StatusBadRequest, err)
+		}
+		if unchanged {
+			return admission.ValidationResponse(true, "")
+		}
+	}
+
+	errs := validation.ValidateGCPMachineProviderSpec(spec, fldPath)
+	errs = append(errs, validation.ValidateImageArchitectureHints(spec, fldPath)...)
+	if len(errs) > 0 {
+		return admission.ErrorResponse(http.StatusUnprocessableEntity, errs.ToAggregate())
+	}
+	return admission.ValidationResponse(true, "")
+}
+
+// providerSpecUnchanged returns whether the update request leaves the providerSpec of the object as it was.
+func providerSpecUnchanged(req atypes.Request, spec *v1beta1.GCPMachineProviderSpec) (bool, error) {
+	old, err := decodeOldObject(req)
+	if err != nil {
+		return false, err
+	}
+	oldValue, _ := providerSpecField(old)
+	if oldValue == nil {
+		return false, nil
+	}
+	oldSpec, err := unmarshalProviderSpec(oldValue)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(oldSpec, spec), nil
+}
+
+// NewValidatingWebhook returns the webhook validating GCP provider specs of Machines and MachineSets.
+func NewValidatingWebhook() *admission.Webhook {
+	return &admission.Webhook{
+		Name:     "validation.gcpprovider.machine.openshift.io",
+		Type:     types.WebhookTypeValidating,
+		Path:     validatingWebhookPath,
+		Rules:    providerSpecRules(),
+		Handlers: []admission.Handler{&providerSpecValidator{}},
+	}
+}
+
+func providerSpecRules() []admissionregistrationv1beta1.RuleWithOperations {
+	return []admissionregistrationv1beta1.RuleWithOperations{
+		{
+			Operations: []admissionregistrationv1beta1.OperationType{
+				admissionregistrationv1beta1.Create,
+				admissionregistrationv1beta1.Update,
+			},
+			Rule: admissionregistrationv1beta1.Rule{
+				APIGroups:   []string{"machine.openshift.io"},
+				APIVersions: []string{"v1beta1"},
+				Resources:   []string{"machines", "machinesets"},
+			},
+		},
+	}
+}
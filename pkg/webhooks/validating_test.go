@@ -0,0 +1,178 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	gcpv1beta1 "github.com/openshift/cluster-api-provider-gcp/pkg/apis/gcpprovider/v1beta1"
+	machinev1 "github.com/openshift/cluster-api/pkg/apis/machine/v1beta1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	atypes "sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+)
+
+func machineRequest(t *testing.T, spec *gcpv1beta1.GCPMachineProviderSpec) atypes.Request {
+	return atypes.Request{
+		AdmissionRequest: &admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "machine.openshift.io", Version: "v1beta1", Kind: "Machine"},
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: marshalMachine(t, machinev1.Machine{}, spec)},
+		},
+	}
+}
+
+func machineUpdateRequest(t *testing.T, machine machinev1.Machine, oldSpec, spec *gcpv1beta1.GCPMachineProviderSpec) atypes.Request {
+	return atypes.Request{
+		AdmissionRequest: &admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "machine.openshift.io", Version: "v1beta1", Kind: "Machine"},
+			Operation: admissionv1beta1.Update,
+			Object:    runtime.RawExtension{Raw: marshalMachine(t, machine, spec)},
+			OldObject: runtime.RawExtension{Raw: marshalMachine(t, machine, oldSpec)},
+		},
+	}
+}
+
+func marshalMachine(t *testing.T, machine machinev1.Machine, spec *gcpv1beta1.GCPMachineProviderSpec) []byte {
+	if spec != nil {
+		raw, err := json.Marshal(spec)
+		if err != nil {
+			t.Fatalf("failed to marshal provider spec: %v", err)
+		}
+		machine.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: raw}
+	}
+	raw, err := json.Marshal(machine)
+	if err != nil {
+		t.Fatalf("failed to marshal machine: %v", err)
+	}
+	return raw
+}
+
+func TestProviderSpecValidator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		spec    *gcpv1beta1.GCPMachineProviderSpec
+		allowed bool
+	}{
+		{
+			name: "valid spec",
+			spec: &gcpv1beta1.GCPMachineProviderSpec{
+				Zone:        "us-east1-b",
+				MachineType: "n1-standard-4",
+				Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			},
+			allowed: true,
+		},
+		{
+			name:    "no provider spec",
+			allowed: false,
+		},
+		{
+			name: "missing zone",
+			spec: &gcpv1beta1.GCPMachineProviderSpec{
+				MachineType: "n1-standard-4",
+				Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			},
+			allowed: false,
+		},
+		{
+			name: "machine type is a path",
+			spec: &gcpv1beta1.GCPMachineProviderSpec{
+				Zone:        "us-east1-b",
+				MachineType: "zones/us-east1-b/machineTypes/n1-standard-4",
+				Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+			},
+			allowed: false,
+		},
+		{
+			name: "image name of another architecture",
+			spec: &gcpv1beta1.GCPMachineProviderSpec{
+				Zone:        "us-east1-b",
+				MachineType: "t2a-standard-4",
+				Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "projects/rhcos-cloud/global/images/rhcos-412-86-202212081411-0-gcp-x86-64"}},
+			},
+			allowed: false,
+		},
+		{
+			name: "no boot disk",
+			spec: &gcpv1beta1.GCPMachineProviderSpec{
+				Zone:        "us-east1-b",
+				MachineType: "n1-standard-4",
+				Disks:       []*gcpv1beta1.GCPDisk{{Image: "rhcos"}},
+			},
+			allowed: false,
+		},
+	}
+
+	validator := &providerSpecValidator{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := validator.Handle(context.Background(), machineRequest(t, tc.spec))
+			if resp.Response.Allowed != tc.allowed {
+				t.Errorf("expected allowed to be %v, got %v: %+v", tc.allowed, resp.Response.Allowed, resp.Response.Result)
+			}
+		})
+	}
+}
+
+func TestProviderSpecValidatorUpdate(t *testing.T) {
+	valid := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:        "us-east1-b",
+		MachineType: "n1-standard-4",
+		Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+	}
+	// Predates the validation of machine types.
+	legacy := &gcpv1beta1.GCPMachineProviderSpec{
+		Zone:        "us-east1-b",
+		MachineType: "zones/us-east1-b/machineTypes/n1-standard-4",
+		Disks:       []*gcpv1beta1.GCPDisk{{Boot: true, Image: "rhcos"}},
+	}
+	legacyRelabelled := legacy.DeepCopy()
+	legacyRelabelled.Labels = map[string]string{"team": "a"}
+	now := metav1.Now()
+
+	testCases := []struct {
+		name    string
+		machine machinev1.Machine
+		oldSpec *gcpv1beta1.GCPMachineProviderSpec
+		spec    *gcpv1beta1.GCPMachineProviderSpec
+		allowed bool
+	}{
+		{
+			name:    "unchanged invalid spec",
+			oldSpec: legacy,
+			spec:    legacy,
+			allowed: true,
+		},
+		{
+			name:    "invalid spec changed",
+			oldSpec: legacy,
+			spec:    legacyRelabelled,
+			allowed: false,
+		},
+		{
+			name:    "valid spec made invalid",
+			oldSpec: valid,
+			spec:    legacy,
+			allowed: false,
+		},
+		{
+			name:    "invalid spec of deleted machine",
+			machine: machinev1.Machine{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}},
+			oldSpec: valid,
+			spec:    legacy,
+			allowed: true,
+		},
+	}
+
+	validator := &providerSpecValidator{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := validator.Handle(context.Background(), machineUpdateRequest(t, tc.machine, tc.oldSpec, tc.spec))
+			if resp.Response.Allowed != tc.allowed {
+				t.Errorf("expected allowed to be %v, got %v: %+v", tc.allowed, resp.Response.Allowed, resp.Response.Result)
+			}
+		})
+	}
+}